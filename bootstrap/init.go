@@ -8,6 +8,8 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/crontab"
 	"github.com/cloudreve/Cloudreve/v3/pkg/email"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/readcache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/fulltext"
 	"github.com/cloudreve/Cloudreve/v3/pkg/task"
 	"github.com/gin-gonic/gin"
 )
@@ -23,10 +25,12 @@ func Init(path string) {
 	cache.Init()
 	if conf.SystemConfig.Mode == "master" {
 		model.Init()
+		fulltext.Init()
 		task.Init()
 		aria2.Init(false)
 		email.Init()
 		crontab.Init()
+		readcache.Init()
 		InitStatic()
 	}
 	auth.Init()
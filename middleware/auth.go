@@ -327,6 +327,21 @@ func S3CallbackAuth() gin.HandlerFunc {
 	}
 }
 
+// AzblobCallbackAuth Azure Blob 回調驗證
+func AzblobCallbackAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 驗證key並尋找使用者
+		resp, _ := uploadCallbackCheck(c)
+		if resp.Code != 0 {
+			c.JSON(401, serializer.GeneralUploadCallbackFailed{Error: resp.Msg})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // IsAdmin 必須為管理員使用者群組
 func IsAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
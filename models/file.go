@@ -9,6 +9,9 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
+// accessTimeResolution 文件最後存取時間的記錄精度
+const accessTimeResolution = time.Hour
+
 // File 文件
 type File struct {
 	// 表欄位
@@ -20,6 +23,9 @@ type File struct {
 	PicInfo    string
 	FolderID   uint `gorm:"index:folder_id;unique_index:idx_only_one"`
 	PolicyID   uint
+	Hash       string     `gorm:"size:64;index:hash"` // 文件內容的 SHA-256 雜湊值
+	Broken     bool       // 完整性檢查發現物理文件遺失或損壞
+	AccessedAt *time.Time // 最後下載、預覽或取得外鏈的時間，用於判斷冷資料
 
 	// 關聯模型
 	Policy Policy `gorm:"PRELOAD:false,association_autoupdate:false"`
@@ -104,6 +110,32 @@ func GetFilesByKeywords(uid uint, keywords ...interface{}) ([]File, error) {
 	return files, result.Error
 }
 
+// SearchFiles 根據給定的查詢條件搜尋使用者的文件，pageSize 為 0 時不分頁。
+// 返回目前頁的文件及符合條件的文件總數
+func SearchFiles(uid uint, condition string, args []interface{}, order string, page, pageSize int) ([]File, int, error) {
+	var (
+		files []File
+		total int
+	)
+
+	dbChain := DB.Model(&File{}).Where("user_id = ?", uid).Where(condition, args...)
+
+	// 計算總數用於分頁
+	if err := dbChain.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if order != "" {
+		dbChain = dbChain.Order(order)
+	}
+	if pageSize > 0 {
+		dbChain = dbChain.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+
+	result := dbChain.Find(&files)
+	return files, total, result.Error
+}
+
 // GetChildFilesOfFolders 批次檢索目錄子文件
 func GetChildFilesOfFolders(folders *[]Folder) ([]File, error) {
 	// 將所有待刪除目錄ID抽離，以便檢索文件
@@ -126,14 +158,195 @@ func (file *File) GetPolicy() *Policy {
 	return &file.Policy
 }
 
+// GetFileByHash 在給定的儲存策略中尋找內容雜湊值與大小相符且未損壞的文件。
+// 結果可能屬於其他使用者，呼叫方僅憑雜湊值與大小即可複製其內容，無法證明持有文件
+func GetFileByHash(hash string, size uint64, policies []uint) (*File, error) {
+	var file File
+	result := DB.Where("hash = ? and size = ? and policy_id in (?) and broken = ?", hash, size, policies, false).
+		First(&file)
+	return &file, result.Error
+}
+
+// GetFilesByPolicy 按ID順序列出儲存策略下ID大於afterID的文件，包括回收站中的文件
+func GetFilesByPolicy(policyID, afterID uint, limit int) ([]File, error) {
+	var files []File
+	result := DB.Unscoped().
+		Where("policy_id = ? and id > ?", policyID, afterID).
+		Order("id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// policySources 返回儲存策略下文件及歷史版本引用的物理文件，包括回收站中的文件。
+// 軟連結的多條記錄指向同一物理文件，需按 source_name 去重後再統計
+func policySources(db *gorm.DB) (files, versions interface{}) {
+	files = db.Unscoped().Model(&File{}).Select("policy_id, source_name, size").QueryExpr()
+	versions = db.Unscoped().Model(&FileVersion{}).Select("policy_id, source_name, size").QueryExpr()
+	return files, versions
+}
+
+// GetPolicyUsage 計算儲存策略下物理文件的總大小，被多條記錄引用的物理文件只計算一次
+func GetPolicyUsage(policyID uint) (uint64, error) {
+	var usage uint64
+	files, versions := policySources(DB.Where("policy_id = ?", policyID))
+	if err := DB.Raw("SELECT coalesce(sum(size), 0) FROM "+
+		"(SELECT max(size) AS size FROM (? UNION ALL ?) AS sources GROUP BY source_name) AS objects",
+		files, versions).Row().Scan(&usage); err != nil {
+		return 0, err
+	}
+	return usage, nil
+}
+
+// GetPoliciesUsage 按儲存策略統計物理文件的總大小，被多條記錄引用的物理文件只計算一次
+func GetPoliciesUsage() (map[uint]uint64, error) {
+	files, versions := policySources(DB)
+	rows, err := DB.Raw("SELECT policy_id, sum(size) FROM "+
+		"(SELECT policy_id, max(size) AS size FROM (? UNION ALL ?) AS sources GROUP BY policy_id, source_name) AS objects "+
+		"GROUP BY policy_id", files, versions).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[uint]uint64)
+	for rows.Next() {
+		var (
+			policyID uint
+			size     uint64
+		)
+		if err := rows.Scan(&policyID, &size); err != nil {
+			return nil, err
+		}
+		usage[policyID] += size
+	}
+
+	return usage, nil
+}
+
+// GetReferencedSources 返回給定的物理文件中仍被給定儲存策略下的文件或歷史版本引用的部分
+func GetReferencedSources(policyIDs []uint, sources []string) ([]string, error) {
+	var referenced []string
+	if len(sources) == 0 {
+		return referenced, nil
+	}
+
+	if err := DB.Unscoped().Model(&File{}).
+		Where("policy_id in (?) and source_name in (?)", policyIDs, sources).
+		Pluck("source_name", &referenced).Error; err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	if err := DB.Unscoped().Model(&FileVersion{}).
+		Where("policy_id in (?) and source_name in (?)", policyIDs, sources).
+		Pluck("source_name", &versions).Error; err != nil {
+		return nil, err
+	}
+
+	return append(referenced, versions...), nil
+}
+
+// ownerScope 按所屬使用者或使用者群組篩選記錄，column 為記錄中表示使用者ID的欄位，
+// userID 和 groupID 均為0時不篩選
+func ownerScope(column string, userID, groupID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if userID > 0 {
+			db = db.Where(column+" = ?", userID)
+		}
+		if groupID > 0 {
+			db = db.Where(column+" in (?)", DB.Model(&User{}).Select("id").Where("group_id = ?", groupID).QueryExpr())
+		}
+		return db
+	}
+}
+
+// MigrateFilter 遷移文件時的篩選條件，零值欄位不參與篩選
+type MigrateFilter struct {
+	UserID     uint      // 只遷移此使用者的文件
+	GroupID    uint      // 只遷移此使用者群組的文件
+	IdleBefore time.Time // 只遷移最後存取時間早於此時間的文件，從未存取過的文件按建立時間計算
+}
+
+// fileScope 按篩選條件篩選文件
+func (filter MigrateFilter) fileScope(db *gorm.DB) *gorm.DB {
+	db = db.Scopes(ownerScope("user_id", filter.UserID, filter.GroupID))
+	if !filter.IdleBefore.IsZero() {
+		db = db.Where("coalesce(accessed_at, created_at) < ?", filter.IdleBefore)
+	}
+	return db
+}
+
+// versionScope 按篩選條件篩選歷史版本，歷史版本按建立時間判斷是否閒置
+func (filter MigrateFilter) versionScope(db *gorm.DB) *gorm.DB {
+	db = db.Scopes(versionOwnerScope(filter.UserID, filter.GroupID))
+	if !filter.IdleBefore.IsZero() {
+		db = db.Where("created_at < ?", filter.IdleBefore)
+	}
+	return db
+}
+
+// GetFilesToMigrate 按ID順序列出儲存策略下ID大於afterID、符合篩選條件的文件，包括回收站中的文件
+func GetFilesToMigrate(policyID uint, filter MigrateFilter, afterID uint, limit int) ([]File, error) {
+	var files []File
+	result := DB.Unscoped().Scopes(filter.fileScope).
+		Where("policy_id = ? and id > ?", policyID, afterID).
+		Order("id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// MigrateSource 將儲存策略下引用給定物理文件、符合篩選條件的文件和歷史版本
+// 一併切換到新的儲存策略和物理文件
+func MigrateSource(policyID uint, source string, filter MigrateFilter, dstPolicyID uint, dstSource string) error {
+	values := map[string]interface{}{
+		"policy_id":   dstPolicyID,
+		"source_name": dstSource,
+	}
+
+	tx := DB.Begin()
+	if err := tx.Model(&File{}).Unscoped().Scopes(filter.fileScope).
+		Where("policy_id = ? and source_name = ?", policyID, source).
+		UpdateColumns(values).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Model(&FileVersion{}).Unscoped().Scopes(filter.versionScope).
+		Where("policy_id = ? and source_name = ?", policyID, source).
+		UpdateColumns(values).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// GetExpiredFiles 列出儲存策略下建立時間早於 before 的文件，folderIDs 不為空時只列出這些目錄下的文件
+func GetExpiredFiles(policyID uint, folderIDs []uint, before time.Time, limit int) ([]File, error) {
+	var files []File
+	db := DB.Where("policy_id = ? and created_at < ?", policyID, before)
+	if len(folderIDs) > 0 {
+		db = db.Where("folder_id in (?)", folderIDs)
+	}
+	result := db.Order("id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// SetFilesBroken 批次設定文件的損壞標記
+func SetFilesBroken(ids []uint, broken bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return DB.Model(&File{}).Unscoped().Where("id in (?)", ids).
+		UpdateColumn("broken", broken).Error
+}
+
 // RemoveFilesWithSoftLinks 去除給定的文件列表中有軟連結的文件
 func RemoveFilesWithSoftLinks(files []File) ([]File, error) {
 	// 結果值
 	filteredFiles := make([]File, 0)
 
-	// 查詢軟連結的文件
+	// 查詢軟連結的文件，回收站中的文件同樣持有物理文件
 	var filesWithSoftLinks []File
-	tx := DB
+	tx := DB.Unscoped()
 	for _, value := range files {
 		tx = tx.Or("source_name = ? and policy_id = ? and id != ?", value.SourceName, value.PolicyID, value.ID)
 	}
@@ -142,6 +355,20 @@ func RemoveFilesWithSoftLinks(files []File) ([]File, error) {
 		return nil, result.Error
 	}
 
+	// 歷史版本同樣可能引用相同的物理文件
+	var versions []FileVersion
+	tx = DB.Unscoped()
+	for _, value := range files {
+		tx = tx.Or("source_name = ? and policy_id = ?", value.SourceName, value.PolicyID)
+	}
+	result = tx.Find(&versions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, value := range versions {
+		filesWithSoftLinks = append(filesWithSoftLinks, File{SourceName: value.SourceName, PolicyID: value.PolicyID})
+	}
+
 	// 過濾具有軟連接的文件
 	// TODO: 最佳化複雜度
 	if len(filesWithSoftLinks) == 0 {
@@ -194,6 +421,23 @@ func (file *File) UpdateSize(value uint64) error {
 	return DB.Model(&file).Set("gorm:association_autoupdate", false).Update("size", value).Error
 }
 
+// UpdateHash 更新文件內容雜湊值
+func (file *File) UpdateHash(value string) error {
+	return DB.Model(&file).Set("gorm:association_autoupdate", false).Update("hash", value).Error
+}
+
+// UpdateAccessTime 將文件的最後存取時間更新為目前時間，距上次記錄不足 accessTimeResolution 時略過，
+// 避免頻繁存取的文件反覆寫入資料庫
+func (file *File) UpdateAccessTime() error {
+	now := time.Now()
+	if file.AccessedAt != nil && now.Sub(*file.AccessedAt) < accessTimeResolution {
+		return nil
+	}
+
+	file.AccessedAt = &now
+	return DB.Model(&File{}).Where("id = ?", file.ID).UpdateColumn("accessed_at", now).Error
+}
+
 // UpdateSourceName 更新文件的來源檔案名
 func (file *File) UpdateSourceName(value string) error {
 	return DB.Model(&file).Set("gorm:association_autoupdate", false).Update("source_name", value).Error
@@ -225,6 +225,9 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 		mock.ExpectQuery("SELECT(.+)files(.+)").
 			WithArgs("1.txt", 23, 1, "2.txt", 24, 2).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("1.txt", 23, "2.txt", 24).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		file, err := RemoveFilesWithSoftLinks(files)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
@@ -248,6 +251,9 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 				sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).
 					AddRow(3, 24, "2.txt"),
 			)
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("1.txt", 23, "2.txt", 24).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		file, err := RemoveFilesWithSoftLinks(files)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
@@ -261,6 +267,9 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 				sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).
 					AddRow(3, 23, "1.txt"),
 			)
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("1.txt", 23, "2.txt", 24).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		file, err := RemoveFilesWithSoftLinks(files)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
@@ -275,11 +284,30 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 					AddRow(3, 24, "2.txt").
 					AddRow(4, 23, "1.txt"),
 			)
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("1.txt", 23, "2.txt", 24).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		file, err := RemoveFilesWithSoftLinks(files)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
 		asserts.Len(file, 0)
 	}
+	// 第一個被歷史版本引用
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs("1.txt", 23, 1, "2.txt", 24, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("1.txt", 23, "2.txt", 24).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).
+					AddRow(1, 23, "1.txt"),
+			)
+		file, err := RemoveFilesWithSoftLinks(files)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(files[1:], file)
+	}
 }
 
 func TestDeleteFileByIDs(t *testing.T) {
@@ -407,3 +435,321 @@ func TestGetFilesByKeywords(t *testing.T) {
 		asserts.Len(res, 1)
 	}
 }
+
+func TestGetFileByHash(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 找到
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)hash(.+)").
+			WithArgs("hash", 10, 1, 2, false).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}).AddRow(1, "1.txt"))
+		file, err := GetFileByHash("hash", 10, []uint{1, 2})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal("1.txt", file.SourceName)
+	}
+
+	// 未找到
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)hash(.+)").
+			WithArgs("hash", 10, 1, false).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := GetFileByHash("hash", 10, []uint{1})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestFile_UpdateHash(t *testing.T) {
+	asserts := assert.New(t)
+	file := File{Model: gorm.Model{ID: 1}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)files(.+)hash(.+)").
+		WithArgs("hash", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(file.UpdateHash("hash"))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetFilesByPolicy(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)files(.+)").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4))
+	files, err := GetFilesByPolicy(1, 2, 10)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(files, 2)
+}
+
+func TestGetFilesToMigrate(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 按使用者篩選
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)user_id = (.+)policy_id(.+)").
+			WithArgs(5, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4))
+		files, err := GetFilesToMigrate(1, MigrateFilter{UserID: 5}, 2, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(files, 2)
+	}
+
+	// 按使用者群組篩選
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)user_id in \\(SELECT id FROM (.+)users(.+)group_id(.+)policy_id(.+)").
+			WithArgs(3, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		files, err := GetFilesToMigrate(1, MigrateFilter{GroupID: 3}, 2, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(files, 0)
+	}
+
+	// 按最後存取時間篩選
+	{
+		idleBefore := time.Now().Add(-time.Hour)
+		mock.ExpectQuery("SELECT(.+)files(.+)coalesce\\(accessed_at, created_at\\) <(.+)policy_id(.+)").
+			WithArgs(idleBefore, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		files, err := GetFilesToMigrate(1, MigrateFilter{IdleBefore: idleBefore}, 2, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(files, 1)
+	}
+}
+
+func TestMigrateSource(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)policy_id(.+)source_name(.+)").
+			WithArgs(2, "new.txt", 5, 1, "old.txt").
+			WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectExec("UPDATE(.+)file_versions(.+)policy_id(.+)source_name(.+)").
+			WithArgs(2, "new.txt", 5, 1, "old.txt").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(MigrateSource(1, "old.txt", MigrateFilter{UserID: 5}, 2, "new.txt"))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 更新歷史版本失敗，整體回滾
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectExec("UPDATE(.+)file_versions(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(MigrateSource(1, "old.txt", MigrateFilter{}, 2, "new.txt"))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 只切換閒置的文件和歷史版本
+	{
+		idleBefore := time.Now().Add(-time.Hour)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)coalesce\\(accessed_at, created_at\\) <(.+)").
+			WithArgs(2, "new.txt", idleBefore, 1, "old.txt").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)file_versions(.+)created_at <(.+)").
+			WithArgs(2, "new.txt", idleBefore, 1, "old.txt").
+			WillReturnResult(sqlmock.NewResult(1, 0))
+		mock.ExpectCommit()
+		asserts.NoError(MigrateSource(1, "old.txt", MigrateFilter{IdleBefore: idleBefore}, 2, "new.txt"))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestGetExpiredFiles(t *testing.T) {
+	asserts := assert.New(t)
+	before := time.Now()
+
+	// 不限制目錄
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)policy_id = (.+)created_at <(.+)").
+			WithArgs(1, before).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		files, err := GetExpiredFiles(1, nil, before, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(files, 1)
+	}
+
+	// 限制目錄
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)folder_id in(.+)").
+			WithArgs(1, before, 4, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		files, err := GetExpiredFiles(1, []uint{4, 5}, before, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(files, 0)
+	}
+}
+
+func TestFile_UpdateAccessTime(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 從未存取過
+	{
+		file := File{Model: gorm.Model{ID: 1}}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)accessed_at(.+)").
+			WithArgs(sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(file.UpdateAccessTime())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotNil(file.AccessedAt)
+	}
+
+	// 距上次存取時間過短，不更新
+	{
+		accessedAt := time.Now().Add(-time.Minute)
+		file := File{Model: gorm.Model{ID: 1}, AccessedAt: &accessedAt}
+		asserts.NoError(file.UpdateAccessTime())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(accessedAt, *file.AccessedAt)
+	}
+
+	// 上次存取已久
+	{
+		accessedAt := time.Now().Add(-2 * time.Hour)
+		file := File{Model: gorm.Model{ID: 1}, AccessedAt: &accessedAt}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)accessed_at(.+)").
+			WithArgs(sqlmock.AnyArg(), 1).
+			WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(file.UpdateAccessTime())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestSearchFiles(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 分頁
+	{
+		mock.ExpectQuery("SELECT count(.+)files(.+)user_id(.+)size >= (.+)").
+			WithArgs(1, 10).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+		mock.ExpectQuery("SELECT(.+)files(.+)ORDER BY size DESC LIMIT 2 OFFSET 2").
+			WithArgs(1, 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4))
+		files, total, err := SearchFiles(1, "size >= ?", []interface{}{10}, "size DESC", 2, 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(5, total)
+		asserts.Len(files, 2)
+	}
+
+	// 計數失敗
+	{
+		mock.ExpectQuery("SELECT count(.+)").WillReturnError(errors.New("error"))
+		files, _, err := SearchFiles(1, "size >= ?", []interface{}{10}, "", 1, 0)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(files)
+	}
+}
+
+func TestGetPolicyUsage(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功，按物理文件去重
+	{
+		mock.ExpectQuery("SELECT coalesce(.+)files(.+)UNION ALL(.+)file_versions(.+)GROUP BY source_name(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(15))
+		usage, err := GetPolicyUsage(1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(15, usage)
+	}
+
+	// 查詢出錯
+	{
+		mock.ExpectQuery("SELECT coalesce(.+)").WillReturnError(errors.New("error"))
+		_, err := GetPolicyUsage(1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestGetPoliciesUsage(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功，按物理文件去重
+	{
+		mock.ExpectQuery("SELECT policy_id, sum\\(size\\)(.+)files(.+)UNION ALL(.+)file_versions(.+)GROUP BY policy_id, source_name(.+)GROUP BY policy_id").
+			WillReturnRows(sqlmock.NewRows([]string{"policy_id", "size"}).AddRow(1, 15).AddRow(2, 20))
+		usage, err := GetPoliciesUsage()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(map[uint]uint64{1: 15, 2: 20}, usage)
+	}
+
+	// 查詢出錯
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnError(errors.New("error"))
+		_, err := GetPoliciesUsage()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestGetReferencedSources(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 列表為空
+	{
+		res, err := GetReferencedSources([]uint{1}, []string{})
+		asserts.NoError(err)
+		asserts.Empty(res)
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, "a", "b", "c").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}).AddRow("a"))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(1, "a", "b", "c").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}).AddRow("b"))
+		res, err := GetReferencedSources([]uint{1}, []string{"a", "b", "c"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal([]string{"a", "b"}, res)
+	}
+
+	// 查詢失敗
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		res, err := GetReferencedSources([]uint{1}, []string{"a"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(res)
+	}
+}
+
+func TestSetFilesBroken(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 列表為空
+	asserts.NoError(SetFilesBroken([]uint{}, true))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)files(.+)broken(.+)").
+		WithArgs(true, 1, 2).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectCommit()
+	asserts.NoError(SetFilesBroken([]uint{1, 2}, true))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,202 @@
+package model
+
+import (
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// FileVersion 文件的歷史版本
+type FileVersion struct {
+	gorm.Model
+	FileID     uint   `gorm:"index:file_id"` // 所屬文件ID
+	SourceName string `gorm:"type:text"`     // 歷史版本的物理文件路徑
+	Size       uint64
+	PicInfo    string
+	PolicyID   uint
+	Hash       string `gorm:"size:64"` // 歷史版本內容的雜湊值
+}
+
+// Create 建立歷史版本記錄
+func (version *FileVersion) Create() (uint, error) {
+	if err := DB.Create(version).Error; err != nil {
+		util.Log().Warning("無法插入歷史版本記錄, %s", err)
+		return 0, err
+	}
+	return version.ID, nil
+}
+
+// AsFile 以歷史版本的內容構造一個文件物件，用於下載、預覽
+func (version *FileVersion) AsFile(file *File) File {
+	res := *file
+	res.SourceName = version.SourceName
+	res.Size = version.Size
+	res.PicInfo = version.PicInfo
+	res.PolicyID = version.PolicyID
+	res.Hash = version.Hash
+	res.Policy = Policy{}
+	res.UpdatedAt = version.CreatedAt
+	return res
+}
+
+// NewVersionFromFile 以文件目前的內容構造歷史版本
+func NewVersionFromFile(file *File) *FileVersion {
+	return &FileVersion{
+		FileID:     file.ID,
+		SourceName: file.SourceName,
+		Size:       file.Size,
+		PicInfo:    file.PicInfo,
+		PolicyID:   file.PolicyID,
+		Hash:       file.Hash,
+	}
+}
+
+// GetVersionsByFileID 列出文件的所有歷史版本，新版本在前
+func GetVersionsByFileID(fileID uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Where("file_id = ?", fileID).Order("id desc").Find(&versions)
+	return versions, result.Error
+}
+
+// GetVersionsByFileIDs 批次列出文件的歷史版本
+func GetVersionsByFileIDs(fileIDs []uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Where("file_id in (?)", fileIDs).Find(&versions)
+	return versions, result.Error
+}
+
+// GetVersionsByPolicy 列出儲存策略下的所有歷史版本
+func GetVersionsByPolicy(policyID uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Unscoped().Where("policy_id = ?", policyID).Find(&versions)
+	return versions, result.Error
+}
+
+// versionOwnerScope 按所屬文件的使用者或使用者群組篩選歷史版本
+func versionOwnerScope(userID, groupID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if userID == 0 && groupID == 0 {
+			return db
+		}
+		owned := DB.Unscoped().Model(&File{}).Select("id").Scopes(ownerScope("user_id", userID, groupID))
+		return db.Where("file_id in (?)", owned.QueryExpr())
+	}
+}
+
+// GetVersionsToMigrate 按ID順序列出儲存策略下ID大於afterID、符合篩選條件的歷史版本
+func GetVersionsToMigrate(policyID uint, filter MigrateFilter, afterID uint, limit int) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Unscoped().Scopes(filter.versionScope).
+		Where("policy_id = ? and id > ?", policyID, afterID).
+		Order("id asc").Limit(limit).Find(&versions)
+	return versions, result.Error
+}
+
+// GetVersionByID 根據ID尋找屬於指定文件的歷史版本
+func GetVersionByID(id, fileID uint) (*FileVersion, error) {
+	var version FileVersion
+	result := DB.Where("id = ? and file_id = ?", id, fileID).First(&version)
+	return &version, result.Error
+}
+
+// DeleteVersionByIDs 根據給定ID批次刪除歷史版本記錄
+func DeleteVersionByIDs(ids []uint) error {
+	return DB.Where("id in (?)", ids).Unscoped().Delete(&FileVersion{}).Error
+}
+
+// RemoveVersionsWithSoftLinks 去除給定的歷史版本中物理文件仍被其他文件或版本引用的部分
+func RemoveVersionsWithSoftLinks(versions []FileVersion) ([]FileVersion, error) {
+	if len(versions) == 0 {
+		return versions, nil
+	}
+
+	// 查詢引用相同物理文件的文件
+	var files []File
+	tx := DB.Unscoped()
+	for _, value := range versions {
+		tx = tx.Or("source_name = ? and policy_id = ?", value.SourceName, value.PolicyID)
+	}
+	if err := tx.Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	// 查詢引用相同物理文件的其他版本
+	var others []FileVersion
+	tx = DB.Unscoped()
+	for _, value := range versions {
+		tx = tx.Or("source_name = ? and policy_id = ? and id != ?", value.SourceName, value.PolicyID, value.ID)
+	}
+	if err := tx.Find(&others).Error; err != nil {
+		return nil, err
+	}
+
+	filtered := make([]FileVersion, 0, len(versions))
+	for _, version := range versions {
+		linked := false
+		for _, file := range files {
+			if file.PolicyID == version.PolicyID && file.SourceName == version.SourceName {
+				linked = true
+				break
+			}
+		}
+		for _, other := range others {
+			if linked {
+				break
+			}
+			if other.PolicyID == version.PolicyID && other.SourceName == version.SourceName {
+				linked = true
+			}
+		}
+		if !linked {
+			filtered = append(filtered, version)
+		}
+	}
+
+	return filtered, nil
+}
+
+// KeepVersion 在同一交易中建立保存文件原有內容的歷史版本 current，
+// 並將文件切換為新上傳的物理文件、大小及雜湊值
+func (file *File) KeepVersion(current *FileVersion, source string, size uint64, hash string) error {
+	return file.switchContent(current, map[string]interface{}{
+		"source_name": source,
+		"size":        size,
+		"hash":        hash,
+	}, 0)
+}
+
+// RestoreVersion 在同一交易中將文件目前的內容保存為歷史版本 current，
+// 將文件內容切換為給定歷史版本的物理文件，並刪除此歷史版本記錄
+func (file *File) RestoreVersion(current, version *FileVersion) error {
+	return file.switchContent(current, map[string]interface{}{
+		"source_name": version.SourceName,
+		"size":        version.Size,
+		"pic_info":    version.PicInfo,
+		"policy_id":   version.PolicyID,
+		"hash":        version.Hash,
+	}, version.ID)
+}
+
+// switchContent 建立歷史版本並更新文件內容，deleteID 不為0時一併刪除對應的歷史版本記錄
+func (file *File) switchContent(current *FileVersion, values map[string]interface{}, deleteID uint) error {
+	tx := DB.Begin()
+	if err := tx.Create(current).Error; err != nil {
+		tx.Rollback()
+		util.Log().Warning("無法插入歷史版本記錄, %s", err)
+		return err
+	}
+
+	if err := tx.Model(&file).Set("gorm:association_autoupdate", false).
+		Updates(values).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if deleteID != 0 {
+		if err := tx.Where("id = ?", deleteID).Unscoped().Delete(&FileVersion{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
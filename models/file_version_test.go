@@ -0,0 +1,249 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileVersion_Create(t *testing.T) {
+	asserts := assert.New(t)
+	version := FileVersion{FileID: 1, SourceName: "1.txt"}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		id, err := version.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(1, id)
+	}
+
+	// 失敗
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		id, err := version.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.EqualValues(0, id)
+	}
+}
+
+func TestFileVersion_AsFile(t *testing.T) {
+	asserts := assert.New(t)
+	now := time.Now()
+	file := File{
+		Model:      gorm.Model{ID: 1},
+		Name:       "1.txt",
+		SourceName: "new.txt",
+		Size:       10,
+		PolicyID:   1,
+		Policy:     Policy{Model: gorm.Model{ID: 1}},
+	}
+	version := FileVersion{
+		Model:      gorm.Model{CreatedAt: now},
+		SourceName: "old.txt",
+		Size:       5,
+		PolicyID:   2,
+	}
+
+	res := version.AsFile(&file)
+	asserts.EqualValues(1, res.ID)
+	asserts.Equal("1.txt", res.Name)
+	asserts.Equal("old.txt", res.SourceName)
+	asserts.EqualValues(5, res.Size)
+	asserts.EqualValues(2, res.PolicyID)
+	asserts.EqualValues(0, res.Policy.ID)
+	asserts.Equal(now, res.UpdatedAt)
+	asserts.Equal("new.txt", file.SourceName)
+
+	version2 := NewVersionFromFile(&file)
+	asserts.EqualValues(1, version2.FileID)
+	asserts.Equal("new.txt", version2.SourceName)
+	asserts.EqualValues(10, version2.Size)
+}
+
+func TestGetVersionsByFileID(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(1))
+	res, err := GetVersionsByFileID(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 2)
+}
+
+func TestGetVersionsByPolicy(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	res, err := GetVersionsByPolicy(3)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 1)
+}
+
+func TestGetVersionsToMigrate(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 不篩選
+	{
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)policy_id(.+)id >(.+)").
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		versions, err := GetVersionsToMigrate(1, MigrateFilter{}, 2, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(versions, 1)
+	}
+
+	// 按使用者群組篩選
+	{
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)file_id in \\(SELECT id FROM (.+)files(.+)user_id in \\(SELECT id FROM (.+)users(.+)group_id(.+)").
+			WithArgs(3, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4))
+		versions, err := GetVersionsToMigrate(1, MigrateFilter{GroupID: 3}, 2, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(versions, 2)
+	}
+}
+
+func TestGetVersionByID(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 找到
+	{
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		res, err := GetVersionByID(2, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(2, res.ID)
+	}
+
+	// 未找到
+	{
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := GetVersionByID(2, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestDeleteVersionByIDs(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	asserts.NoError(DeleteVersionByIDs([]uint{1, 2}))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestRemoveVersionsWithSoftLinks(t *testing.T) {
+	asserts := assert.New(t)
+	versions := []FileVersion{
+		{Model: gorm.Model{ID: 1}, SourceName: "1.txt", PolicyID: 1},
+		{Model: gorm.Model{ID: 2}, SourceName: "2.txt", PolicyID: 1},
+		{Model: gorm.Model{ID: 3}, SourceName: "3.txt", PolicyID: 1},
+	}
+
+	// 空列表
+	{
+		res, err := RemoveVersionsWithSoftLinks([]FileVersion{})
+		asserts.NoError(err)
+		asserts.Len(res, 0)
+	}
+
+	// 第一個被文件引用，第二個被其他版本引用
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "policy_id"}).AddRow(5, "1.txt", 1))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "policy_id"}).AddRow(6, "2.txt", 1))
+		res, err := RemoveVersionsWithSoftLinks(versions)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(versions[2:], res)
+	}
+
+	// 查詢出錯
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		res, err := RemoveVersionsWithSoftLinks(versions)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(res)
+	}
+}
+
+func TestFile_KeepVersion(t *testing.T) {
+	asserts := assert.New(t)
+	file := File{Model: gorm.Model{ID: 1}, SourceName: "old.txt"}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		version := NewVersionFromFile(&file)
+		asserts.NoError(file.KeepVersion(version, "new.txt", 5, "hash"))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(2, version.ID)
+		asserts.Equal("old.txt", version.SourceName)
+		asserts.Equal("new.txt", file.SourceName)
+	}
+
+	// 更新文件失敗，歷史版本一併回滾
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(file.KeepVersion(NewVersionFromFile(&file), "new2.txt", 5, "hash"))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFile_RestoreVersion(t *testing.T) {
+	asserts := assert.New(t)
+	file := File{Model: gorm.Model{ID: 1}, SourceName: "new.txt"}
+	version := &FileVersion{Model: gorm.Model{ID: 2}, SourceName: "old.txt", Size: 5, PolicyID: 1}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)file_versions(.+)").WithArgs(2).WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		asserts.NoError(file.RestoreVersion(NewVersionFromFile(&file), version))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("old.txt", file.SourceName)
+	}
+
+	// 刪除歷史版本失敗，整體回滾
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(4, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)file_versions(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(file.RestoreVersion(NewVersionFromFile(&file), version))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -79,11 +80,15 @@ func (folder *Folder) GetChildFolder() ([]Folder, error) {
 
 // GetRecursiveChildFolder 尋找所有遞迴子目錄，包括自身
 func GetRecursiveChildFolder(dirs []uint, uid uint, includeSelf bool) ([]Folder, error) {
+	return getRecursiveChildFolder(DB, dirs, uid, includeSelf)
+}
+
+func getRecursiveChildFolder(db *gorm.DB, dirs []uint, uid uint, includeSelf bool) ([]Folder, error) {
 	folders := make([]Folder, 0, len(dirs))
 	var err error
 
 	var parFolders []Folder
-	result := DB.Where("owner_id = ? and id in (?)", uid, dirs).Find(&parFolders)
+	result := db.Where("owner_id = ? and id in (?)", uid, dirs).Find(&parFolders)
 	if result.Error != nil {
 		return folders, err
 	}
@@ -103,7 +108,7 @@ func GetRecursiveChildFolder(dirs []uint, uid uint, includeSelf bool) ([]Folder,
 	// 遞迴查詢子目錄,最大遞迴65535次
 	for i := 0; i < 65535; i++ {
 
-		result = DB.Where("owner_id = ? and parent_id in (?)", uid, parentIDs).Find(&parFolders)
+		result = db.Where("owner_id = ? and parent_id in (?)", uid, parentIDs).Find(&parFolders)
 
 		// 查詢結束條件
 		if len(parFolders) == 0 {
@@ -125,6 +130,34 @@ func GetRecursiveChildFolder(dirs []uint, uid uint, includeSelf bool) ([]Folder,
 	return folders, err
 }
 
+// GetFoldersByPath 列出所有使用者中虛擬路徑為 p 的目錄
+func GetFoldersByPath(p string) ([]Folder, error) {
+	var folders []Folder
+	if err := DB.Where("parent_id is null").Find(&folders).Error; err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(path.Clean("/"+p), "/") {
+		if name == "" {
+			continue
+		}
+		if len(folders) == 0 {
+			break
+		}
+
+		parentIDs := make([]uint, 0, len(folders))
+		for _, folder := range folders {
+			parentIDs = append(parentIDs, folder.ID)
+		}
+		folders = nil
+		if err := DB.Where("parent_id in (?) and name = ?", parentIDs, name).Find(&folders).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return folders, nil
+}
+
 // DeleteFolderByIDs 根據給定ID批次刪除目錄記錄
 func DeleteFolderByIDs(ids []uint) error {
 	result := DB.Where("id in (?)", ids).Unscoped().Delete(&Folder{})
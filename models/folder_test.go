@@ -95,6 +95,58 @@ func TestFolder_GetChildFolder(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 }
 
+func TestGetFoldersByPath(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 根目錄
+	{
+		mock.ExpectQuery("SELECT(.+)parent_id is null(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1).AddRow(2, 2))
+		folders, err := GetFoldersByPath("/")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(folders, 2)
+	}
+
+	// 逐層尋找
+	{
+		mock.ExpectQuery("SELECT(.+)parent_id is null(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1).AddRow(2, 2))
+		mock.ExpectQuery("SELECT(.+)parent_id in(.+)name(.+)").
+			WithArgs(1, 2, "tmp").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(3, 1))
+		mock.ExpectQuery("SELECT(.+)parent_id in(.+)name(.+)").
+			WithArgs(3, "cache").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(4, 1))
+		folders, err := GetFoldersByPath("tmp/cache/")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(folders, 1)
+		asserts.EqualValues(4, folders[0].ID)
+	}
+
+	// 不存在
+	{
+		mock.ExpectQuery("SELECT(.+)parent_id is null(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)parent_id in(.+)name(.+)").
+			WithArgs(1, "tmp").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
+		folders, err := GetFoldersByPath("/tmp/cache")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(folders, 0)
+	}
+
+	// 資料庫錯誤
+	{
+		mock.ExpectQuery("SELECT(.+)parent_id is null(.+)").WillReturnError(errors.New("error"))
+		_, err := GetFoldersByPath("/tmp")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
 func TestGetRecursiveChildFolderSQLite(t *testing.T) {
 	conf.DatabaseConfig.Type = "sqlite3"
 	asserts := assert.New(t)
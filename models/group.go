@@ -31,6 +31,21 @@ type GroupOption struct {
 	ShareDownload   bool                   `json:"share_download,omitempty"`
 	Aria2           bool                   `json:"aria2,omitempty"`         // 離線下載
 	Aria2Options    map[string]interface{} `json:"aria2_options,omitempty"` // 離線下載使用者群組配置
+	RecycleDays     int                    `json:"recycle_days,omitempty"`  // 回收站保留天數，0 表示不使用回收站
+	MaxVersions     int                    `json:"max_versions,omitempty"`  // 保留的歷史版本數量，0 表示不保留
+	PolicyRules     []PolicyRule           `json:"policy_rules,omitempty"`  // 上傳時選擇儲存策略的規則
+}
+
+// PolicyRule 上傳時選擇儲存策略的規則，所有已設定的條件均滿足時，文件上傳到 PolicyID 對應的儲存策略。
+// 多條規則按順序匹配，均不滿足時使用使用者群組的第一個儲存策略
+type PolicyRule struct {
+	PolicyID   uint     `json:"policy_id"`            // 目標儲存策略，須為使用者群組可用的儲存策略
+	Extensions []string `json:"extensions,omitempty"` // 副檔名，不含點號，不區分大小寫
+	MIMETypes  []string `json:"mime_types,omitempty"` // MIME 類型，支援 video/* 形式的萬用字元
+	MinSize    uint64   `json:"min_size,omitempty"`   // 檔案大小下限
+	MaxSize    uint64   `json:"max_size,omitempty"`   // 檔案大小上限
+	Folder     string   `json:"folder,omitempty"`     // 上傳到此目錄或其子目錄
+	Capacity   uint64   `json:"capacity,omitempty"`   // 目標儲存策略的已用容量加上檔案大小不超過此值
 }
 
 // GetGroupByID 用ID獲取使用者群組
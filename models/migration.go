@@ -35,7 +35,7 @@ func migration() {
 		DB = DB.Set("gorm:table_options", "ENGINE=InnoDB")
 	}
 	DB.AutoMigrate(&User{}, &Setting{}, &Group{}, &Policy{}, &Folder{}, &File{}, &Share{},
-		&Task{}, &Download{}, &Tag{}, &Webdav{})
+		&Task{}, &Download{}, &Tag{}, &Webdav{}, &Recycle{}, &FileVersion{})
 
 	// 建立初始儲存策略
 	addDefaultPolicy()
@@ -106,8 +106,12 @@ func addDefaultSettings() {
 		{Name: "aria2_call_timeout", Value: `5`, Type: "timeout"},
 		{Name: "folder_props_timeout", Value: `300`, Type: "timeout"},
 		{Name: "onedrive_chunk_retries", Value: `1`, Type: "retry"},
+		{Name: "s3_part_retries", Value: `3`, Type: "retry"},
 		{Name: "onedrive_source_timeout", Value: `1800`, Type: "timeout"},
+		{Name: "multipart_upload_timeout", Value: `86400`, Type: "timeout"},
 		{Name: "reset_after_upload_failed", Value: `0`, Type: "upload"},
+		{Name: "upload_chunk_size", Value: `10485760`, Type: "upload"},
+		{Name: "instant_upload", Value: `0`, Type: "upload"},
 		{Name: "login_captcha", Value: `0`, Type: "login"},
 		{Name: "reg_captcha", Value: `0`, Type: "login"},
 		{Name: "email_active", Value: `0`, Type: "register"},
@@ -138,6 +142,10 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 		{Name: "aria2_interval", Value: `60`, Type: "aria2"},
 		{Name: "max_worker_num", Value: `10`, Type: "task"},
 		{Name: "max_parallel_transfer", Value: `4`, Type: "task"},
+		{Name: "decompress_max_size", Value: `10737418240`, Type: "task"},
+		{Name: "decompress_max_entries", Value: `10000`, Type: "task"},
+		{Name: "decompress_max_depth", Value: `32`, Type: "task"},
+		{Name: "decompress_max_ratio", Value: `100`, Type: "task"},
 		{Name: "secret_key", Value: util.RandStringRunes(256), Type: "auth"},
 		{Name: "temp_path", Value: "temp", Type: "path"},
 		{Name: "avatar_path", Value: "avatar", Type: "path"},
@@ -148,6 +156,16 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 		{Name: "home_view_method", Value: "icon", Type: "view"},
 		{Name: "share_view_method", Value: "list", Type: "view"},
 		{Name: "cron_garbage_collect", Value: "@hourly", Type: "cron"},
+		{Name: "cron_recycle_collect", Value: "@hourly", Type: "cron"},
+		{Name: "cron_scrub", Value: "@weekly", Type: "cron"},
+		{Name: "cron_policy_health", Value: "@every 5m", Type: "cron"},
+		{Name: "cron_lifecycle", Value: "@daily", Type: "cron"},
+		{Name: "cron_multipart_cleanup", Value: "@daily", Type: "cron"},
+		{Name: "scrub_verify_hash", Value: `0`, Type: "scrub"},
+		{Name: "scrub_mark_broken", Value: `1`, Type: "scrub"},
+		{Name: "fulltext_enabled", Value: `1`, Type: "fulltext"},
+		{Name: "fulltext_max_size", Value: `20971520`, Type: "fulltext"},
+		{Name: "fulltext_index_path", Value: `fulltext.idx`, Type: "fulltext"},
 		{Name: "authn_enabled", Value: "0", Type: "authn"},
 		{Name: "captcha_type", Value: "normal", Type: "captcha"},
 		{Name: "captcha_height", Value: "60", Type: "captcha"},
@@ -197,6 +215,8 @@ func addDefaultGroups() {
 				ArchiveTask:     true,
 				ShareDownload:   true,
 				Aria2:           true,
+				RecycleDays:     30,
+				MaxVersions:     10,
 			},
 		}
 		if err := DB.Create(&defaultAdminGroup).Error; err != nil {
@@ -216,6 +236,8 @@ func addDefaultGroups() {
 			WebDAVEnabled: true,
 			OptionsSerialized: GroupOption{
 				ShareDownload: true,
+				RecycleDays:   30,
+				MaxVersions:   10,
 			},
 		}
 		if err := DB.Create(&defaultAdminGroup).Error; err != nil {
@@ -57,6 +57,50 @@ type PolicyOption struct {
 	Region string `json:"region,omitempty"`
 	// ServerSideEndpoint 服務端請求使用的 Endpoint，為空時使用 Policy.Server 欄位
 	ServerSideEndpoint string `json:"server_side_endpoint,omitempty"`
+	// Capacity 總容量，按文件及歷史版本的大小統計已用容量，0 表示不限制
+	Capacity uint64 `json:"capacity,omitempty"`
+	// FallbackPolicy 容量已滿或健康檢查失敗時改用的備用儲存策略ID
+	FallbackPolicy uint `json:"fallback_policy,omitempty"`
+	// LifecycleRules 生命週期規則，由定時任務定期執行
+	LifecycleRules []LifecycleRule `json:"lifecycle_rules,omitempty"`
+	// Encrypt 是否加密儲存文件內容，啟用後上傳、下載均經由伺服器中轉
+	Encrypt bool `json:"encrypt,omitempty"`
+	// MirrorPolicies 鏡像儲存策略的成員儲存策略ID，讀取時按順序嘗試
+	MirrorPolicies []uint `json:"mirror_policies,omitempty"`
+	// ReadCache 是否將讀取的文件快取在本機磁碟，僅對遠端儲存策略有效
+	ReadCache bool `json:"read_cache,omitempty"`
+	// S3AbortUnscopedUploads 儲存路徑規則沒有固定前綴時，是否允許定時任務中止整個儲存桶中超時的分塊上傳
+	S3AbortUnscopedUploads bool `json:"s3_abort_unscoped_uploads,omitempty"`
+	// SFTPRoot SFTP 儲存策略在遠端伺服器上的根目錄
+	SFTPRoot string `json:"sftp_root,omitempty"`
+	// SFTPHostKey SFTP 伺服器的主機公鑰，authorized_keys 格式
+	SFTPHostKey string `json:"sftp_host_key,omitempty"`
+	// SFTPInsecureIgnoreHostKey 未設定主機公鑰時是否允許不驗證伺服器身份
+	SFTPInsecureIgnoreHostKey bool `json:"sftp_insecure_ignore_host_key,omitempty"`
+	// SFTPMaxConns SFTP 儲存策略的最大連線數
+	SFTPMaxConns int `json:"sftp_max_conns,omitempty"`
+	// WebDAVAuth WebDAV 儲存策略的認證方式，basic 或 digest，為空時使用 basic
+	WebDAVAuth string `json:"webdav_auth,omitempty"`
+	// S3PartSize S3 儲存策略服務端分塊上傳的分塊大小，單位為位元組，0 表示使用預設值
+	S3PartSize int64 `json:"s3_part_size,omitempty"`
+	// S3PartConcurrency S3 儲存策略服務端分塊上傳同時上傳的分塊數，0 表示使用預設值
+	S3PartConcurrency int `json:"s3_part_concurrency,omitempty"`
+}
+
+// 生命週期規則的動作
+const (
+	// LifecycleMove 將超過天數未存取的文件遷移到目標儲存策略
+	LifecycleMove = "move"
+	// LifecycleExpire 刪除上傳超過天數的文件
+	LifecycleExpire = "expire"
+)
+
+// LifecycleRule 儲存策略的生命週期規則
+type LifecycleRule struct {
+	Action       string `json:"action"`
+	Days         int    `json:"days"`
+	TargetPolicy uint   `json:"target_policy,omitempty"` // move 動作的目標儲存策略ID
+	Folder       string `json:"folder,omitempty"`        // expire 動作只處理各使用者此目錄及其子目錄下的文件，為空時不限制
 }
 
 var thumbSuffix = map[string][]string{
@@ -65,8 +109,11 @@ var thumbSuffix = map[string][]string{
 	"oss":      {".jpg", ".jpeg", ".png", ".gif", ".webp", ".tiff", ".bmp"},
 	"cos":      {".jpg", ".jpeg", ".png", ".gif", ".webp", ".tiff", ".bmp"},
 	"upyun":    {".svg", ".jpg", ".jpeg", ".png", ".gif", ".webp", ".tiff", ".bmp"},
-	"s3":       {},
-	"remote":   {},
+	"s3":       {".jpg", ".jpeg", ".png", ".gif"},
+	"remote":   {".jpg", ".jpeg", ".png", ".gif"},
+	"sftp":     {},
+	"webdav":   {},
+	"azblob":   {},
 	"onedrive": {"*"},
 }
 
@@ -143,6 +190,26 @@ func (policy *Policy) GeneratePath(uid uint, origin string) string {
 	return path.Clean(dirRule)
 }
 
+// DirNamePrefix 返回儲存路徑規則中不含變數的前綴目錄，規則以變數開頭時返回空字串
+func (policy *Policy) DirNamePrefix() string {
+	parts := strings.Split(policy.DirNameRule, "/")
+	for i, part := range parts {
+		if strings.Contains(part, "{") {
+			parts = parts[:i]
+			break
+		}
+	}
+
+	prefix := strings.Join(parts, "/")
+	if prefix == "" {
+		if strings.HasPrefix(policy.DirNameRule, "/") {
+			return "/"
+		}
+		return ""
+	}
+	return path.Clean(prefix)
+}
+
 // GenerateFileName 生成儲存檔案名
 func (policy *Policy) GenerateFileName(uid uint, origin string) string {
 	// 未開啟自動重新命名時，直接返回原始檔案名
@@ -197,11 +264,15 @@ func (policy Policy) getOriginNameRule(origin string) string {
 
 // IsDirectlyPreview 返回此策略下文件是否可以直接預覽（不需要重定向）
 func (policy *Policy) IsDirectlyPreview() bool {
-	return policy.Type == "local"
+	return policy.Type == "local" || policy.Type == "mirror" || policy.Type == "sftp" ||
+		policy.Type == "webdav" || policy.OptionsSerialized.Encrypt || policy.IsReadCacheEnabled()
 }
 
 // IsThumbExist 給定檔案名，返回此儲存策略下是否可能存在縮圖
 func (policy *Policy) IsThumbExist(name string) bool {
+	if policy.OptionsSerialized.Encrypt {
+		return false
+	}
 	if list, ok := thumbSuffix[policy.Type]; ok {
 		if len(list) == 1 && list[0] == "*" {
 			return true
@@ -213,7 +284,8 @@ func (policy *Policy) IsThumbExist(name string) bool {
 
 // IsTransitUpload 返回此策略上傳給定size文件時是否需要服務端中轉
 func (policy *Policy) IsTransitUpload(size uint64) bool {
-	if policy.Type == "local" {
+	if policy.Type == "local" || policy.Type == "mirror" || policy.Type == "sftp" ||
+		policy.Type == "webdav" || policy.OptionsSerialized.Encrypt {
 		return true
 	}
 	if policy.Type == "onedrive" && size < 4*1024*1024 {
@@ -227,9 +299,25 @@ func (policy *Policy) IsPathGenerateNeeded() bool {
 	return policy.Type != "remote"
 }
 
-// IsThumbGenerateNeeded 返回此策略是否需要在上傳後生成縮圖
+// IsThumbGenerateNeeded 返回此策略是否需要在上傳後由主機生成縮圖
 func (policy *Policy) IsThumbGenerateNeeded() bool {
-	return policy.Type == "local"
+	return (policy.Type == "local" || policy.Type == "sftp" || policy.Type == "webdav" || policy.Type == "s3") &&
+		!policy.OptionsSerialized.Encrypt
+}
+
+// ClientType 返回用戶端上傳時使用的儲存策略類型，加密、鏡像、SFTP 及 WebDAV
+// 儲存策略須經由伺服器中轉上傳，按本機儲存策略處理
+func (policy *Policy) ClientType() string {
+	if policy.OptionsSerialized.Encrypt || policy.Type == "mirror" || policy.Type == "sftp" ||
+		policy.Type == "webdav" {
+		return "local"
+	}
+	return policy.Type
+}
+
+// IsReadCacheEnabled 返回此策略是否啟用了本機讀取快取，本機及鏡像儲存策略不使用快取
+func (policy *Policy) IsReadCacheEnabled() bool {
+	return policy.OptionsSerialized.ReadCache && policy.Type != "local" && policy.Type != "mirror"
 }
 
 // CanStructureBeListed 返回儲存策略是否能被前台列物理目錄
@@ -239,6 +327,11 @@ func (policy *Policy) CanStructureBeListed() bool {
 
 // GetUploadURL 獲取文件上傳服務API地址
 func (policy *Policy) GetUploadURL() string {
+	// SFTP 儲存策略的伺服器地址不是URL，經由伺服器中轉上傳
+	if policy.Type == "sftp" {
+		return "/api/v3/file/upload"
+	}
+
 	server, err := url.Parse(policy.Server)
 	if err != nil {
 		return policy.Server
@@ -246,7 +339,7 @@ func (policy *Policy) GetUploadURL() string {
 
 	controller, _ := url.Parse("")
 	switch policy.Type {
-	case "local", "onedrive":
+	case "local", "onedrive", "mirror", "webdav":
 		return "/api/v3/file/upload"
 	case "remote":
 		controller, _ = url.Parse("/api/v3/slave/upload")
@@ -256,6 +349,11 @@ func (policy *Policy) GetUploadURL() string {
 		return policy.Server
 	case "upyun":
 		return "https://v0.api.upyun.com/" + policy.BucketName
+	case "azblob":
+		if policy.Server == "" {
+			return fmt.Sprintf("https://%s.blob.core.windows.net/%s", policy.AccessKey, policy.BucketName)
+		}
+		return strings.TrimSuffix(policy.Server, "/") + "/" + policy.BucketName
 	case "s3":
 		if policy.Server == "" {
 			return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", policy.BucketName,
@@ -270,6 +368,27 @@ func (policy *Policy) GetUploadURL() string {
 	return server.ResolveReference(controller).String()
 }
 
+// ClientUploadURL 獲取用戶端上傳文件使用的API地址，加密的儲存策略經由伺服器中轉上傳
+func (policy *Policy) ClientUploadURL() string {
+	if policy.OptionsSerialized.Encrypt {
+		return "/api/v3/file/upload"
+	}
+	return policy.GetUploadURL()
+}
+
+// IsFull 判斷儲存策略放入 size 大小的文件後是否超出總容量
+func (policy *Policy) IsFull(size uint64) (bool, error) {
+	if policy.OptionsSerialized.Capacity == 0 {
+		return false, nil
+	}
+
+	usage, err := GetPolicyUsage(policy.ID)
+	if err != nil {
+		return false, err
+	}
+	return usage+size > policy.OptionsSerialized.Capacity, nil
+}
+
 // SaveAndClearCache 更新並清理快取
 func (policy *Policy) SaveAndClearCache() error {
 	err := DB.Save(policy).Error
@@ -281,3 +400,23 @@ func (policy *Policy) SaveAndClearCache() error {
 func (policy *Policy) ClearCache() {
 	cache.Deletes([]string{strconv.FormatUint(uint64(policy.ID), 10)}, "policy_")
 }
+
+// GetSiblingPolicyIDs 返回與給定儲存策略共用同一儲存空間的儲存策略ID，包括其本身。
+// 本機策略共用同一檔案系統，其他策略按類型、伺服器及儲存桶判斷
+func GetSiblingPolicyIDs(policy *Policy) ([]uint, error) {
+	var ids []uint
+	query := DB.Model(&Policy{}).Where("type = ?", policy.Type)
+	if policy.Type != "local" {
+		query = query.Where("server = ? and bucket_name = ?", policy.Server, policy.BucketName)
+	}
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if id == policy.ID {
+			return ids, nil
+		}
+	}
+	return append(ids, policy.ID), nil
+}
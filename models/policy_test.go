@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
 	"strconv"
 	"testing"
 	"time"
@@ -93,6 +94,23 @@ func TestPolicy_GeneratePath(t *testing.T) {
 
 }
 
+func TestPolicy_DirNamePrefix(t *testing.T) {
+	asserts := assert.New(t)
+	testCases := map[string]string{
+		"uploads/{uid}/{path}":     "uploads",
+		"/data/uploads/{uid}":      "/data/uploads",
+		"/{uid}/{path}":            "/",
+		"{uid}/{path}":             "",
+		"uploads/{date}-bak/{uid}": "uploads",
+		"uploads/files/":           "uploads/files",
+	}
+
+	for rule, expect := range testCases {
+		policy := Policy{DirNameRule: rule}
+		asserts.Equal(expect, policy.DirNamePrefix(), rule)
+	}
+}
+
 func TestPolicy_GenerateFileName(t *testing.T) {
 	asserts := assert.New(t)
 	// 重新命名關閉
@@ -320,6 +338,21 @@ func TestPolicy_IsThumbExist(t *testing.T) {
 			true,
 			"onedrive",
 		},
+		{
+			"1.JPG",
+			true,
+			"s3",
+		},
+		{
+			"1.psd",
+			false,
+			"s3",
+		},
+		{
+			"1.png",
+			true,
+			"remote",
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -327,3 +360,157 @@ func TestPolicy_IsThumbExist(t *testing.T) {
 		asserts.Equal(testCase.expect, policy.IsThumbExist(testCase.name))
 	}
 }
+
+func TestPolicy_IsFull(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 不限制容量
+	{
+		policy := Policy{}
+		full, err := policy.IsFull(100)
+		asserts.NoError(err)
+		asserts.False(full)
+	}
+
+	// 超出容量
+	{
+		policy := Policy{Model: gorm.Model{ID: 1}}
+		policy.OptionsSerialized.Capacity = 100
+		mock.ExpectQuery("SELECT(.+)files(.+)file_versions(.+)").WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(90))
+		full, err := policy.IsFull(11)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.True(full)
+	}
+}
+
+func TestPolicy_Encrypt(t *testing.T) {
+	asserts := assert.New(t)
+	policy := Policy{Type: "s3", Server: "https://s3.example.com/bucket", BucketName: "bucket"}
+
+	// 未啟用加密
+	asserts.Equal("s3", policy.ClientType())
+	asserts.Equal(policy.GetUploadURL(), policy.ClientUploadURL())
+	asserts.False(policy.IsTransitUpload(100))
+	asserts.False(policy.IsDirectlyPreview())
+	asserts.True(policy.IsThumbGenerateNeeded())
+
+	// 啟用加密後經由伺服器中轉
+	policy.OptionsSerialized.Encrypt = true
+	asserts.Equal("local", policy.ClientType())
+	asserts.Equal("/api/v3/file/upload", policy.ClientUploadURL())
+	asserts.Equal("https://s3.example.com/bucket", policy.GetUploadURL())
+	asserts.True(policy.IsTransitUpload(100))
+	asserts.True(policy.IsDirectlyPreview())
+	asserts.False(policy.IsThumbExist("1.jpg"))
+
+	// 本機儲存策略不再生成縮圖
+	policy.Type = "local"
+	asserts.False(policy.IsThumbGenerateNeeded())
+}
+
+func TestPolicy_Mirror(t *testing.T) {
+	asserts := assert.New(t)
+	policy := Policy{Type: "mirror"}
+
+	// 經由伺服器中轉上傳和下載
+	asserts.Equal("local", policy.ClientType())
+	asserts.Equal("/api/v3/file/upload", policy.ClientUploadURL())
+	asserts.True(policy.IsTransitUpload(100))
+	asserts.True(policy.IsDirectlyPreview())
+	asserts.False(policy.IsThumbExist("1.jpg"))
+	asserts.False(policy.IsThumbGenerateNeeded())
+}
+
+func TestPolicy_IsReadCacheEnabled(t *testing.T) {
+	asserts := assert.New(t)
+	policy := Policy{Type: "onedrive"}
+	asserts.False(policy.IsReadCacheEnabled())
+	asserts.False(policy.IsDirectlyPreview())
+
+	// 啟用後經由伺服器預覽
+	policy.OptionsSerialized.ReadCache = true
+	asserts.True(policy.IsReadCacheEnabled())
+	asserts.True(policy.IsDirectlyPreview())
+
+	// 本機及鏡像儲存策略不使用快取
+	policy.Type = "local"
+	asserts.False(policy.IsReadCacheEnabled())
+	policy.Type = "mirror"
+	asserts.False(policy.IsReadCacheEnabled())
+}
+
+func TestPolicy_SFTP(t *testing.T) {
+	asserts := assert.New(t)
+	policy := Policy{Type: "sftp", Server: "192.168.1.1:22"}
+
+	// 經由伺服器中轉上傳、預覽，由主機生成縮圖
+	asserts.Equal("local", policy.ClientType())
+	asserts.Equal("/api/v3/file/upload", policy.GetUploadURL())
+	asserts.True(policy.IsTransitUpload(1024 * 1024 * 1024))
+	asserts.True(policy.IsDirectlyPreview())
+	asserts.True(policy.IsThumbGenerateNeeded())
+
+	// 加密後不生成縮圖
+	policy.OptionsSerialized.Encrypt = true
+	asserts.False(policy.IsThumbGenerateNeeded())
+}
+
+func TestPolicy_WebDAV(t *testing.T) {
+	asserts := assert.New(t)
+	policy := Policy{Type: "webdav", Server: "https://nas/dav"}
+
+	asserts.Equal("local", policy.ClientType())
+	asserts.Equal("/api/v3/file/upload", policy.GetUploadURL())
+	asserts.True(policy.IsTransitUpload(1024))
+	asserts.True(policy.IsDirectlyPreview())
+	asserts.True(policy.IsThumbGenerateNeeded())
+}
+
+func TestPolicy_Azblob(t *testing.T) {
+	asserts := assert.New(t)
+	policy := Policy{Type: "azblob", AccessKey: "account", BucketName: "container"}
+
+	asserts.Equal("azblob", policy.ClientType())
+	asserts.Equal("https://account.blob.core.windows.net/container", policy.GetUploadURL())
+	asserts.False(policy.IsTransitUpload(1024))
+	asserts.False(policy.IsThumbGenerateNeeded())
+
+	policy.Server = "http://127.0.0.1:10000/devstoreaccount1/"
+	asserts.Equal("http://127.0.0.1:10000/devstoreaccount1/container", policy.GetUploadURL())
+}
+
+func TestGetSiblingPolicyIDs(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 本機策略
+	{
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WithArgs("local").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+		ids, err := GetSiblingPolicyIDs(&Policy{Model: gorm.Model{ID: 2}, Type: "local"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal([]uint{1, 2}, ids)
+	}
+
+	// 共用儲存桶，結果中不含自身
+	{
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WithArgs("s3", "https://s3", "bucket").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		ids, err := GetSiblingPolicyIDs(&Policy{Model: gorm.Model{ID: 4}, Type: "s3", Server: "https://s3", BucketName: "bucket"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal([]uint{3, 4}, ids)
+	}
+
+	// 查詢失敗
+	{
+		mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnError(errors.New("error"))
+		ids, err := GetSiblingPolicyIDs(&Policy{Type: "local"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(ids)
+	}
+}
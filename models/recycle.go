@@ -0,0 +1,172 @@
+package model
+
+import (
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// Recycle 回收站項目
+type Recycle struct {
+	gorm.Model
+	UserID     uint   `gorm:"index:user_id"` // 所有者ID
+	Name       string // 物件原名稱
+	IsDir      bool   // 是否為目錄
+	ObjectID   uint   // 物件原始ID
+	FolderID   uint   // 存放物件的隱藏容器目錄ID
+	OriginPath string `gorm:"type:text"` // 物件原所在的目錄
+	Size       uint64 // 物件佔用的容量，目錄為所有子文件大小之和
+}
+
+// Create 建立回收站記錄
+func (recycle *Recycle) Create() (uint, error) {
+	if err := DB.Create(recycle).Error; err != nil {
+		util.Log().Warning("無法插入回收站記錄, %s", err)
+		return 0, err
+	}
+	return recycle.ID, nil
+}
+
+// Delete 刪除回收站記錄
+func (recycle *Recycle) Delete() error {
+	return DB.Unscoped().Delete(recycle).Error
+}
+
+// ExpireAt 根據保留天數計算過期時間
+func (recycle *Recycle) ExpireAt(days int) time.Time {
+	return recycle.CreatedAt.Add(time.Duration(days) * 24 * time.Hour)
+}
+
+// GetRecyclesByUID 列出使用者回收站中的所有項目
+func GetRecyclesByUID(uid uint) ([]Recycle, error) {
+	var recycles []Recycle
+	result := DB.Where("user_id = ?", uid).Order("created_at desc").Find(&recycles)
+	return recycles, result.Error
+}
+
+// GetRecyclesByIDs 根據ID和使用者ID尋找回收站項目
+func GetRecyclesByIDs(ids []uint, uid uint) ([]Recycle, error) {
+	var recycles []Recycle
+	result := DB.Where("id in (?) and user_id = ?", ids, uid).Find(&recycles)
+	return recycles, result.Error
+}
+
+// GetExpiredRecycles 尋找給定使用者群組下早於 before 放入回收站的項目
+func GetExpiredRecycles(groupID uint, before time.Time) ([]Recycle, error) {
+	var recycles []Recycle
+	users := DB.Model(&User{}).Select("id").Where("group_id = ?", groupID).SubQuery()
+	result := DB.Where("user_id in (?) and created_at < ?", users, before).Find(&recycles)
+	return recycles, result.Error
+}
+
+// Trash 在同一交易中建立存放物件的隱藏容器目錄，將 parent 目錄下的頂級物件移入容器，
+// 軟刪除物件及其子物件，並建立回收站記錄。容器目錄建立後即被軟刪除，不會出現在使用者的文件系統中
+func (recycle *Recycle) Trash(parentID uint, folderIDs, fileIDs []uint) error {
+	tx := DB.Begin()
+
+	container := &Folder{
+		Name:    "recycle",
+		OwnerID: recycle.UserID,
+	}
+	if err := tx.Create(container).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Delete(container).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	recycle.FolderID = container.ID
+
+	if err := moveRecycleObject(tx, recycle, parentID, container.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if len(fileIDs) > 0 {
+		if err := tx.Where("id in (?)", fileIDs).Delete(&File{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if len(folderIDs) > 0 {
+		if err := tx.Where("id in (?)", folderIDs).Delete(&Folder{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Create(recycle).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Restore 在同一交易中復原容器目錄中被軟刪除的物件，將頂級物件移回 dstID 目錄，
+// 並刪除容器目錄和回收站記錄
+func (recycle *Recycle) Restore(dstID uint, folderIDs, fileIDs []uint) error {
+	tx := DB.Begin()
+
+	if len(folderIDs) > 0 {
+		if err := tx.Unscoped().Model(&Folder{}).Where("id in (?)", folderIDs).
+			Update("deleted_at", nil).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if len(fileIDs) > 0 {
+		if err := tx.Unscoped().Model(&File{}).Where("id in (?)", fileIDs).
+			Update("deleted_at", nil).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := moveRecycleObject(tx, recycle, recycle.FolderID, dstID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Where("id = ?", recycle.FolderID).Delete(&Folder{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Delete(recycle).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// moveRecycleObject 將回收站項目對應的頂級物件從 fromID 目錄移動到 toID 目錄
+func moveRecycleObject(tx *gorm.DB, recycle *Recycle, fromID, toID uint) error {
+	if recycle.IsDir {
+		return tx.Model(Folder{}).
+			Where("id = ? and owner_id = ? and parent_id = ?", recycle.ObjectID, recycle.UserID, fromID).
+			Update("parent_id", toID).Error
+	}
+	return tx.Model(File{}).
+		Where("id = ? and user_id = ? and folder_id = ?", recycle.ObjectID, recycle.UserID, fromID).
+		Update("folder_id", toID).Error
+}
+
+// GetTrashedChildFolder 遞迴列出回收站容器目錄下的所有目錄，包括自身
+func GetTrashedChildFolder(folderID, uid uint) ([]Folder, error) {
+	return getRecursiveChildFolder(DB.Unscoped(), []uint{folderID}, uid, true)
+}
+
+// GetTrashedChildFilesOfFolders 列出已被軟刪除的目錄下的所有文件
+func GetTrashedChildFilesOfFolders(folders *[]Folder) ([]File, error) {
+	folderIDs := make([]uint, 0, len(*folders))
+	for _, value := range *folders {
+		folderIDs = append(folderIDs, value.ID)
+	}
+
+	var files []File
+	result := DB.Unscoped().Where("folder_id in (?)", folderIDs).Find(&files)
+	return files, result.Error
+}
@@ -0,0 +1,186 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecycle_Create(t *testing.T) {
+	asserts := assert.New(t)
+	recycle := Recycle{Name: "1.txt"}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		id, err := recycle.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(1, id)
+	}
+
+	// 失敗
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		id, err := recycle.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.EqualValues(0, id)
+	}
+}
+
+func TestRecycle_Delete(t *testing.T) {
+	asserts := assert.New(t)
+	recycle := Recycle{Model: gorm.Model{ID: 1}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)recycles(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(recycle.Delete())
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestRecycle_ExpireAt(t *testing.T) {
+	asserts := assert.New(t)
+	now := time.Now()
+	recycle := Recycle{Model: gorm.Model{CreatedAt: now}}
+	asserts.Equal(now.Add(48*time.Hour), recycle.ExpireAt(2))
+}
+
+func TestGetRecyclesByUID(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)recycles(.+)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	res, err := GetRecyclesByUID(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 2)
+}
+
+func TestGetRecyclesByIDs(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)recycles(.+)").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	res, err := GetRecyclesByIDs([]uint{1, 2}, 3)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 1)
+}
+
+func TestGetExpiredRecycles(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)recycles(.+)users(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, 2))
+	res, err := GetExpiredRecycles(1, time.Now())
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 1)
+	asserts.EqualValues(2, res[0].UserID)
+}
+
+func TestRecycle_Trash(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		record := &Recycle{UserID: 1, ObjectID: 2, IsDir: true}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)deleted_at(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)parent_id(.+)").
+			WithArgs(5, sqlmock.AnyArg(), 2, 1, 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)deleted_at(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("UPDATE(.+)folders(.+)deleted_at(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("INSERT(.+)recycles(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		err := record.Trash(3, []uint{2, 4}, []uint{6, 7})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(5, record.FolderID)
+		asserts.EqualValues(1, record.ID)
+	}
+
+	// 建立回收站記錄失敗，整體回滾
+	{
+		record := &Recycle{UserID: 1, ObjectID: 6}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)deleted_at(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)folder_id(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)deleted_at(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT(.+)recycles(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		err := record.Trash(3, nil, []uint{6})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestRecycle_Restore(t *testing.T) {
+	asserts := assert.New(t)
+	record := &Recycle{UserID: 1, ObjectID: 6, FolderID: 5}
+	record.ID = 1
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)deleted_at(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)folder_id(.+)").
+			WithArgs(3, sqlmock.AnyArg(), 6, 1, 5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE(.+)folders(.+)").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE(.+)recycles(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		err := record.Restore(3, nil, []uint{6})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+	}
+
+	// 移回目的目錄失敗，整體回滾
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)deleted_at(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)folder_id(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		err := record.Restore(3, nil, []uint{6})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestGetTrashedChildFolder(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)folders(.+)").
+		WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery("SELECT(.+)folders(.+)").
+		WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+	mock.ExpectQuery("SELECT(.+)folders(.+)").
+		WithArgs(1, 6).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	folders, err := GetTrashedChildFolder(5, 1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(folders, 2)
+
+	mock.ExpectQuery("SELECT(.+)files(.+)").
+		WithArgs(5, 6).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	files, err := GetTrashedChildFilesOfFolders(&folders)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(files, 1)
+}
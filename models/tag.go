@@ -13,6 +13,7 @@ type Tag struct {
 	Color      string // 圖示顏色
 	Type       int    // 標籤類型（文件分類/目錄直達）
 	Expression string `gorm:"type:text"` // 搜尋表表達式/直達路徑
+	Query      bool   // 搜尋表達式是否為查詢語法
 	UserID     uint   // 建立者ID
 }
 
@@ -51,3 +52,10 @@ func GetTagsByID(id, uid uint) (*Tag, error) {
 	result := DB.Where("user_id = ? and id = ?", uid, id).First(&tag)
 	return &tag, result.Error
 }
+
+// GetFileTagByName 根據名稱尋找使用者的文件分類標籤
+func GetFileTagByName(name string, uid uint) (*Tag, error) {
+	var tag Tag
+	result := DB.Where("user_id = ? and type = ? and name = ?", uid, FileTagType, name).First(&tag)
+	return &tag, result.Error
+}
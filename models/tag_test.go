@@ -61,3 +61,15 @@ func TestGetTagsByID(t *testing.T) {
 	asserts.NoError(err)
 	asserts.EqualValues(1, res.ID)
 }
+
+func TestGetFileTagByName(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)tags(.+)").
+		WithArgs(1, FileTagType, "work").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "query"}).AddRow(2, true))
+	res, err := GetFileTagByName("work", 1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.EqualValues(2, res.ID)
+	asserts.True(res.Query)
+}
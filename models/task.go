@@ -40,6 +40,11 @@ func (task *Task) SetError(err string) error {
 	return DB.Model(task).Select("error").Updates(map[string]interface{}{"error": err}).Error
 }
 
+// SetProps 更新任務屬性
+func (task *Task) SetProps(props string) error {
+	return DB.Model(task).Select("props").Updates(map[string]interface{}{"props": props}).Error
+}
+
 // GetTasksByStatus 根據狀態檢索任務
 func GetTasksByStatus(status ...int) []Task {
 	var tasks []Task
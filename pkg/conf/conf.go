@@ -84,8 +84,24 @@ type cors struct {
 	ExposeHeaders    []string
 }
 
+// encryption 靜態加密配置
+type encryption struct {
+	MasterKey     string // Base64 編碼的 32 位元組主金鑰
+	MasterKeyFile string // 存放 Base64 編碼主金鑰的文件路徑，MasterKey 為空時使用
+}
+
+// readCache 遠端儲存策略的本機讀取快取配置
+type readCache struct {
+	Path          string // 快取目錄
+	MaxSize       uint64 // 快取總大小上限，為 0 時停用
+	MaxObjectSize uint64 // 可被快取的單個文件大小上限
+}
+
 var cfg *ini.File
 
+// ConfigPath 目前使用的配置檔案路徑
+var ConfigPath string
+
 const defaultConf = `[System]
 Mode = master
 Listen = :5212
@@ -121,6 +137,7 @@ func Init(path string) {
 	if err != nil {
 		util.Log().Panic("無法解析配置檔案 '%s': %s", path, err)
 	}
+	ConfigPath = path
 
 	sections := map[string]interface{}{
 		"Database":   DatabaseConfig,
@@ -132,6 +149,8 @@ func Init(path string) {
 		"Thumbnail":  ThumbConfig,
 		"CORS":       CORSConfig,
 		"Slave":      SlaveConfig,
+		"Encryption": EncryptionConfig,
+		"ReadCache":  ReadCacheConfig,
 	}
 	for sectionName, sectionStruct := range sections {
 		err = mapSection(sectionName, sectionStruct)
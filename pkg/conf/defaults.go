@@ -62,6 +62,16 @@ var SlaveConfig = &slave{
 	SignatureTTL:    60,
 }
 
+// EncryptionConfig 靜態加密配置
+var EncryptionConfig = &encryption{}
+
+// ReadCacheConfig 讀取快取配置
+var ReadCacheConfig = &readCache{
+	Path:          "cache",
+	MaxSize:       1 << 30,
+	MaxObjectSize: 64 << 20,
+}
+
 var SSLConfig = &ssl{
 	Listen:   ":443",
 	CertPath: "",
@@ -4,7 +4,7 @@ package conf
 var BackendVersion = "3.3.2"
 
 // RequiredDBVersion 與目前版本匹配的資料庫版本
-var RequiredDBVersion = "3.3.2"
+var RequiredDBVersion = "3.3.3"
 
 // RequiredStaticVersion 與目前版本匹配的靜態資源版本
 var RequiredStaticVersion = "3.3.2"
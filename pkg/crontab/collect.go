@@ -8,6 +8,7 @@ import (
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 )
 
@@ -15,6 +16,9 @@ func garbageCollect() {
 	// 清理打包下載產生的暫存檔
 	collectArchiveFile()
 
+	// 清理過期分片上傳工作階段的暫存分片
+	filesystem.CollectChunkSessions()
+
 	// 清理過期的內建記憶體快取
 	if store, ok := cache.Store.(*cache.MemoStore); ok {
 		collectCache(store)
@@ -0,0 +1,26 @@
+package crontab
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// policyHealthCheck 檢查設定了備用儲存策略的儲存策略是否可用
+func policyHealthCheck() {
+	var policies []model.Policy
+	if err := model.DB.Find(&policies).Error; err != nil {
+		util.Log().Warning("[定時任務] 無法列取儲存策略, %s", err)
+		return
+	}
+
+	for i := range policies {
+		if policies[i].OptionsSerialized.FallbackPolicy == 0 {
+			continue
+		}
+
+		if err := filesystem.CheckPolicyHealth(&policies[i]); err != nil {
+			util.Log().Warning("[定時任務] 儲存策略 [%s] 健康檢查失敗, %s", policies[i].Name, err)
+		}
+	}
+}
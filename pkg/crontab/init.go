@@ -21,13 +21,23 @@ func Reload() {
 func Init() {
 	util.Log().Info("初始化定時任務...")
 	// 讀取cron日程設定
-	options := model.GetSettingByNames("cron_garbage_collect")
+	options := model.GetSettingByNames("cron_garbage_collect", "cron_recycle_collect", "cron_scrub", "cron_policy_health", "cron_lifecycle", "cron_multipart_cleanup")
 	Cron := cron.New()
 	for k, v := range options {
 		var handler func()
 		switch k {
 		case "cron_garbage_collect":
 			handler = garbageCollect
+		case "cron_recycle_collect":
+			handler = recycleCollect
+		case "cron_scrub":
+			handler = scrub
+		case "cron_policy_health":
+			handler = policyHealthCheck
+		case "cron_lifecycle":
+			handler = lifecycle
+		case "cron_multipart_cleanup":
+			handler = multipartCleanup
 		default:
 			util.Log().Warning("未知定時任務類型 [%s]，跳過", k)
 			continue
@@ -0,0 +1,143 @@
+package crontab
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// lifecycleExpireLimit 每條過期規則每次最多刪除的文件數量，其餘文件在下次執行時處理
+const lifecycleExpireLimit = 1000
+
+// lifecycle 執行所有儲存策略的生命週期規則
+func lifecycle() {
+	var policies []model.Policy
+	if err := model.DB.Find(&policies).Error; err != nil {
+		util.Log().Warning("[定時任務] 無法列取儲存策略, %s", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range policies {
+		for _, rule := range policies[i].OptionsSerialized.LifecycleRules {
+			if rule.Days <= 0 {
+				continue
+			}
+
+			before := now.AddDate(0, 0, -rule.Days)
+			switch rule.Action {
+			case model.LifecycleMove:
+				tierPolicyFiles(&policies[i], rule.TargetPolicy, before)
+			case model.LifecycleExpire:
+				expirePolicyFiles(&policies[i], rule.Folder, before)
+			default:
+				util.Log().Warning("[定時任務] 儲存策略 [%s] 的生命週期規則動作 [%s] 無效，跳過", policies[i].Name, rule.Action)
+			}
+		}
+	}
+
+	util.Log().Info("定時任務 [cron_lifecycle] 執行完畢")
+}
+
+// tierPolicyFiles 提交遷移任務，將儲存策略下 idleBefore 之後未被存取的文件遷移到目標儲存策略。
+// 相同儲存策略間的遷移任務尚未完成時不重複提交
+func tierPolicyFiles(policy *model.Policy, target uint, idleBefore time.Time) {
+	if target == 0 || target == policy.ID {
+		util.Log().Warning("[定時任務] 儲存策略 [%s] 的生命週期規則未設定有效的目標儲存策略，跳過", policy.Name)
+		return
+	}
+
+	for _, pending := range model.GetTasksByStatus(task.Queued, task.Processing) {
+		if pending.Type != task.MigrateTaskType {
+			continue
+		}
+
+		var props task.MigrateProps
+		if err := json.Unmarshal([]byte(pending.Props), &props); err == nil &&
+			props.SrcPolicy == policy.ID && props.DstPolicy == target {
+			util.Log().Info("[定時任務] 儲存策略 [%s] 的遷移任務 [%d] 尚未完成，跳過", policy.Name, pending.ID)
+			return
+		}
+	}
+
+	job, err := task.NewMigrateTask(0, policy.ID, target, model.MigrateFilter{IdleBefore: idleBefore}, true)
+	if err != nil {
+		util.Log().Warning("[定時任務] 無法建立儲存策略 [%s] 的遷移任務, %s", policy.Name, err)
+		return
+	}
+	task.TaskPoll.Submit(job)
+}
+
+// expirePolicyFiles 刪除儲存策略下在 before 之前上傳的文件，folder 不為空時
+// 只處理各使用者此目錄及其子目錄下的文件
+func expirePolicyFiles(policy *model.Policy, folder string, before time.Time) {
+	if folder = path.Clean("/" + folder); folder == "/" {
+		expireFiles(policy, nil, before)
+		return
+	}
+
+	roots, err := model.GetFoldersByPath(folder)
+	if err != nil {
+		util.Log().Warning("[定時任務] 無法列取目錄 [%s], %s", folder, err)
+		return
+	}
+
+	for _, root := range roots {
+		folders, err := model.GetRecursiveChildFolder([]uint{root.ID}, root.OwnerID, true)
+		if err != nil {
+			util.Log().Warning("[定時任務] 無法列取使用者 [%d] 的目錄 [%s], %s", root.OwnerID, folder, err)
+			continue
+		}
+
+		folderIDs := make([]uint, 0, len(folders))
+		for _, child := range folders {
+			folderIDs = append(folderIDs, child.ID)
+		}
+		expireFiles(policy, folderIDs, before)
+	}
+}
+
+// expireFiles 按使用者分組刪除過期的文件，使用者群組啟用回收站時移入回收站
+func expireFiles(policy *model.Policy, folderIDs []uint, before time.Time) {
+	files, err := model.GetExpiredFiles(policy.ID, folderIDs, before, lifecycleExpireLimit)
+	if err != nil {
+		util.Log().Warning("[定時任務] 無法列取儲存策略 [%s] 的過期文件, %s", policy.Name, err)
+		return
+	}
+
+	userFiles := make(map[uint][]uint)
+	for _, file := range files {
+		userFiles[file.UserID] = append(userFiles[file.UserID], file.ID)
+	}
+
+	for uid, ids := range userFiles {
+		removeUserFiles(uid, ids)
+	}
+}
+
+// removeUserFiles 刪除使用者的文件
+func removeUserFiles(uid uint, ids []uint) {
+	user, err := model.GetUserByID(uid)
+	if err != nil {
+		util.Log().Warning("[定時任務] 找不到過期文件所屬使用者 [%d], %s", uid, err)
+		return
+	}
+
+	fs, err := filesystem.NewFileSystem(&user)
+	if err != nil {
+		util.Log().Warning("[定時任務] 無法建立使用者 [%d] 的文件系統, %s", uid, err)
+		return
+	}
+	defer fs.Recycle()
+
+	util.Log().Debug("刪除使用者 [%d] 的 %d 個過期文件", uid, len(ids))
+	if err := fs.Remove(context.Background(), nil, ids); err != nil {
+		util.Log().Warning("[定時任務] 無法刪除使用者 [%d] 的過期文件, %s", uid, err)
+	}
+}
@@ -0,0 +1,33 @@
+package crontab
+
+import (
+	"context"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/s3"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// multipartCleanup 中止 S3 儲存策略中超時未完成的分塊上傳
+func multipartCleanup() {
+	var policies []model.Policy
+	if err := model.DB.Where("type = ?", "s3").Find(&policies).Error; err != nil {
+		util.Log().Warning("[定時任務] 無法列取儲存策略, %s", err)
+		return
+	}
+
+	before := time.Now().Add(-time.Duration(model.GetIntSetting("multipart_upload_timeout", 86400)) * time.Second)
+	for i := range policies {
+		handler := s3.Driver{Policy: &policies[i]}
+		aborted, err := handler.AbortStaleUploads(context.Background(), before)
+		if err != nil {
+			util.Log().Warning("[定時任務] 無法清理儲存策略 [%s] 的分塊上傳, %s", policies[i].Name, err)
+		}
+		if aborted > 0 {
+			util.Log().Info("[定時任務] 已中止儲存策略 [%s] 中 %d 個未完成的分塊上傳", policies[i].Name, aborted)
+		}
+	}
+
+	util.Log().Info("定時任務 [cron_multipart_cleanup] 執行完畢")
+}
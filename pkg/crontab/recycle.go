@@ -0,0 +1,66 @@
+package crontab
+
+import (
+	"context"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+func recycleCollect() {
+	var groups []model.Group
+	if err := model.DB.Find(&groups).Error; err != nil {
+		util.Log().Warning("[定時任務] 無法列取使用者群組, %s", err)
+		return
+	}
+
+	for _, group := range groups {
+		// 使用者群組停用回收站後，先前移入回收站的項目同樣應被清理
+		before := time.Now()
+		if days := group.OptionsSerialized.RecycleDays; days > 0 {
+			before = before.Add(-time.Duration(days) * 24 * time.Hour)
+		}
+
+		// 列出超過保留期限的回收站項目
+		expired, err := model.GetExpiredRecycles(group.ID, before)
+		if err != nil {
+			util.Log().Warning("[定時任務] 無法列取過期回收站項目, %s", err)
+			continue
+		}
+
+		// 按使用者分組
+		userRecycles := make(map[uint][]model.Recycle)
+		for _, recycle := range expired {
+			userRecycles[recycle.UserID] = append(userRecycles[recycle.UserID], recycle)
+		}
+
+		for uid, recycles := range userRecycles {
+			purgeUserRecycles(uid, recycles)
+		}
+	}
+
+	util.Log().Info("定時任務 [cron_recycle_collect] 執行完畢")
+}
+
+// purgeUserRecycles 徹底刪除使用者的回收站項目
+func purgeUserRecycles(uid uint, recycles []model.Recycle) {
+	user, err := model.GetUserByID(uid)
+	if err != nil {
+		util.Log().Warning("[定時任務] 找不到回收站項目所屬使用者 [%d], %s", uid, err)
+		return
+	}
+
+	fs, err := filesystem.NewFileSystem(&user)
+	if err != nil {
+		util.Log().Warning("[定時任務] 無法建立使用者 [%d] 的文件系統, %s", uid, err)
+		return
+	}
+	defer fs.Recycle()
+
+	util.Log().Debug("清理使用者 [%d] 的 %d 個過期回收站項目", uid, len(recycles))
+	if err := fs.DeleteRecycles(context.Background(), recycles); err != nil {
+		util.Log().Warning("[定時任務] 無法清理使用者 [%d] 的回收站, %s", uid, err)
+	}
+}
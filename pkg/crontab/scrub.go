@@ -0,0 +1,19 @@
+package crontab
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+func scrub() {
+	options := model.GetSettingByNames("scrub_verify_hash", "scrub_mark_broken")
+	job, err := task.NewScrubTask(0, nil, options["scrub_verify_hash"] == "1", options["scrub_mark_broken"] == "1")
+	if err != nil {
+		util.Log().Warning("[定時任務] 無法建立完整性檢查任務, %s", err)
+		return
+	}
+	task.TaskPoll.Submit(job)
+
+	util.Log().Info("定時任務 [cron_scrub] 已提交完整性檢查任務")
+}
@@ -6,11 +6,9 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
@@ -18,8 +16,6 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/text/encoding/simplifiedchinese"
-	"golang.org/x/text/transform"
 )
 
 /* ===============
@@ -27,18 +23,53 @@ import (
    ===============
 */
 
-// Compress 建立給定目錄和文件的壓縮文件
-func (fs *FileSystem) Compress(ctx context.Context, folderIDs, fileIDs []uint, isArchive bool) (string, error) {
+// Compress 建立給定目錄和文件的壓縮文件，format 為壓縮文件格式
+func (fs *FileSystem) Compress(ctx context.Context, folderIDs, fileIDs []uint, format string, isArchive bool) (string, error) {
+	// 列出所有待壓縮文件
+	files, err := fs.ListArchiveFiles(ctx, folderIDs, fileIDs)
+	if err != nil {
+		return "", err
+	}
+
+	// 建立臨時壓縮文件
+	saveFolder := "archive"
+	if !isArchive {
+		saveFolder = "compress"
+	}
+	zipFilePath := filepath.Join(
+		util.RelativePath(model.GetSettingByName("temp_path")),
+		saveFolder,
+		fmt.Sprintf("archive_%d.%s", time.Now().UnixNano(), format),
+	)
+	zipFile, err := util.CreatNestedFile(zipFilePath)
+	if err != nil {
+		util.Log().Warning("%s", err)
+		return "", err
+	}
+
+	err = fs.WriteArchive(ctx, zipFile, files, format, isArchive)
+	zipFile.Close()
+	if err != nil {
+		_ = os.Remove(zipFilePath)
+		return "", err
+	}
+
+	return zipFilePath, nil
+}
+
+// ListArchiveFiles 遞迴列出給定目錄和文件下所有待壓縮的文件，
+// 返回文件的 Position 為其在壓縮文件中所在的目錄
+func (fs *FileSystem) ListArchiveFiles(ctx context.Context, folderIDs, fileIDs []uint) ([]model.File, error) {
 	// 尋找待壓縮目錄
 	folders, err := model.GetFoldersByIDs(folderIDs, fs.User.ID)
 	if err != nil && len(folderIDs) != 0 {
-		return "", ErrDBListObjects
+		return nil, ErrDBListObjects
 	}
 
 	// 尋找待壓縮文件
 	files, err := model.GetFilesByIDs(fileIDs, fs.User.ID)
 	if err != nil && len(fileIDs) != 0 {
-		return "", ErrDBListObjects
+		return nil, ErrDBListObjects
 	}
 
 	// 如果上下文限制了父目錄，則進行檢查
@@ -46,24 +77,19 @@ func (fs *FileSystem) Compress(ctx context.Context, folderIDs, fileIDs []uint, i
 		// 檢查目錄
 		for _, folder := range folders {
 			if *folder.ParentID != parent.ID {
-				return "", ErrObjectNotExist
+				return nil, ErrObjectNotExist
 			}
 		}
 
 		// 檢查文件
 		for _, file := range files {
 			if file.FolderID != parent.ID {
-				return "", ErrObjectNotExist
+				return nil, ErrObjectNotExist
 			}
 		}
 	}
 
-	// 嘗試獲取請求上下文，以便於後續檢查使用者取消任務
-	reqContext := ctx
-	ginCtx, ok := ctx.Value(fsctx.GinCtx).(*gin.Context)
-	if ok {
-		reqContext = ginCtx.Request.Context()
-	}
+	reqContext := requestContext(ctx)
 
 	// 將頂級待處理物件的路徑設為根路徑
 	for i := 0; i < len(folders); i++ {
@@ -73,178 +99,188 @@ func (fs *FileSystem) Compress(ctx context.Context, folderIDs, fileIDs []uint, i
 		files[i].Position = ""
 	}
 
-	// 建立臨時壓縮文件
-	saveFolder := "archive"
-	if !isArchive {
-		saveFolder = "compress"
-	}
-	zipFilePath := filepath.Join(
-		util.RelativePath(model.GetSettingByName("temp_path")),
-		saveFolder,
-		fmt.Sprintf("archive_%d.zip", time.Now().UnixNano()),
-	)
-	zipFile, err := util.CreatNestedFile(zipFilePath)
-	if err != nil {
-		util.Log().Warning("%s", err)
-		return "", err
-	}
-	defer zipFile.Close()
-
-	// 建立壓縮文件Writer
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	ctx = reqContext
-
-	// 壓縮各個目錄及文件
+	// 遞迴列出各個目錄下的文件
+	res := make([]model.File, 0, len(files))
 	for i := 0; i < len(folders); i++ {
 		select {
 		case <-reqContext.Done():
 			// 取消壓縮請求
-			fs.cancelCompress(ctx, zipWriter, zipFile, zipFilePath)
-			return "", ErrClientCanceled
+			util.Log().Debug("用戶端取消壓縮請求")
+			return nil, ErrClientCanceled
 		default:
-			fs.doCompress(ctx, nil, &folders[i], zipWriter, isArchive)
+			res = listArchiveFolder(&folders[i], res)
 		}
+	}
+
+	return append(res, files...), nil
+}
 
+func listArchiveFolder(folder *model.Folder, files []model.File) []model.File {
+	// 獲取子文件
+	subFiles, err := folder.GetChildFiles()
+	if err == nil && len(subFiles) > 0 {
+		files = append(files, subFiles...)
 	}
-	for i := 0; i < len(files); i++ {
-		select {
-		case <-reqContext.Done():
-			// 取消壓縮請求
-			fs.cancelCompress(ctx, zipWriter, zipFile, zipFilePath)
-			return "", ErrClientCanceled
-		default:
-			fs.doCompress(ctx, &files[i], nil, zipWriter, isArchive)
+
+	// 獲取子目錄，繼續遞迴遍歷
+	subFolders, err := folder.GetChildFolder()
+	if err == nil && len(subFolders) > 0 {
+		for i := 0; i < len(subFolders); i++ {
+			files = listArchiveFolder(&subFolders[i], files)
 		}
 	}
 
-	return zipFilePath, nil
+	return files
 }
 
-// cancelCompress 取消壓縮行程
-func (fs *FileSystem) cancelCompress(ctx context.Context, zipWriter *zip.Writer, file *os.File, path string) {
-	util.Log().Debug("用戶端取消壓縮請求")
-	zipWriter.Close()
-	file.Close()
-	_ = os.Remove(path)
+// requestContext 嘗試獲取請求上下文，以便於後續檢查使用者取消任務
+func requestContext(ctx context.Context) context.Context {
+	if ginCtx, ok := ctx.Value(fsctx.GinCtx).(*gin.Context); ok {
+		return ginCtx.Request.Context()
+	}
+	return ctx
 }
 
-func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *model.Folder, zipWriter *zip.Writer, isArchive bool) {
-	// 如果物件是文件
-	if file != nil {
-		// 切換上傳策略
-		fs.Policy = file.GetPolicy()
-		err := fs.DispatchHandler()
-		if err != nil {
-			util.Log().Warning("無法壓縮文件%s，%s", file.Name, err)
-			return
-		}
-
-		// 獲取文件內容
-		fileToZip, err := fs.Handler.Get(
-			context.WithValue(ctx, fsctx.FileModelCtx, *file),
-			file.SourceName,
-		)
-		if err != nil {
-			util.Log().Debug("Open%s，%s", file.Name, err)
-			return
-		}
-		if closer, ok := fileToZip.(io.Closer); ok {
-			defer closer.Close()
-		}
+// WriteArchive 將 ListArchiveFiles 列出的文件壓縮後寫入 w，isArchive 為真時 zip 格式僅歸檔不壓縮
+func (fs *FileSystem) WriteArchive(ctx context.Context, w io.Writer, files []model.File, format string, isArchive bool) error {
+	// 建立壓縮文件Writer
+	zipWriter, err := newArchiveWriter(w, format, isArchive)
+	if err != nil {
+		return err
+	}
 
-		// 建立壓縮文件頭
-		header := &zip.FileHeader{
-			Name:               filepath.FromSlash(path.Join(file.Position, file.Name)),
-			Modified:           file.UpdatedAt,
-			UncompressedSize64: file.Size,
+	ctx = requestContext(ctx)
+	for i := 0; i < len(files); i++ {
+		select {
+		case <-ctx.Done():
+			// 取消壓縮請求
+			util.Log().Debug("用戶端取消壓縮請求")
+			zipWriter.Close()
+			return ErrClientCanceled
+		default:
+			fs.doCompress(ctx, &files[i], zipWriter)
 		}
+	}
 
-		// 指定是壓縮還是歸檔
-		if isArchive {
-			header.Method = zip.Store
-		} else {
-			header.Method = zip.Deflate
-		}
+	return zipWriter.Close()
+}
 
-		writer, err := zipWriter.CreateHeader(header)
-		if err != nil {
-			return
-		}
+// ArchiveSize 計算 ListArchiveFiles 列出的文件僅歸檔不壓縮時壓縮文件的大小，
+// 僅支援 zip 及 tar 格式
+func ArchiveSize(files []model.File, format string) (int64, error) {
+	if format != ArchiveZip && format != ArchiveTar {
+		return 0, ErrUnknownArchiveFormat
+	}
 
-		_, err = io.Copy(writer, fileToZip)
-	} else if folder != nil {
-		// 物件是目錄
-		// 獲取子文件
-		subFiles, err := folder.GetChildFiles()
-		if err == nil && len(subFiles) > 0 {
-			for i := 0; i < len(subFiles); i++ {
-				fs.doCompress(ctx, &subFiles[i], nil, zipWriter, isArchive)
-			}
+	// 以相同的文件頭寫入全為 0 的內容，歸檔時大小與實際內容無關
+	counter := &countWriter{}
+	zipWriter, err := newArchiveWriter(counter, format, true)
+	if err != nil {
+		return 0, err
+	}
 
+	for _, file := range files {
+		writer, err := zipWriter.Create(path.Join(file.Position, file.Name), file.Size, file.UpdatedAt)
+		if err != nil {
+			return 0, err
 		}
-		// 獲取子目錄，繼續遞迴遍歷
-		subFolders, err := folder.GetChildFolder()
-		if err == nil && len(subFolders) > 0 {
-			for i := 0; i < len(subFolders); i++ {
-				fs.doCompress(ctx, nil, &subFolders[i], zipWriter, isArchive)
-			}
+		if _, err := io.CopyN(writer, zeroReader{}, int64(file.Size)); err != nil {
+			return 0, err
 		}
 	}
+
+	if err := zipWriter.Close(); err != nil {
+		return 0, err
+	}
+	return counter.n, nil
 }
 
-// Decompress 解壓縮給定壓縮文件到dst目錄
-func (fs *FileSystem) Decompress(ctx context.Context, src, dst string) error {
-	err := fs.ResetFileIfNotExist(ctx, src)
+func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, zipWriter archiveWriter) {
+	// 切換上傳策略
+	fs.Policy = file.GetPolicy()
+	err := fs.DispatchHandler()
 	if err != nil {
-		return err
+		util.Log().Warning("無法壓縮文件%s，%s", file.Name, err)
+		return
 	}
 
-	tempZipFilePath := ""
-	defer func() {
-		// 結束時刪除臨時壓縮文件
-		if tempZipFilePath != "" {
-			if err := os.Remove(tempZipFilePath); err != nil {
-				util.Log().Warning("無法刪除臨時壓縮文件 %s , %s", tempZipFilePath, err)
-			}
-		}
-	}()
+	// 獲取文件內容
+	fileToZip, err := fs.Handler.Get(
+		context.WithValue(ctx, fsctx.FileModelCtx, *file),
+		file.SourceName,
+	)
+	if err != nil {
+		util.Log().Debug("Open%s，%s", file.Name, err)
+		return
+	}
+	if closer, ok := fileToZip.(io.Closer); ok {
+		defer closer.Close()
+	}
 
-	// 下載壓縮文件到暫存資料夾
-	fileStream, err := fs.Handler.Get(ctx, fs.FileTarget[0].SourceName)
+	// 建立壓縮文件頭
+	writer, err := zipWriter.Create(path.Join(file.Position, file.Name), file.Size, file.UpdatedAt)
 	if err != nil {
-		return err
+		return
 	}
 
-	tempZipFilePath = filepath.Join(
-		util.RelativePath(model.GetSettingByName("temp_path")),
-		"decompress",
-		fmt.Sprintf("archive_%d.zip", time.Now().UnixNano()),
-	)
+	// 內容短於記錄的大小時以 0 補齊，確保壓縮文件結構及預先計算的大小不變
+	n, _ := io.Copy(writer, io.LimitReader(fileToZip, int64(file.Size)))
+	if n < int64(file.Size) {
+		util.Log().Warning("文件%s的內容短於記錄的大小，以 0 補齊", file.Name)
+		io.CopyN(writer, zeroReader{}, int64(file.Size)-n)
+	}
+}
 
-	zipFile, err := util.CreatNestedFile(tempZipFilePath)
+// countWriter 只計算寫入位元組數的Writer
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// zeroReader 返回無限個 0 的Reader
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// Decompress 解壓縮給定壓縮文件到dst目錄，encoding 為非 UTF-8 檔案名的編碼。
+// entries 為要解壓縮的條目路徑，選中目錄時包含其下所有條目，為空時解壓縮所有條目
+func (fs *FileSystem) Decompress(ctx context.Context, src, dst, encoding string, entries []string) error {
+	err := fs.ResetFileIfNotExist(ctx, src)
 	if err != nil {
-		util.Log().Warning("無法建立臨時壓縮文件 %s , %s", tempZipFilePath, err)
-		tempZipFilePath = ""
 		return err
 	}
-	defer zipFile.Close()
 
-	_, err = io.Copy(zipFile, fileStream)
+	r, cleanup, err := fs.openTargetArchive(ctx)
 	if err != nil {
-		util.Log().Warning("無法寫入臨時壓縮文件 %s , %s", tempZipFilePath, err)
 		return err
 	}
+	defer cleanup()
 
-	zipFile.Close()
+	decodeName, _ := archiveNameDecoder(r, encoding)
+	selected := selectArchiveEntries(entries)
 
-	// 解壓縮文件
-	r, err := zip.OpenReader(tempZipFilePath)
+	// 解壓縮前檢查所有條目，超出安全限制時不建立任何文件
+	limit := archiveLimitFromSettings()
+	total, err := checkArchive(r, decodeName, selected, limit, fs.FileTarget[0].Size)
 	if err != nil {
 		return err
 	}
-	defer r.Close()
+	if total > fs.User.GetRemainingCapacity() {
+		return ErrInsufficientCapacity
+	}
+	if err := r.Reset(); err != nil {
+		return err
+	}
+	guard := &archiveGuard{maxSize: limit.MaxSize}
 
 	// 重設儲存策略
 	fs.Policy = &fs.User.Policy
@@ -254,31 +290,48 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst string) error {
 	}
 
 	var wg sync.WaitGroup
+	defer wg.Wait()
 	parallel := model.GetIntSetting("max_parallel_transfer", 4)
 	worker := make(chan int, parallel)
 	for i := 0; i < parallel; i++ {
 		worker <- i
 	}
 
-	for _, f := range r.File {
+	for {
+		// 讀取時超出限制則中止
+		if err := guard.Err(); err != nil {
+			return err
+		}
+
+		f, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
 		fileName := f.Name
 		// 處理非UTF-8編碼
 		if f.NonUTF8 {
-			i := bytes.NewReader([]byte(fileName))
-			decoder := transform.NewReader(i, simplifiedchinese.GB18030.NewDecoder())
-			content, _ := ioutil.ReadAll(decoder)
-			fileName = string(content)
+			fileName = decodeName(fileName)
 		}
 
-		rawPath := util.FormSlash(fileName)
-		savePath := path.Join(dst, rawPath)
-		// 路徑是否合法
-		if !strings.HasPrefix(savePath, util.FillSlash(path.Clean(dst))) {
-			return fmt.Errorf("%s: illegal file path", f.Name)
+		// 路徑是否合法，未選中的條目不解壓縮
+		rawPath, err := archiveEntryPath(fileName)
+		if selected != nil && (err != nil || !selected(rawPath)) {
+			continue
 		}
+		if err != nil {
+			return err
+		}
+		if rawPath == "." {
+			continue
+		}
+		savePath := path.Join(dst, rawPath)
 
 		// 如果是目錄
-		if f.FileInfo().IsDir() {
+		if f.IsDir {
 			fs.CreateDirectory(ctx, savePath)
 			continue
 		}
@@ -289,6 +342,21 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst string) error {
 			util.Log().Warning("無法打開壓縮包內文件%s , %s , 跳過", rawPath, err)
 			continue
 		}
+		fileStream = guard.wrap(fileStream, rawPath, f.Size)
+
+		upload := func(fileStream io.ReadCloser, size int64) {
+			err := fs.UploadFromStream(ctx, fileStream, savePath, uint64(size))
+			fileStream.Close()
+			if err != nil {
+				util.Log().Debug("無法上傳壓縮包內的文件%s , %s , 跳過", rawPath, err)
+			}
+		}
+
+		// tar 格式的條目只能依序讀取，需上傳完成後才能繼續
+		if r.Sequential() {
+			upload(fileStream, f.Size)
+			continue
+		}
 
 		select {
 		case <-worker:
@@ -303,16 +371,196 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst string) error {
 					}
 				}()
 
-				err = fs.UploadFromStream(ctx, fileStream, savePath, uint64(size))
-				fileStream.Close()
-				if err != nil {
-					util.Log().Debug("無法上傳壓縮包內的文件%s , %s , 跳過", rawPath, err)
-				}
-			}(fileStream, f.FileInfo().Size())
+				upload(fileStream, size)
+			}(fileStream, f.Size)
 		}
 
 	}
+
 	wg.Wait()
-	return nil
+	return guard.Err()
+}
+
+// ArchivePreview 壓縮文件內容預覽
+type ArchivePreview struct {
+	// Encoding 非 UTF-8 檔案名使用的編碼
+	Encoding string             `json:"encoding"`
+	Entries  []ArchiveEntryInfo `json:"entries"`
+}
+
+// ArchiveEntryInfo 壓縮文件中的條目訊息
+type ArchiveEntryInfo struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	IsDir    bool      `json:"is_dir"`
+	Modified time.Time `json:"modified"`
+}
+
+// PreviewArchive 列出壓縮文件中以給定編碼解碼後的條目
+func (fs *FileSystem) PreviewArchive(ctx context.Context, src, encoding string) (*ArchivePreview, error) {
+	err := fs.ResetFileIfNotExist(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	r, cleanup, err := fs.openTargetArchive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	decodeName, detected := archiveNameDecoder(r, encoding)
+	preview := &ArchivePreview{Encoding: detected, Entries: []ArchiveEntryInfo{}}
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := f.Name
+		if f.NonUTF8 {
+			name = decodeName(name)
+		}
+		preview.Entries = append(preview.Entries, ArchiveEntryInfo{
+			Name:     util.FormSlash(name),
+			Size:     f.Size,
+			IsDir:    f.IsDir,
+			Modified: f.Modified,
+		})
+	}
+
+	return preview, nil
+}
+
+// openTargetArchive 打開目標壓縮文件，cleanup 用於關閉壓縮文件並清理臨時文件。
+// tar 格式依序讀取文件流；zip 格式在文件流支援隨機讀取或儲存策略支援範圍請求時
+// 只讀取需要的部分，否則下載到暫存資料夾
+func (fs *FileSystem) openTargetArchive(ctx context.Context) (archiveReader, func(), error) {
+	file := fs.FileTarget[0]
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, file)
+	open := func() (io.ReadCloser, error) {
+		return fs.Handler.Get(ctx, file.SourceName)
+	}
+
+	fileStream, err := fs.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 根據文件開頭判斷壓縮文件格式，Seek 用於取消部分儲存策略首次讀取時返回的空資料
+	fileStream.Seek(0, io.SeekStart)
+	header := make([]byte, 512)
+	n, err := io.ReadFull(fileStream, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		fileStream.Close()
+		return nil, nil, err
+	}
+	format := DetectArchiveFormat(file.Name, header[:n])
+	if format == "" {
+		fileStream.Close()
+		return nil, nil, ErrUnknownArchiveFormat
+	}
+
+	var r archiveReader
+	switch readerAt, ok := fileStream.(io.ReaderAt); {
+	case format != ArchiveZip:
+		fileStream.Close()
+		r, err = newTarArchiveReader(open, format)
+	case ok:
+		var reader *zip.Reader
+		if reader, err = zip.NewReader(readerAt, int64(file.Size)); err != nil {
+			fileStream.Close()
+			return nil, nil, err
+		}
+		r = &zipArchiveReader{reader: reader, closer: fileStream}
+	default:
+		rangeReader, rangeErr := fs.targetRangeReader(ctx)
+		if rangeErr != nil {
+			util.Log().Debug("無法範圍讀取壓縮文件，將下載到暫存資料夾，%s", rangeErr)
+			defer fileStream.Close()
+			return downloadArchive(io.MultiReader(bytes.NewReader(header[:n]), fileStream), format)
+		}
+		fileStream.Close()
+		var reader *zip.Reader
+		if reader, err = zip.NewReader(rangeReader, int64(file.Size)); err == nil {
+			r = &zipArchiveReader{reader: reader}
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, func() {
+		r.Close()
+	}, nil
+}
+
+// downloadArchive 將壓縮文件下載到暫存資料夾並打開，cleanup 用於關閉並刪除臨時文件
+func downloadArchive(fileStream io.Reader, format string) (archiveReader, func(), error) {
+	tempZipFilePath := filepath.Join(
+		util.RelativePath(model.GetSettingByName("temp_path")),
+		"decompress",
+		fmt.Sprintf("archive_%d", time.Now().UnixNano()),
+	)
+
+	zipFile, err := util.CreatNestedFile(tempZipFilePath)
+	if err != nil {
+		util.Log().Warning("無法建立臨時壓縮文件 %s , %s", tempZipFilePath, err)
+		return nil, nil, err
+	}
+
+	// 結束時刪除臨時壓縮文件
+	removeTemp := func() {
+		if err := os.Remove(tempZipFilePath); err != nil {
+			util.Log().Warning("無法刪除臨時壓縮文件 %s , %s", tempZipFilePath, err)
+		}
+	}
+
+	_, err = io.Copy(zipFile, fileStream)
+	zipFile.Close()
+	if err != nil {
+		util.Log().Warning("無法寫入臨時壓縮文件 %s , %s", tempZipFilePath, err)
+		removeTemp()
+		return nil, nil, err
+	}
+
+	r, err := openArchive(tempZipFilePath, format)
+	if err != nil {
+		removeTemp()
+		return nil, nil, err
+	}
+
+	return r, func() {
+		r.Close()
+		removeTemp()
+	}, nil
+}
+
+// archiveNameDecoder 返回解碼壓縮文件中非 UTF-8 檔案名的函式及使用的編碼。
+// 自動判斷時 zip 根據所有檔案名判斷，只能依序讀取的格式則逐個檔案名判斷
+func archiveNameDecoder(r archiveReader, encoding string) (func(name string) string, string) {
+	if encoding == "" {
+		encoding = EncodingAuto
+	}
+
+	if encoding == EncodingAuto {
+		if zipReader, ok := r.(*zipArchiveReader); ok {
+			var names []string
+			for _, f := range zipReader.reader.File {
+				if f.NonUTF8 {
+					names = append(names, f.Name)
+				}
+			}
+			if len(names) > 0 {
+				encoding = DetectNameEncoding(names)
+			}
+		}
+	}
 
+	return func(name string) string {
+		return DecodeName(name, encoding)
+	}, encoding
 }
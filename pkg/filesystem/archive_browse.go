@@ -0,0 +1,206 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+)
+
+// ArchiveListing 壓縮文件的目錄結構
+type ArchiveListing struct {
+	// Encoding 非 UTF-8 檔案名使用的編碼
+	Encoding string `json:"encoding"`
+	// Size 解壓縮後的總大小
+	Size uint64 `json:"size"`
+	// Files 文件數量
+	Files int `json:"files"`
+	// Tree 根目錄下的文件和目錄
+	Tree []*ArchiveNode `json:"tree"`
+}
+
+// ArchiveNode 壓縮文件中的文件或目錄
+type ArchiveNode struct {
+	Name     string         `json:"name"`
+	Path     string         `json:"path"`
+	Size     int64          `json:"size"`
+	IsDir    bool           `json:"is_dir"`
+	Modified time.Time      `json:"modified"`
+	Children []*ArchiveNode `json:"children,omitempty"`
+}
+
+// ListArchive 列出壓縮文件中的條目並建立目錄樹，條目中未單獨記錄的上層目錄會自動補齊。
+// 路徑不安全的條目無法解壓縮，不會被列出
+func (fs *FileSystem) ListArchive(ctx context.Context, src, encoding string) (*ArchiveListing, error) {
+	err := fs.ResetFileIfNotExist(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	r, cleanup, err := fs.openTargetArchive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	decodeName, detected := archiveNameDecoder(r, encoding)
+	limit := archiveLimitFromSettings()
+	listing := &ArchiveListing{Encoding: detected, Tree: []*ArchiveNode{}}
+	dirs := make(map[string]*ArchiveNode)
+
+	// add 將節點加入其上層目錄，dir 返回給定路徑的目錄節點，不存在時逐級建立
+	var dir func(dirPath string) *ArchiveNode
+	add := func(node *ArchiveNode) {
+		if parent := path.Dir(node.Path); parent == "." {
+			listing.Tree = append(listing.Tree, node)
+		} else {
+			parentNode := dir(parent)
+			parentNode.Children = append(parentNode.Children, node)
+		}
+	}
+	dir = func(dirPath string) *ArchiveNode {
+		if node, ok := dirs[dirPath]; ok {
+			return node
+		}
+
+		node := &ArchiveNode{Name: path.Base(dirPath), Path: dirPath, IsDir: true}
+		dirs[dirPath] = node
+		add(node)
+		return node
+	}
+
+	entries := 0
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entries++
+		if limit.MaxEntries > 0 && entries > limit.MaxEntries {
+			return nil, fmt.Errorf("%w: 條目數量超過 %d", ErrUnsafeArchive, limit.MaxEntries)
+		}
+
+		name := f.Name
+		if f.NonUTF8 {
+			name = decodeName(name)
+		}
+		entryPath, err := archiveEntryPath(name)
+		if err != nil || entryPath == "." {
+			continue
+		}
+
+		if f.IsDir {
+			dir(entryPath).Modified = f.Modified
+			continue
+		}
+
+		add(&ArchiveNode{
+			Name:     path.Base(entryPath),
+			Path:     entryPath,
+			Size:     f.Size,
+			Modified: f.Modified,
+		})
+
+		listing.Files++
+		if f.Size > 0 {
+			listing.Size += uint64(f.Size)
+		}
+	}
+
+	sortArchiveNodes(listing.Tree)
+	return listing, nil
+}
+
+// sortArchiveNodes 遞迴排序節點，目錄在前，同類型按名稱排序
+func sortArchiveNodes(nodes []*ArchiveNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].IsDir != nodes[j].IsDir {
+			return nodes[i].IsDir
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+	for _, node := range nodes {
+		sortArchiveNodes(node.Children)
+	}
+}
+
+// OpenArchiveEntry 打開壓縮文件中給定路徑的文件條目，返回其內容及訊息。
+// 內容的讀取量受解壓縮安全限制約束，使用完畢後需關閉
+func (fs *FileSystem) OpenArchiveEntry(ctx context.Context, src, name, encoding string) (io.ReadCloser, *ArchiveNode, error) {
+	target, err := archiveEntryPath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = fs.ResetFileIfNotExist(ctx, src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, cleanup, err := fs.openTargetArchive(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decodeName, _ := archiveNameDecoder(r, encoding)
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+
+		entryName := f.Name
+		if f.NonUTF8 {
+			entryName = decodeName(entryName)
+		}
+		if f.IsDir {
+			continue
+		}
+		if entryPath, err := archiveEntryPath(entryName); err != nil || entryPath != target {
+			continue
+		}
+
+		fileStream, err := f.Open()
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+
+		guard := &archiveGuard{maxSize: archiveLimitFromSettings().MaxSize}
+		entry := &archiveEntryReader{
+			ReadCloser: guard.wrap(fileStream, target, f.Size),
+			cleanup:    cleanup,
+		}
+		return entry, &ArchiveNode{
+			Name:     path.Base(target),
+			Path:     target,
+			Size:     f.Size,
+			Modified: f.Modified,
+		}, nil
+	}
+
+	cleanup()
+	return nil, nil, ErrObjectNotExist
+}
+
+// archiveEntryReader 關閉條目內容時一併關閉壓縮文件
+type archiveEntryReader struct {
+	io.ReadCloser
+	cleanup func()
+}
+
+func (r *archiveEntryReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.cleanup()
+	return err
+}
@@ -0,0 +1,171 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+// writeTestArchive 建立包含隱含目錄及不安全路徑的壓縮文件
+func writeTestArchive(t *testing.T, format string) []byte {
+	var buf bytes.Buffer
+	writer, err := newArchiveWriter(&buf, format, false)
+	assert.NoError(t, err)
+
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for name, content := range map[string]string{
+		"b.txt":        "b",
+		"dir/1.txt":    "hello",
+		"dir/sub/2.md": "world!",
+		"../evil.txt":  "evil",
+	} {
+		w, err := writer.Create(name, uint64(len(content)), modified)
+		assert.NoError(t, err)
+		w.Write([]byte(content))
+	}
+	assert.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+// cacheArchiveLimit 快取解壓縮安全限制的設定
+func cacheArchiveLimit(t *testing.T, maxEntries string) {
+	assert.NoError(t, cache.SetSettings(map[string]string{
+		"decompress_max_size":    "0",
+		"decompress_max_entries": maxEntries,
+		"decompress_max_depth":   "0",
+		"decompress_max_ratio":   "0",
+	}, "setting_"))
+}
+
+// newArchiveFS 建立目標文件為給定壓縮文件的文件系統，每次讀取文件都返回新的文件流
+func newArchiveFS(t *testing.T, name string, content []byte) *FileSystem {
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	fs.FileTarget = []model.File{{Name: name, SourceName: name, Size: uint64(len(content)), Policy: model.Policy{Type: "mock"}}}
+	fs.FileTarget[0].Policy.ID = 1
+	testHandler := new(FileHeaderMock)
+	for i := 0; i < 4; i++ {
+		testHandler.On("Get", testMock.Anything, name).Return(MockRSC{rs: bytes.NewReader(content)}, nil).Once()
+	}
+	fs.Handler = testHandler
+	return fs
+}
+
+func TestFileSystem_ListArchive(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.NoError(cache.Set("setting_temp_path", "tests", 0))
+	cacheArchiveLimit(t, "0")
+
+	for _, format := range []string{ArchiveZip, ArchiveTarGz} {
+		fs := newArchiveFS(t, "1."+format, writeTestArchive(t, format))
+		listing, err := fs.ListArchive(context.Background(), "/1."+format, "")
+		asserts.NoError(err, format)
+		asserts.Equal(3, listing.Files, format)
+		asserts.EqualValues(12, listing.Size, format)
+
+		// 目錄在前，不安全的條目不列出
+		asserts.Len(listing.Tree, 2, format)
+		dir := listing.Tree[0]
+		asserts.Equal("dir", dir.Name)
+		asserts.True(dir.IsDir)
+		asserts.Len(dir.Children, 2)
+		asserts.Equal("dir/sub", dir.Children[0].Path)
+		asserts.Equal("dir/sub/2.md", dir.Children[0].Children[0].Path)
+		asserts.EqualValues(6, dir.Children[0].Children[0].Size)
+		asserts.Equal("dir/1.txt", dir.Children[1].Path)
+		asserts.Equal("b.txt", listing.Tree[1].Path)
+		asserts.Equal(2020, listing.Tree[1].Modified.Year())
+	}
+
+	// 條目數量超出限制
+	{
+		cacheArchiveLimit(t, "2")
+		fs := newArchiveFS(t, "1.zip", writeTestArchive(t, ArchiveZip))
+		_, err := fs.ListArchive(context.Background(), "/1.zip", "")
+		asserts.True(errors.Is(err, ErrUnsafeArchive))
+		cacheArchiveLimit(t, "0")
+	}
+
+	// 沒有殘留臨時文件
+	tempFiles, _ := ioutil.ReadDir(util.RelativePath("tests/decompress"))
+	asserts.Empty(tempFiles)
+}
+
+func TestFileSystem_OpenArchiveEntry(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.NoError(cache.Set("setting_temp_path", "tests", 0))
+	cacheArchiveLimit(t, "0")
+
+	for _, format := range []string{ArchiveZip, ArchiveTarXz} {
+		content := writeTestArchive(t, format)
+
+		// 成功
+		{
+			fs := newArchiveFS(t, "1."+format, content)
+			rc, entry, err := fs.OpenArchiveEntry(context.Background(), "/1."+format, "dir\\sub/./2.md", "")
+			asserts.NoError(err, format)
+			asserts.Equal("2.md", entry.Name)
+			asserts.EqualValues(6, entry.Size)
+			res, err := ioutil.ReadAll(rc)
+			asserts.NoError(err)
+			asserts.Equal("world!", string(res))
+			asserts.NoError(rc.Close())
+		}
+
+		// 條目不存在或為目錄
+		for _, name := range []string{"dir/3.txt", "dir"} {
+			fs := newArchiveFS(t, "1."+format, content)
+			_, _, err := fs.OpenArchiveEntry(context.Background(), "/1."+format, name, "")
+			asserts.Equal(ErrObjectNotExist, err, format)
+		}
+
+		// 不安全的路徑
+		{
+			fs := newArchiveFS(t, "1."+format, content)
+			_, _, err := fs.OpenArchiveEntry(context.Background(), "/1."+format, "../evil.txt", "")
+			asserts.True(errors.Is(err, ErrUnsafeArchive), format)
+		}
+	}
+}
+
+func TestFileSystem_OpenArchiveEntry_ReaderAt(t *testing.T) {
+	asserts := assert.New(t)
+	cacheArchiveLimit(t, "0")
+	filePath := util.RelativePath("tests/archive_browse/1.zip")
+	asserts.NoError(os.MkdirAll(filepath.Dir(filePath), 0744))
+	defer os.RemoveAll(filepath.Dir(filePath))
+	content := writeTestArchive(t, ArchiveZip)
+	asserts.NoError(ioutil.WriteFile(filePath, content, 0644))
+
+	// 本機文件直接隨機讀取，不下載到暫存資料夾
+	asserts.NoError(cache.Set("setting_temp_path", "/tests:", 0))
+	defer cache.Set("setting_temp_path", "tests", 0)
+
+	file, err := os.Open(filePath)
+	asserts.NoError(err)
+	fs := newArchiveFS(t, "1.zip", content)
+	fs.Handler = new(FileHeaderMock)
+	fs.Handler.(*FileHeaderMock).On("Get", testMock.Anything, "1.zip").Return(file, nil)
+
+	rc, _, err := fs.OpenArchiveEntry(context.Background(), "/1.zip", "dir/1.txt", "")
+	asserts.NoError(err)
+	res, err := ioutil.ReadAll(rc)
+	asserts.NoError(err)
+	asserts.Equal("hello", string(res))
+	asserts.NoError(rc.Close())
+
+	// 關閉條目時一併關閉文件
+	_, err = file.Stat()
+	asserts.Error(err)
+}
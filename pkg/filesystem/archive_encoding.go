@@ -0,0 +1,184 @@
+package filesystem
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// 壓縮文件檔案名編碼
+const (
+	// EncodingAuto 自動判斷非 UTF-8 檔案名的編碼
+	EncodingAuto = "auto"
+	// EncodingUTF8 不轉換檔案名
+	EncodingUTF8 = "utf-8"
+)
+
+// archiveEncodings 可選的舊式檔案名編碼
+var archiveEncodings = map[string]encoding.Encoding{
+	"gb18030":   simplifiedchinese.GB18030,
+	"big5":      traditionalchinese.Big5,
+	"shift_jis": japanese.ShiftJIS,
+	"euc-kr":    korean.EUCKR,
+	"cp437":     charmap.CodePage437,
+}
+
+// encodingCandidates 自動判斷時嘗試的編碼及其評分函式，分數相同時排在前面的優先
+var encodingCandidates = []struct {
+	name  string
+	score func(b []byte) (int, bool)
+}{
+	{"gb18030", scoreGBK},
+	{"big5", scoreBig5},
+	{"shift_jis", scoreShiftJIS},
+}
+
+// IsArchiveEncoding 返回是否為支援的檔案名編碼
+func IsArchiveEncoding(name string) bool {
+	if name == EncodingAuto || name == EncodingUTF8 {
+		return true
+	}
+	_, ok := archiveEncodings[name]
+	return ok
+}
+
+// DetectNameEncoding 根據一組非 UTF-8 檔案名判斷其編碼。各候選編碼依其位元組規則
+// 為檔案名評分，常用字元得分較高，不符合規則的編碼被排除；都不符合時視為 CP437
+func DetectNameEncoding(names []string) string {
+	best, bestScore := "cp437", -1
+	for _, candidate := range encodingCandidates {
+		total := 0
+		valid := true
+		for _, name := range names {
+			score, ok := candidate.score([]byte(name))
+			if !ok {
+				valid = false
+				break
+			}
+			total += score
+		}
+
+		if valid && total > bestScore {
+			best, bestScore = candidate.name, total
+		}
+	}
+
+	return best
+}
+
+// DecodeName 以給定編碼解碼檔案名，失敗時返回原始檔案名
+func DecodeName(name, enc string) string {
+	if enc == EncodingAuto {
+		enc = DetectNameEncoding([]string{name})
+	}
+
+	decoder, ok := archiveEncodings[enc]
+	if !ok {
+		return name
+	}
+
+	res, err := decoder.NewDecoder().String(name)
+	if err != nil {
+		return name
+	}
+	return res
+}
+
+// scoreGBK 按 GBK 規則評分，GB2312 漢字區的字元為常用字元
+func scoreGBK(b []byte) (int, bool) {
+	score := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if c < 0x80 {
+			continue
+		}
+		if c == 0x80 || c == 0xff || i+1 >= len(b) {
+			return 0, false
+		}
+
+		trail := b[i+1]
+		// GB18030 四位元組序列
+		if trail >= 0x30 && trail <= 0x39 {
+			if i+3 >= len(b) || b[i+2] < 0x81 || b[i+2] > 0xfe || b[i+3] < 0x30 || b[i+3] > 0x39 {
+				return 0, false
+			}
+			i += 3
+			continue
+		}
+		if trail < 0x40 || trail == 0x7f || trail == 0xff {
+			return 0, false
+		}
+
+		if c >= 0xb0 && c <= 0xf7 && trail >= 0xa1 {
+			score += 2
+		} else {
+			score++
+		}
+		i++
+	}
+	return score, true
+}
+
+// scoreBig5 按 Big5 規則評分，常用字區的字元為常用字元
+func scoreBig5(b []byte) (int, bool) {
+	score := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if c < 0x80 {
+			continue
+		}
+		if c < 0xa1 || c > 0xf9 || i+1 >= len(b) {
+			return 0, false
+		}
+
+		trail := b[i+1]
+		if !(trail >= 0x40 && trail <= 0x7e) && !(trail >= 0xa1 && trail <= 0xfe) {
+			return 0, false
+		}
+
+		if c >= 0xa4 && (c < 0xc6 || (c == 0xc6 && trail <= 0x7e)) {
+			score += 2
+		} else {
+			score++
+		}
+		i++
+	}
+	return score, true
+}
+
+// scoreShiftJIS 按 Shift-JIS 規則評分，假名及第一水準漢字為常用字元，
+// 半形片假名極少用於檔案名，通常是誤判其他編碼的結果，因此扣分
+func scoreShiftJIS(b []byte) (int, bool) {
+	score := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if c < 0x80 {
+			continue
+		}
+		if c >= 0xa1 && c <= 0xdf {
+			score--
+			continue
+		}
+		if !(c >= 0x81 && c <= 0x9f) && !(c >= 0xe0 && c <= 0xfc) || i+1 >= len(b) {
+			return 0, false
+		}
+
+		trail := b[i+1]
+		if trail < 0x40 || trail == 0x7f || trail > 0xfc {
+			return 0, false
+		}
+
+		code := uint16(c)<<8 | uint16(trail)
+		if (c == 0x82 && trail >= 0x9f && trail <= 0xf1) || (c == 0x83 && trail <= 0x96) ||
+			(code >= 0x889f && code <= 0x9872) {
+			score += 2
+		} else {
+			score++
+		}
+		i++
+	}
+	return score, true
+}
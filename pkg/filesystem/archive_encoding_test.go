@@ -0,0 +1,103 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func encodeName(t *testing.T, name, enc string) string {
+	res, err := archiveEncodings[enc].NewEncoder().String(name)
+	assert.NoError(t, err)
+	return res
+}
+
+func TestDetectNameEncoding(t *testing.T) {
+	asserts := assert.New(t)
+
+	testCases := []struct {
+		names    []string
+		encoding string
+	}{
+		{[]string{"中文测试.txt", "文件夹/说明.doc"}, "gb18030"},
+		{[]string{"中文測試.txt"}, "gb18030"},
+		{[]string{"中文測試.txt", "資料夾/說明.doc"}, "big5"},
+		{[]string{"日本語テスト.txt", "フォルダ/説明.doc"}, "shift_jis"},
+		{[]string{"ひらがな.txt"}, "shift_jis"},
+	}
+
+	for _, testCase := range testCases {
+		names := make([]string, 0, len(testCase.names))
+		for _, name := range testCase.names {
+			names = append(names, encodeName(t, name, testCase.encoding))
+		}
+		asserts.Equal(testCase.encoding, DetectNameEncoding(names), testCase.names)
+	}
+
+	// 不符合任何東亞編碼時視為 CP437
+	asserts.Equal("cp437", DetectNameEncoding([]string{encodeName(t, "café.txt", "cp437")}))
+}
+
+func TestDecodeName(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal("中文测试.txt", DecodeName(encodeName(t, "中文测试.txt", "gb18030"), EncodingAuto))
+	asserts.Equal("說明.txt", DecodeName(encodeName(t, "說明.txt", "big5"), "big5"))
+	asserts.Equal("한국어.txt", DecodeName(encodeName(t, "한국어.txt", "euc-kr"), "euc-kr"))
+	asserts.Equal("raw", DecodeName("raw", EncodingUTF8))
+
+	asserts.True(IsArchiveEncoding(EncodingAuto))
+	asserts.True(IsArchiveEncoding("shift_jis"))
+	asserts.False(IsArchiveEncoding("utf-16"))
+}
+
+func TestFileSystem_PreviewArchive(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.NoError(cache.Set("setting_temp_path", "tests", 0))
+
+	// 以 Shift-JIS 檔案名建立壓縮文件
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	_, err := writer.CreateHeader(&zip.FileHeader{Name: encodeName(t, "フォルダ/", "shift_jis"), NonUTF8: true})
+	asserts.NoError(err)
+	w, err := writer.CreateHeader(&zip.FileHeader{Name: encodeName(t, "フォルダ/日本語.txt", "shift_jis"), NonUTF8: true})
+	asserts.NoError(err)
+	w.Write([]byte("hello"))
+	asserts.NoError(writer.Close())
+
+	newFS := func() *FileSystem {
+		fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+		fs.FileTarget = []model.File{{Name: "1.zip", SourceName: "1.zip", Policy: model.Policy{Type: "mock"}}}
+		fs.FileTarget[0].Policy.ID = 1
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "1.zip").Return(MockRSC{rs: bytes.NewReader(buf.Bytes())}, nil)
+		fs.Handler = testHandler
+		return fs
+	}
+
+	// 自動判斷編碼
+	{
+		preview, err := newFS().PreviewArchive(context.Background(), "/1.zip", EncodingAuto)
+		asserts.NoError(err)
+		asserts.Equal("shift_jis", preview.Encoding)
+		asserts.Len(preview.Entries, 2)
+		asserts.Equal("フォルダ", preview.Entries[0].Name)
+		asserts.True(preview.Entries[0].IsDir)
+		asserts.Equal("フォルダ/日本語.txt", preview.Entries[1].Name)
+		asserts.EqualValues(5, preview.Entries[1].Size)
+	}
+
+	// 指定編碼
+	{
+		preview, err := newFS().PreviewArchive(context.Background(), "/1.zip", "gb18030")
+		asserts.NoError(err)
+		asserts.Equal("gb18030", preview.Encoding)
+		asserts.NotEqual("フォルダ/日本語.txt", preview.Entries[1].Name)
+	}
+}
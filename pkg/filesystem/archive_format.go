@@ -0,0 +1,330 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/ulikunitz/xz"
+)
+
+// 支援的壓縮文件格式
+const (
+	ArchiveZip    = "zip"
+	ArchiveTar    = "tar"
+	ArchiveTarGz  = "tar.gz"
+	ArchiveTarBz2 = "tar.bz2"
+	ArchiveTarXz  = "tar.xz"
+)
+
+// archiveSuffixes 各格式對應的副檔名，按匹配優先順序排列
+var archiveSuffixes = []struct {
+	suffix string
+	format string
+}{
+	{".tar.gz", ArchiveTarGz},
+	{".tgz", ArchiveTarGz},
+	{".tar.bz2", ArchiveTarBz2},
+	{".tbz2", ArchiveTarBz2},
+	{".tbz", ArchiveTarBz2},
+	{".tar.xz", ArchiveTarXz},
+	{".txz", ArchiveTarXz},
+	{".tar", ArchiveTar},
+	{".zip", ArchiveZip},
+}
+
+// IsArchiveFormat 返回是否為支援的壓縮文件格式
+func IsArchiveFormat(format string) bool {
+	switch format {
+	case ArchiveZip, ArchiveTar, ArchiveTarGz, ArchiveTarBz2, ArchiveTarXz:
+		return true
+	}
+	return false
+}
+
+// ArchiveFormatFromName 根據檔案名的副檔名判斷壓縮文件格式，無法判斷時返回空字串
+func ArchiveFormatFromName(name string) string {
+	name = strings.ToLower(name)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(name, suffix.suffix) {
+			return suffix.format
+		}
+	}
+	return ""
+}
+
+// DetectArchiveFormat 根據文件開頭的特徵位元組判斷壓縮文件格式，
+// 無法識別時按檔案名判斷
+func DetectArchiveFormat(name string, header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return ArchiveZip
+	case bytes.HasPrefix(header, []byte("\x1f\x8b")):
+		return ArchiveTarGz
+	case bytes.HasPrefix(header, []byte("BZh")):
+		return ArchiveTarBz2
+	case bytes.HasPrefix(header, []byte("\xfd7zXZ\x00")):
+		return ArchiveTarXz
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return ArchiveTar
+	}
+	return ArchiveFormatFromName(name)
+}
+
+// archiveWriter 壓縮文件寫入器
+type archiveWriter interface {
+	// Create 在壓縮文件中建立文件，返回其內容的Writer
+	Create(name string, size uint64, modified time.Time) (io.Writer, error)
+	Close() error
+}
+
+// newArchiveWriter 建立給定格式的壓縮文件寫入器，isArchive 為真時 zip 格式僅歸檔不壓縮
+func newArchiveWriter(w io.Writer, format string, isArchive bool) (archiveWriter, error) {
+	switch format {
+	case ArchiveZip:
+		method := zip.Deflate
+		if isArchive {
+			method = zip.Store
+		}
+		return &zipArchiveWriter{writer: zip.NewWriter(w), method: method}, nil
+	case ArchiveTar:
+		return &tarArchiveWriter{writer: tar.NewWriter(w)}, nil
+	case ArchiveTarGz:
+		compressor := gzip.NewWriter(w)
+		return &tarArchiveWriter{writer: tar.NewWriter(compressor), compressor: compressor}, nil
+	case ArchiveTarBz2:
+		compressor, err := bzip2.NewWriter(w, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &tarArchiveWriter{writer: tar.NewWriter(compressor), compressor: compressor}, nil
+	case ArchiveTarXz:
+		compressor, err := xz.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &tarArchiveWriter{writer: tar.NewWriter(compressor), compressor: compressor}, nil
+	}
+	return nil, ErrUnknownArchiveFormat
+}
+
+type zipArchiveWriter struct {
+	writer *zip.Writer
+	method uint16
+}
+
+func (w *zipArchiveWriter) Create(name string, size uint64, modified time.Time) (io.Writer, error) {
+	return w.writer.CreateHeader(&zip.FileHeader{
+		Name:               filepath.FromSlash(name),
+		Modified:           modified,
+		UncompressedSize64: size,
+		Method:             w.method,
+	})
+}
+
+func (w *zipArchiveWriter) Close() error {
+	return w.writer.Close()
+}
+
+type tarArchiveWriter struct {
+	writer     *tar.Writer
+	compressor io.WriteCloser
+}
+
+func (w *tarArchiveWriter) Create(name string, size uint64, modified time.Time) (io.Writer, error) {
+	err := w.writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(size),
+		Mode:     0644,
+		ModTime:  modified,
+	})
+	return w.writer, err
+}
+
+func (w *tarArchiveWriter) Close() error {
+	err := w.writer.Close()
+	if w.compressor != nil {
+		if closeErr := w.compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// archiveEntry 壓縮文件中的條目
+type archiveEntry struct {
+	Name     string
+	NonUTF8  bool
+	IsDir    bool
+	Size     int64
+	Modified time.Time
+	Open     func() (io.ReadCloser, error)
+}
+
+// archiveReader 依序讀取壓縮文件中的條目
+type archiveReader interface {
+	// Next 返回下一個條目，沒有更多條目時返回 io.EOF
+	Next() (*archiveEntry, error)
+	// Sequential 返回條目內容是否必須在讀取下一個條目之前讀取完畢
+	Sequential() bool
+	// Reset 回到第一個條目
+	Reset() error
+	Close() error
+}
+
+// openArchive 打開給定格式的本機壓縮文件
+func openArchive(filePath, format string) (archiveReader, error) {
+	if format == ArchiveZip {
+		reader, err := zip.OpenReader(filePath)
+		if err != nil {
+			return nil, err
+		}
+		return &zipArchiveReader{reader: &reader.Reader, closer: reader}, nil
+	}
+
+	return newTarArchiveReader(func() (io.ReadCloser, error) {
+		return os.Open(filePath)
+	}, format)
+}
+
+// newTarArchiveReader 建立 tar 格式的讀取器，open 用於（重新）打開壓縮文件的資料流
+func newTarArchiveReader(open func() (io.ReadCloser, error), format string) (archiveReader, error) {
+	r := &tarArchiveReader{open: open, format: format}
+	if err := r.Reset(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+type zipArchiveReader struct {
+	reader *zip.Reader
+	closer io.Closer
+	index  int
+}
+
+func (r *zipArchiveReader) Next() (*archiveEntry, error) {
+	if r.index >= len(r.reader.File) {
+		return nil, io.EOF
+	}
+
+	f := r.reader.File[r.index]
+	r.index++
+	return &archiveEntry{
+		Name:     f.Name,
+		NonUTF8:  f.NonUTF8,
+		IsDir:    f.FileInfo().IsDir(),
+		Size:     f.FileInfo().Size(),
+		Modified: f.Modified,
+		Open:     f.Open,
+	}, nil
+}
+
+func (r *zipArchiveReader) Sequential() bool {
+	return false
+}
+
+func (r *zipArchiveReader) Reset() error {
+	r.index = 0
+	return nil
+}
+
+func (r *zipArchiveReader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+type tarArchiveReader struct {
+	open         func() (io.ReadCloser, error)
+	format       string
+	source       io.ReadCloser
+	decompressor io.Reader
+	reader       *tar.Reader
+}
+
+func (r *tarArchiveReader) Next() (*archiveEntry, error) {
+	for {
+		header, err := r.reader.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// 略過符號連結等特殊條目
+		switch header.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeRegA:
+		default:
+			continue
+		}
+
+		return &archiveEntry{
+			Name:     header.Name,
+			NonUTF8:  !utf8.ValidString(header.Name),
+			IsDir:    header.Typeflag == tar.TypeDir,
+			Size:     header.Size,
+			Modified: header.ModTime,
+			Open: func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(r.reader), nil
+			},
+		}, nil
+	}
+}
+
+func (r *tarArchiveReader) Sequential() bool {
+	return true
+}
+
+// Reset 重新打開資料流，tar 格式不支援隨機讀取
+func (r *tarArchiveReader) Reset() error {
+	r.Close()
+	source, err := r.open()
+	if err != nil {
+		return err
+	}
+	r.source = source
+
+	switch r.format {
+	case ArchiveTar:
+		r.decompressor = source
+	case ArchiveTarGz:
+		r.decompressor, err = gzip.NewReader(source)
+	case ArchiveTarBz2:
+		r.decompressor, err = bzip2.NewReader(source, nil)
+	case ArchiveTarXz:
+		r.decompressor, err = xz.NewReader(source)
+	default:
+		err = ErrUnknownArchiveFormat
+	}
+	if err != nil {
+		r.decompressor = nil
+		return err
+	}
+
+	r.reader = tar.NewReader(r.decompressor)
+	return nil
+}
+
+func (r *tarArchiveReader) Close() error {
+	if closer, ok := r.decompressor.(io.Closer); ok && r.format != ArchiveTar {
+		closer.Close()
+	}
+	r.decompressor = nil
+
+	if r.source == nil {
+		return nil
+	}
+	err := r.source.Close()
+	r.source = nil
+	return err
+}
@@ -0,0 +1,110 @@
+package filesystem
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveFormatFromName(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal(ArchiveZip, ArchiveFormatFromName("a.ZIP"))
+	asserts.Equal(ArchiveTar, ArchiveFormatFromName("a.tar"))
+	asserts.Equal(ArchiveTarGz, ArchiveFormatFromName("a.tar.gz"))
+	asserts.Equal(ArchiveTarGz, ArchiveFormatFromName("a.tgz"))
+	asserts.Equal(ArchiveTarBz2, ArchiveFormatFromName("a.tar.bz2"))
+	asserts.Equal(ArchiveTarXz, ArchiveFormatFromName("a.txz"))
+	asserts.Equal("", ArchiveFormatFromName("a.gz"))
+	asserts.Equal("", ArchiveFormatFromName("a.txt"))
+	asserts.True(IsArchiveFormat(ArchiveTarXz))
+	asserts.False(IsArchiveFormat("rar"))
+}
+
+func TestDetectArchiveFormat(t *testing.T) {
+	asserts := assert.New(t)
+	tarHeader := make([]byte, 512)
+	copy(tarHeader[257:], "ustar")
+
+	asserts.Equal(ArchiveZip, DetectArchiveFormat("a.tar", []byte("PK\x03\x04....")))
+	asserts.Equal(ArchiveTarGz, DetectArchiveFormat("a.zip", []byte("\x1f\x8b\x08")))
+	asserts.Equal(ArchiveTarBz2, DetectArchiveFormat("a", []byte("BZh91AY")))
+	asserts.Equal(ArchiveTarXz, DetectArchiveFormat("a", []byte("\xfd7zXZ\x00\x00")))
+	asserts.Equal(ArchiveTar, DetectArchiveFormat("a.zip", tarHeader))
+	asserts.Equal(ArchiveZip, DetectArchiveFormat("a.zip", []byte("read")))
+	asserts.Equal("", DetectArchiveFormat("a.txt", []byte("read")))
+}
+
+func TestArchiveWriterReader(t *testing.T) {
+	asserts := assert.New(t)
+	dir := util.RelativePath("tests/archive_format")
+	defer os.RemoveAll(dir)
+
+	for _, format := range []string{ArchiveZip, ArchiveTar, ArchiveTarGz, ArchiveTarBz2, ArchiveTarXz} {
+		filePath := filepath.Join(dir, "archive."+format)
+		file, err := util.CreatNestedFile(filePath)
+		asserts.NoError(err)
+
+		// 寫入
+		writer, err := newArchiveWriter(file, format, false)
+		asserts.NoError(err)
+		w, err := writer.Create("sub/1.txt", 5, time.Now())
+		asserts.NoError(err)
+		_, err = w.Write([]byte("hello"))
+		asserts.NoError(err)
+		_, err = writer.Create("2.txt", 0, time.Now())
+		asserts.NoError(err)
+		asserts.NoError(writer.Close())
+		file.Close()
+
+		// 判斷格式
+		content, err := ioutil.ReadFile(filePath)
+		asserts.NoError(err)
+		asserts.Equal(format, DetectArchiveFormat("archive", content))
+
+		// 讀取
+		reader, err := openArchive(filePath, format)
+		asserts.NoError(err, format)
+		entry, err := reader.Next()
+		asserts.NoError(err, format)
+		asserts.Equal("sub/1.txt", filepath.ToSlash(entry.Name))
+		asserts.EqualValues(5, entry.Size)
+		asserts.False(entry.IsDir)
+		asserts.False(entry.NonUTF8)
+		rc, err := entry.Open()
+		asserts.NoError(err)
+		content, err = ioutil.ReadAll(rc)
+		asserts.NoError(err)
+		asserts.Equal("hello", string(content))
+		rc.Close()
+
+		entry, err = reader.Next()
+		asserts.NoError(err, format)
+		asserts.Equal("2.txt", entry.Name)
+
+		_, err = reader.Next()
+		asserts.Equal(io.EOF, err, format)
+
+		// 重新讀取
+		asserts.NoError(reader.Reset(), format)
+		entry, err = reader.Next()
+		asserts.NoError(err, format)
+		asserts.Equal("sub/1.txt", filepath.ToSlash(entry.Name))
+		asserts.NoError(reader.Close())
+	}
+
+	// 未知格式
+	{
+		_, err := newArchiveWriter(ioutil.Discard, "rar", false)
+		asserts.Equal(ErrUnknownArchiveFormat, err)
+		_, err = openArchive(filepath.Join(dir, "archive.zip"), "rar")
+		asserts.Error(err)
+		_, err = openArchive(filepath.Join(dir, "not_exist"), ArchiveTarGz)
+		asserts.Error(err)
+	}
+}
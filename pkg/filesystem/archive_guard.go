@@ -0,0 +1,183 @@
+package filesystem
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+)
+
+// ArchiveLimit 解壓縮的安全限制，值為 0 時不限制
+type ArchiveLimit struct {
+	// MaxSize 解壓縮後的總大小
+	MaxSize uint64
+	// MaxEntries 條目數量
+	MaxEntries int
+	// MaxDepth 條目路徑的層數
+	MaxDepth int
+	// MaxRatio 解壓縮後的總大小與壓縮文件大小之比
+	MaxRatio uint64
+}
+
+// archiveLimitFromSettings 從設定中讀取解壓縮的安全限制
+func archiveLimitFromSettings() ArchiveLimit {
+	maxSize, _ := strconv.ParseUint(model.GetSettingByName("decompress_max_size"), 10, 64)
+	return ArchiveLimit{
+		MaxSize:    maxSize,
+		MaxEntries: model.GetIntSetting("decompress_max_entries", 10000),
+		MaxDepth:   model.GetIntSetting("decompress_max_depth", 32),
+		MaxRatio:   uint64(model.GetIntSetting("decompress_max_ratio", 100)),
+	}
+}
+
+// archiveEntryPath 將解碼後的條目名稱轉換為目標目錄下的相對路徑，
+// 名稱為絕對路徑或超出目標目錄時返回錯誤
+func archiveEntryPath(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(slashed, "/") || (len(slashed) >= 2 && slashed[1] == ':') {
+		return "", fmt.Errorf("%w: 條目 %q 為絕對路徑", ErrUnsafeArchive, name)
+	}
+
+	clean := path.Clean(slashed)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: 條目 %q 超出目標目錄", ErrUnsafeArchive, name)
+	}
+	return clean, nil
+}
+
+// checkArchive 在解壓縮前檢查壓縮文件中被選中的條目，返回宣告的解壓縮後總大小。
+// selected 為 nil 時檢查所有條目。檢查後需要呼叫 Reset 才能再次讀取條目
+func checkArchive(r archiveReader, decodeName func(string) string, selected func(string) bool,
+	limit ArchiveLimit, archiveSize uint64) (uint64, error) {
+	var (
+		total   uint64
+		entries int
+	)
+
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		name := f.Name
+		if f.NonUTF8 {
+			name = decodeName(name)
+		}
+		entryPath, err := archiveEntryPath(name)
+		if selected != nil && (err != nil || !selected(entryPath)) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		entries++
+		if limit.MaxEntries > 0 && entries > limit.MaxEntries {
+			return 0, fmt.Errorf("%w: 條目數量超過 %d", ErrUnsafeArchive, limit.MaxEntries)
+		}
+		if limit.MaxDepth > 0 && strings.Count(entryPath, "/")+1 > limit.MaxDepth {
+			return 0, fmt.Errorf("%w: 條目 %q 的目錄層數超過 %d", ErrUnsafeArchive, name, limit.MaxDepth)
+		}
+
+		if f.IsDir {
+			continue
+		}
+		if f.Size < 0 {
+			return 0, fmt.Errorf("%w: 條目 %q 的大小無效", ErrUnsafeArchive, name)
+		}
+		total += uint64(f.Size)
+		if limit.MaxSize > 0 && total > limit.MaxSize {
+			return 0, fmt.Errorf("%w: 解壓縮後的大小超過 %d 位元組", ErrUnsafeArchive, limit.MaxSize)
+		}
+	}
+
+	if limit.MaxRatio > 0 && archiveSize > 0 && total/archiveSize > limit.MaxRatio {
+		return 0, fmt.Errorf("%w: 壓縮率超過 %d 倍", ErrUnsafeArchive, limit.MaxRatio)
+	}
+
+	return total, nil
+}
+
+// selectArchiveEntries 返回判斷條目是否被選中的函式，選中目錄時包含其下所有條目，
+// entries 為空時返回 nil
+func selectArchiveEntries(entries []string) func(entryPath string) bool {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	selected := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entryPath, err := archiveEntryPath(entry); err == nil && entryPath != "." {
+			selected = append(selected, entryPath)
+		}
+	}
+
+	return func(entryPath string) bool {
+		for _, prefix := range selected {
+			if entryPath == prefix || strings.HasPrefix(entryPath, prefix+"/") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// archiveGuard 解壓縮時限制實際讀取的位元組數，防止條目內容超出其宣告的大小
+type archiveGuard struct {
+	maxSize uint64
+	read    uint64
+
+	mu  sync.Mutex
+	err error
+}
+
+// Err 返回讀取時遇到的第一個超出限制的錯誤
+func (g *archiveGuard) Err() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
+
+func (g *archiveGuard) fail(err error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err == nil {
+		g.err = err
+	}
+	return err
+}
+
+// wrap 限制條目內容的讀取
+func (g *archiveGuard) wrap(rc io.ReadCloser, name string, size int64) io.ReadCloser {
+	return &guardedReader{ReadCloser: rc, guard: g, name: name, remaining: size}
+}
+
+type guardedReader struct {
+	io.ReadCloser
+	guard     *archiveGuard
+	name      string
+	remaining int64
+}
+
+func (r *guardedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	read := atomic.AddUint64(&r.guard.read, uint64(n))
+
+	if r.remaining < 0 {
+		return n, r.guard.fail(fmt.Errorf("%w: 條目 %q 的實際大小超出宣告的大小", ErrUnsafeArchive, r.name))
+	}
+	if r.guard.maxSize > 0 && read > r.guard.maxSize {
+		return n, r.guard.fail(fmt.Errorf("%w: 解壓縮後的大小超過 %d 位元組", ErrUnsafeArchive, r.guard.maxSize))
+	}
+	return n, err
+}
@@ -0,0 +1,85 @@
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveEntryPath(t *testing.T) {
+	asserts := assert.New(t)
+
+	testCases := []struct {
+		name     string
+		expected string
+		unsafe   bool
+	}{
+		{"a/b.txt", "a/b.txt", false},
+		{"a\\b.txt", "a/b.txt", false},
+		{"a/../b.txt", "b.txt", false},
+		{"./a/", "a", false},
+		{"../b.txt", "", true},
+		{"a/../../b.txt", "", true},
+		{"..\\..\\b.txt", "", true},
+		{"/etc/passwd", "", true},
+		{"C:\\Windows\\b.txt", "", true},
+	}
+
+	for _, testCase := range testCases {
+		res, err := archiveEntryPath(testCase.name)
+		if testCase.unsafe {
+			asserts.True(errors.Is(err, ErrUnsafeArchive), testCase.name)
+			continue
+		}
+		asserts.NoError(err, testCase.name)
+		asserts.Equal(testCase.expected, res, testCase.name)
+	}
+}
+
+func TestSelectArchiveEntries(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.Nil(selectArchiveEntries(nil))
+
+	selected := selectArchiveEntries([]string{"dir/", "a\\b.txt", "../evil", "."})
+	asserts.True(selected("dir"))
+	asserts.True(selected("dir/sub/1.txt"))
+	asserts.True(selected("a/b.txt"))
+	asserts.False(selected("dir2/1.txt"))
+	asserts.False(selected("a/b.txt.bak"))
+	asserts.False(selected("evil"))
+}
+
+func TestArchiveGuard(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 未超出限制
+	{
+		guard := &archiveGuard{maxSize: 10}
+		content, err := ioutil.ReadAll(guard.wrap(ioutil.NopCloser(bytes.NewReader([]byte("12345"))), "a", 5))
+		asserts.NoError(err)
+		asserts.Equal("12345", string(content))
+		asserts.NoError(guard.Err())
+	}
+
+	// 實際大小超出宣告的大小
+	{
+		guard := &archiveGuard{}
+		_, err := ioutil.ReadAll(guard.wrap(ioutil.NopCloser(bytes.NewReader([]byte("12345"))), "a", 2))
+		asserts.True(errors.Is(err, ErrUnsafeArchive))
+		asserts.Equal(err, guard.Err())
+	}
+
+	// 總大小超出限制
+	{
+		guard := &archiveGuard{maxSize: 8}
+		_, err := ioutil.ReadAll(guard.wrap(ioutil.NopCloser(bytes.NewReader([]byte("12345"))), "a", 5))
+		asserts.NoError(err)
+		_, err = ioutil.ReadAll(guard.wrap(ioutil.NopCloser(bytes.NewReader([]byte("12345"))), "b", 5))
+		asserts.True(errors.Is(err, ErrUnsafeArchive))
+		asserts.Error(guard.Err())
+	}
+}
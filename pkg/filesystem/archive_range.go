@@ -0,0 +1,155 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+)
+
+const (
+	// rangeBlockSize 每次範圍請求讀取的位元組數
+	rangeBlockSize = 256 << 10
+	// rangeCacheBlocks 快取的區塊數量
+	rangeCacheBlocks = 16
+)
+
+// rangeReadPolicies 下載網址支援 HTTP 範圍請求的儲存策略類型
+var rangeReadPolicies = map[string]bool{
+	"remote":   true,
+	"oss":      true,
+	"qiniu":    true,
+	"upyun":    true,
+	"cos":      true,
+	"s3":       true,
+	"onedrive": true,
+}
+
+// rangeReader 以 HTTP 範圍請求按區塊讀取遠端文件，實現 io.ReaderAt
+type rangeReader struct {
+	ctx    context.Context
+	client request.Client
+	url    string
+	size   int64
+
+	mu     sync.Mutex
+	blocks map[int64][]byte
+	order  []int64
+}
+
+// newRangeReader 建立遠端文件的範圍讀取器，並確認伺服器支援範圍請求
+func newRangeReader(ctx context.Context, client request.Client, target string, size int64) (*rangeReader, error) {
+	r := &rangeReader{
+		ctx:    ctx,
+		client: client,
+		url:    target,
+		size:   size,
+		blocks: make(map[int64][]byte, rangeCacheBlocks),
+	}
+
+	if size > 0 {
+		if _, err := r.block(0); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// ReadAt 實現 io.ReaderAt，可並行呼叫
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("無效的讀取位置 %d", off)
+	}
+
+	n := 0
+	for n < len(p) && off+int64(n) < r.size {
+		pos := off + int64(n)
+		block, err := r.block(pos / rangeBlockSize)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], block[pos%rangeBlockSize:])
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// block 返回第 index 個區塊的內容，未快取時發送範圍請求
+func (r *rangeReader) block(index int64) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if block, ok := r.blocks[index]; ok {
+		return block, nil
+	}
+
+	start := index * rangeBlockSize
+	end := start + rangeBlockSize
+	if end > r.size {
+		end = r.size
+	}
+
+	resp := r.client.Request(
+		"GET",
+		r.url,
+		nil,
+		request.WithContext(r.ctx),
+		request.WithTimeout(time.Duration(0)),
+		request.WithHeader(http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", start, end-1)}}),
+	)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	defer resp.Response.Body.Close()
+
+	if resp.Response.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("伺服器不支援範圍請求，返回HTTP狀態%d", resp.Response.StatusCode)
+	}
+
+	block := make([]byte, end-start)
+	if _, err := io.ReadFull(resp.Response.Body, block); err != nil {
+		return nil, err
+	}
+
+	// 淘汰最早讀取的區塊
+	if len(r.order) >= rangeCacheBlocks {
+		delete(r.blocks, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.blocks[index] = block
+	r.order = append(r.order, index)
+
+	return block, nil
+}
+
+// targetRangeReader 為目前的目標文件建立範圍讀取器，儲存策略不支援時返回錯誤
+func (fs *FileSystem) targetRangeReader(ctx context.Context) (*rangeReader, error) {
+	// 加密的文件無法直接按範圍讀取
+	if !rangeReadPolicies[fs.Policy.Type] || fs.Policy.OptionsSerialized.Encrypt {
+		return nil, fmt.Errorf("儲存策略 %q 不支援範圍讀取", fs.Policy.Type)
+	}
+
+	file := fs.FileTarget[0]
+	sourceURL, err := fs.Handler.Source(
+		ctx,
+		file.SourceName,
+		url.URL{},
+		int64(model.GetIntSetting("preview_timeout", 60)),
+		false,
+		0,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRangeReader(ctx, request.GeneralClient, sourceURL, int64(file.Size))
+}
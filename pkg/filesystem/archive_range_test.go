@@ -0,0 +1,130 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func TestRangeReader(t *testing.T) {
+	asserts := assert.New(t)
+
+	content := make([]byte, rangeBlockSize*3+100)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.ServeContent(w, r, "1.bin", time.Now(), bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	r, err := newRangeReader(context.Background(), request.HTTPClient{}, server.URL, int64(len(content)))
+	asserts.NoError(err)
+
+	// 跨區塊讀取
+	{
+		buf := make([]byte, 200)
+		n, err := r.ReadAt(buf, rangeBlockSize-100)
+		asserts.NoError(err)
+		asserts.Equal(200, n)
+		asserts.Equal(content[rangeBlockSize-100:rangeBlockSize+100], buf)
+	}
+
+	// 讀取快取的區塊
+	{
+		before := atomic.LoadInt32(&requests)
+		buf := make([]byte, 10)
+		_, err := r.ReadAt(buf, 5)
+		asserts.NoError(err)
+		asserts.Equal(content[5:15], buf)
+		asserts.Equal(before, atomic.LoadInt32(&requests))
+	}
+
+	// 讀取到結尾
+	{
+		buf := make([]byte, 200)
+		n, err := r.ReadAt(buf, int64(len(content))-50)
+		asserts.Error(err)
+		asserts.Equal(50, n)
+		asserts.Equal(content[len(content)-50:], buf[:n])
+	}
+
+	// 無效的位置
+	{
+		_, err := r.ReadAt(make([]byte, 1), -1)
+		asserts.Error(err)
+	}
+}
+
+func TestRangeReader_NotSupported(t *testing.T) {
+	asserts := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	_, err := newRangeReader(context.Background(), request.HTTPClient{}, server.URL, 7)
+	asserts.Error(err)
+}
+
+func TestFileSystem_targetRangeReader(t *testing.T) {
+	asserts := assert.New(t)
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	w, err := writer.Create("1.txt")
+	asserts.NoError(err)
+	w.Write([]byte("hello"))
+	asserts.NoError(writer.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "1.zip", time.Now(), bytes.NewReader(buf.Bytes()))
+	}))
+	defer server.Close()
+
+	fs := &FileSystem{User: &model.User{}}
+	fs.FileTarget = []model.File{{Name: "1.zip", SourceName: "1.zip", Size: uint64(buf.Len())}}
+
+	// 儲存策略不支援
+	{
+		fs.Policy = &model.Policy{Type: "local"}
+		_, err := fs.targetRangeReader(context.Background())
+		asserts.Error(err)
+	}
+
+	// 成功
+	{
+		asserts.NoError(cache.Set("setting_preview_timeout", "60", 0))
+		fs.Policy = &model.Policy{Type: "s3"}
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Source", testMock.Anything, "1.zip", url.URL{}, testMock.Anything, false, 0).
+			Return(server.URL, nil)
+		fs.Handler = testHandler
+
+		r, err := fs.targetRangeReader(context.Background())
+		asserts.NoError(err)
+		reader, err := zip.NewReader(r, int64(buf.Len()))
+		asserts.NoError(err)
+		rc, err := reader.File[0].Open()
+		asserts.NoError(err)
+		content, err := ioutil.ReadAll(rc)
+		asserts.NoError(err)
+		asserts.Equal("hello", string(content))
+	}
+}
@@ -1,9 +1,11 @@
 package filesystem
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
+	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
@@ -59,7 +61,7 @@ func TestFileSystem_Compress(t *testing.T) {
 		// 尋找上傳策略
 		asserts.NoError(cache.Set("policy_1", model.Policy{Type: "local"}, -1))
 
-		zipFile, err := fs.Compress(ctx, []uint{1}, []uint{1}, true)
+		zipFile, err := fs.Compress(ctx, []uint{1}, []uint{1}, ArchiveZip, true)
 		asserts.NoError(err)
 		asserts.NotEmpty(zipFile)
 		asserts.Contains(zipFile, "archive_")
@@ -84,7 +86,7 @@ func TestFileSystem_Compress(t *testing.T) {
 			)
 		asserts.NoError(cache.Set("setting_temp_path", "tests", -1))
 
-		zipFile, err := fs.Compress(ctx, []uint{1}, []uint{1}, true)
+		zipFile, err := fs.Compress(ctx, []uint{1}, []uint{1}, ArchiveZip, true)
 		asserts.Error(err)
 		asserts.Empty(zipFile)
 	}
@@ -108,7 +110,7 @@ func TestFileSystem_Compress(t *testing.T) {
 			)
 		asserts.NoError(cache.Set("setting_temp_path", "tests", -1))
 
-		zipFile, err := fs.Compress(ctx, []uint{1}, []uint{1}, true)
+		zipFile, err := fs.Compress(ctx, []uint{1}, []uint{1}, ArchiveZip, true)
 		asserts.Error(err)
 		asserts.Equal(ErrObjectNotExist, err)
 		asserts.Empty(zipFile)
@@ -161,7 +163,7 @@ func TestFileSystem_Decompress(t *testing.T) {
 		// 尋找壓縮文件，未找到
 		mock.ExpectQuery("SELECT(.+)files(.+)").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
-		err := fs.Decompress(ctx, "/1.zip", "/")
+		err := fs.Decompress(ctx, "/1.zip", "/", "", nil)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Error(err)
 	}
@@ -173,7 +175,7 @@ func TestFileSystem_Decompress(t *testing.T) {
 		testHandler := new(FileHeaderMock)
 		testHandler.On("Get", testMock.Anything, "1.zip").Return(request.NopRSCloser{}, errors.New("error"))
 		fs.Handler = testHandler
-		err := fs.Decompress(ctx, "/1.zip", "/")
+		err := fs.Decompress(ctx, "/1.zip", "/", "", nil)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Error(err)
 		asserts.EqualError(err, "error")
@@ -187,7 +189,7 @@ func TestFileSystem_Decompress(t *testing.T) {
 		testHandler := new(FileHeaderMock)
 		testHandler.On("Get", testMock.Anything, "1.zip").Return(request.NopRSCloser{}, nil)
 		fs.Handler = testHandler
-		err := fs.Decompress(ctx, "/1.zip", "/")
+		err := fs.Decompress(ctx, "/1.zip", "/", "", nil)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Error(err)
 	}
@@ -200,7 +202,7 @@ func TestFileSystem_Decompress(t *testing.T) {
 		testHandler := new(FileHeaderMock)
 		testHandler.On("Get", testMock.Anything, "1.zip").Return(MockNopRSC("1"), nil)
 		fs.Handler = testHandler
-		err := fs.Decompress(ctx, "/1.zip", "/")
+		err := fs.Decompress(ctx, "/1.zip", "/", "", nil)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Error(err)
 		asserts.EqualError(err, "read error")
@@ -214,7 +216,7 @@ func TestFileSystem_Decompress(t *testing.T) {
 		testHandler := new(FileHeaderMock)
 		testHandler.On("Get", testMock.Anything, "1.zip").Return(MockRSC{rs: strings.NewReader("read")}, nil)
 		fs.Handler = testHandler
-		err := fs.Decompress(ctx, "/1.zip", "/")
+		err := fs.Decompress(ctx, "/1.zip", "/", "", nil)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Error(err)
 		asserts.EqualError(err, "zip: not a valid zip file")
@@ -228,7 +230,7 @@ func TestFileSystem_Decompress(t *testing.T) {
 		testHandler := new(FileHeaderMock)
 		testHandler.On("Get", testMock.Anything, "1.zip").Return(zipFile, nil)
 		fs.Handler = testHandler
-		err := fs.Decompress(ctx, "/1.zip", "/")
+		err := fs.Decompress(ctx, "/1.zip", "/", "", nil)
 		zipFile.Close()
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Error(err)
@@ -246,7 +248,7 @@ func TestFileSystem_Decompress(t *testing.T) {
 		testHandler.On("Get", testMock.Anything, "1.zip").Return(zipFile, nil)
 		fs.Handler = testHandler
 
-		fs.Decompress(ctx, "/1.zip", "/")
+		fs.Decompress(ctx, "/1.zip", "/", "", nil)
 
 		zipFile.Close()
 
@@ -254,3 +256,45 @@ func TestFileSystem_Decompress(t *testing.T) {
 		testHandler.AssertExpectations(t)
 	}
 }
+
+func TestFileSystem_WriteArchive(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	files := []model.File{
+		{Name: "1.txt", SourceName: "1.txt", Size: 5, Position: "sub", PolicyID: 1},
+		{Name: "2.txt", SourceName: "2.txt", Size: 10, PolicyID: 1},
+	}
+	asserts.NoError(cache.Set("policy_1", model.Policy{Type: "mock"}, -1))
+	defer cache.Deletes([]string{"1"}, "policy_")
+
+	for _, format := range []string{ArchiveZip, ArchiveTar} {
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "1.txt").Return(MockRSC{rs: strings.NewReader("hello")}, nil)
+		// 內容短於記錄的大小
+		testHandler.On("Get", testMock.Anything, "2.txt").Return(MockRSC{rs: strings.NewReader("world")}, nil)
+		fs := FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}, Handler: testHandler}
+
+		var buf bytes.Buffer
+		asserts.NoError(fs.WriteArchive(ctx, &buf, files, format, true))
+		testHandler.AssertExpectations(t)
+
+		// 預先計算的大小與實際一致
+		size, err := ArchiveSize(files, format)
+		asserts.NoError(err)
+		asserts.EqualValues(buf.Len(), size, format)
+	}
+
+	// 壓縮格式無法預先計算大小
+	{
+		_, err := ArchiveSize(files, ArchiveTarGz)
+		asserts.Equal(ErrUnknownArchiveFormat, err)
+	}
+
+	// 上下文取消
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		fs := FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+		asserts.Equal(ErrClientCanceled, fs.WriteArchive(ctx, ioutil.Discard, files, ArchiveZip, true))
+	}
+}
@@ -0,0 +1,255 @@
+package filesystem
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ================
+	 分片上傳相關
+   ================
+*/
+
+// DefaultChunkSize 未配置時使用的分片大小
+const DefaultChunkSize = 10 << 20
+
+// ChunkTempPath 返回分片上傳工作階段的暫存目錄，key 為空時返回所有工作階段的上級目錄
+func ChunkTempPath(key string) string {
+	// 從機模式沒有資料庫設定，使用預設的暫存目錄
+	tempPath := "temp"
+	if conf.SystemConfig.Mode == "master" {
+		tempPath = model.GetSettingByName("temp_path")
+	}
+	return filepath.Join(util.RelativePath(tempPath), "chunks", key)
+}
+
+// chunkFile 返回分片的暫存路徑
+func chunkFile(key string, index int) string {
+	return filepath.Join(ChunkTempPath(key), strconv.Itoa(index))
+}
+
+// chunkSessionTTL 返回分片上傳工作階段的有效期
+func chunkSessionTTL() int {
+	if conf.SystemConfig.Mode == "master" {
+		return model.GetIntSetting("upload_session_timeout", 86400)
+	}
+	return 86400
+}
+
+// NewChunkSession 建立分片上傳工作階段，未指定分片大小時使用系統設定
+func NewChunkSession(session serializer.UploadSession) (*serializer.UploadSession, error) {
+	session.Key = util.RandStringRunes(32)
+	if session.ChunkSize == 0 {
+		session.ChunkSize = DefaultChunkSize
+		if conf.SystemConfig.Mode == "master" {
+			session.ChunkSize = uint64(model.GetIntSetting("upload_chunk_size", DefaultChunkSize))
+		}
+	}
+
+	if err := os.MkdirAll(ChunkTempPath(session.Key), 0700); err != nil {
+		return nil, ErrIO.WithError(err)
+	}
+
+	if err := cache.Set("chunk_"+session.Key, session, chunkSessionTTL()); err != nil {
+		return nil, serializer.NewError(serializer.CodeCacheOperation, "無法建立上傳工作階段", err)
+	}
+
+	// 記錄使用者的工作階段，用於預留容量
+	if session.UID != 0 {
+		keys := []string{session.Key}
+		for _, active := range ChunkSessionsOfUser(session.UID) {
+			keys = append(keys, active.Key)
+		}
+		if err := cache.Set(chunkUserKey(session.UID), keys, chunkSessionTTL()); err != nil {
+			util.Log().Warning("無法記錄使用者的上傳工作階段，%s", err)
+		}
+	}
+
+	return &session, nil
+}
+
+// chunkUserKey 返回記錄使用者分片上傳工作階段的快取鍵
+func chunkUserKey(uid uint) string {
+	return "chunk_user_" + strconv.FormatUint(uint64(uid), 10)
+}
+
+// ChunkSessionsOfUser 列出使用者尚未完成且未過期的分片上傳工作階段
+func ChunkSessionsOfUser(uid uint) []*serializer.UploadSession {
+	keysRaw, ok := cache.Get(chunkUserKey(uid))
+	if !ok {
+		return nil
+	}
+
+	keys, _ := keysRaw.([]string)
+	res := make([]*serializer.UploadSession, 0, len(keys))
+	for _, key := range keys {
+		if session, err := GetChunkSession(key); err == nil {
+			res = append(res, session)
+		}
+	}
+	return res
+}
+
+// PendingChunkSize 返回使用者尚未完成的分片上傳工作階段的總大小。
+// 暫存分片不計入已用容量，建立新工作階段時需為這些工作階段預留容量
+func PendingChunkSize(uid uint) uint64 {
+	var total uint64
+	for _, session := range ChunkSessionsOfUser(uid) {
+		total += session.Size
+	}
+	return total
+}
+
+// GetChunkSession 根據ID獲取分片上傳工作階段
+func GetChunkSession(key string) (*serializer.UploadSession, error) {
+	sessionRaw, ok := cache.Get("chunk_" + key)
+	if !ok {
+		return nil, ErrUploadSessionNotExist
+	}
+	session := sessionRaw.(serializer.UploadSession)
+	return &session, nil
+}
+
+// DeleteChunkSession 刪除分片上傳工作階段及其暫存分片
+func DeleteChunkSession(session *serializer.UploadSession) {
+	_ = cache.Deletes([]string{session.Key}, "chunk_")
+	if err := os.RemoveAll(ChunkTempPath(session.Key)); err != nil {
+		util.Log().Warning("無法刪除分片暫存目錄，%s", err)
+	}
+}
+
+// ChunkCount 返回工作階段的分片總數
+func ChunkCount(session *serializer.UploadSession) int {
+	if session.ChunkSize == 0 {
+		return 0
+	}
+	return int((session.Size + session.ChunkSize - 1) / session.ChunkSize)
+}
+
+// chunkLength 返回給定序號分片的預期大小
+func chunkLength(session *serializer.UploadSession, index int) uint64 {
+	if index == ChunkCount(session)-1 {
+		return session.Size - session.ChunkSize*uint64(index)
+	}
+	return session.ChunkSize
+}
+
+// ChunkStatus 返回分片上傳工作階段的狀態，包括已完成的分片序號
+func ChunkStatus(session *serializer.UploadSession) serializer.ChunkUploadStatus {
+	status := serializer.ChunkUploadStatus{
+		SessionID:  session.Key,
+		ChunkSize:  session.ChunkSize,
+		ChunkCount: ChunkCount(session),
+		Uploaded:   make([]int, 0),
+	}
+
+	for i := 0; i < status.ChunkCount; i++ {
+		if util.Exists(chunkFile(session.Key, i)) {
+			status.Uploaded = append(status.Uploaded, i)
+		}
+	}
+	sort.Ints(status.Uploaded)
+
+	return status
+}
+
+// SaveChunk 儲存序號為 index 的分片，分片先寫入暫存檔，
+// 完整接收後才會被標記為已完成
+func SaveChunk(session *serializer.UploadSession, index int, chunk io.Reader) error {
+	if index < 0 || index >= ChunkCount(session) {
+		return ErrChunkIndexOutOfRange
+	}
+
+	expected := chunkLength(session, index)
+	dst := chunkFile(session.Key, index)
+	tempFile := dst + "." + util.RandStringRunes(8) + ".part"
+
+	out, err := util.CreatNestedFile(tempFile)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	// 多讀取一個字元組，用於判斷分片是否超出預期大小
+	written, err := io.Copy(out, io.LimitReader(chunk, int64(expected)+1))
+	out.Close()
+	if err != nil {
+		os.Remove(tempFile)
+		return ErrIO.WithError(err)
+	}
+
+	if uint64(written) != expected {
+		os.Remove(tempFile)
+		return ErrChunkSizeMismatch
+	}
+
+	if err := os.Rename(tempFile, dst); err != nil {
+		os.Remove(tempFile)
+		return ErrIO.WithError(err)
+	}
+
+	return nil
+}
+
+// chunkReader 按順序讀取所有分片
+type chunkReader struct {
+	io.Reader
+	files []*os.File
+}
+
+// Close 關閉所有分片文件
+func (r *chunkReader) Close() error {
+	for _, file := range r.files {
+		file.Close()
+	}
+	return nil
+}
+
+// OpenChunks 打開工作階段的所有分片，返回拼接後的文件流
+func OpenChunks(session *serializer.UploadSession) (io.ReadCloser, error) {
+	count := ChunkCount(session)
+	reader := &chunkReader{files: make([]*os.File, 0, count)}
+	readers := make([]io.Reader, 0, count)
+
+	for i := 0; i < count; i++ {
+		file, err := os.Open(chunkFile(session.Key, i))
+		if err != nil {
+			reader.Close()
+			return nil, ErrChunkMissing.WithError(err)
+		}
+		reader.files = append(reader.files, file)
+		readers = append(readers, file)
+	}
+
+	reader.Reader = io.MultiReader(readers...)
+	return reader, nil
+}
+
+// CollectChunkSessions 清理已過期工作階段遺留的暫存分片
+func CollectChunkSessions() {
+	root := ChunkTempPath("")
+	dirs, err := ioutil.ReadDir(root)
+	if err != nil {
+		return
+	}
+
+	for _, dir := range dirs {
+		if _, ok := cache.Get("chunk_" + dir.Name()); ok {
+			continue
+		}
+
+		util.Log().Debug("刪除過期的分片暫存目錄 [%s]", dir.Name())
+		if err := os.RemoveAll(filepath.Join(root, dir.Name())); err != nil {
+			util.Log().Debug("分片暫存目錄 [%s] 刪除失敗 , %s", dir.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkSession(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.NoError(cache.Set("setting_temp_path", "tests", 0))
+	asserts.NoError(cache.Set("setting_upload_session_timeout", "60", 0))
+	defer os.RemoveAll(util.RelativePath("tests/chunks"))
+
+	session, err := NewChunkSession(serializer.UploadSession{
+		UID:       1,
+		Name:      "1.txt",
+		Size:      10,
+		ChunkSize: 4,
+	})
+	asserts.NoError(err)
+	asserts.Len(session.Key, 32)
+	asserts.Equal(3, ChunkCount(session))
+
+	// 獲取工作階段
+	{
+		res, err := GetChunkSession(session.Key)
+		asserts.NoError(err)
+		asserts.Equal(*session, *res)
+
+		_, err = GetChunkSession("not_exist")
+		asserts.Equal(ErrUploadSessionNotExist, err)
+	}
+
+	// 序號超出範圍
+	asserts.Equal(ErrChunkIndexOutOfRange, SaveChunk(session, 3, strings.NewReader("1234")))
+	asserts.Equal(ErrChunkIndexOutOfRange, SaveChunk(session, -1, strings.NewReader("1234")))
+
+	// 分片大小不符
+	asserts.Equal(ErrChunkSizeMismatch, SaveChunk(session, 0, strings.NewReader("123")))
+	asserts.Equal(ErrChunkSizeMismatch, SaveChunk(session, 0, strings.NewReader("12345")))
+	asserts.Equal(ErrChunkSizeMismatch, SaveChunk(session, 2, strings.NewReader("1234")))
+	asserts.Empty(ChunkStatus(session).Uploaded)
+
+	// 分片未齊全
+	asserts.NoError(SaveChunk(session, 2, strings.NewReader("90")))
+	asserts.NoError(SaveChunk(session, 0, strings.NewReader("1234")))
+	asserts.Equal([]int{0, 2}, ChunkStatus(session).Uploaded)
+	_, err = OpenChunks(session)
+	asserts.Error(err)
+
+	// 合併分片
+	{
+		asserts.NoError(SaveChunk(session, 1, strings.NewReader("5678")))
+		status := ChunkStatus(session)
+		asserts.Equal([]int{0, 1, 2}, status.Uploaded)
+		asserts.Equal(3, status.ChunkCount)
+		asserts.EqualValues(4, status.ChunkSize)
+
+		reader, err := OpenChunks(session)
+		asserts.NoError(err)
+		content, err := ioutil.ReadAll(reader)
+		asserts.NoError(err)
+		asserts.Equal("1234567890", string(content))
+		asserts.NoError(reader.Close())
+	}
+
+	// 刪除工作階段
+	DeleteChunkSession(session)
+	_, err = GetChunkSession(session.Key)
+	asserts.Error(err)
+	asserts.False(util.Exists(ChunkTempPath(session.Key)))
+}
+
+func TestPendingChunkSize(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.NoError(cache.Set("setting_temp_path", "tests", 0))
+	asserts.NoError(cache.Set("setting_upload_session_timeout", "60", 0))
+	defer os.RemoveAll(util.RelativePath("tests/chunks"))
+
+	asserts.EqualValues(0, PendingChunkSize(2))
+
+	first, err := NewChunkSession(serializer.UploadSession{UID: 2, Size: 10})
+	asserts.NoError(err)
+	_, err = NewChunkSession(serializer.UploadSession{UID: 2, Size: 20})
+	asserts.NoError(err)
+	_, err = NewChunkSession(serializer.UploadSession{UID: 3, Size: 40})
+	asserts.NoError(err)
+	asserts.Len(ChunkSessionsOfUser(2), 2)
+	asserts.EqualValues(30, PendingChunkSize(2))
+
+	// 已結束的工作階段不再佔用容量
+	DeleteChunkSession(first)
+	asserts.EqualValues(20, PendingChunkSize(2))
+	asserts.EqualValues(40, PendingChunkSize(3))
+}
+
+func TestChunkCount(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal(0, ChunkCount(&serializer.UploadSession{Size: 0, ChunkSize: 4}))
+	asserts.Equal(1, ChunkCount(&serializer.UploadSession{Size: 4, ChunkSize: 4}))
+	asserts.Equal(2, ChunkCount(&serializer.UploadSession{Size: 5, ChunkSize: 4}))
+	asserts.Equal(0, ChunkCount(&serializer.UploadSession{Size: 5}))
+}
+
+func TestCollectChunkSessions(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.NoError(cache.Set("setting_temp_path", "tests", 0))
+	defer os.RemoveAll(util.RelativePath("tests/chunks"))
+
+	session, err := NewChunkSession(serializer.UploadSession{Size: 1, ChunkSize: 1})
+	asserts.NoError(err)
+	asserts.NoError(os.MkdirAll(ChunkTempPath("expired"), 0700))
+
+	CollectChunkSessions()
+	asserts.True(util.Exists(ChunkTempPath(session.Key)))
+	asserts.False(util.Exists(ChunkTempPath("expired")))
+}
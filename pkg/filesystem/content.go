@@ -0,0 +1,140 @@
+package filesystem
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/fulltext"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// contentJob 全文索引任務，remove 不為空時刪除索引，否則重新索引文件
+type contentJob struct {
+	fileID uint
+	remove []uint
+}
+
+var (
+	contentQueue     = make(chan contentJob, 1000)
+	contentQueueOnce sync.Once
+)
+
+// HookIndexContent 將目標文件加入全文索引佇列
+func HookIndexContent(ctx context.Context, fs *FileSystem) error {
+	IndexContent(fs.FileTarget...)
+	return nil
+}
+
+// IndexContent 將文件加入全文索引佇列，文件內容會在背景提取
+func IndexContent(files ...model.File) {
+	if !fulltext.IsEnabled() {
+		return
+	}
+
+	for _, file := range files {
+		submitContentJob(contentJob{fileID: file.ID})
+	}
+}
+
+// RemoveContentIndex 刪除文件的全文索引
+func RemoveContentIndex(ids ...uint) {
+	indexed := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if fulltext.Default.Contains(id) {
+			indexed = append(indexed, id)
+		}
+	}
+
+	if len(indexed) > 0 {
+		submitContentJob(contentJob{remove: indexed})
+	}
+}
+
+// submitContentJob 提交索引任務，佇列已滿時不阻塞呼叫方
+func submitContentJob(job contentJob) {
+	contentQueueOnce.Do(func() {
+		go contentWorker()
+	})
+
+	select {
+	case contentQueue <- job:
+	default:
+		go func() {
+			contentQueue <- job
+		}()
+	}
+}
+
+// contentWorker 依序處理索引任務，佇列清空後持久化索引
+func contentWorker() {
+	for job := range contentQueue {
+		if len(job.remove) > 0 {
+			fulltext.Default.Remove(job.remove...)
+		} else if err := indexFileContent(job.fileID); err != nil {
+			util.Log().Warning("無法索引文件 [%d] 的內容，%s", job.fileID, err)
+		}
+
+		if len(contentQueue) == 0 {
+			if err := fulltext.Default.Save(); err != nil {
+				util.Log().Warning("無法儲存全文索引，%s", err)
+			}
+		}
+	}
+}
+
+// indexFileContent 提取並索引單個文件的內容，文件不存在或不支援時刪除其索引
+func indexFileContent(id uint) error {
+	files, err := model.GetFilesByIDs([]uint{id}, 0)
+	if err != nil {
+		return err
+	}
+
+	maxSize := uint64(model.GetIntSetting("fulltext_max_size", 20<<20))
+	if len(files) == 0 || !fulltext.Supported(files[0].Name) || files[0].Size > maxSize {
+		fulltext.Default.Remove(id)
+		return nil
+	}
+	file := files[0]
+
+	user, err := model.GetUserByID(file.UserID)
+	if err != nil {
+		return err
+	}
+
+	fs, err := NewFileSystem(&user)
+	if err != nil {
+		return err
+	}
+	defer fs.Recycle()
+
+	// 切換到文件所在的儲存策略
+	fs.Policy = file.GetPolicy()
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+	rs, err := fs.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(rs, int64(maxSize)))
+	if err != nil {
+		return err
+	}
+
+	text, err := fulltext.Extract(file.Name, content)
+	if err != nil {
+		fulltext.Default.Remove(id)
+		return err
+	}
+
+	fulltext.Default.Add(file.ID, file.UserID, text)
+	return nil
+}
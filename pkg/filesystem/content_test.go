@@ -0,0 +1,79 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/fulltext"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHookIndexContent(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{FileTarget: []model.File{{Model: gorm.Model{ID: 1}}}}
+
+	// 未啟用全文索引
+	asserts.NoError(cache.Set("setting_fulltext_enabled", "0", 0))
+	asserts.NoError(HookIndexContent(context.Background(), fs))
+	asserts.Len(contentQueue, 0)
+}
+
+func TestRemoveContentIndex(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 未索引的文件不提交任務
+	RemoveContentIndex(1, 2)
+	asserts.Len(contentQueue, 0)
+}
+
+func TestIndexFileContent(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.NoError(cache.Set("setting_fulltext_max_size", "10", 0))
+
+	// 文件不存在，刪除索引
+	{
+		fulltext.Default.Add(1, 1, "hello")
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		asserts.NoError(indexFileContent(1))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.False(fulltext.Default.Contains(1))
+	}
+
+	// 不支援的類型
+	{
+		fulltext.Default.Add(1, 1, "hello")
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "size"}).AddRow(1, "a.png", 1))
+		asserts.NoError(indexFileContent(1))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.False(fulltext.Default.Contains(1))
+	}
+
+	// 文件過大
+	{
+		fulltext.Default.Add(1, 1, "hello")
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "size"}).AddRow(1, "a.txt", 11))
+		asserts.NoError(indexFileContent(1))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.False(fulltext.Default.Contains(1))
+	}
+
+	// 資料庫錯誤
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1).
+			WillReturnError(errors.New("error"))
+		asserts.Error(indexFileContent(1))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
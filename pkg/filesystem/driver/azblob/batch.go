@@ -0,0 +1,139 @@
+package azblob
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// maxBatchSize 每個批次請求最多包含的子請求數
+const maxBatchSize = 256
+
+// batchDelete 以 Blob Batch 請求刪除文件，返回未刪除的文件。
+// 伺服器不支援批次請求時逐個刪除
+func (handler Driver) batchDelete(ctx context.Context, files []string) ([]string, error) {
+	boundary := "batch_" + util.RandStringRunes(32)
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return files, err
+	}
+
+	// 生成子請求
+	for i, file := range files {
+		target, err := handler.blobURL(file)
+		if err != nil {
+			return files, err
+		}
+
+		header := http.Header{}
+		if err := handler.authorize("DELETE", target, header, 0); err != nil {
+			return files, err
+		}
+
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"application/http"},
+			"Content-Transfer-Encoding": {"binary"},
+			"Content-Id":                {strconv.Itoa(i)},
+		})
+		if err != nil {
+			return files, err
+		}
+		fmt.Fprintf(part, "DELETE %s HTTP/1.1\r\n", target.EscapedPath())
+		for _, name := range []string{"x-ms-date", "x-ms-version", "Authorization"} {
+			fmt.Fprintf(part, "%s: %s\r\n", name, header.Get(name))
+		}
+		fmt.Fprint(part, "Content-Length: 0\r\n\r\n")
+	}
+	if err := writer.Close(); err != nil {
+		return files, err
+	}
+
+	// 發送批次請求
+	target, err := url.Parse(Endpoint(handler.Policy) + "/?comp=batch")
+	if err != nil {
+		return files, err
+	}
+	resp, err := handler.request(
+		ctx,
+		"POST",
+		target,
+		bytes.NewReader(body.Bytes()),
+		int64(body.Len()),
+		http.Header{"Content-Type": {"multipart/mixed; boundary=" + boundary}},
+		http.StatusAccepted,
+	)
+	if err != nil {
+		util.Log().Debug("Azure Blob 批次刪除失敗，將逐個刪除：%s", err)
+		return handler.deleteEach(ctx, files)
+	}
+	defer resp.Body.Close()
+
+	return parseBatchResponse(resp, files)
+}
+
+// parseBatchResponse 解析批次請求的響應，返回未刪除的文件
+func parseBatchResponse(resp *http.Response, files []string) ([]string, error) {
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return files, err
+	}
+
+	deleted := make(map[int]bool, len(files))
+	reader := multipart.NewReader(resp.Body, params["boundary"])
+	for i := 0; ; i++ {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+
+		index := i
+		if id, err := strconv.Atoi(part.Header.Get("Content-Id")); err == nil {
+			index = id
+		}
+
+		subResp, err := http.ReadResponse(bufio.NewReader(part), nil)
+		if err != nil {
+			continue
+		}
+		subResp.Body.Close()
+
+		// 文件不存在視為刪除成功
+		if subResp.StatusCode == http.StatusAccepted || subResp.StatusCode == http.StatusNotFound {
+			deleted[index] = true
+		}
+	}
+
+	failed := make([]string, 0, len(files))
+	for i, file := range files {
+		if !deleted[i] {
+			failed = append(failed, file)
+		}
+	}
+	if len(failed) > 0 {
+		return failed, fmt.Errorf("%d 個文件無法刪除", len(failed))
+	}
+	return failed, nil
+}
+
+// deleteEach 逐個刪除文件，返回未刪除的文件
+func (handler Driver) deleteEach(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var retErr error
+	for _, file := range files {
+		if err := handler.deleteBlob(ctx, file); err != nil {
+			retErr = err
+			failed = append(failed, file)
+		}
+	}
+	return failed, retErr
+}
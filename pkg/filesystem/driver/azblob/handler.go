@@ -0,0 +1,482 @@
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+)
+
+// blockSize 分塊上傳時每個塊的大小，不超過此大小的文件直接上傳
+const blockSize = 8 * 1024 * 1024
+
+var (
+	// ErrInvalidAccountKey 帳戶金鑰格式錯誤
+	ErrInvalidAccountKey = errors.New("無法解析 Azure 儲存帳戶金鑰，須為 Base64 編碼")
+	// ErrFileExisted 物理同名文件已存在
+	ErrFileExisted = errors.New("物理同名文件已存在")
+)
+
+// Driver Azure Blob Storage 適配器。
+// AccessKey 為儲存帳戶名稱，SecretKey 為帳戶金鑰，BucketName 為容器名稱，
+// Server 為 Blob 服務地址，為空時使用 Azure 公有雲地址
+type Driver struct {
+	Policy     *model.Policy
+	HTTPClient request.Client
+}
+
+// accountKey 解碼帳戶金鑰
+func (handler Driver) accountKey() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(handler.Policy.SecretKey)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidAccountKey
+	}
+	return key, nil
+}
+
+// Endpoint 返回 Blob 服務地址
+func Endpoint(policy *model.Policy) string {
+	if policy.Server == "" {
+		return fmt.Sprintf("https://%s.blob.core.windows.net", policy.AccessKey)
+	}
+	return strings.TrimSuffix(policy.Server, "/")
+}
+
+// containerURL 返回容器的URL
+func (handler Driver) containerURL() (*url.URL, error) {
+	return url.Parse(Endpoint(handler.Policy) + "/" + handler.Policy.BucketName)
+}
+
+// blobURL 返回 Blob 的URL
+func (handler Driver) blobURL(name string) (*url.URL, error) {
+	target, err := handler.containerURL()
+	if err != nil {
+		return nil, err
+	}
+	target.Path = path.Join(target.Path, name)
+	target.RawPath = ""
+	return target, nil
+}
+
+// request 發送經 Shared Key 認證的請求，狀態碼不在 expected 中時返回 RespError
+func (handler Driver) request(
+	ctx context.Context,
+	method string,
+	target *url.URL,
+	body io.Reader,
+	contentLength int64,
+	header http.Header,
+	expected ...int,
+) (*http.Response, error) {
+	if header == nil {
+		header = http.Header{}
+	}
+	if err := handler.authorize(method, target, header, contentLength); err != nil {
+		return nil, err
+	}
+
+	res := handler.HTTPClient.Request(
+		method,
+		target.String(),
+		body,
+		request.WithHeader(header),
+		request.WithContentLength(contentLength),
+		request.WithContext(ctx),
+		request.WithTimeout(0),
+	)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+
+	for _, status := range expected {
+		if res.Response.StatusCode == status {
+			return res.Response, nil
+		}
+	}
+
+	// 解析錯誤訊息
+	defer res.Response.Body.Close()
+	respErr := RespError{StatusCode: res.Response.StatusCode}
+	respBody, _ := ioutil.ReadAll(res.Response.Body)
+	_ = xml.Unmarshal(respBody, &respErr)
+	if respErr.Code == "" {
+		respErr.Code = res.Response.Header.Get("x-ms-error-code")
+	}
+	return nil, respErr
+}
+
+// List 列出給定路徑下的文件
+func (handler Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	// 初始化列目錄參數
+	base = strings.TrimPrefix(base, "/")
+	if base != "" {
+		base += "/"
+	}
+
+	var (
+		blobs    []blobItem
+		prefixes []blobPrefix
+		marker   string
+	)
+
+	for {
+		target, err := handler.containerURL()
+		if err != nil {
+			return nil, err
+		}
+		query := url.Values{
+			"restype":    {"container"},
+			"comp":       {"list"},
+			"prefix":     {base},
+			"maxresults": {"1000"},
+		}
+		// 是否為遞迴列出
+		if !recursive {
+			query.Set("delimiter", "/")
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		target.RawQuery = query.Encode()
+
+		resp, err := handler.request(ctx, "GET", target, nil, 0, nil, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+
+		var res listResult
+		err = xml.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, res.Blobs...)
+		prefixes = append(prefixes, res.Prefixes...)
+
+		// 如果本次未列取完，則繼續使用marker獲取結果
+		if res.NextMarker == "" {
+			break
+		}
+		marker = res.NextMarker
+	}
+
+	// 處理列取結果
+	res := make([]response.Object, 0, len(blobs)+len(prefixes))
+
+	// 處理目錄
+	for _, prefix := range prefixes {
+		rel := strings.TrimSuffix(strings.TrimPrefix(prefix.Name, base), "/")
+		res = append(res, response.Object{
+			Name:         path.Base(rel),
+			RelativePath: rel,
+			Size:         0,
+			IsDir:        true,
+			LastModify:   time.Now(),
+		})
+	}
+	// 處理文件
+	for _, blob := range blobs {
+		lastModify, err := http.ParseTime(blob.Properties.LastModified)
+		if err != nil {
+			lastModify = time.Now()
+		}
+		res = append(res, response.Object{
+			Name:         path.Base(blob.Name),
+			Source:       blob.Name,
+			RelativePath: strings.TrimPrefix(blob.Name, base),
+			Size:         blob.Properties.ContentLength,
+			IsDir:        false,
+			LastModify:   lastModify,
+		})
+	}
+
+	return res, nil
+}
+
+// Get 獲取文件
+func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	// 獲取文件源地址，不使用自訂的加速域名
+	downloadURL, err := handler.signedURL(
+		path,
+		int64(model.GetIntSetting("preview_timeout", 60)),
+		"",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// 獲取文件資料流
+	resp, err := handler.HTTPClient.Request(
+		"GET",
+		downloadURL.String(),
+		nil,
+		request.WithContext(ctx),
+		request.WithHeader(
+			http.Header{"Cache-Control": {"no-cache", "no-store", "must-revalidate"}},
+		),
+		request.WithTimeout(time.Duration(0)),
+	).CheckHTTPResponse(200).GetRSCloser()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.SetFirstFakeChunk()
+
+	// 嘗試自主獲取檔案大小
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		resp.SetContentLength(int64(file.Size))
+	}
+
+	return resp, nil
+}
+
+// Put 將文件流儲存到指定目錄，超過 blockSize 的文件分塊上傳
+func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	defer file.Close()
+
+	target, err := handler.blobURL(dst)
+	if err != nil {
+		return err
+	}
+
+	// 如果禁止了 Overwrite，則不能覆蓋同名文件
+	header := http.Header{}
+	if ctx.Value(fsctx.DisableOverwrite) != nil {
+		header.Set("If-None-Match", "*")
+	}
+
+	if size <= blockSize {
+		header.Set("x-ms-blob-type", "BlockBlob")
+		resp, err := handler.request(ctx, "PUT", target, file, int64(size), header, http.StatusCreated)
+		return handler.putResult(resp, err)
+	}
+
+	// 分塊上傳
+	var (
+		blocks = blockList{}
+		buf    = make([]byte, blockSize)
+	)
+	for index, remain := 0, size; remain > 0; index++ {
+		chunk := buf
+		if remain < blockSize {
+			chunk = buf[:remain]
+		}
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			return err
+		}
+
+		blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", index)))
+		blockURL := *target
+		blockURL.RawQuery = url.Values{"comp": {"block"}, "blockid": {blockID}}.Encode()
+		resp, err := handler.request(ctx, "PUT", &blockURL, bytes.NewReader(chunk), int64(len(chunk)), nil, http.StatusCreated)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		blocks.Latest = append(blocks.Latest, blockID)
+		remain -= uint64(len(chunk))
+	}
+
+	// 提交塊列表
+	body, err := xml.Marshal(blocks)
+	if err != nil {
+		return err
+	}
+	body = append([]byte(xml.Header), body...)
+	listURL := *target
+	listURL.RawQuery = url.Values{"comp": {"blocklist"}}.Encode()
+	header.Set("Content-Type", "application/xml")
+	resp, err := handler.request(ctx, "PUT", &listURL, bytes.NewReader(body), int64(len(body)), header, http.StatusCreated)
+	return handler.putResult(resp, err)
+}
+
+// putResult 處理上傳請求的結果
+func (handler Driver) putResult(resp *http.Response, err error) error {
+	if respErr, ok := err.(RespError); ok && respErr.StatusCode == http.StatusConflict && respErr.Code == "BlobAlreadyExists" {
+		return ErrFileExisted
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Delete 刪除一個或多個文件，
+// 返回未刪除的文件，及遇到的最後一個錯誤
+func (handler Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var retErr error
+
+	for start := 0; start < len(files); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(files) {
+			end = len(files)
+		}
+
+		batchFailed, err := handler.batchDelete(ctx, files[start:end])
+		if err != nil {
+			retErr = err
+		}
+		failed = append(failed, batchFailed...)
+	}
+
+	return failed, retErr
+}
+
+// deleteBlob 刪除單個 Blob，不存在時視為成功
+func (handler Driver) deleteBlob(ctx context.Context, name string) error {
+	target, err := handler.blobURL(name)
+	if err != nil {
+		return err
+	}
+	resp, err := handler.request(ctx, "DELETE", target, nil, 0, nil, http.StatusAccepted, http.StatusNotFound)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Thumb 獲取文件縮圖
+func (handler Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	return nil, errors.New("未實現")
+}
+
+// signedURL 生成帶有讀取權限 SAS 的文件URL
+func (handler Driver) signedURL(path string, ttl int64, disposition string) (*url.URL, error) {
+	target, err := handler.blobURL(path)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := handler.sas(path, sasOptions{
+		permissions: "r",
+		expiry:      time.Now().Add(time.Duration(ttl) * time.Second),
+		disposition: disposition,
+	})
+	if err != nil {
+		return nil, err
+	}
+	target.RawQuery = query.Encode()
+	return target, nil
+}
+
+// Source 獲取外鏈URL
+func (handler Driver) Source(
+	ctx context.Context,
+	path string,
+	baseURL url.URL,
+	ttl int64,
+	isDownload bool,
+	speed int,
+) (string, error) {
+	// 嘗試從上下文獲取檔案名
+	disposition := ""
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok && isDownload {
+		disposition = "attachment; filename=\"" + url.PathEscape(file.Name) + "\""
+	}
+
+	if ttl == 0 {
+		ttl = 3600
+	}
+
+	finalURL, err := handler.signedURL(path, ttl, disposition)
+	if err != nil {
+		return "", err
+	}
+
+	// 公有容器不需要簽名
+	if !handler.Policy.IsPrivate {
+		finalURL.RawQuery = ""
+	}
+
+	// 將最終生成的簽名URL域名換成使用者自訂的加速域名（如果有）
+	if handler.Policy.BaseURL != "" {
+		cdnURL, err := url.Parse(handler.Policy.BaseURL)
+		if err != nil {
+			return "", err
+		}
+		finalURL.Host = cdnURL.Host
+		finalURL.Scheme = cdnURL.Scheme
+	}
+
+	return finalURL.String(), nil
+}
+
+// Token 獲取用戶端直傳使用的 SAS，用戶端上傳完成後請求回調地址
+func (handler Driver) Token(ctx context.Context, TTL int64, key string) (serializer.UploadCredential, error) {
+	// 讀取上下文中生成的儲存路徑
+	savePath, ok := ctx.Value(fsctx.SavePathCtx).(string)
+	if !ok {
+		return serializer.UploadCredential{}, errors.New("無法獲取儲存路徑")
+	}
+
+	// 生成回調地址
+	siteURL := model.GetSiteURL()
+	apiBaseURI, _ := url.Parse("/api/v3/callback/azblob/" + key)
+	apiURL := siteURL.ResolveReference(apiBaseURI)
+
+	// 生成建立、寫入權限的 SAS
+	target, err := handler.blobURL(savePath)
+	if err != nil {
+		return serializer.UploadCredential{}, err
+	}
+	query, err := handler.sas(savePath, sasOptions{
+		permissions: "cw",
+		expiry:      time.Now().Add(time.Duration(TTL) * time.Second),
+	})
+	if err != nil {
+		return serializer.UploadCredential{}, err
+	}
+	target.RawQuery = query.Encode()
+
+	return serializer.UploadCredential{
+		Policy:   target.String(),
+		Token:    query.Encode(),
+		Path:     savePath,
+		Callback: apiURL.String(),
+		Key:      key,
+	}, nil
+}
+
+// Meta 獲取文件訊息
+func (handler Driver) Meta(ctx context.Context, path string) (*MetaData, error) {
+	target, err := handler.blobURL(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := handler.request(ctx, "HEAD", target, nil, 0, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	size, err := strconv.ParseUint(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetaData{
+		Size: size,
+		Etag: resp.Header.Get("ETag"),
+	}, nil
+}
@@ -0,0 +1,512 @@
+package azblob
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAccount = "devstoreaccount1"
+
+var testKey = base64.StdEncoding.EncodeToString([]byte("cloudreve-test-account-key"))
+
+// fakeBlobService 模擬 Blob 服務，驗證 Shared Key 及 SAS 簽名
+type fakeBlobService struct {
+	handler Driver
+
+	mu     sync.Mutex
+	blobs  map[string][]byte
+	blocks map[string][]byte
+	// methods 收到的請求
+	methods []string
+	// noBatch 不支援批次請求
+	noBatch bool
+}
+
+func newTestDriver(t *testing.T) (Driver, *fakeBlobService, *httptest.Server) {
+	service := &fakeBlobService{
+		blobs:  make(map[string][]byte),
+		blocks: make(map[string][]byte),
+	}
+	server := httptest.NewServer(service)
+	handler := Driver{
+		Policy: &model.Policy{
+			Type:       "azblob",
+			Server:     server.URL + "/" + testAccount,
+			BucketName: "container",
+			AccessKey:  testAccount,
+			SecretKey:  testKey,
+			IsPrivate:  true,
+		},
+		HTTPClient: request.HTTPClient{},
+	}
+	service.handler = handler
+	return handler, service, server
+}
+
+func (service *fakeBlobService) writeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+// authorized 驗證請求的 Shared Key 簽名
+func (service *fakeBlobService) authorized(r *http.Request) bool {
+	key, _ := service.handler.accountKey()
+	expected := "SharedKey " + testAccount + ":" +
+		sign(key, sharedKeyString(testAccount, r.Method, r.URL, r.Header, r.ContentLength))
+	return r.Header.Get("Authorization") == expected && r.Header.Get("x-ms-version") == serviceVersion
+}
+
+// sasAuthorized 驗證請求的 SAS 簽名
+func (service *fakeBlobService) sasAuthorized(r *http.Request, name string) bool {
+	query := r.URL.Query()
+	expiry, err := time.Parse(sasTimeFormat, query.Get("se"))
+	if err != nil || expiry.Before(time.Now()) {
+		return false
+	}
+	expected, _ := service.handler.sas(name, sasOptions{
+		permissions: query.Get("sp"),
+		expiry:      expiry,
+		disposition: query.Get("rscd"),
+	})
+	return query.Get("sig") == expected.Get("sig")
+}
+
+func (service *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	query := r.URL.Query()
+	service.methods = append(service.methods, strings.TrimSpace(r.Method+" "+query.Get("comp")))
+	name := strings.TrimPrefix(r.URL.Path, "/"+testAccount+"/container/")
+
+	if query.Get("sig") != "" {
+		if r.Method != "GET" || !service.sasAuthorized(r, name) {
+			service.writeError(w, http.StatusForbidden, "AuthenticationFailed")
+			return
+		}
+		content, ok := service.blobs[name]
+		if !ok {
+			service.writeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		w.Write(content)
+		return
+	}
+
+	if !service.authorized(r) {
+		service.writeError(w, http.StatusForbidden, "AuthenticationFailed")
+		return
+	}
+
+	switch {
+	case r.Method == "POST" && query.Get("comp") == "batch":
+		service.batch(w, r)
+	case r.Method == "GET" && query.Get("comp") == "list":
+		service.list(w, query)
+	case r.Method == "PUT" && query.Get("comp") == "block":
+		content, _ := ioutil.ReadAll(r.Body)
+		service.blocks[name+"/"+query.Get("blockid")] = content
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PUT":
+		if _, ok := service.blobs[name]; ok && r.Header.Get("If-None-Match") == "*" {
+			service.writeError(w, http.StatusConflict, "BlobAlreadyExists")
+			return
+		}
+		content, _ := ioutil.ReadAll(r.Body)
+		if query.Get("comp") == "blocklist" {
+			var blocks blockList
+			xml.Unmarshal(content, &blocks)
+			content = nil
+			for _, id := range blocks.Latest {
+				content = append(content, service.blocks[name+"/"+id]...)
+			}
+		} else if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+			service.writeError(w, http.StatusBadRequest, "MissingRequiredHeader")
+			return
+		}
+		service.blobs[name] = content
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "HEAD":
+		content, ok := service.blobs[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == "DELETE":
+		if _, ok := service.blobs[name]; !ok {
+			service.writeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(service.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		service.writeError(w, http.StatusBadRequest, "UnsupportedHttpVerb")
+	}
+}
+
+// list 列取 Blob，每頁最多返回兩項以測試分頁
+func (service *fakeBlobService) list(w http.ResponseWriter, query url.Values) {
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	names := make([]string, 0, len(service.blobs))
+	seen := make(map[string]bool)
+	for name := range service.blobs {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				name = name[:len(prefix)+i+1]
+			}
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	start, _ := strconv.Atoi(query.Get("marker"))
+	end := start + 2
+	res := listResult{}
+	if end < len(names) {
+		res.NextMarker = strconv.Itoa(end)
+	} else {
+		end = len(names)
+	}
+	for _, name := range names[start:end] {
+		if strings.HasSuffix(name, "/") {
+			res.Prefixes = append(res.Prefixes, blobPrefix{Name: name})
+			continue
+		}
+		res.Blobs = append(res.Blobs, blobItem{Name: name, Properties: blobProperties{
+			LastModified:  "Mon, 02 Jan 2006 15:04:05 GMT",
+			ContentLength: uint64(len(service.blobs[name])),
+		}})
+	}
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(res)
+}
+
+// batch 處理批次刪除請求
+func (service *fakeBlobService) batch(w http.ResponseWriter, r *http.Request) {
+	if service.noBatch {
+		service.writeError(w, http.StatusBadRequest, "InvalidQueryParameterValue")
+		return
+	}
+
+	_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	res := &bytes.Buffer{}
+	writer := multipart.NewWriter(res)
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		id := part.Header.Get("Content-Id")
+		subReq, err := http.ReadRequest(bufio.NewReader(part))
+		if err != nil {
+			continue
+		}
+
+		status := "202 Accepted"
+		name := strings.TrimPrefix(subReq.URL.Path, "/"+testAccount+"/container/")
+		if subReq.Method != "DELETE" || !service.authorized(subReq) {
+			status = "403 Forbidden"
+		} else if _, ok := service.blobs[name]; !ok {
+			status = "404 Not Found"
+		} else if name == "locked.txt" {
+			status = "409 Conflict"
+		} else {
+			delete(service.blobs, name)
+		}
+
+		subResp, _ := writer.CreatePart(map[string][]string{
+			"Content-Type": {"application/http"},
+			"Content-Id":   {id},
+		})
+		fmt.Fprintf(subResp, "HTTP/1.1 %s\r\nx-ms-version: %s\r\n\r\n", status, serviceVersion)
+	}
+	writer.Close()
+
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(res.Bytes())
+}
+
+// takeMethods 返回收到的請求，並清空記錄
+func (service *fakeBlobService) takeMethods() []string {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	res := service.methods
+	service.methods = nil
+	return res
+}
+
+func TestSharedKeyString(t *testing.T) {
+	asserts := assert.New(t)
+	target, _ := url.Parse("https://account.blob.core.windows.net/container/dir/%E6%96%87%E4%BB%B6.txt?comp=block&blockid=MDA%3D")
+	header := http.Header{
+		"X-Ms-Version":  {serviceVersion},
+		"X-Ms-Date":     {"Mon, 02 Jan 2006 15:04:05 GMT"},
+		"If-None-Match": {"*"},
+		"Content-Type":  {"application/xml"},
+	}
+
+	asserts.Equal("PUT\n\n\n10\n\napplication/xml\n\n\n\n*\n\n\n"+
+		"x-ms-date:Mon, 02 Jan 2006 15:04:05 GMT\nx-ms-version:2019-12-12\n"+
+		"/account/container/dir/%E6%96%87%E4%BB%B6.txt\nblockid:MDA=\ncomp:block",
+		sharedKeyString("account", "PUT", target, header, 10))
+
+	// 正文為空時 Content-Length 留空
+	target, _ = url.Parse("https://account.blob.core.windows.net/container/1.txt")
+	asserts.Equal("GET\n\n\n\n\n\n\n\n\n\n\n\n/account/container/1.txt",
+		sharedKeyString("account", "GET", target, http.Header{}, 0))
+}
+
+func TestDriver_PutGet(t *testing.T) {
+	asserts := assert.New(t)
+	handler, service, server := newTestDriver(t)
+	defer server.Close()
+	ctx := context.Background()
+	cache.Set("setting_preview_timeout", "60", 0)
+
+	// 小文件直接上傳
+	{
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("hello")), "1/文件 1.txt", 5)
+		asserts.NoError(err)
+		asserts.Equal("hello", string(service.blobs["1/文件 1.txt"]))
+		asserts.Equal([]string{"PUT"}, service.takeMethods())
+	}
+
+	// 大文件分塊上傳
+	{
+		content := bytes.Repeat([]byte("a"), blockSize*2+10)
+		err := handler.Put(ctx, ioutil.NopCloser(bytes.NewReader(content)), "1/large.bin", uint64(len(content)))
+		asserts.NoError(err)
+		asserts.Equal(content, service.blobs["1/large.bin"])
+		asserts.Equal([]string{"PUT block", "PUT block", "PUT block", "PUT blocklist"}, service.takeMethods())
+	}
+
+	// 禁止覆蓋時不能覆蓋同名文件
+	{
+		overwriteCtx := context.WithValue(ctx, fsctx.DisableOverwrite, true)
+		err := handler.Put(overwriteCtx, ioutil.NopCloser(strings.NewReader("world")), "1/文件 1.txt", 5)
+		asserts.Equal(ErrFileExisted, err)
+		asserts.Equal("hello", string(service.blobs["1/文件 1.txt"]))
+	}
+
+	// 上傳大小與宣告不符
+	{
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("hi")), "1/short.bin", blockSize+1)
+		asserts.Error(err)
+	}
+
+	// 經由 SAS 讀取
+	{
+		getCtx := context.WithValue(ctx, fsctx.FileModelCtx, model.File{Size: 5})
+		rs, err := handler.Get(getCtx, "1/文件 1.txt")
+		asserts.NoError(err)
+		rs.Seek(0, io.SeekStart)
+		content, err := ioutil.ReadAll(rs)
+		asserts.NoError(err)
+		asserts.Equal("hello", string(content))
+		rs.Close()
+	}
+
+	// 文件不存在
+	{
+		_, err := handler.Get(ctx, "not_exist.txt")
+		asserts.Error(err)
+	}
+
+	// 文件訊息
+	{
+		meta, err := handler.Meta(ctx, "1/文件 1.txt")
+		asserts.NoError(err)
+		asserts.EqualValues(5, meta.Size)
+		asserts.Equal(`"etag"`, meta.Etag)
+
+		_, err = handler.Meta(ctx, "not_exist.txt")
+		asserts.Error(err)
+	}
+
+	// 帳戶金鑰無效
+	{
+		handler.Policy.SecretKey = "!invalid"
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("hello")), "2.txt", 5)
+		asserts.Equal(ErrInvalidAccountKey, err)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	asserts := assert.New(t)
+	handler, service, server := newTestDriver(t)
+	defer server.Close()
+	ctx := context.Background()
+
+	// 批次刪除，不存在的文件視為刪除成功
+	{
+		service.blobs["1.txt"] = []byte("1")
+		service.blobs["2.txt"] = []byte("2")
+		service.blobs["locked.txt"] = []byte("3")
+		failed, err := handler.Delete(ctx, []string{"1.txt", "2.txt", "locked.txt", "not_exist.txt"})
+		asserts.Error(err)
+		asserts.Equal([]string{"locked.txt"}, failed)
+		asserts.Len(service.blobs, 1)
+		asserts.Equal([]string{"POST batch"}, service.takeMethods())
+	}
+
+	// 超出單個批次請求的上限時分多次請求
+	{
+		files := make([]string, maxBatchSize+1)
+		for i := range files {
+			files[i] = fmt.Sprintf("%d.txt", i)
+			service.blobs[files[i]] = []byte("1")
+		}
+		failed, err := handler.Delete(ctx, files)
+		asserts.NoError(err)
+		asserts.Empty(failed)
+		asserts.Len(service.blobs, 1)
+		asserts.Equal([]string{"POST batch", "POST batch"}, service.takeMethods())
+	}
+
+	// 不支援批次請求時逐個刪除
+	{
+		service.noBatch = true
+		service.blobs["1.txt"] = []byte("1")
+		failed, err := handler.Delete(ctx, []string{"1.txt", "not_exist.txt"})
+		asserts.NoError(err)
+		asserts.Empty(failed)
+		asserts.Len(service.blobs, 1)
+		asserts.Equal([]string{"POST batch", "DELETE", "DELETE"}, service.takeMethods())
+	}
+}
+
+func TestDriver_List(t *testing.T) {
+	asserts := assert.New(t)
+	handler, service, server := newTestDriver(t)
+	defer server.Close()
+	ctx := context.Background()
+
+	for _, name := range []string{"import/1.txt", "import/2.txt", "import/sub/3.txt", "other/4.txt"} {
+		service.blobs[name] = []byte(name)
+	}
+
+	// 遞迴列取，分頁獲取全部結果
+	{
+		res, err := handler.List(ctx, "/import", true)
+		asserts.NoError(err)
+		asserts.Len(res, 3)
+		asserts.Equal("sub/3.txt", res[2].RelativePath)
+		asserts.Equal("import/sub/3.txt", res[2].Source)
+		asserts.Equal("3.txt", res[2].Name)
+		asserts.EqualValues(16, res[2].Size)
+		asserts.Equal(2006, res[2].LastModify.Year())
+		asserts.Equal([]string{"GET list", "GET list"}, service.takeMethods())
+	}
+
+	// 非遞迴列取
+	{
+		res, err := handler.List(ctx, "/import", false)
+		asserts.NoError(err)
+		asserts.Len(res, 3)
+		asserts.True(res[0].IsDir)
+		asserts.Equal("sub", res[0].RelativePath)
+		asserts.Equal("sub", res[0].Name)
+	}
+
+	// 根目錄
+	{
+		res, err := handler.List(ctx, "/", false)
+		asserts.NoError(err)
+		asserts.Len(res, 2)
+	}
+}
+
+func TestDriver_SourceAndToken(t *testing.T) {
+	asserts := assert.New(t)
+	handler, _, server := newTestDriver(t)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, model.File{Name: "文件.txt"})
+	cache.Set("setting_siteURL", "https://cloudreve.org", 0)
+
+	// 私有容器，下載時附帶檔案名
+	{
+		sourceURL, err := handler.Source(ctx, "1/文件.txt", url.URL{}, 0, true, 0)
+		asserts.NoError(err)
+		parsed, _ := url.Parse(sourceURL)
+		asserts.Equal("/"+testAccount+"/container/1/文件.txt", parsed.Path)
+		asserts.Equal("r", parsed.Query().Get("sp"))
+		asserts.Contains(parsed.Query().Get("rscd"), "attachment")
+		asserts.NotEmpty(parsed.Query().Get("sig"))
+
+		// SAS 可以被服務端驗證
+		resp, err := http.Get(sourceURL)
+		asserts.NoError(err)
+		asserts.Equal(http.StatusNotFound, resp.StatusCode)
+	}
+
+	// 公有容器使用自訂域名
+	{
+		handler.Policy.IsPrivate = false
+		handler.Policy.BaseURL = "https://cdn.cloudreve.org"
+		sourceURL, err := handler.Source(ctx, "1.txt", url.URL{}, 0, false, 0)
+		asserts.NoError(err)
+		asserts.Equal("https://cdn.cloudreve.org/"+testAccount+"/container/1.txt", sourceURL)
+	}
+
+	// 上傳憑證
+	{
+		_, err := handler.Token(context.Background(), 10, "key")
+		asserts.Error(err)
+
+		tokenCtx := context.WithValue(context.Background(), fsctx.SavePathCtx, "1/文件.txt")
+		credential, err := handler.Token(tokenCtx, 10, "key")
+		asserts.NoError(err)
+		asserts.Equal("https://cloudreve.org/api/v3/callback/azblob/key", credential.Callback)
+		asserts.Equal("1/文件.txt", credential.Path)
+		asserts.Equal("key", credential.Key)
+		token, _ := url.ParseQuery(credential.Token)
+		asserts.Equal("cw", token.Get("sp"))
+		asserts.True(strings.HasPrefix(credential.Policy, server.URL+"/"+testAccount+"/container/1/"))
+	}
+
+	// 縮圖
+	{
+		_, err := handler.Thumb(ctx, "1.txt")
+		asserts.Error(err)
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal("https://account.blob.core.windows.net", Endpoint(&model.Policy{AccessKey: "account"}))
+	asserts.Equal("http://127.0.0.1:10000/devstoreaccount1", Endpoint(&model.Policy{Server: "http://127.0.0.1:10000/devstoreaccount1/"}))
+}
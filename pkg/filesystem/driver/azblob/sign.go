@@ -0,0 +1,154 @@
+package azblob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serviceVersion 請求及 SAS 使用的 Blob 服務 API 版本
+const serviceVersion = "2019-12-12"
+
+// sasTimeFormat SAS 中時間的格式
+const sasTimeFormat = "2006-01-02T15:04:05Z"
+
+// sign 使用帳戶金鑰對字串進行 HMAC-SHA256 簽名
+func sign(key []byte, stringToSign string) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// sharedKeyString 生成 Shared Key 認證的待簽名字串
+func sharedKeyString(account, method string, target *url.URL, header http.Header, contentLength int64) string {
+	length := ""
+	if contentLength > 0 {
+		length = strconv.FormatInt(contentLength, 10)
+	}
+
+	lines := []string{
+		method,
+		header.Get("Content-Encoding"),
+		header.Get("Content-Language"),
+		length,
+		header.Get("Content-MD5"),
+		header.Get("Content-Type"),
+		"", // Date，使用 x-ms-date 代替
+		header.Get("If-Modified-Since"),
+		header.Get("If-Match"),
+		header.Get("If-None-Match"),
+		header.Get("If-Unmodified-Since"),
+		header.Get("Range"),
+	}
+
+	return strings.Join(lines, "\n") + "\n" +
+		canonicalizedHeaders(header) +
+		canonicalizedResource(account, target)
+}
+
+// canonicalizedHeaders 按名稱排序的 x-ms- 標頭
+func canonicalizedHeaders(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	values := make(map[string]string, len(header))
+	for k, v := range header {
+		name := strings.ToLower(strings.TrimSpace(k))
+		if strings.HasPrefix(name, "x-ms-") {
+			keys = append(keys, name)
+			values[name] = strings.Join(v, ",")
+		}
+	}
+	sort.Strings(keys)
+
+	var res strings.Builder
+	for _, k := range keys {
+		res.WriteString(k + ":" + values[k] + "\n")
+	}
+	return res.String()
+}
+
+// canonicalizedResource 帳戶名稱、請求路徑及按名稱排序的查詢參數
+func canonicalizedResource(account string, target *url.URL) string {
+	res := "/" + account + target.EscapedPath()
+	query := target.Query()
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		res += "\n" + strings.ToLower(k) + ":" + strings.Join(values, ",")
+	}
+	return res
+}
+
+// authorize 為請求添加 Shared Key 認證標頭
+func (handler Driver) authorize(method string, target *url.URL, header http.Header, contentLength int64) error {
+	key, err := handler.accountKey()
+	if err != nil {
+		return err
+	}
+
+	header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	header.Set("x-ms-version", serviceVersion)
+	header.Set("Authorization", "SharedKey "+handler.Policy.AccessKey+":"+
+		sign(key, sharedKeyString(handler.Policy.AccessKey, method, target, header, contentLength)))
+	return nil
+}
+
+// sasOptions 生成 SAS 的參數
+type sasOptions struct {
+	// permissions 授予的權限，r 讀取，c 建立，w 寫入
+	permissions string
+	expiry      time.Time
+	// disposition 覆蓋響應的 Content-Disposition
+	disposition string
+}
+
+// sas 生成 Blob 的服務 SAS 查詢參數
+func (handler Driver) sas(name string, opt sasOptions) (url.Values, error) {
+	key, err := handler.accountKey()
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := opt.expiry.UTC().Format(sasTimeFormat)
+	stringToSign := strings.Join([]string{
+		opt.permissions,
+		"", // signedStart
+		expiry,
+		"/blob/" + handler.Policy.AccessKey + "/" + handler.Policy.BucketName + "/" + name,
+		"", // signedIdentifier
+		"", // signedIP
+		"", // signedProtocol
+		serviceVersion,
+		"b", // signedResource
+		"",  // signedSnapshotTime
+		"",  // rscc
+		opt.disposition,
+		"", // rsce
+		"", // rscl
+		"", // rsct
+	}, "\n")
+
+	query := url.Values{
+		"sv":  {serviceVersion},
+		"sr":  {"b"},
+		"sp":  {opt.permissions},
+		"se":  {expiry},
+		"sig": {sign(key, stringToSign)},
+	}
+	if opt.disposition != "" {
+		query.Set("rscd", opt.disposition)
+	}
+	return query, nil
+}
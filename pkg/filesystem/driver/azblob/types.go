@@ -0,0 +1,51 @@
+package azblob
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// RespError Blob 服務返回的錯誤
+type RespError struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (err RespError) Error() string {
+	return fmt.Sprintf("Azure Blob 服務返回錯誤 %d %s：%s", err.StatusCode, err.Code, err.Message)
+}
+
+// MetaData 文件訊息
+type MetaData struct {
+	Size uint64
+	Etag string
+}
+
+// listResult List Blobs 的響應
+type listResult struct {
+	XMLName    xml.Name     `xml:"EnumerationResults"`
+	Blobs      []blobItem   `xml:"Blobs>Blob"`
+	Prefixes   []blobPrefix `xml:"Blobs>BlobPrefix"`
+	NextMarker string       `xml:"NextMarker"`
+}
+
+type blobItem struct {
+	Name       string         `xml:"Name"`
+	Properties blobProperties `xml:"Properties"`
+}
+
+type blobProperties struct {
+	LastModified  string `xml:"Last-Modified"`
+	ContentLength uint64 `xml:"Content-Length"`
+}
+
+type blobPrefix struct {
+	Name string `xml:"Name"`
+}
+
+// blockList Put Block List 的請求正文
+type blockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
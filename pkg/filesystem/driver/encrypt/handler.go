@@ -0,0 +1,124 @@
+package encrypt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+)
+
+// ErrThumbUnsupported 加密的儲存策略不支援縮圖
+var ErrThumbUnsupported = errors.New("加密的儲存策略不支援縮圖")
+
+// Handler 被加密的儲存策略適配器，與 filesystem.Handler 相同
+type Handler interface {
+	Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error
+	Delete(ctx context.Context, files []string) ([]string, error)
+	Get(ctx context.Context, path string) (response.RSCloser, error)
+	Thumb(ctx context.Context, path string) (*response.ContentResponse, error)
+	Source(ctx context.Context, path string, url url.URL, ttl int64, isDownload bool, speed int) (string, error)
+	Token(ctx context.Context, ttl int64, callbackKey string) (serializer.UploadCredential, error)
+	List(ctx context.Context, path string, recursive bool) ([]response.Object, error)
+}
+
+// Driver 加密儲存適配器，上傳時加密文件內容後交給底層適配器儲存，下載時解密。
+// 文件內容須經由伺服器中轉，不提供用戶端直傳憑證和重定向外鏈
+type Driver struct {
+	Policy    *model.Policy
+	Handler   Handler
+	MasterKey []byte
+}
+
+// readCloser 加密後的文件流，關閉時關閉原始文件流
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Put 加密文件流並交給底層適配器儲存
+func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	encrypted, err := NewEncryptReader(file, size, handler.MasterKey)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return handler.Handler.Put(ctx, readCloser{Reader: encrypted, Closer: file}, dst, EncryptedSize(size))
+}
+
+// Delete 刪除一個或多個文件
+func (handler Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	return handler.Handler.Delete(ctx, files)
+}
+
+// Get 獲取解密後的文件流，支援隨機存取
+func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	// 底層適配器看到的是加密後的文件大小
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		file.Size = EncryptedSize(file.Size)
+		ctx = context.WithValue(ctx, fsctx.FileModelCtx, file)
+	}
+
+	rs, err := handler.Handler.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 部分適配器需要先定位一次才能正常讀取
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		rs.Close()
+		return nil, err
+	}
+
+	decrypted, err := NewDecryptReader(rs, handler.MasterKey)
+	if err != nil {
+		rs.Close()
+		return nil, err
+	}
+	return decrypted, nil
+}
+
+// Thumb 加密的文件無法由儲存端生成縮圖
+func (handler Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	return nil, ErrThumbUnsupported
+}
+
+// Source 獲取經由伺服器中轉的外鏈URL，不使用儲存端的CDN
+func (handler Driver) Source(
+	ctx context.Context,
+	path string,
+	baseURL url.URL,
+	ttl int64,
+	isDownload bool,
+	speed int,
+) (string, error) {
+	policy := *handler.Policy
+	policy.BaseURL = ""
+	return local.Driver{Policy: &policy}.Source(ctx, path, baseURL, ttl, isDownload, speed)
+}
+
+// Token 加密的儲存策略須經由伺服器中轉上傳，直接返回空值
+func (handler Driver) Token(ctx context.Context, ttl int64, key string) (serializer.UploadCredential, error) {
+	return serializer.UploadCredential{}, nil
+}
+
+// List 列出底層儲存中的文件，大小換算為明文大小
+func (handler Driver) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	objects, err := handler.Handler.List(ctx, path, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range objects {
+		if size, ok := PlainSize(objects[i].Size); !objects[i].IsDir && ok {
+			objects[i].Size = size
+		}
+	}
+	return objects, nil
+}
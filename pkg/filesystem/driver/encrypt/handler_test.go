@@ -0,0 +1,190 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryHandler 將文件存放在記憶體中的適配器
+type memoryHandler struct {
+	files   map[string][]byte
+	sizes   map[string]uint64
+	getSize uint64
+}
+
+func newMemoryHandler() *memoryHandler {
+	return &memoryHandler{files: make(map[string][]byte), sizes: make(map[string]uint64)}
+}
+
+type nopSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+func (handler *memoryHandler) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	handler.files[dst] = content
+	handler.sizes[dst] = size
+	return nil
+}
+
+func (handler *memoryHandler) Delete(ctx context.Context, files []string) ([]string, error) {
+	for _, file := range files {
+		delete(handler.files, file)
+	}
+	return []string{}, nil
+}
+
+func (handler *memoryHandler) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		handler.getSize = file.Size
+	}
+	content, ok := handler.files[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return nopSeekCloser{bytes.NewReader(content)}, nil
+}
+
+func (handler *memoryHandler) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	return &response.ContentResponse{}, nil
+}
+
+func (handler *memoryHandler) Source(ctx context.Context, path string, url url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	return "https://bucket.example.com/" + path, nil
+}
+
+func (handler *memoryHandler) Token(ctx context.Context, ttl int64, callbackKey string) (serializer.UploadCredential, error) {
+	return serializer.UploadCredential{Token: "direct"}, nil
+}
+
+func (handler *memoryHandler) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	var objects []response.Object
+	for name, content := range handler.files {
+		objects = append(objects, response.Object{Name: name, Size: uint64(len(content))})
+	}
+	return append(objects, response.Object{Name: "dir", IsDir: true}), nil
+}
+
+func TestDriver_PutGet(t *testing.T) {
+	asserts := assert.New(t)
+	inner := newMemoryHandler()
+	handler := Driver{Policy: &model.Policy{}, Handler: inner, MasterKey: testKey}
+	ctx := context.Background()
+	content := strings.Repeat("cloudreve", DefaultChunkSize/4)
+
+	// 上傳時加密
+	{
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader(content)), "1.txt", uint64(len(content)))
+		asserts.NoError(err)
+		asserts.EqualValues(EncryptedSize(uint64(len(content))), inner.sizes["1.txt"])
+		asserts.EqualValues(inner.sizes["1.txt"], len(inner.files["1.txt"]))
+		asserts.NotContains(string(inner.files["1.txt"]), "cloudreve")
+	}
+
+	// 下載時解密，底層適配器得到加密後的大小
+	{
+		file := model.File{Size: uint64(len(content))}
+		rs, err := handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, file), "1.txt")
+		asserts.NoError(err)
+		asserts.EqualValues(EncryptedSize(file.Size), inner.getSize)
+
+		res, err := ioutil.ReadAll(rs)
+		asserts.NoError(err)
+		asserts.Equal(content, string(res))
+
+		rs.Seek(int64(len(content))-9, io.SeekStart)
+		res, err = ioutil.ReadAll(rs)
+		asserts.NoError(err)
+		asserts.Equal("cloudreve", string(res))
+		asserts.NoError(rs.Close())
+	}
+
+	// 不是加密文件
+	{
+		inner.files["plain.txt"] = []byte("plain")
+		_, err := handler.Get(ctx, "plain.txt")
+		asserts.Equal(ErrInvalidHeader, err)
+	}
+
+	// 底層文件不存在
+	{
+		_, err := handler.Get(ctx, "not_exist.txt")
+		asserts.Error(err)
+	}
+
+	// 列出文件時換算為明文大小
+	{
+		objects, err := handler.List(ctx, "/", true)
+		asserts.NoError(err)
+		for _, object := range objects {
+			switch object.Name {
+			case "1.txt":
+				asserts.EqualValues(len(content), object.Size)
+			case "plain.txt":
+				asserts.EqualValues(5, object.Size)
+			case "dir":
+				asserts.True(object.IsDir)
+			}
+		}
+	}
+}
+
+func TestDriver_SourceAndToken(t *testing.T) {
+	asserts := assert.New(t)
+	auth.General = auth.HMACAuth{SecretKey: []byte("test")}
+	handler := Driver{
+		Policy:    &model.Policy{BaseURL: "https://cdn.example.com"},
+		Handler:   newMemoryHandler(),
+		MasterKey: testKey,
+	}
+	file := model.File{Model: gorm.Model{ID: 1}, Name: "1.txt"}
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+	baseURL, _ := url.Parse("https://cloudreve.org")
+
+	// 外鏈經由伺服器中轉，不使用儲存端CDN
+	{
+		sourceURL, err := handler.Source(ctx, "1.txt", *baseURL, 0, false, 0)
+		asserts.NoError(err)
+		asserts.Contains(sourceURL, "https://cloudreve.org/api/v3/file/get/1/1.txt")
+		asserts.Equal("https://cdn.example.com", handler.Policy.BaseURL)
+	}
+
+	// 不提供直傳憑證和縮圖
+	{
+		credential, err := handler.Token(ctx, 10, "key")
+		asserts.NoError(err)
+		asserts.Empty(credential.Token)
+
+		_, err = handler.Thumb(ctx, "1.txt")
+		asserts.Equal(ErrThumbUnsupported, err)
+	}
+
+	// 刪除
+	{
+		failed, err := handler.Delete(ctx, []string{"1.txt"})
+		asserts.NoError(err)
+		asserts.Empty(failed)
+	}
+}
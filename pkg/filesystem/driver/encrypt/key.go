@@ -0,0 +1,41 @@
+package encrypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+var (
+	// ErrMasterKeyNotSet 未設定主金鑰
+	ErrMasterKeyNotSet = errors.New("未設定加密主金鑰，請在配置檔案 [Encryption] 分區中設定 MasterKey 或 MasterKeyFile")
+	// ErrInvalidMasterKey 主金鑰格式錯誤
+	ErrInvalidMasterKey = errors.New("加密主金鑰須為 Base64 編碼的 32 位元組資料")
+)
+
+// LoadMasterKey 按配置檔案讀取主金鑰，MasterKey 優先於 MasterKeyFile
+func LoadMasterKey() ([]byte, error) {
+	encoded := conf.EncryptionConfig.MasterKey
+	if encoded == "" && conf.EncryptionConfig.MasterKeyFile != "" {
+		content, err := ioutil.ReadFile(util.RelativePath(conf.EncryptionConfig.MasterKeyFile))
+		if err != nil {
+			return nil, err
+		}
+		encoded = string(content)
+	}
+
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, ErrMasterKeyNotSet
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != keySize {
+		return nil, ErrInvalidMasterKey
+	}
+	return key, nil
+}
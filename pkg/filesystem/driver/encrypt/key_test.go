@@ -0,0 +1,56 @@
+package encrypt
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMasterKey(t *testing.T) {
+	asserts := assert.New(t)
+	encoded := base64.StdEncoding.EncodeToString(testKey)
+	defer func() { conf.EncryptionConfig.MasterKey, conf.EncryptionConfig.MasterKeyFile = "", "" }()
+
+	// 未設定
+	{
+		_, err := LoadMasterKey()
+		asserts.Equal(ErrMasterKeyNotSet, err)
+	}
+
+	// 配置檔案中設定
+	{
+		conf.EncryptionConfig.MasterKey = encoded
+		key, err := LoadMasterKey()
+		asserts.NoError(err)
+		asserts.Equal(testKey, key)
+	}
+
+	// 格式錯誤
+	{
+		conf.EncryptionConfig.MasterKey = base64.StdEncoding.EncodeToString([]byte("short"))
+		_, err := LoadMasterKey()
+		asserts.Equal(ErrInvalidMasterKey, err)
+		conf.EncryptionConfig.MasterKey = "!"
+		_, err = LoadMasterKey()
+		asserts.Equal(ErrInvalidMasterKey, err)
+	}
+
+	// 從金鑰文件讀取
+	{
+		conf.EncryptionConfig.MasterKey = ""
+		conf.EncryptionConfig.MasterKeyFile = "TestLoadMasterKey.key"
+		_, err := LoadMasterKey()
+		asserts.Error(err)
+
+		asserts.NoError(ioutil.WriteFile(util.RelativePath("TestLoadMasterKey.key"), []byte(encoded+"\n"), 0600))
+		defer os.Remove(util.RelativePath("TestLoadMasterKey.key"))
+		key, err := LoadMasterKey()
+		asserts.NoError(err)
+		asserts.Equal(testKey, key)
+	}
+}
@@ -0,0 +1,347 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+/*
+	加密文件格式：
+
+	| magic (8) | chunkSize (4) | plainSize (8) | keyNonce (12) | wrappedKey (48) | chunk 0 | chunk 1 | ...
+
+	每個文件使用隨機生成的文件金鑰，以主金鑰經 AES-256-GCM 包裝後存放在文件頭，
+	包裝時以文件頭前段作為附加資料，防止分塊大小和文件大小被竄改。
+	正文按 chunkSize 分塊，以文件金鑰經 AES-256-GCM 分別加密，nonce 由分塊序號和
+	是否為最後一塊組成，分塊被重排、截斷時無法通過驗證。各分塊可獨立解密，支援隨機存取。
+*/
+
+const (
+	// magic 加密文件的識別標頭
+	magic = "CREVENC1"
+	// DefaultChunkSize 預設的明文分塊大小
+	DefaultChunkSize = 64 << 10
+	// maxChunkSize 允許的最大分塊大小，避免損壞的文件頭導致分配過多記憶體
+	maxChunkSize = 16 << 20
+	// keySize 主金鑰及文件金鑰的長度
+	keySize = 32
+	// tagSize 每個分塊附加的驗證標籤長度
+	tagSize = 16
+	// nonceSize GCM nonce 長度
+	nonceSize = 12
+	// headerPrefixSize 文件頭中作為金鑰包裝附加資料的部分
+	headerPrefixSize = 8 + 4 + 8
+	// HeaderSize 文件頭長度
+	HeaderSize = headerPrefixSize + nonceSize + keySize + tagSize
+)
+
+var (
+	// ErrInvalidHeader 不是有效的加密文件
+	ErrInvalidHeader = errors.New("不是有效的加密文件")
+	// ErrAuthFailed 金鑰錯誤或密文已被竄改
+	ErrAuthFailed = errors.New("無法解密文件，金鑰錯誤或內容已被竄改")
+	// ErrSizeMismatch 文件流長度與宣告的大小不符
+	ErrSizeMismatch = errors.New("文件流長度與宣告的大小不符")
+)
+
+// chunkCount 返回明文大小對應的分塊數量
+func chunkCount(plainSize uint64, chunkSize uint32) uint64 {
+	return (plainSize + uint64(chunkSize) - 1) / uint64(chunkSize)
+}
+
+// EncryptedSize 返回明文加密後的大小
+func EncryptedSize(plainSize uint64) uint64 {
+	return uint64(HeaderSize) + plainSize + chunkCount(plainSize, DefaultChunkSize)*tagSize
+}
+
+// PlainSize 由加密後的大小推算明文大小，不是有效的加密文件大小時 ok 為 false
+func PlainSize(encryptedSize uint64) (size uint64, ok bool) {
+	if encryptedSize < HeaderSize {
+		return 0, false
+	}
+	body := encryptedSize - HeaderSize
+	chunks := (body + DefaultChunkSize + tagSize - 1) / (DefaultChunkSize + tagSize)
+	if body < chunks*tagSize || (chunks > 0 && body-chunks*tagSize <= (chunks-1)*DefaultChunkSize) {
+		return 0, false
+	}
+	return body - chunks*tagSize, true
+}
+
+// chunkNonce 返回分塊使用的 nonce
+func chunkNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce, index)
+	if last {
+		nonce[nonceSize-1] = 1
+	}
+	return nonce
+}
+
+// newGCM 以給定金鑰建立 AES-256-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptReader 邊讀取明文邊輸出密文的文件流
+type encryptReader struct {
+	src       io.Reader
+	gcm       cipher.AEAD
+	plainSize uint64
+	chunkSize uint32
+	index     uint64
+	chunks    uint64
+	plain     []byte
+	sealed    []byte
+	pending   []byte
+	err       error
+}
+
+// NewEncryptReader 建立加密文件流，src 須恰好提供 size 位元組的明文
+func NewEncryptReader(src io.Reader, size uint64, masterKey []byte) (io.Reader, error) {
+	fileKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, fileKey); err != nil {
+		return nil, err
+	}
+	keyNonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, keyNonce); err != nil {
+		return nil, err
+	}
+
+	master, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerPrefixSize, HeaderSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint32(header[len(magic):], DefaultChunkSize)
+	binary.BigEndian.PutUint64(header[len(magic)+4:], size)
+	header = append(header, keyNonce...)
+	header = master.Seal(header, keyNonce, fileKey, header[:headerPrefixSize])
+
+	return &encryptReader{
+		src:       src,
+		gcm:       gcm,
+		plainSize: size,
+		chunkSize: DefaultChunkSize,
+		chunks:    chunkCount(size, DefaultChunkSize),
+		plain:     make([]byte, DefaultChunkSize),
+		pending:   header,
+	}, nil
+}
+
+// Read 輸出文件頭及已加密的分塊
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.nextChunk()
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// nextChunk 讀取並加密下一個分塊，全部完成後返回 io.EOF
+func (r *encryptReader) nextChunk() error {
+	if r.index == r.chunks {
+		// 明文須恰好為宣告的大小
+		if n, _ := r.src.Read(make([]byte, 1)); n > 0 {
+			return ErrSizeMismatch
+		}
+		return io.EOF
+	}
+
+	length := uint64(r.chunkSize)
+	if rest := r.plainSize - r.index*uint64(r.chunkSize); rest < length {
+		length = rest
+	}
+	if _, err := io.ReadFull(r.src, r.plain[:length]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrSizeMismatch
+		}
+		return err
+	}
+
+	last := r.index == r.chunks-1
+	r.sealed = r.gcm.Seal(r.sealed[:0], chunkNonce(r.index, last), r.plain[:length], nil)
+	r.pending = r.sealed
+	r.index++
+	return nil
+}
+
+// Reader 可隨機存取的解密文件流
+type Reader struct {
+	src       io.ReadSeeker
+	gcm       cipher.AEAD
+	plainSize uint64
+	chunkSize uint32
+	// 明文中的目前位置
+	offset int64
+	// 底層文件流中的目前位置
+	srcOffset int64
+	// 已解密的分塊快取
+	chunk      []byte
+	chunkIndex uint64
+	buffer     []byte
+}
+
+// NewDecryptReader 讀取加密文件的文件頭並建立解密文件流，src 須位於文件開頭
+func NewDecryptReader(src io.ReadSeeker, masterKey []byte) (*Reader, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, ErrInvalidHeader
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, ErrInvalidHeader
+	}
+
+	chunkSize := binary.BigEndian.Uint32(header[len(magic):])
+	if chunkSize == 0 || chunkSize > maxChunkSize {
+		return nil, ErrInvalidHeader
+	}
+
+	master, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	keyNonce := header[headerPrefixSize : headerPrefixSize+nonceSize]
+	fileKey, err := master.Open(nil, keyNonce, header[headerPrefixSize+nonceSize:], header[:headerPrefixSize])
+	if err != nil {
+		return nil, ErrAuthFailed
+	}
+	gcm, err := newGCM(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		src:       src,
+		gcm:       gcm,
+		plainSize: binary.BigEndian.Uint64(header[len(magic)+4:]),
+		chunkSize: chunkSize,
+		srcOffset: HeaderSize,
+	}, nil
+}
+
+// Size 返回明文大小
+func (r *Reader) Size() uint64 {
+	return r.plainSize
+}
+
+// Read 從目前位置讀取明文
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.offset >= int64(r.plainSize) {
+		return 0, io.EOF
+	}
+
+	index := uint64(r.offset) / uint64(r.chunkSize)
+	if r.chunk == nil || r.chunkIndex != index {
+		if err := r.loadChunk(index); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.chunk[uint64(r.offset)-index*uint64(r.chunkSize):])
+	r.offset += int64(n)
+	return n, nil
+}
+
+// Seek 設定明文中的讀取位置，實際定位延遲到下次讀取時進行
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += int64(r.plainSize)
+	default:
+		return 0, errors.New("無效的 whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("無效的定位位置")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Close 關閉底層文件流
+func (r *Reader) Close() error {
+	if closer, ok := r.src.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// loadChunk 讀取並解密給定序號的分塊
+func (r *Reader) loadChunk(index uint64) error {
+	start := int64(HeaderSize) + int64(index)*int64(r.chunkSize+tagSize)
+	if err := r.seekSource(start); err != nil {
+		return err
+	}
+
+	length := uint64(r.chunkSize)
+	if rest := r.plainSize - index*uint64(r.chunkSize); rest < length {
+		length = rest
+	}
+	if r.buffer == nil {
+		r.buffer = make([]byte, r.chunkSize+tagSize)
+	}
+	ciphertext := r.buffer[:length+tagSize]
+	n, err := io.ReadFull(r.src, ciphertext)
+	r.srcOffset += int64(n)
+	if err != nil {
+		r.chunk = nil
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	last := index == chunkCount(r.plainSize, r.chunkSize)-1
+	plain, err := r.gcm.Open(ciphertext[:0], chunkNonce(index, last), ciphertext, nil)
+	if err != nil {
+		r.chunk = nil
+		return ErrAuthFailed
+	}
+
+	r.chunk = plain
+	r.chunkIndex = index
+	return nil
+}
+
+// seekSource 將底層文件流定位到 offset。底層文件流不支援任意定位時，
+// 透過讀取並丟棄資料向後前進
+func (r *Reader) seekSource(offset int64) error {
+	if offset == r.srcOffset {
+		return nil
+	}
+
+	if pos, err := r.src.Seek(offset, io.SeekStart); err == nil && pos == offset {
+		r.srcOffset = offset
+		return nil
+	}
+
+	if offset < r.srcOffset {
+		return errors.New("底層文件流不支援向前定位")
+	}
+	n, err := io.CopyN(ioutil.Discard, r.src, offset-r.srcOffset)
+	r.srcOffset += n
+	return err
+}
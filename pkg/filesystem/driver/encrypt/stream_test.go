@@ -0,0 +1,206 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testKey = bytes.Repeat([]byte{1}, keySize)
+
+// encryptBytes 加密給定明文並返回全部密文
+func encryptBytes(t *testing.T, plain []byte) []byte {
+	r, err := NewEncryptReader(bytes.NewReader(plain), uint64(len(plain)), testKey)
+	assert.NoError(t, err)
+	ciphertext, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	return ciphertext
+}
+
+// randomBytes 生成給定長度的隨機資料
+func randomBytes(size int) []byte {
+	data := make([]byte, size)
+	rand.Read(data)
+	return data
+}
+
+func TestEncryptReader(t *testing.T) {
+	asserts := assert.New(t)
+
+	for _, size := range []int{0, 1, DefaultChunkSize, DefaultChunkSize + 1, 3*DefaultChunkSize - 5} {
+		plain := randomBytes(size)
+		ciphertext := encryptBytes(t, plain)
+		asserts.EqualValues(EncryptedSize(uint64(size)), len(ciphertext), "size %d", size)
+
+		plainSize, ok := PlainSize(uint64(len(ciphertext)))
+		asserts.True(ok)
+		asserts.EqualValues(size, plainSize)
+
+		r, err := NewDecryptReader(bytes.NewReader(ciphertext), testKey)
+		asserts.NoError(err)
+		asserts.EqualValues(size, r.Size())
+		res, err := ioutil.ReadAll(r)
+		asserts.NoError(err)
+		asserts.True(bytes.Equal(plain, res), "size %d", size)
+	}
+
+	// 每個文件使用不同的金鑰
+	{
+		plain := []byte("cloudreve")
+		asserts.NotEqual(encryptBytes(t, plain), encryptBytes(t, plain))
+	}
+
+	// 明文長度與宣告不符
+	{
+		r, err := NewEncryptReader(strings.NewReader("123"), 4, testKey)
+		asserts.NoError(err)
+		_, err = ioutil.ReadAll(r)
+		asserts.Equal(ErrSizeMismatch, err)
+
+		r, err = NewEncryptReader(strings.NewReader("12345"), 4, testKey)
+		asserts.NoError(err)
+		_, err = ioutil.ReadAll(r)
+		asserts.Equal(ErrSizeMismatch, err)
+	}
+}
+
+func TestPlainSize(t *testing.T) {
+	asserts := assert.New(t)
+
+	for _, size := range []uint64{HeaderSize - 1, HeaderSize + 1, HeaderSize + tagSize, HeaderSize + DefaultChunkSize + tagSize + 5} {
+		_, ok := PlainSize(size)
+		asserts.False(ok, "size %d", size)
+	}
+}
+
+func TestReader_Seek(t *testing.T) {
+	asserts := assert.New(t)
+	plain := randomBytes(3*DefaultChunkSize + 100)
+	r, err := NewDecryptReader(bytes.NewReader(encryptBytes(t, plain)), testKey)
+	asserts.NoError(err)
+
+	size, err := r.Seek(0, io.SeekEnd)
+	asserts.NoError(err)
+	asserts.EqualValues(len(plain), size)
+
+	for _, offset := range []int64{2*DefaultChunkSize + 7, 5, DefaultChunkSize - 1, int64(len(plain)) - 3} {
+		pos, err := r.Seek(offset, io.SeekStart)
+		asserts.NoError(err)
+		asserts.Equal(offset, pos)
+
+		buf := make([]byte, 10)
+		n, err := io.ReadFull(r, buf)
+		if offset+10 > int64(len(plain)) {
+			asserts.Equal(io.ErrUnexpectedEOF, err)
+		} else {
+			asserts.NoError(err)
+		}
+		asserts.Equal(plain[offset:offset+int64(n)], buf[:n])
+	}
+
+	// 相對定位
+	{
+		r.Seek(10, io.SeekStart)
+		pos, err := r.Seek(-5, io.SeekCurrent)
+		asserts.NoError(err)
+		asserts.EqualValues(5, pos)
+		_, err = r.Seek(-1, io.SeekStart)
+		asserts.Error(err)
+	}
+}
+
+// forwardOnly 只能從頭開始順序讀取的文件流
+type forwardOnly struct {
+	io.Reader
+}
+
+func (forwardOnly) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		return 0, nil
+	}
+	return 0, errors.New("not supported")
+}
+
+func TestReader_ForwardOnlySource(t *testing.T) {
+	asserts := assert.New(t)
+	plain := randomBytes(3 * DefaultChunkSize)
+	r, err := NewDecryptReader(forwardOnly{bytes.NewReader(encryptBytes(t, plain))}, testKey)
+	asserts.NoError(err)
+
+	// 向後定位時丟棄中間的資料
+	r.Seek(2*DefaultChunkSize+1, io.SeekStart)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(r, buf)
+	asserts.NoError(err)
+	asserts.Equal(plain[2*DefaultChunkSize+1:2*DefaultChunkSize+5], buf)
+
+	// 無法向前定位
+	r.Seek(0, io.SeekStart)
+	_, err = r.Read(buf)
+	asserts.Error(err)
+}
+
+func TestNewDecryptReader(t *testing.T) {
+	asserts := assert.New(t)
+	plain := randomBytes(2*DefaultChunkSize + 10)
+	ciphertext := encryptBytes(t, plain)
+
+	// 不是加密文件
+	{
+		_, err := NewDecryptReader(strings.NewReader("not encrypted"), testKey)
+		asserts.Equal(ErrInvalidHeader, err)
+		_, err = NewDecryptReader(bytes.NewReader(bytes.Repeat([]byte{0}, HeaderSize)), testKey)
+		asserts.Equal(ErrInvalidHeader, err)
+	}
+
+	// 主金鑰錯誤
+	{
+		_, err := NewDecryptReader(bytes.NewReader(ciphertext), bytes.Repeat([]byte{2}, keySize))
+		asserts.Equal(ErrAuthFailed, err)
+	}
+
+	// 文件頭被竄改
+	{
+		tampered := append([]byte{}, ciphertext...)
+		tampered[len(magic)+4+7]++
+		_, err := NewDecryptReader(bytes.NewReader(tampered), testKey)
+		asserts.Equal(ErrAuthFailed, err)
+	}
+
+	// 正文被竄改
+	{
+		tampered := append([]byte{}, ciphertext...)
+		tampered[HeaderSize+DefaultChunkSize+tagSize+1]++
+		r, err := NewDecryptReader(bytes.NewReader(tampered), testKey)
+		asserts.NoError(err)
+		_, err = ioutil.ReadAll(r)
+		asserts.Equal(ErrAuthFailed, err)
+	}
+
+	// 分塊被重排
+	{
+		chunk := DefaultChunkSize + tagSize
+		tampered := append([]byte{}, ciphertext[:HeaderSize]...)
+		tampered = append(tampered, ciphertext[HeaderSize+chunk:HeaderSize+2*chunk]...)
+		tampered = append(tampered, ciphertext[HeaderSize:HeaderSize+chunk]...)
+		tampered = append(tampered, ciphertext[HeaderSize+2*chunk:]...)
+		r, err := NewDecryptReader(bytes.NewReader(tampered), testKey)
+		asserts.NoError(err)
+		_, err = ioutil.ReadAll(r)
+		asserts.Equal(ErrAuthFailed, err)
+	}
+
+	// 被截斷
+	{
+		r, err := NewDecryptReader(bytes.NewReader(ciphertext[:len(ciphertext)-1]), testKey)
+		asserts.NoError(err)
+		_, err = ioutil.ReadAll(r)
+		asserts.Equal(io.ErrUnexpectedEOF, err)
+	}
+}
@@ -0,0 +1,212 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+var (
+	// ErrNoMember 鏡像儲存策略沒有可用的成員
+	ErrNoMember = errors.New("鏡像儲存策略沒有可用的成員儲存策略")
+	// errAllMembersFailed 所有成員均已寫入失敗
+	errAllMembersFailed = errors.New("所有成員儲存策略均寫入失敗")
+)
+
+// Handler 成員儲存策略的適配器，與 filesystem.Handler 相同
+type Handler interface {
+	Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error
+	Delete(ctx context.Context, files []string) ([]string, error)
+	Get(ctx context.Context, path string) (response.RSCloser, error)
+	Thumb(ctx context.Context, path string) (*response.ContentResponse, error)
+	Source(ctx context.Context, path string, url url.URL, ttl int64, isDownload bool, speed int) (string, error)
+	Token(ctx context.Context, ttl int64, callbackKey string) (serializer.UploadCredential, error)
+	List(ctx context.Context, path string, recursive bool) ([]response.Object, error)
+}
+
+// Member 鏡像儲存策略的成員
+type Member struct {
+	Policy  *model.Policy
+	Handler Handler
+}
+
+// Driver 鏡像儲存適配器，上傳的文件同時寫入所有成員儲存策略，讀取時依序嘗試各成員。
+// 文件內容須經由伺服器中轉，不提供用戶端直傳憑證和重定向外鏈
+type Driver struct {
+	Policy *model.Policy
+	// Members 成員儲存策略，讀取時按順序嘗試
+	Members []Member
+}
+
+// fanout 將寫入的資料同時寫入多個管道，寫入失敗的管道在之後被略過
+type fanout struct {
+	writers []*io.PipeWriter
+	failed  []bool
+}
+
+// Write 寫入所有仍可用的管道，全部失敗時返回錯誤
+func (f *fanout) Write(p []byte) (int, error) {
+	alive := 0
+	for i, writer := range f.writers {
+		if f.failed[i] {
+			continue
+		}
+		if _, err := writer.Write(p); err != nil {
+			f.failed[i] = true
+			continue
+		}
+		alive++
+	}
+
+	if alive == 0 {
+		return 0, errAllMembersFailed
+	}
+	return len(p), nil
+}
+
+// Put 將文件流同時寫入所有成員，至少一個成員寫入成功即視為成功，
+// 寫入失敗的成員可由修復任務補齊
+func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	defer file.Close()
+
+	if len(handler.Members) == 0 {
+		return ErrNoMember
+	}
+
+	writers := make([]*io.PipeWriter, len(handler.Members))
+	errs := make([]error, len(handler.Members))
+	var wg sync.WaitGroup
+	for i, member := range handler.Members {
+		reader, writer := io.Pipe()
+		writers[i] = writer
+		wg.Add(1)
+		go func(i int, member Member, reader *io.PipeReader) {
+			defer wg.Done()
+			errs[i] = member.Handler.Put(ctx, reader, dst, size)
+			// 成員提前結束時，之後的寫入不再阻塞
+			reader.Close()
+		}(i, member, reader)
+	}
+
+	_, copyErr := io.Copy(&fanout{writers: writers, failed: make([]bool, len(writers))}, file)
+	if copyErr == errAllMembersFailed {
+		copyErr = nil
+	}
+	for _, writer := range writers {
+		writer.CloseWithError(copyErr)
+	}
+	wg.Wait()
+
+	// 讀取上傳的文件流失敗
+	if copyErr != nil {
+		return copyErr
+	}
+
+	var lastErr error
+	succeeded := 0
+	for i, err := range errs {
+		if err != nil {
+			util.Log().Warning("無法寫入鏡像成員儲存策略 [%s] 中的文件 %q, %s", handler.Members[i].Policy.Name, dst, err)
+			lastErr = err
+			continue
+		}
+		succeeded++
+	}
+
+	if succeeded == 0 {
+		return lastErr
+	}
+	return nil
+}
+
+// Delete 從所有成員中刪除文件，任一成員刪除失敗的文件均視為刪除失敗
+func (handler Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	var retErr error
+	failedMap := make(map[string]bool)
+	for _, member := range handler.Members {
+		failed, err := member.Handler.Delete(ctx, files)
+		if err != nil {
+			util.Log().Warning("無法刪除鏡像成員儲存策略 [%s] 中的文件, %s", member.Policy.Name, err)
+			retErr = err
+		}
+		for _, file := range failed {
+			failedMap[file] = true
+		}
+	}
+
+	deleteFailed := make([]string, 0, len(failedMap))
+	for _, file := range files {
+		if failedMap[file] {
+			deleteFailed = append(deleteFailed, file)
+		}
+	}
+	return deleteFailed, retErr
+}
+
+// Get 依序嘗試從各成員獲取文件流，返回第一個成功的結果
+func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	var lastErr error = ErrNoMember
+	for _, member := range handler.Members {
+		rs, err := member.Handler.Get(ctx, path)
+		if err == nil {
+			return rs, nil
+		}
+		util.Log().Warning("無法從鏡像成員儲存策略 [%s] 讀取文件 %q，嘗試下一個成員, %s", member.Policy.Name, path, err)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Thumb 依序嘗試從各成員獲取縮圖
+func (handler Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	var lastErr error = ErrNoMember
+	for _, member := range handler.Members {
+		res, err := member.Handler.Thumb(ctx, path)
+		if err == nil {
+			return res, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Source 獲取經由伺服器中轉的外鏈URL，下載時由伺服器選擇可用的成員
+func (handler Driver) Source(
+	ctx context.Context,
+	path string,
+	baseURL url.URL,
+	ttl int64,
+	isDownload bool,
+	speed int,
+) (string, error) {
+	policy := *handler.Policy
+	policy.BaseURL = ""
+	return local.Driver{Policy: &policy}.Source(ctx, path, baseURL, ttl, isDownload, speed)
+}
+
+// Token 鏡像儲存策略須經由伺服器中轉上傳，直接返回空值
+func (handler Driver) Token(ctx context.Context, ttl int64, key string) (serializer.UploadCredential, error) {
+	return serializer.UploadCredential{}, nil
+}
+
+// List 依序嘗試列出各成員中的文件，返回第一個成功的結果
+func (handler Driver) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	var lastErr error = ErrNoMember
+	for _, member := range handler.Members {
+		objects, err := member.Handler.List(ctx, path, recursive)
+		if err == nil {
+			return objects, nil
+		}
+		util.Log().Warning("無法列出鏡像成員儲存策略 [%s] 中的文件，嘗試下一個成員, %s", member.Policy.Name, err)
+		lastErr = err
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,276 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryHandler 將文件存放在記憶體中的適配器，broken 時所有操作均失敗
+type memoryHandler struct {
+	files  map[string][]byte
+	broken bool
+}
+
+func newMemoryHandler() *memoryHandler {
+	return &memoryHandler{files: make(map[string][]byte)}
+}
+
+var errBroken = errors.New("broken")
+
+type nopSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+func (handler *memoryHandler) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	defer file.Close()
+	if handler.broken {
+		return errBroken
+	}
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	handler.files[dst] = content
+	return nil
+}
+
+func (handler *memoryHandler) Delete(ctx context.Context, files []string) ([]string, error) {
+	if handler.broken {
+		return files, errBroken
+	}
+	for _, file := range files {
+		delete(handler.files, file)
+	}
+	return []string{}, nil
+}
+
+func (handler *memoryHandler) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	content, ok := handler.files[path]
+	if handler.broken || !ok {
+		return nil, errBroken
+	}
+	return nopSeekCloser{bytes.NewReader(content)}, nil
+}
+
+func (handler *memoryHandler) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	if handler.broken {
+		return nil, errBroken
+	}
+	return &response.ContentResponse{URL: path}, nil
+}
+
+func (handler *memoryHandler) Source(ctx context.Context, path string, url url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	return "https://bucket.example.com/" + path, nil
+}
+
+func (handler *memoryHandler) Token(ctx context.Context, ttl int64, callbackKey string) (serializer.UploadCredential, error) {
+	return serializer.UploadCredential{Token: "direct"}, nil
+}
+
+func (handler *memoryHandler) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	if handler.broken {
+		return nil, errBroken
+	}
+	var objects []response.Object
+	for name, content := range handler.files {
+		objects = append(objects, response.Object{Name: name, Size: uint64(len(content))})
+	}
+	return objects, nil
+}
+
+// newTestDriver 建立包含給定成員適配器的鏡像適配器
+func newTestDriver(handlers ...*memoryHandler) Driver {
+	driver := Driver{Policy: &model.Policy{BaseURL: "https://cdn.example.com"}}
+	for i, handler := range handlers {
+		driver.Members = append(driver.Members, Member{
+			Policy:  &model.Policy{Model: gorm.Model{ID: uint(i + 1)}},
+			Handler: handler,
+		})
+	}
+	return driver
+}
+
+func TestDriver_Put(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	content := strings.Repeat("cloudreve", 10000)
+
+	// 寫入所有成員
+	{
+		first, second := newMemoryHandler(), newMemoryHandler()
+		handler := newTestDriver(first, second)
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader(content)), "1.txt", uint64(len(content)))
+		asserts.NoError(err)
+		asserts.Equal(content, string(first.files["1.txt"]))
+		asserts.Equal(content, string(second.files["1.txt"]))
+	}
+
+	// 部分成員失敗
+	{
+		first, second := newMemoryHandler(), newMemoryHandler()
+		first.broken = true
+		handler := newTestDriver(first, second)
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader(content)), "1.txt", uint64(len(content)))
+		asserts.NoError(err)
+		asserts.Empty(first.files)
+		asserts.Equal(content, string(second.files["1.txt"]))
+	}
+
+	// 所有成員失敗
+	{
+		first, second := newMemoryHandler(), newMemoryHandler()
+		first.broken, second.broken = true, true
+		handler := newTestDriver(first, second)
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader(content)), "1.txt", uint64(len(content)))
+		asserts.Equal(errBroken, err)
+	}
+
+	// 讀取文件流失敗
+	{
+		first := newMemoryHandler()
+		handler := newTestDriver(first)
+		err := handler.Put(ctx, ioutil.NopCloser(io.MultiReader(
+			strings.NewReader(content),
+			errorReader{},
+		)), "1.txt", uint64(len(content)))
+		asserts.Equal(errBroken, err)
+		asserts.Empty(first.files)
+	}
+
+	// 沒有成員
+	{
+		handler := newTestDriver()
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader(content)), "1.txt", uint64(len(content)))
+		asserts.Equal(ErrNoMember, err)
+	}
+}
+
+type errorReader struct{}
+
+func (errorReader) Read(p []byte) (int, error) {
+	return 0, errBroken
+}
+
+func TestDriver_GetAndList(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	first, second := newMemoryHandler(), newMemoryHandler()
+	second.files["1.txt"] = []byte("second")
+	handler := newTestDriver(first, second)
+
+	// 第一個成員缺少文件時讀取下一個成員
+	{
+		rs, err := handler.Get(ctx, "1.txt")
+		asserts.NoError(err)
+		res, _ := ioutil.ReadAll(rs)
+		asserts.Equal("second", string(res))
+	}
+
+	// 優先讀取第一個成員
+	{
+		first.files["1.txt"] = []byte("first")
+		rs, err := handler.Get(ctx, "1.txt")
+		asserts.NoError(err)
+		res, _ := ioutil.ReadAll(rs)
+		asserts.Equal("first", string(res))
+	}
+
+	// 所有成員均無法讀取
+	{
+		_, err := handler.Get(ctx, "not_exist.txt")
+		asserts.Equal(errBroken, err)
+		_, err = newTestDriver().Get(ctx, "1.txt")
+		asserts.Equal(ErrNoMember, err)
+	}
+
+	// 列出文件
+	{
+		first.broken = true
+		objects, err := handler.List(ctx, "/", true)
+		asserts.NoError(err)
+		asserts.Len(objects, 1)
+		asserts.Equal("1.txt", objects[0].Name)
+		asserts.EqualValues(6, objects[0].Size)
+
+		second.broken = true
+		_, err = handler.List(ctx, "/", true)
+		asserts.Equal(errBroken, err)
+	}
+
+	// 縮圖
+	{
+		first.broken, second.broken = true, false
+		res, err := handler.Thumb(ctx, "1.txt")
+		asserts.NoError(err)
+		asserts.Equal("1.txt", res.URL)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+
+	// 從所有成員刪除
+	{
+		first, second := newMemoryHandler(), newMemoryHandler()
+		first.files["1.txt"], second.files["1.txt"] = []byte("1"), []byte("1")
+		failed, err := newTestDriver(first, second).Delete(ctx, []string{"1.txt"})
+		asserts.NoError(err)
+		asserts.Empty(failed)
+		asserts.Empty(first.files)
+		asserts.Empty(second.files)
+	}
+
+	// 部分成員刪除失敗
+	{
+		first, second := newMemoryHandler(), newMemoryHandler()
+		second.files["1.txt"] = []byte("1")
+		first.broken = true
+		failed, err := newTestDriver(first, second).Delete(ctx, []string{"1.txt"})
+		asserts.Equal(errBroken, err)
+		asserts.Equal([]string{"1.txt"}, failed)
+		asserts.Empty(second.files)
+	}
+}
+
+func TestDriver_SourceAndToken(t *testing.T) {
+	asserts := assert.New(t)
+	auth.General = auth.HMACAuth{SecretKey: []byte("test")}
+	handler := newTestDriver(newMemoryHandler())
+	file := model.File{Model: gorm.Model{ID: 1}, Name: "1.txt"}
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+	baseURL, _ := url.Parse("https://cloudreve.org")
+
+	// 外鏈經由伺服器中轉
+	{
+		sourceURL, err := handler.Source(ctx, "1.txt", *baseURL, 0, false, 0)
+		asserts.NoError(err)
+		asserts.Contains(sourceURL, "https://cloudreve.org/api/v3/file/get/1/1.txt")
+		asserts.Equal("https://cdn.example.com", handler.Policy.BaseURL)
+	}
+
+	// 不提供直傳憑證
+	{
+		credential, err := handler.Token(ctx, 10, "key")
+		asserts.NoError(err)
+		asserts.Empty(credential.Token)
+	}
+}
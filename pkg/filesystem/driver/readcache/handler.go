@@ -0,0 +1,125 @@
+package readcache
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// Handler 被快取的儲存策略適配器，與 filesystem.Handler 相同
+type Handler interface {
+	Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error
+	Delete(ctx context.Context, files []string) ([]string, error)
+	Get(ctx context.Context, path string) (response.RSCloser, error)
+	Thumb(ctx context.Context, path string) (*response.ContentResponse, error)
+	Source(ctx context.Context, path string, url url.URL, ttl int64, isDownload bool, speed int) (string, error)
+	Token(ctx context.Context, ttl int64, callbackKey string) (serializer.UploadCredential, error)
+	List(ctx context.Context, path string, recursive bool) ([]response.Object, error)
+}
+
+// Driver 讀取快取適配器，從底層適配器讀取的文件存放到本機磁碟，
+// 之後的讀取及範圍請求直接由快取提供。寫入和刪除文件時使快取失效
+type Driver struct {
+	Policy  *model.Policy
+	Handler Handler
+	Store   *Store
+}
+
+// key 返回文件的快取鍵
+func (handler Driver) key(path string) string {
+	return strconv.FormatUint(uint64(handler.Policy.ID), 10) + "/" + path
+}
+
+// Unwrap 返回被快取的底層適配器
+func (handler Driver) Unwrap() Handler {
+	return handler.Handler
+}
+
+// Put 上傳文件，並使快取中的舊內容失效
+func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	handler.Store.Remove(handler.key(dst))
+	err := handler.Handler.Put(ctx, file, dst, size)
+	// 上傳期間可能有讀取請求快取了舊內容
+	handler.Store.Remove(handler.key(dst))
+	return err
+}
+
+// Delete 刪除一個或多個文件，並移除對應的快取
+func (handler Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	for _, file := range files {
+		handler.Store.Remove(handler.key(file))
+	}
+	return handler.Handler.Delete(ctx, files)
+}
+
+// Get 優先從快取讀取文件。未命中時從底層適配器讀取並放入快取，
+// 大小未知或超出上限的文件不被快取
+func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	file, sizeKnown := ctx.Value(fsctx.FileModelCtx).(model.File)
+	key := handler.key(path)
+
+	if cached, ok := handler.Store.Open(key); ok {
+		// 大小與記錄不符時，快取的內容已過期
+		info, err := cached.Stat()
+		if err == nil && (!sizeKnown || uint64(info.Size()) == file.Size) {
+			return cached, nil
+		}
+		cached.Close()
+		handler.Store.Remove(key)
+	}
+
+	rs, err := handler.Handler.Get(ctx, path)
+	if err != nil || !sizeKnown || int64(file.Size) > handler.Store.maxObjectSize {
+		return rs, err
+	}
+
+	// 部分適配器需要先定位一次才能正常讀取
+	_, err = rs.Seek(0, io.SeekStart)
+	if err == nil {
+		err = handler.Store.Add(key, rs, int64(file.Size))
+	}
+	rs.Close()
+	if err != nil {
+		util.Log().Warning("無法快取文件 %q, %s", path, err)
+		return handler.Handler.Get(ctx, path)
+	}
+
+	if cached, ok := handler.Store.Open(key); ok {
+		return cached, nil
+	}
+	return handler.Handler.Get(ctx, path)
+}
+
+// Thumb 獲取縮圖
+func (handler Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	return handler.Handler.Thumb(ctx, path)
+}
+
+// Source 獲取外鏈URL
+func (handler Driver) Source(
+	ctx context.Context,
+	path string,
+	baseURL url.URL,
+	ttl int64,
+	isDownload bool,
+	speed int,
+) (string, error) {
+	return handler.Handler.Source(ctx, path, baseURL, ttl, isDownload, speed)
+}
+
+// Token 獲取上傳憑證
+func (handler Driver) Token(ctx context.Context, ttl int64, key string) (serializer.UploadCredential, error) {
+	return handler.Handler.Token(ctx, ttl, key)
+}
+
+// List 列出文件
+func (handler Driver) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	return handler.Handler.List(ctx, path, recursive)
+}
@@ -0,0 +1,204 @@
+package readcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryHandler 將文件存放在記憶體中的適配器，記錄讀取次數
+type memoryHandler struct {
+	files map[string][]byte
+	gets  int
+}
+
+type nopSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+func (handler *memoryHandler) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	handler.files[dst] = content
+	return nil
+}
+
+func (handler *memoryHandler) Delete(ctx context.Context, files []string) ([]string, error) {
+	for _, file := range files {
+		delete(handler.files, file)
+	}
+	return []string{}, nil
+}
+
+func (handler *memoryHandler) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	handler.gets++
+	content, ok := handler.files[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return nopSeekCloser{bytes.NewReader(content)}, nil
+}
+
+func (handler *memoryHandler) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	return &response.ContentResponse{URL: "thumb"}, nil
+}
+
+func (handler *memoryHandler) Source(ctx context.Context, path string, url url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	return "https://bucket.example.com/" + path, nil
+}
+
+func (handler *memoryHandler) Token(ctx context.Context, ttl int64, callbackKey string) (serializer.UploadCredential, error) {
+	return serializer.UploadCredential{Token: "direct"}, nil
+}
+
+func (handler *memoryHandler) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	return []response.Object{{Name: "1.txt"}}, nil
+}
+
+// getContent 讀取文件的全部內容
+func getContent(t *testing.T, handler Driver, path string, size uint64) string {
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, model.File{Size: size})
+	rs, err := handler.Get(ctx, path)
+	assert.NoError(t, err)
+	defer rs.Close()
+	content, _ := ioutil.ReadAll(rs)
+	return string(content)
+}
+
+func TestDriver_Get(t *testing.T) {
+	asserts := assert.New(t)
+	store, dir := newTestStore(t, 100, 10)
+	defer os.RemoveAll(dir)
+	inner := &memoryHandler{files: map[string][]byte{
+		"1.txt":     []byte("hello"),
+		"large.txt": []byte("hello world"),
+	}}
+	handler := Driver{Policy: &model.Policy{Model: gorm.Model{ID: 1}}, Handler: inner, Store: store}
+
+	// 第二次讀取命中快取
+	{
+		asserts.Equal("hello", getContent(t, handler, "1.txt", 5))
+		asserts.Equal("hello", getContent(t, handler, "1.txt", 5))
+		asserts.Equal(1, inner.gets)
+	}
+
+	// 支援範圍讀取
+	{
+		rs, err := handler.Get(context.WithValue(context.Background(), fsctx.FileModelCtx, model.File{Size: 5}), "1.txt")
+		asserts.NoError(err)
+		rs.Seek(2, io.SeekStart)
+		content, _ := ioutil.ReadAll(rs)
+		asserts.Equal("llo", string(content))
+		rs.Close()
+		asserts.Equal(1, inner.gets)
+	}
+
+	// 大小與記錄不符時重新讀取
+	{
+		inner.files["1.txt"] = []byte("hello!")
+		asserts.Equal("hello!", getContent(t, handler, "1.txt", 6))
+		asserts.Equal(2, inner.gets)
+	}
+
+	// 超出大小上限的文件不被快取
+	{
+		inner.gets = 0
+		asserts.Equal("hello world", getContent(t, handler, "large.txt", 11))
+		asserts.Equal("hello world", getContent(t, handler, "large.txt", 11))
+		asserts.Equal(2, inner.gets)
+	}
+
+	// 大小未知的文件不被快取
+	{
+		inner.gets = 0
+		rs, err := handler.Get(context.Background(), "2.txt")
+		asserts.Error(err)
+		asserts.Nil(rs)
+		_, ok := store.Open(handler.key("2.txt"))
+		asserts.False(ok)
+	}
+
+	// 讀取到的大小與記錄不符時直接返回底層文件
+	{
+		inner.gets = 0
+		inner.files["3.txt"] = []byte("abc")
+		asserts.Equal("abc", getContent(t, handler, "3.txt", 5))
+		asserts.Equal(2, inner.gets)
+		_, ok := store.Open(handler.key("3.txt"))
+		asserts.False(ok)
+	}
+}
+
+func TestDriver_Invalidate(t *testing.T) {
+	asserts := assert.New(t)
+	store, dir := newTestStore(t, 100, 10)
+	defer os.RemoveAll(dir)
+	inner := &memoryHandler{files: map[string][]byte{"1.txt": []byte("hello")}}
+	handler := Driver{Policy: &model.Policy{Model: gorm.Model{ID: 1}}, Handler: inner, Store: store}
+	ctx := context.Background()
+
+	// 上傳時使快取失效
+	{
+		asserts.Equal("hello", getContent(t, handler, "1.txt", 5))
+		asserts.NoError(handler.Put(ctx, ioutil.NopCloser(strings.NewReader("world")), "1.txt", 5))
+		asserts.Equal("world", getContent(t, handler, "1.txt", 5))
+		asserts.Equal(2, inner.gets)
+	}
+
+	// 刪除時移除快取
+	{
+		failed, err := handler.Delete(ctx, []string{"1.txt"})
+		asserts.NoError(err)
+		asserts.Empty(failed)
+		_, ok := store.Open(handler.key("1.txt"))
+		asserts.False(ok)
+	}
+
+	// 不同儲存策略的快取互不影響
+	{
+		asserts.NotEqual(handler.key("1.txt"), Driver{Policy: &model.Policy{Model: gorm.Model{ID: 2}}}.key("1.txt"))
+	}
+}
+
+func TestDriver_Delegate(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{Policy: &model.Policy{}, Handler: &memoryHandler{}}
+	ctx := context.Background()
+	baseURL, _ := url.Parse("https://cloudreve.org")
+
+	res, err := handler.Thumb(ctx, "1.txt")
+	asserts.NoError(err)
+	asserts.Equal("thumb", res.URL)
+
+	sourceURL, err := handler.Source(ctx, "1.txt", *baseURL, 0, false, 0)
+	asserts.NoError(err)
+	asserts.Equal("https://bucket.example.com/1.txt", sourceURL)
+
+	credential, err := handler.Token(ctx, 10, "key")
+	asserts.NoError(err)
+	asserts.Equal("direct", credential.Token)
+
+	objects, err := handler.List(ctx, "/", false)
+	asserts.NoError(err)
+	asserts.Len(objects, 1)
+}
@@ -0,0 +1,211 @@
+package readcache
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// tempPrefix 寫入中的快取文件名前綴
+const tempPrefix = ".tmp_"
+
+var (
+	// ErrObjectTooLarge 文件超出可快取的大小上限
+	ErrObjectTooLarge = errors.New("文件超出可快取的大小上限")
+	// ErrSizeMismatch 讀取到的文件大小與預期不符
+	ErrSizeMismatch = errors.New("讀取到的文件大小與預期不符")
+)
+
+// Default 預設的快取儲存，未初始化或已停用時為 nil
+var Default *Store
+
+// Init 按配置檔案初始化預設的快取儲存
+func Init() {
+	if conf.ReadCacheConfig.MaxSize == 0 {
+		return
+	}
+
+	store, err := NewStore(
+		util.RelativePath(conf.ReadCacheConfig.Path),
+		int64(conf.ReadCacheConfig.MaxSize),
+		int64(conf.ReadCacheConfig.MaxObjectSize),
+	)
+	if err != nil {
+		util.Log().Warning("無法初始化讀取快取，%s", err)
+		return
+	}
+	Default = store
+}
+
+// entry 快取中的一個文件
+type entry struct {
+	name string
+	size int64
+}
+
+// Store 以本機磁碟存放文件的快取，總大小超出上限時淘汰最久未使用的文件
+type Store struct {
+	dir           string
+	maxSize       int64
+	maxObjectSize int64
+
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List // 由新到舊排列
+	items map[string]*list.Element
+}
+
+// NewStore 建立快取儲存，目錄中已有的快取文件按修改時間載入
+func NewStore(dir string, maxSize, maxObjectSize int64) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+
+	store := &Store{
+		dir:           dir,
+		maxSize:       maxSize,
+		maxObjectSize: maxObjectSize,
+		lru:           list.New(),
+		items:         make(map[string]*list.Element),
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		// 上次未寫入完成的文件
+		if strings.HasPrefix(file.Name(), tempPrefix) {
+			os.Remove(filepath.Join(dir, file.Name()))
+			continue
+		}
+		store.items[file.Name()] = store.lru.PushBack(&entry{name: file.Name(), size: file.Size()})
+		store.size += file.Size()
+	}
+
+	store.mu.Lock()
+	store.evict()
+	store.mu.Unlock()
+	return store, nil
+}
+
+// fileName 返回快取鍵對應的文件名
+func fileName(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Open 開啟快取的文件，不存在時 ok 為 false
+func (store *Store) Open(key string) (file *os.File, ok bool) {
+	name := fileName(key)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	elem, exist := store.items[name]
+	if !exist {
+		return nil, false
+	}
+
+	file, err := os.Open(filepath.Join(store.dir, name))
+	if err != nil {
+		store.remove(elem)
+		return nil, false
+	}
+
+	// 更新修改時間，重新啟動後仍可按使用順序淘汰
+	store.lru.MoveToFront(elem)
+	now := time.Now()
+	os.Chtimes(file.Name(), now, now)
+	return file, true
+}
+
+// Add 從 src 讀取恰好 size 位元組的文件內容並放入快取
+func (store *Store) Add(key string, src io.Reader, size int64) error {
+	if size > store.maxObjectSize || size > store.maxSize {
+		return ErrObjectTooLarge
+	}
+
+	temp, err := ioutil.TempFile(store.dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(temp, io.LimitReader(src, size+1))
+	temp.Close()
+	if err == nil && n != size {
+		err = ErrSizeMismatch
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	name := fileName(key)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if elem, exist := store.items[name]; exist {
+		store.remove(elem)
+	}
+	if err := os.Rename(temp.Name(), filepath.Join(store.dir, name)); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	store.items[name] = store.lru.PushFront(&entry{name: name, size: n})
+	store.size += n
+	store.evict()
+	return nil
+}
+
+// Remove 從快取中移除文件
+func (store *Store) Remove(key string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if elem, exist := store.items[fileName(key)]; exist {
+		store.remove(elem)
+	}
+}
+
+// Size 返回快取文件的總大小
+func (store *Store) Size() int64 {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.size
+}
+
+// evict 淘汰最久未使用的文件，直到總大小不超過上限，呼叫前須持有鎖
+func (store *Store) evict() {
+	for store.size > store.maxSize && store.lru.Len() > 0 {
+		store.remove(store.lru.Back())
+	}
+}
+
+// remove 刪除快取文件，呼叫前須持有鎖
+func (store *Store) remove(elem *list.Element) {
+	item := store.lru.Remove(elem).(*entry)
+	delete(store.items, item.name)
+	store.size -= item.size
+	if err := os.Remove(filepath.Join(store.dir, item.name)); err != nil && !os.IsNotExist(err) {
+		util.Log().Warning("無法刪除快取文件 %s, %s", item.name, err)
+	}
+}
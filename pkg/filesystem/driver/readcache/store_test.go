@@ -0,0 +1,124 @@
+package readcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestStore 在臨時目錄中建立快取儲存
+func newTestStore(t *testing.T, maxSize, maxObjectSize int64) (*Store, string) {
+	dir, err := ioutil.TempDir("", "readcache")
+	assert.NoError(t, err)
+	store, err := NewStore(dir, maxSize, maxObjectSize)
+	assert.NoError(t, err)
+	return store, dir
+}
+
+// readCached 讀取快取的文件內容，不存在時返回空字串
+func readCached(store *Store, key string) string {
+	file, ok := store.Open(key)
+	if !ok {
+		return ""
+	}
+	defer file.Close()
+	content, _ := ioutil.ReadAll(file)
+	return string(content)
+}
+
+func TestStore_AddAndOpen(t *testing.T) {
+	asserts := assert.New(t)
+	store, dir := newTestStore(t, 10, 5)
+	defer os.RemoveAll(dir)
+
+	// 未命中
+	{
+		_, ok := store.Open("1/a.txt")
+		asserts.False(ok)
+	}
+
+	// 放入後命中
+	{
+		asserts.NoError(store.Add("1/a.txt", strings.NewReader("hello"), 5))
+		asserts.Equal("hello", readCached(store, "1/a.txt"))
+		asserts.EqualValues(5, store.Size())
+	}
+
+	// 大小不符時不放入快取
+	{
+		asserts.Equal(ErrSizeMismatch, store.Add("1/b.txt", strings.NewReader("hel"), 5))
+		asserts.Equal(ErrSizeMismatch, store.Add("1/b.txt", strings.NewReader("hello!"), 5))
+		_, ok := store.Open("1/b.txt")
+		asserts.False(ok)
+		asserts.EqualValues(5, store.Size())
+	}
+
+	// 超出大小上限
+	{
+		asserts.Equal(ErrObjectTooLarge, store.Add("1/c.txt", strings.NewReader("hello!"), 6))
+	}
+
+	// 覆蓋已有的快取
+	{
+		asserts.NoError(store.Add("1/a.txt", strings.NewReader("world"), 5))
+		asserts.Equal("world", readCached(store, "1/a.txt"))
+		asserts.EqualValues(5, store.Size())
+	}
+
+	// 移除
+	{
+		store.Remove("1/a.txt")
+		store.Remove("1/not_exist.txt")
+		_, ok := store.Open("1/a.txt")
+		asserts.False(ok)
+		asserts.EqualValues(0, store.Size())
+		files, _ := ioutil.ReadDir(dir)
+		asserts.Empty(files)
+	}
+}
+
+func TestStore_Evict(t *testing.T) {
+	asserts := assert.New(t)
+	store, dir := newTestStore(t, 10, 5)
+	defer os.RemoveAll(dir)
+
+	asserts.NoError(store.Add("a", strings.NewReader("aaaa"), 4))
+	asserts.NoError(store.Add("b", strings.NewReader("bbbb"), 4))
+	// 使用過的文件較晚被淘汰
+	asserts.Equal("aaaa", readCached(store, "a"))
+	asserts.NoError(store.Add("c", strings.NewReader("cccc"), 4))
+
+	asserts.Equal("aaaa", readCached(store, "a"))
+	asserts.Equal("", readCached(store, "b"))
+	asserts.Equal("cccc", readCached(store, "c"))
+	asserts.EqualValues(8, store.Size())
+}
+
+func TestNewStore(t *testing.T) {
+	asserts := assert.New(t)
+	dir, err := ioutil.TempDir("", "readcache")
+	asserts.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// 載入已有的快取文件，按修改時間淘汰
+	old := time.Now().Add(-time.Hour)
+	asserts.NoError(ioutil.WriteFile(filepath.Join(dir, fileName("a")), []byte("aaaa"), 0600))
+	asserts.NoError(os.Chtimes(filepath.Join(dir, fileName("a")), old, old))
+	asserts.NoError(ioutil.WriteFile(filepath.Join(dir, fileName("b")), []byte("bbbb"), 0600))
+	asserts.NoError(ioutil.WriteFile(filepath.Join(dir, tempPrefix+"1"), []byte("temp"), 0600))
+
+	store, err := NewStore(dir, 6, 6)
+	asserts.NoError(err)
+	asserts.EqualValues(4, store.Size())
+	asserts.Equal("", readCached(store, "a"))
+	asserts.Equal("bbbb", readCached(store, "b"))
+
+	// 未寫入完成的文件被刪除
+	_, err = os.Stat(filepath.Join(dir, tempPrefix+"1"))
+	asserts.True(os.IsNotExist(err))
+}
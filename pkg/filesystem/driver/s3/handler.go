@@ -23,6 +23,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
@@ -42,6 +43,9 @@ type UploadPolicy struct {
 	Conditions []interface{} `json:"conditions"`
 }
 
+// maxDeleteKeys 每個刪除請求最多包含的文件數
+const maxDeleteKeys = 1000
+
 //MetaData 文件訊息
 type MetaData struct {
 	Size uint64
@@ -197,7 +201,7 @@ func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser,
 	return resp, nil
 }
 
-// Put 將文件流儲存到指定目錄
+// Put 將文件流儲存到指定目錄，超過分塊大小的文件使用分塊上傳
 func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
 
 	// 初始化用戶端
@@ -205,15 +209,19 @@ func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, s
 		return err
 	}
 
-	uploader := s3manager.NewUploader(handler.sess)
+	uploader := handler.newUploader(size)
 
-	_, err := uploader.Upload(&s3manager.UploadInput{
+	_, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
 		Bucket: &handler.Policy.BucketName,
 		Key:    &dst,
 		Body:   file,
 	})
 
 	if err != nil {
+		// 中止未完成的分塊上傳
+		if multiErr, ok := err.(s3manager.MultiUploadFailure); ok {
+			handler.abortUpload(dst, multiErr.UploadID())
+		}
 		return err
 	}
 
@@ -232,28 +240,38 @@ func (handler Driver) Delete(ctx context.Context, files []string) ([]string, err
 	failed := make([]string, 0, len(files))
 	deleted := make([]string, 0, len(files))
 
-	keys := make([]*s3.ObjectIdentifier, 0, len(files))
+	keys := make([]*s3.ObjectIdentifier, 0, len(files)*2)
 	for _, file := range files {
 		filePath := file
-		keys = append(keys, &s3.ObjectIdentifier{Key: &filePath})
+		// 同時刪除文件的縮圖
+		thumbPath := file + conf.ThumbConfig.FileSuffix
+		keys = append(keys, &s3.ObjectIdentifier{Key: &filePath}, &s3.ObjectIdentifier{Key: &thumbPath})
 	}
 
-	// 發送非同步刪除請求
-	res, err := handler.svc.DeleteObjects(
-		&s3.DeleteObjectsInput{
-			Bucket: &handler.Policy.BucketName,
-			Delete: &s3.Delete{
-				Objects: keys,
-			},
-		})
+	// 每個請求最多刪除 maxDeleteKeys 個文件
+	for start := 0; start < len(keys); start += maxDeleteKeys {
+		end := start + maxDeleteKeys
+		if end > len(keys) {
+			end = len(keys)
+		}
 
-	if err != nil {
-		return files, err
-	}
+		// 發送非同步刪除請求
+		res, err := handler.svc.DeleteObjects(
+			&s3.DeleteObjectsInput{
+				Bucket: &handler.Policy.BucketName,
+				Delete: &s3.Delete{
+					Objects: keys[start:end],
+				},
+			})
+
+		if err != nil {
+			return files, err
+		}
 
-	// 統計未刪除的文件
-	for _, deleteRes := range res.Deleted {
-		deleted = append(deleted, *deleteRes.Key)
+		// 統計未刪除的文件
+		for _, deleteRes := range res.Deleted {
+			deleted = append(deleted, *deleteRes.Key)
+		}
 	}
 	failed = util.SliceDifference(failed, deleted)
 
@@ -261,9 +279,46 @@ func (handler Driver) Delete(ctx context.Context, files []string) ([]string, err
 
 }
 
-// Thumb 獲取文件縮圖
+// Thumb 獲取由主機生成並與文件存放在一起的縮圖
 func (handler Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
-	return nil, errors.New("未實現")
+
+	// 初始化用戶端
+	if err := handler.InitS3Client(); err != nil {
+		return nil, err
+	}
+
+	// 縮圖不存在時返回錯誤，以便重新生成
+	thumbPath := path + conf.ThumbConfig.FileSuffix
+	_, err := handler.svc.HeadObjectWithContext(ctx,
+		&s3.HeadObjectInput{
+			Bucket: &handler.Policy.BucketName,
+			Key:    &thumbPath,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	req, _ := handler.svc.GetObjectRequest(
+		&s3.GetObjectInput{
+			Bucket: &handler.Policy.BucketName,
+			Key:    &thumbPath,
+		})
+
+	ttl := model.GetIntSetting("preview_timeout", 60)
+	signedURL, err := req.Presign(time.Duration(ttl) * time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	finalURL, err := handler.finalURL(signedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.ContentResponse{
+		Redirect: true,
+		URL:      finalURL,
+	}, nil
 }
 
 // Source 獲取外鏈URL
@@ -300,7 +355,11 @@ func (handler Driver) Source(
 
 	signedURL, _ := req.Presign(time.Duration(ttl) * time.Second)
 
-	// 將最終生成的簽名URL域名換成使用者自訂的加速域名（如果有）
+	return handler.finalURL(signedURL)
+}
+
+// finalURL 處理簽名URL，返回最終的訪問地址
+func (handler Driver) finalURL(signedURL string) (string, error) {
 	finalURL, err := url.Parse(signedURL)
 	if err != nil {
 		return "", err
@@ -311,6 +370,7 @@ func (handler Driver) Source(
 		finalURL.RawQuery = ""
 	}
 
+	// 將最終生成的簽名URL域名換成使用者自訂的加速域名（如果有）
 	if handler.Policy.BaseURL != "" {
 		cdnURL, err := url.Parse(handler.Policy.BaseURL)
 		if err != nil {
@@ -0,0 +1,92 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriver_Thumb(t *testing.T) {
+	asserts := assert.New(t)
+	fake := newFakeS3Server()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	handler := newTestDriver(server)
+	handler.Policy.IsPrivate = true
+	cache.Set("setting_preview_timeout", "60", 0)
+
+	// 縮圖不存在
+	{
+		_, err := handler.Thumb(context.Background(), "1.jpg")
+		asserts.Error(err)
+	}
+
+	// 縮圖存在，返回簽名URL
+	{
+		fake.objects["1.jpg"+conf.ThumbConfig.FileSuffix] = []byte("thumb")
+		res, err := handler.Thumb(context.Background(), "1.jpg")
+		asserts.NoError(err)
+		asserts.True(res.Redirect)
+		thumbURL, _ := url.Parse(res.URL)
+		asserts.Equal("/bucket/1.jpg"+conf.ThumbConfig.FileSuffix, thumbURL.Path)
+		asserts.NotEmpty(thumbURL.Query().Get("X-Amz-Signature"))
+	}
+
+	// 公有空間使用自訂域名
+	{
+		handler.Policy.IsPrivate = false
+		handler.Policy.BaseURL = "https://cdn.cloudreve.org"
+		res, err := handler.Thumb(context.Background(), "1.jpg")
+		asserts.NoError(err)
+		asserts.Equal("https://cdn.cloudreve.org/bucket/1.jpg"+conf.ThumbConfig.FileSuffix, res.URL)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	asserts := assert.New(t)
+	fake := newFakeS3Server()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	handler := newTestDriver(server)
+
+	// 同時刪除縮圖
+	{
+		fake.objects["1.jpg"] = []byte("1")
+		fake.objects["1.jpg"+conf.ThumbConfig.FileSuffix] = []byte("thumb")
+		fake.objects["2.txt"] = []byte("2")
+		failed, err := handler.Delete(context.Background(), []string{"1.jpg", "2.txt"})
+		asserts.NoError(err)
+		asserts.Empty(failed)
+		asserts.Empty(fake.objects)
+		asserts.Equal(1, fake.deleteRequests)
+	}
+
+	// 超出單個請求的上限時分多次請求
+	{
+		fake.deleteRequests = 0
+		files := make([]string, maxDeleteKeys)
+		for i := range files {
+			files[i] = fmt.Sprintf("%d.txt", i)
+			fake.objects[files[i]] = []byte("1")
+		}
+		failed, err := handler.Delete(context.Background(), files)
+		asserts.NoError(err)
+		asserts.Empty(failed)
+		asserts.Empty(fake.objects)
+		asserts.Equal(2, fake.deleteRequests)
+	}
+
+	// 刪除請求失敗
+	{
+		server.Close()
+		failed, err := handler.Delete(context.Background(), []string{"1.jpg"})
+		asserts.Error(err)
+		asserts.Equal([]string{"1.jpg"}, failed)
+	}
+}
@@ -0,0 +1,127 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// partSize 計算服務端分塊上傳的分塊大小，分塊數超過上限時增大分塊
+func (handler Driver) partSize(size uint64) int64 {
+	partSize := handler.Policy.OptionsSerialized.S3PartSize
+	if partSize < s3manager.MinUploadPartSize {
+		partSize = s3manager.DefaultUploadPartSize
+	}
+
+	if int64(size)/partSize >= s3manager.MaxUploadParts {
+		partSize = int64(size)/s3manager.MaxUploadParts + 1
+	}
+
+	return partSize
+}
+
+// newUploader 建立服務端分塊上傳使用的 Uploader。
+// 上傳失敗時保留已上傳的分塊，由 abortUpload 中止上傳
+func (handler Driver) newUploader(size uint64) *s3manager.Uploader {
+	return s3manager.NewUploader(handler.sess, func(u *s3manager.Uploader) {
+		u.PartSize = handler.partSize(size)
+		u.Concurrency = handler.Policy.OptionsSerialized.S3PartConcurrency
+		u.LeavePartsOnError = true
+		u.RequestOptions = append(u.RequestOptions,
+			withRetries(model.GetIntSetting("s3_part_retries", 3)))
+	})
+}
+
+// withRetries 設定單個請求失敗後的最大重試次數
+func withRetries(retries int) request.Option {
+	return func(r *request.Request) {
+		r.Retryer = client.DefaultRetryer{NumMaxRetries: retries}
+	}
+}
+
+// abortUpload 中止未完成的分塊上傳，刪除已上傳的分塊。
+// 上傳的 context 可能已被取消，因此使用新的 context
+func (handler Driver) abortUpload(key, uploadID string) {
+	_, err := handler.svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   &handler.Policy.BucketName,
+		Key:      &key,
+		UploadId: &uploadID,
+	})
+	if err != nil {
+		util.Log().Warning("無法中止分塊上傳 [%s], %s", key, err)
+	}
+}
+
+// ErrUnscopedStaleUploads 儲存路徑規則沒有固定前綴，且未允許清理整個儲存桶
+var ErrUnscopedStaleUploads = errors.New("儲存路徑規則沒有固定前綴，為避免中止共用儲存桶中其他應用的上傳，已略過清理")
+
+// uploadPrefix 返回儲存策略上傳文件的鍵前綴，即儲存路徑規則中不含變數的部分
+func (handler Driver) uploadPrefix() string {
+	prefix := strings.Trim(handler.Policy.DirNamePrefix(), "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// AbortStaleUploads 中止儲存策略鍵前綴下在 before 之前發起且仍未完成的分塊上傳，
+// 返回中止的上傳數量，及遇到的最後一個錯誤。儲存路徑規則沒有固定前綴時，
+// 需在儲存策略中明確允許才會清理整個儲存桶
+func (handler Driver) AbortStaleUploads(ctx context.Context, before time.Time) (int, error) {
+	// 初始化用戶端
+	if err := handler.InitS3Client(); err != nil {
+		return 0, err
+	}
+
+	prefix := handler.uploadPrefix()
+	if prefix == "" && !handler.Policy.OptionsSerialized.S3AbortUnscopedUploads {
+		return 0, ErrUnscopedStaleUploads
+	}
+
+	input := &s3.ListMultipartUploadsInput{Bucket: &handler.Policy.BucketName}
+	if prefix != "" {
+		input.Prefix = &prefix
+	}
+
+	var stale []*s3.MultipartUpload
+	err := handler.svc.ListMultipartUploadsPagesWithContext(ctx, input,
+		func(page *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+			for _, upload := range page.Uploads {
+				if upload.Key == nil || !strings.HasPrefix(*upload.Key, prefix) {
+					continue
+				}
+				if upload.Initiated != nil && upload.Initiated.Before(before) {
+					stale = append(stale, upload)
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return 0, err
+	}
+
+	aborted := 0
+	var retErr error
+	for _, upload := range stale {
+		_, err := handler.svc.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   &handler.Policy.BucketName,
+			Key:      upload.Key,
+			UploadId: upload.UploadId,
+		})
+		if err != nil {
+			retErr = err
+			continue
+		}
+		aborted++
+	}
+
+	return aborted, retErr
+}
@@ -0,0 +1,306 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3Server 模擬 S3 的上傳、刪除及分塊上傳接口
+type fakeS3Server struct {
+	mu sync.Mutex
+	// objects 已完成上傳的文件
+	objects map[string][]byte
+	// uploads 未完成的分塊上傳，uploadID => 分塊
+	uploads map[string]map[int][]byte
+	// initiated 分塊上傳的發起時間
+	initiated map[string]time.Time
+	keys      map[string]string
+	aborted   []string
+	// failPart 分塊編號 => 剩餘的失敗次數，小於0時始終失敗
+	failPart map[int]int
+	// onPart 收到分塊時調用
+	onPart func(part int)
+	nextID int
+	// deleteRequests 收到的批次刪除請求數
+	deleteRequests int
+}
+
+func newFakeS3Server() *fakeS3Server {
+	return &fakeS3Server{
+		objects:   make(map[string][]byte),
+		uploads:   make(map[string]map[int][]byte),
+		initiated: make(map[string]time.Time),
+		keys:      make(map[string]string),
+		failPart:  make(map[int]int),
+	}
+}
+
+func (server *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	query := r.URL.Query()
+	body, _ := ioutil.ReadAll(r.Body)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	switch {
+	case r.Method == "GET" && hasQuery(r, "uploads"):
+		server.list(w, query.Get("prefix"))
+	case r.Method == "POST" && hasQuery(r, "uploads"):
+		server.nextID++
+		uploadID := fmt.Sprintf("upload-%d", server.nextID)
+		server.uploads[uploadID] = make(map[int][]byte)
+		server.initiated[uploadID] = time.Now()
+		server.keys[uploadID] = key
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, key, uploadID)
+	case r.Method == "PUT" && query.Get("uploadId") != "":
+		part, _ := strconv.Atoi(query.Get("partNumber"))
+		if server.onPart != nil {
+			server.onPart(part)
+		}
+		if remain, ok := server.failPart[part]; ok && remain != 0 {
+			server.failPart[part] = remain - 1
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `<Error><Code>InternalError</Code><Message>fail</Message></Error>`)
+			return
+		}
+		server.uploads[query.Get("uploadId")][part] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, part))
+	case r.Method == "POST" && query.Get("uploadId") != "":
+		parts := server.uploads[query.Get("uploadId")]
+		numbers := make([]int, 0, len(parts))
+		for number := range parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var content []byte
+		for _, number := range numbers {
+			content = append(content, parts[number]...)
+		}
+		server.objects[key] = content
+		delete(server.uploads, query.Get("uploadId"))
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>%s</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`, key)
+	case r.Method == "DELETE" && query.Get("uploadId") != "":
+		server.aborted = append(server.aborted, query.Get("uploadId"))
+		delete(server.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "PUT":
+		server.objects[key] = body
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == "HEAD":
+		if _, ok := server.objects[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(server.objects[key])))
+	case r.Method == "POST" && hasQuery(r, "delete"):
+		server.delete(w, body)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// delete 批次刪除文件，不存在的文件同樣視為刪除成功
+func (server *fakeS3Server) delete(w http.ResponseWriter, body []byte) {
+	var req struct {
+		Objects []struct {
+			Key string
+		} `xml:"Object"`
+	}
+	xml.Unmarshal(body, &req)
+
+	server.deleteRequests++
+	res := &bytes.Buffer{}
+	res.WriteString(`<DeleteResult>`)
+	for _, object := range req.Objects {
+		delete(server.objects, object.Key)
+		fmt.Fprintf(res, `<Deleted><Key>%s</Key></Deleted>`, object.Key)
+	}
+	res.WriteString(`</DeleteResult>`)
+	w.Write(res.Bytes())
+}
+
+func hasQuery(r *http.Request, name string) bool {
+	_, ok := r.URL.Query()[name]
+	return ok
+}
+
+// list 列出未完成的分塊上傳
+func (server *fakeS3Server) list(w http.ResponseWriter, prefix string) {
+	res := &bytes.Buffer{}
+	res.WriteString(`<ListMultipartUploadsResult><Bucket>bucket</Bucket><IsTruncated>false</IsTruncated>`)
+	for uploadID := range server.uploads {
+		if !strings.HasPrefix(server.keys[uploadID], prefix) {
+			continue
+		}
+		fmt.Fprintf(res, `<Upload><Key>%s</Key><UploadId>%s</UploadId><Initiated>%s</Initiated></Upload>`,
+			server.keys[uploadID], uploadID, server.initiated[uploadID].UTC().Format(time.RFC3339))
+	}
+	res.WriteString(`</ListMultipartUploadsResult>`)
+	w.Write(res.Bytes())
+}
+
+func newTestDriver(server *httptest.Server) Driver {
+	policy := &model.Policy{
+		Type:       "s3",
+		Server:     server.URL,
+		BucketName: "bucket",
+		AccessKey:  "ak",
+		SecretKey:  "sk",
+	}
+	policy.OptionsSerialized.Region = "us-east-1"
+	return Driver{Policy: policy}
+}
+
+func TestDriver_PartSize(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{Policy: &model.Policy{}}
+
+	// 預設值
+	asserts.EqualValues(s3manager.DefaultUploadPartSize, handler.partSize(1024))
+
+	// 小於最小值時使用預設值
+	handler.Policy.OptionsSerialized.S3PartSize = 1024
+	asserts.EqualValues(s3manager.DefaultUploadPartSize, handler.partSize(1024))
+
+	// 自訂分塊大小
+	handler.Policy.OptionsSerialized.S3PartSize = 16 << 20
+	asserts.EqualValues(16<<20, handler.partSize(1024))
+
+	// 分塊數超過上限時增大分塊
+	size := uint64(16<<20) * s3manager.MaxUploadParts
+	partSize := handler.partSize(size)
+	asserts.True(partSize > 16<<20)
+	asserts.True(uint64(partSize)*s3manager.MaxUploadParts >= size)
+}
+
+func TestDriver_Put(t *testing.T) {
+	asserts := assert.New(t)
+	fake := newFakeS3Server()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	handler := newTestDriver(server)
+	cache.Set("setting_s3_part_retries", "2", 0)
+	content := bytes.Repeat([]byte("a"), int(2*s3manager.MinUploadPartSize+10))
+
+	// 小文件直接上傳
+	{
+		err := handler.Put(context.Background(), ioutil.NopCloser(strings.NewReader("hello")), "small.txt", 5)
+		asserts.NoError(err)
+		asserts.Equal("hello", string(fake.objects["small.txt"]))
+		asserts.Zero(fake.nextID)
+	}
+
+	// 分塊上傳，失敗的分塊重試
+	{
+		fake.failPart[2] = 2
+		err := handler.Put(context.Background(), ioutil.NopCloser(bytes.NewReader(content)), "large.bin", uint64(len(content)))
+		asserts.NoError(err)
+		asserts.Equal(content, fake.objects["large.bin"])
+		asserts.Empty(fake.uploads)
+		asserts.Empty(fake.aborted)
+	}
+
+	// 分塊重試後仍失敗，中止上傳
+	{
+		fake.failPart[2] = -1
+		err := handler.Put(context.Background(), ioutil.NopCloser(bytes.NewReader(content)), "failed.bin", uint64(len(content)))
+		asserts.Error(err)
+		asserts.NotContains(fake.objects, "failed.bin")
+		asserts.Equal([]string{"upload-2"}, fake.aborted)
+		asserts.Empty(fake.uploads)
+		delete(fake.failPart, 2)
+	}
+
+	// 上傳過程中取消，中止上傳
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		fake.onPart = func(part int) {
+			if part == 2 {
+				cancel()
+			}
+		}
+		fake.failPart[2] = -1
+		err := handler.Put(ctx, ioutil.NopCloser(bytes.NewReader(content)), "canceled.bin", uint64(len(content)))
+		asserts.Error(err)
+		asserts.NotContains(fake.objects, "canceled.bin")
+		asserts.Equal([]string{"upload-2", "upload-3"}, fake.aborted)
+		asserts.Empty(fake.uploads)
+	}
+}
+
+func TestDriver_AbortStaleUploads(t *testing.T) {
+	asserts := assert.New(t)
+	fake := newFakeS3Server()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	handler := newTestDriver(server)
+
+	now := time.Now()
+	for uploadID, upload := range map[string]struct {
+		key       string
+		initiated time.Time
+	}{
+		"stale":  {"uploads/1/1.txt", now.Add(-48 * time.Hour)},
+		"recent": {"uploads/1/2.txt", now},
+		"others": {"others/1.txt", now.Add(-48 * time.Hour)},
+	} {
+		fake.uploads[uploadID] = map[int][]byte{}
+		fake.initiated[uploadID] = upload.initiated
+		fake.keys[uploadID] = upload.key
+	}
+
+	// 儲存路徑規則沒有固定前綴，且未允許清理整個儲存桶
+	{
+		handler.Policy.DirNameRule = "{uid}/{path}"
+		aborted, err := handler.AbortStaleUploads(context.Background(), now.Add(-24*time.Hour))
+		asserts.Equal(ErrUnscopedStaleUploads, err)
+		asserts.Zero(aborted)
+		asserts.Empty(fake.aborted)
+	}
+
+	// 只中止儲存策略鍵前綴下的上傳
+	{
+		handler.Policy.DirNameRule = "/uploads/{uid}/{path}"
+		aborted, err := handler.AbortStaleUploads(context.Background(), now.Add(-24*time.Hour))
+		asserts.NoError(err)
+		asserts.Equal(1, aborted)
+		asserts.Equal([]string{"stale"}, fake.aborted)
+		asserts.Contains(fake.uploads, "recent")
+		asserts.Contains(fake.uploads, "others")
+	}
+
+	// 明確允許時清理整個儲存桶
+	{
+		handler.Policy.DirNameRule = "{uid}/{path}"
+		handler.Policy.OptionsSerialized.S3AbortUnscopedUploads = true
+		aborted, err := handler.AbortStaleUploads(context.Background(), now.Add(-24*time.Hour))
+		asserts.NoError(err)
+		asserts.Equal(1, aborted)
+		asserts.Equal([]string{"stale", "others"}, fake.aborted)
+		asserts.Contains(fake.uploads, "recent")
+	}
+
+	// 未指定儲存策略
+	{
+		handler := Driver{}
+		_, err := handler.AbortStaleUploads(context.Background(), now)
+		asserts.Error(err)
+	}
+}
@@ -0,0 +1,230 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	sftpsdk "github.com/pkg/sftp"
+)
+
+// ErrFileExisted 物理同名文件已存在
+var ErrFileExisted = errors.New("物理同名文件已存在")
+
+// Driver SFTP 適配器，經由 SSH 連線存取遠端伺服器上的文件。
+// 文件內容經由伺服器中轉，縮圖由主機生成
+type Driver struct {
+	Policy *model.Policy
+}
+
+// remotePath 返回文件在遠端伺服器上的路徑
+func (handler Driver) remotePath(p string) string {
+	root := handler.Policy.OptionsSerialized.SFTPRoot
+	if root == "" {
+		return path.Clean(p)
+	}
+	return path.Join(root, p)
+}
+
+// withClient 從連線池取用連線並執行 fn
+func (handler Driver) withClient(ctx context.Context, fn func(client *sftpsdk.Client) error) error {
+	p, err := getPool(handler.Policy)
+	if err != nil {
+		return err
+	}
+	c, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(c.sftp)
+	p.release(c, err)
+	return err
+}
+
+// Put 將文件流儲存到指定路徑
+func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	defer file.Close()
+
+	return handler.withClient(ctx, func(client *sftpsdk.Client) error {
+		dst := handler.remotePath(dst)
+		if err := client.MkdirAll(path.Dir(dst)); err != nil {
+			util.Log().Warning("無法建立目錄，%s", err)
+			return err
+		}
+
+		// 如果禁止了 Overwrite，則不能覆蓋同名文件
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if ctx.Value(fsctx.DisableOverwrite) != nil {
+			if _, err := client.Stat(dst); err == nil {
+				return ErrFileExisted
+			}
+			flags |= os.O_EXCL
+		}
+
+		out, err := client.OpenFile(dst, flags)
+		if err != nil {
+			util.Log().Warning("無法建立文件，%s", err)
+			return err
+		}
+
+		_, err = io.Copy(out, file)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+}
+
+// Delete 刪除一個或多個文件，
+// 返回未刪除的文件，及遇到的最後一個錯誤
+func (handler Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	deleteFailed := make([]string, 0, len(files))
+	var retErr error
+
+	err := handler.withClient(ctx, func(client *sftpsdk.Client) error {
+		for _, value := range files {
+			err := client.Remove(handler.remotePath(value))
+			if err != nil && !os.IsNotExist(err) {
+				util.Log().Warning("無法刪除文件，%s", err)
+				retErr = err
+				deleteFailed = append(deleteFailed, value)
+			}
+
+			// 嘗試刪除文件的縮圖（如果有）
+			_ = client.Remove(handler.remotePath(value + conf.ThumbConfig.FileSuffix))
+		}
+		return retErr
+	})
+
+	// 無法取得連線
+	if err != nil && retErr == nil {
+		return files, err
+	}
+	return deleteFailed, retErr
+}
+
+// remoteFile 遠端文件流，關閉時歸還連線
+type remoteFile struct {
+	*sftpsdk.File
+	release func()
+	once    sync.Once
+	err     error
+}
+
+// Close 關閉文件並歸還連線，可重複呼叫
+func (file *remoteFile) Close() error {
+	file.once.Do(func() {
+		file.err = file.File.Close()
+		file.release()
+	})
+	return file.err
+}
+
+// Get 獲取文件內容，支援隨機存取
+func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	p, err := getPool(handler.Policy)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := c.sftp.Open(handler.remotePath(path))
+	if err != nil {
+		p.release(c, err)
+		return nil, err
+	}
+
+	return &remoteFile{
+		File:    file,
+		release: func() { p.release(c, nil) },
+	}, nil
+}
+
+// Thumb 獲取由主機生成並存放在遠端的縮圖
+func (handler Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	file, err := handler.Get(ctx, path+conf.ThumbConfig.FileSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.ContentResponse{
+		Redirect: false,
+		Content:  file,
+	}, nil
+}
+
+// Source 獲取由主機中轉的外鏈URL
+func (handler Driver) Source(
+	ctx context.Context,
+	path string,
+	baseURL url.URL,
+	ttl int64,
+	isDownload bool,
+	speed int,
+) (string, error) {
+	return local.Driver{Policy: handler.Policy}.Source(ctx, path, baseURL, ttl, isDownload, speed)
+}
+
+// Token SFTP 儲存策略經由伺服器中轉上傳，直接返回空值
+func (handler Driver) Token(ctx context.Context, ttl int64, key string) (serializer.UploadCredential, error) {
+	return serializer.UploadCredential{}, nil
+}
+
+// List 遞迴列取遠端路徑下文件、目錄，不包含path本身，
+// 返回的物件路徑以path作為起始根目錄
+func (handler Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	var res []response.Object
+
+	err := handler.withClient(ctx, func(client *sftpsdk.Client) error {
+		root := handler.remotePath(base)
+		walker := client.Walk(root)
+		for walker.Step() {
+			if err := walker.Err(); err != nil {
+				// 起始路徑無法讀取
+				if walker.Path() == root {
+					return err
+				}
+				util.Log().Warning("無法遍歷目錄 %s, %s", walker.Path(), err)
+				continue
+			}
+			if walker.Path() == root {
+				continue
+			}
+
+			rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), root), "/")
+			info := walker.Stat()
+			res = append(res, response.Object{
+				Name:         info.Name(),
+				RelativePath: rel,
+				Source:       strings.TrimPrefix(path.Join(base, rel), "/"),
+				Size:         uint64(info.Size()),
+				IsDir:        info.IsDir(),
+				LastModify:   info.ModTime(),
+			})
+
+			// 如果非遞迴，則不步入目錄
+			if !recursive && info.IsDir() {
+				walker.SkipDir()
+			}
+		}
+		return nil
+	})
+
+	return res, err
+}
@@ -0,0 +1,314 @@
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	sftpsdk "github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer 在本機啟動的 SSH 伺服器，提供 SFTP 子系統
+type testServer struct {
+	listener net.Listener
+	hostKey  ssh.PublicKey
+	dials    int32
+}
+
+// newKey 生成 PEM 格式的私鑰
+func newKey(t *testing.T) (ssh.Signer, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	signer, err := ssh.ParsePrivateKey(keyPEM)
+	assert.NoError(t, err)
+	return signer, string(keyPEM)
+}
+
+// newTestServer 啟動只接受 clientKey 登入的測試伺服器
+func newTestServer(t *testing.T, clientKey ssh.PublicKey) *testServer {
+	hostSigner, _ := newKey(t)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() == "cloudreve" && bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := &testServer{listener: listener, hostKey: hostSigner.PublicKey()}
+
+	go func() {
+		for {
+			nConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&server.dials, 1)
+			go serveConn(nConn, config)
+		}
+	}()
+	return server
+}
+
+// serveConn 處理一個 SSH 連線上的 SFTP 請求
+func serveConn(nConn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(nConn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func(in <-chan *ssh.Request) {
+			for req := range in {
+				req.Reply(req.Type == "subsystem" && string(req.Payload[4:]) == "sftp", nil)
+			}
+		}(requests)
+
+		go func() {
+			server, err := sftpsdk.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+			server.Close()
+		}()
+	}
+}
+
+// newTestDriver 建立連線到測試伺服器的適配器，根目錄為臨時目錄
+func newTestDriver(t *testing.T, id uint) (Driver, *testServer, string) {
+	clientSigner, clientKey := newKey(t)
+	server := newTestServer(t, clientSigner.PublicKey())
+	root, err := ioutil.TempDir("", "sftp")
+	assert.NoError(t, err)
+
+	return Driver{Policy: &model.Policy{
+		Model:     gorm.Model{ID: id},
+		Type:      "sftp",
+		Server:    server.listener.Addr().String(),
+		AccessKey: "cloudreve",
+		SecretKey: clientKey,
+		OptionsSerialized: model.PolicyOption{
+			SFTPRoot:    root,
+			SFTPHostKey: string(ssh.MarshalAuthorizedKey(server.hostKey)),
+		},
+	}}, server, root
+}
+
+func TestDriver_PutGetDelete(t *testing.T) {
+	asserts := assert.New(t)
+	handler, server, root := newTestDriver(t, 1)
+	defer server.listener.Close()
+	defer os.RemoveAll(root)
+	ctx := context.Background()
+
+	// 上傳到不存在的目錄
+	{
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("hello world")), "1/dir/1.txt", 11)
+		asserts.NoError(err)
+		content, err := ioutil.ReadFile(filepath.Join(root, "1/dir/1.txt"))
+		asserts.NoError(err)
+		asserts.Equal("hello world", string(content))
+	}
+
+	// 禁止覆蓋時不能覆蓋同名文件
+	{
+		overwriteCtx := context.WithValue(ctx, fsctx.DisableOverwrite, true)
+		err := handler.Put(overwriteCtx, ioutil.NopCloser(strings.NewReader("new")), "1/dir/1.txt", 3)
+		asserts.Equal(ErrFileExisted, err)
+	}
+
+	// 隨機讀取
+	{
+		rs, err := handler.Get(ctx, "1/dir/1.txt")
+		asserts.NoError(err)
+		_, err = rs.Seek(6, io.SeekStart)
+		asserts.NoError(err)
+		content, err := ioutil.ReadAll(rs)
+		asserts.NoError(err)
+		asserts.Equal("world", string(content))
+		asserts.NoError(rs.Close())
+		asserts.NoError(rs.Close())
+	}
+
+	// 文件不存在
+	{
+		rs, err := handler.Get(ctx, "not_exist.txt")
+		asserts.Error(err)
+		asserts.Nil(rs)
+	}
+
+	// 縮圖
+	{
+		asserts.NoError(handler.Put(ctx, ioutil.NopCloser(strings.NewReader("thumb")), "1/dir/1.txt"+conf.ThumbConfig.FileSuffix, 5))
+		res, err := handler.Thumb(ctx, "1/dir/1.txt")
+		asserts.NoError(err)
+		asserts.False(res.Redirect)
+		content, _ := ioutil.ReadAll(res.Content)
+		asserts.Equal("thumb", string(content))
+		res.Content.Close()
+	}
+
+	// 刪除文件及其縮圖，不存在的文件視為刪除成功
+	{
+		failed, err := handler.Delete(ctx, []string{"1/dir/1.txt", "not_exist.txt"})
+		asserts.NoError(err)
+		asserts.Empty(failed)
+		_, err = os.Stat(filepath.Join(root, "1/dir/1.txt"))
+		asserts.True(os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(root, "1/dir/1.txt"+conf.ThumbConfig.FileSuffix))
+		asserts.True(os.IsNotExist(err))
+	}
+
+	// 連線被重複使用
+	{
+		asserts.EqualValues(1, atomic.LoadInt32(&server.dials))
+	}
+}
+
+func TestDriver_List(t *testing.T) {
+	asserts := assert.New(t)
+	handler, server, root := newTestDriver(t, 2)
+	defer server.listener.Close()
+	defer os.RemoveAll(root)
+	ctx := context.Background()
+
+	asserts.NoError(os.MkdirAll(filepath.Join(root, "import/sub"), 0755))
+	asserts.NoError(ioutil.WriteFile(filepath.Join(root, "import/1.txt"), []byte("1"), 0644))
+	asserts.NoError(ioutil.WriteFile(filepath.Join(root, "import/sub/2.txt"), []byte("22"), 0644))
+
+	// 遞迴列取
+	{
+		res, err := handler.List(ctx, "/import", true)
+		asserts.NoError(err)
+		asserts.Len(res, 3)
+		objects := make(map[string]bool)
+		for _, object := range res {
+			objects[object.RelativePath] = object.IsDir
+			if object.RelativePath == "sub/2.txt" {
+				asserts.Equal("import/sub/2.txt", object.Source)
+				asserts.Equal("2.txt", object.Name)
+				asserts.EqualValues(2, object.Size)
+			}
+		}
+		asserts.Equal(map[string]bool{"1.txt": false, "sub": true, "sub/2.txt": false}, objects)
+	}
+
+	// 非遞迴列取
+	{
+		res, err := handler.List(ctx, "/import", false)
+		asserts.NoError(err)
+		asserts.Len(res, 2)
+	}
+
+	// 目錄不存在
+	{
+		_, err := handler.List(ctx, "/not_exist", true)
+		asserts.Error(err)
+	}
+}
+
+func TestDriver_Auth(t *testing.T) {
+	asserts := assert.New(t)
+	handler, server, root := newTestDriver(t, 3)
+	defer server.listener.Close()
+	defer os.RemoveAll(root)
+	ctx := context.Background()
+
+	// 主機公鑰不符
+	{
+		other, _ := newKey(t)
+		handler.Policy.OptionsSerialized.SFTPHostKey = string(ssh.MarshalAuthorizedKey(other.PublicKey()))
+		_, err := handler.List(ctx, "/", true)
+		asserts.Error(err)
+	}
+
+	// 私鑰格式錯誤
+	{
+		handler.Policy.SecretKey = "invalid"
+		_, err := handler.Get(ctx, "1.txt")
+		asserts.Equal(ErrInvalidPrivateKey, err)
+	}
+
+	// 主機公鑰格式錯誤
+	{
+		_, clientKey := newKey(t)
+		_, err := ClientConfig(&model.Policy{
+			SecretKey:         clientKey,
+			OptionsSerialized: model.PolicyOption{SFTPHostKey: "invalid"},
+		})
+		asserts.Equal(ErrInvalidHostKey, err)
+	}
+
+	// 未設定主機公鑰
+	{
+		_, clientKey := newKey(t)
+		policy := &model.Policy{SecretKey: clientKey}
+		_, err := ClientConfig(policy)
+		asserts.Equal(ErrHostKeyRequired, err)
+
+		// 明確允許不驗證伺服器身份
+		policy.OptionsSerialized.SFTPInsecureIgnoreHostKey = true
+		_, err = ClientConfig(policy)
+		asserts.NoError(err)
+	}
+}
+
+func TestDriver_SourceAndToken(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{Policy: &model.Policy{Type: "sftp"}}
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, model.File{Model: gorm.Model{ID: 1}, Name: "1.txt"})
+	baseURL, _ := url.Parse("https://cloudreve.org")
+	auth.General = auth.HMACAuth{SecretKey: []byte("test")}
+
+	// 由主機中轉
+	{
+		sourceURL, err := handler.Source(ctx, "1.txt", *baseURL, 0, false, 0)
+		asserts.NoError(err)
+		asserts.Contains(sourceURL, "https://cloudreve.org")
+		asserts.Contains(sourceURL, "sign=")
+	}
+
+	// 上傳憑證為空
+	{
+		credential, err := handler.Token(ctx, 10, "key")
+		asserts.NoError(err)
+		asserts.Empty(credential)
+	}
+}
@@ -0,0 +1,248 @@
+package sftp
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	sftpsdk "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// defaultMaxConns 未設定時每個儲存策略的最大連線數
+	defaultMaxConns = 4
+	// dialTimeout 建立連線的逾時時間
+	dialTimeout = 10 * time.Second
+	// idleCheckAfter 閒置超過此時間的連線在取用前檢查是否仍可用
+	idleCheckAfter = 30 * time.Second
+)
+
+var (
+	// ErrInvalidPrivateKey 私鑰格式錯誤
+	ErrInvalidPrivateKey = errors.New("無法解析 SFTP 登入私鑰")
+	// ErrInvalidHostKey 主機公鑰格式錯誤
+	ErrInvalidHostKey = errors.New("無法解析 SFTP 主機公鑰，須為 authorized_keys 格式")
+	// ErrHostKeyRequired 未設定主機公鑰
+	ErrHostKeyRequired = errors.New("請設定 SFTP 主機公鑰，或明確允許不驗證伺服器身份")
+)
+
+// conn 連線池中的一個連線
+type conn struct {
+	ssh      *ssh.Client
+	sftp     *sftpsdk.Client
+	lastUsed time.Time
+}
+
+// close 關閉連線
+func (c *conn) close() {
+	c.sftp.Close()
+	c.ssh.Close()
+}
+
+// alive 返回連線是否仍可用
+func (c *conn) alive() bool {
+	_, err := c.sftp.Getwd()
+	return err == nil
+}
+
+// pool 到同一台伺服器的連線池
+type pool struct {
+	addr   string
+	config *ssh.ClientConfig
+	// sem 限制同時使用中的連線數
+	sem chan struct{}
+
+	mu   sync.Mutex
+	idle []*conn
+	// closed 連線池已被取代，歸還的連線直接關閉
+	closed bool
+}
+
+// policyPool 儲存策略的連線池及建立時使用的配置
+type policyPool struct {
+	fingerprint string
+	pool        *pool
+}
+
+var (
+	poolsMu sync.Mutex
+	pools   = make(map[uint]*policyPool)
+)
+
+// fingerprint 返回儲存策略連線配置的摘要，配置變更後使用新的連線池
+func fingerprint(policy *model.Policy) string {
+	h := sha1.New()
+	for _, field := range []string{
+		policy.Server,
+		policy.AccessKey,
+		policy.SecretKey,
+		policy.OptionsSerialized.SFTPHostKey,
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{byte(policy.OptionsSerialized.SFTPMaxConns)})
+	if policy.OptionsSerialized.SFTPInsecureIgnoreHostKey {
+		h.Write([]byte{1})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ClientConfig 按儲存策略建立 SSH 用戶端配置，使用私鑰登入。
+// 未設定主機公鑰時，僅在管理員明確允許後才不驗證伺服器身份
+func ClientConfig(policy *model.Policy) (*ssh.ClientConfig, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(policy.SecretKey))
+	if err != nil {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case policy.OptionsSerialized.SFTPHostKey != "":
+		hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(policy.OptionsSerialized.SFTPHostKey))
+		if err != nil {
+			return nil, ErrInvalidHostKey
+		}
+		hostKeyCallback = ssh.FixedHostKey(hostKey)
+	case policy.OptionsSerialized.SFTPInsecureIgnoreHostKey:
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, ErrHostKeyRequired
+	}
+
+	return &ssh.ClientConfig{
+		User:            policy.AccessKey,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         dialTimeout,
+	}, nil
+}
+
+// getPool 獲取儲存策略的連線池，不存在或配置已變更時建立
+func getPool(policy *model.Policy) (*pool, error) {
+	fp := fingerprint(policy)
+
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	if existing, ok := pools[policy.ID]; ok {
+		if existing.fingerprint == fp {
+			return existing.pool, nil
+		}
+		existing.pool.close()
+	}
+
+	config, err := ClientConfig(policy)
+	if err != nil {
+		return nil, err
+	}
+	if policy.OptionsSerialized.SFTPHostKey == "" {
+		util.Log().Warning("SFTP 儲存策略 [%s] 未設定主機公鑰，將不驗證伺服器身份", policy.Name)
+	}
+
+	maxConns := policy.OptionsSerialized.SFTPMaxConns
+	if maxConns <= 0 {
+		maxConns = defaultMaxConns
+	}
+
+	p := &pool{
+		addr:   policy.Server,
+		config: config,
+		sem:    make(chan struct{}, maxConns),
+	}
+	pools[policy.ID] = &policyPool{fingerprint: fp, pool: p}
+	return p, nil
+}
+
+// acquire 取用一個連線，連線數已達上限時等待其他連線被歸還
+func (p *pool) acquire(ctx context.Context) (*conn, error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		p.mu.Lock()
+		n := len(p.idle)
+		if n == 0 {
+			p.mu.Unlock()
+			break
+		}
+		c := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+
+		if time.Since(c.lastUsed) < idleCheckAfter || c.alive() {
+			return c, nil
+		}
+		c.close()
+	}
+
+	c, err := p.dial()
+	if err != nil {
+		<-p.sem
+		return nil, err
+	}
+	return c, nil
+}
+
+// release 歸還連線。操作出錯且連線已不可用時關閉連線
+func (p *pool) release(c *conn, err error) {
+	defer func() { <-p.sem }()
+
+	if err != nil && !c.alive() {
+		c.close()
+		return
+	}
+
+	c.lastUsed = time.Now()
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		c.close()
+		return
+	}
+	p.idle = append(p.idle, c)
+	p.mu.Unlock()
+}
+
+// close 關閉連線池及所有閒置的連線
+func (p *pool) close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, c := range idle {
+		c.close()
+	}
+}
+
+// dial 建立新的連線
+func (p *pool) dial() (*conn, error) {
+	addr := p.addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	sshClient, err := ssh.Dial("tcp", addr, p.config)
+	if err != nil {
+		return nil, err
+	}
+	sftpClient, err := sftpsdk.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+
+	return &conn{ssh: sshClient, sftp: sftpClient, lastUsed: time.Now()}, nil
+}
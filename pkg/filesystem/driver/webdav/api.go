@@ -0,0 +1,195 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+)
+
+const (
+	// DepthOne 列取目錄下的直接子項目
+	DepthOne = "1"
+	// DepthInfinity 遞迴列取目錄下的全部項目
+	DepthInfinity = "infinity"
+)
+
+// PropFind 列取遠端路徑及其子項目的屬性，路徑不存在時返回 ErrObjectNotExist
+func (client *Client) PropFind(ctx context.Context, p string, depth string) ([]Entry, error) {
+	resp, err := client.request(
+		ctx,
+		"PROPFIND",
+		client.objectURL(p, false),
+		strings.NewReader(propfindBody),
+		http.Header{
+			"Depth":        {depth},
+			"Content-Type": {"application/xml; charset=utf-8"},
+		},
+		request.WithContentLength(int64(len(propfindBody))),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer drain(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusMultiStatus:
+	case http.StatusNotFound:
+		return nil, ErrObjectNotExist
+	default:
+		return nil, StatusError{Method: "PROPFIND", StatusCode: resp.StatusCode}
+	}
+
+	var res multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(res.Responses))
+	for _, item := range res.Responses {
+		entry, ok := client.parseResponse(item)
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// parseResponse 將 PROPFIND 響應中的一項轉換為 Entry
+func (client *Client) parseResponse(item propResponse) (Entry, bool) {
+	href, err := url.Parse(item.Href)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	// 轉換為相對於伺服器根目錄的路徑
+	rel := strings.TrimPrefix(path.Clean("/"+href.Path), path.Clean("/"+client.Endpoint.Path))
+	entry := Entry{Path: strings.Trim(rel, "/")}
+
+	for _, stat := range item.Propstat {
+		if !strings.Contains(stat.Status, " 200 ") {
+			continue
+		}
+		entry.IsDir = stat.Prop.ResourceType.Collection != nil
+		entry.Size = stat.Prop.ContentLength
+		if modified, err := http.ParseTime(stat.Prop.LastModified); err == nil {
+			entry.LastModify = modified
+		}
+		return entry, true
+	}
+	return Entry{}, false
+}
+
+// Stat 獲取遠端路徑的屬性
+func (client *Client) Stat(ctx context.Context, p string) (*Entry, error) {
+	entries, err := client.PropFind(ctx, p, "0")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrObjectNotExist
+	}
+	return &entries[0], nil
+}
+
+// MkcolAll 建立遠端目錄及其所有上層目錄
+func (client *Client) MkcolAll(ctx context.Context, dir string) error {
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	if dir == "" {
+		return nil
+	}
+
+	// 目錄已存在
+	if entry, err := client.Stat(ctx, dir); err == nil && entry.IsDir {
+		return nil
+	}
+
+	current := ""
+	for _, segment := range strings.Split(dir, "/") {
+		current = path.Join(current, segment)
+		// 405 表示目錄已存在
+		if _, err := client.do(ctx, "MKCOL", client.objectURL(current, true), nil, nil,
+			[]int{http.StatusCreated, http.StatusMethodNotAllowed}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Put 上傳文件，overwrite 為假時不覆蓋已存在的文件
+func (client *Client) Put(ctx context.Context, p string, body io.Reader, size uint64, overwrite bool) error {
+	if !overwrite {
+		if _, err := client.Stat(ctx, p); err == nil {
+			return ErrFileExisted
+		} else if err != ErrObjectNotExist {
+			return err
+		}
+	}
+
+	if err := client.MkcolAll(ctx, path.Dir(p)); err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if !overwrite {
+		header.Set("If-None-Match", "*")
+	}
+	status, err := client.do(ctx, "PUT", client.objectURL(p, false), body, header,
+		[]int{http.StatusOK, http.StatusCreated, http.StatusNoContent, http.StatusPreconditionFailed},
+		request.WithContentLength(int64(size)),
+		request.WithTimeout(0),
+	)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusPreconditionFailed {
+		return ErrFileExisted
+	}
+	return nil
+}
+
+// Get 從 offset 處開始下載文件，伺服器不支援範圍請求時丟棄 offset 之前的內容
+func (client *Client) Get(ctx context.Context, p string, offset int64) (io.ReadCloser, int64, error) {
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := client.request(ctx, "GET", client.objectURL(p, false), nil, header, request.WithTimeout(0))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, offset + resp.ContentLength, nil
+	case http.StatusOK:
+		if offset > 0 {
+			if _, err := io.CopyN(ioutil.Discard, resp.Body, offset); err != nil {
+				drain(resp.Body)
+				return nil, 0, err
+			}
+		}
+		return resp.Body, resp.ContentLength, nil
+	case http.StatusNotFound:
+		drain(resp.Body)
+		return nil, 0, ErrObjectNotExist
+	default:
+		drain(resp.Body)
+		return nil, 0, StatusError{Method: "GET", StatusCode: resp.StatusCode}
+	}
+}
+
+// Delete 刪除遠端文件，文件不存在時視為成功
+func (client *Client) Delete(ctx context.Context, p string) error {
+	_, err := client.do(ctx, "DELETE", client.objectURL(p, false), nil, nil,
+		[]int{http.StatusOK, http.StatusNoContent, http.StatusNotFound})
+	return err
+}
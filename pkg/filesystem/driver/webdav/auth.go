@@ -0,0 +1,171 @@
+package webdav
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// ErrInvalidChallenge 伺服器未返回有效的 Digest 認證挑戰
+var ErrInvalidChallenge = errors.New("WebDAV 伺服器未返回有效的 Digest 認證挑戰")
+
+// digestChallenge 伺服器返回的 Digest 認證挑戰
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	// nc 使用此挑戰的請求計數
+	nc int
+}
+
+// parseChallenge 解析 WWW-Authenticate 標頭中的 Digest 認證挑戰
+func parseChallenge(header string) (*digestChallenge, error) {
+	if !strings.HasPrefix(strings.ToLower(header), "digest ") {
+		return nil, ErrInvalidChallenge
+	}
+
+	params := make(map[string]string)
+	for _, param := range splitParams(header[len("digest "):]) {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		params[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+	}
+
+	challenge := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+	}
+	if challenge.nonce == "" {
+		return nil, ErrInvalidChallenge
+	}
+	if challenge.algorithm != "" && !strings.EqualFold(challenge.algorithm, "MD5") {
+		return nil, ErrInvalidChallenge
+	}
+
+	// 只支援 qop=auth
+	for _, qop := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(qop) == "auth" {
+			challenge.qop = "auth"
+		}
+	}
+	return challenge, nil
+}
+
+// splitParams 按逗號分割認證參數，忽略引號中的逗號
+func splitParams(s string) []string {
+	var (
+		res     []string
+		quoted  bool
+		current strings.Builder
+	)
+	for _, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			res = append(res, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteRune(c)
+	}
+	return append(res, current.String())
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// challenge 返回快取的 Digest 認證挑戰
+func (client *Client) challenge() *digestChallenge {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.digest
+}
+
+// setChallenge 快取 Digest 認證挑戰
+func (client *Client) setChallenge(challenge *digestChallenge) {
+	client.mu.Lock()
+	client.digest = challenge
+	client.mu.Unlock()
+}
+
+// refreshChallenge 發送不帶認證的請求以獲取 Digest 認證挑戰
+func (client *Client) refreshChallenge(ctx context.Context) error {
+	resp, err := client.requestUnauthorized(ctx, "OPTIONS", client.objectURL("/", true))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return ErrInvalidChallenge
+	}
+
+	challenge, err := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	if err != nil {
+		return err
+	}
+	client.setChallenge(challenge)
+	return nil
+}
+
+// requestUnauthorized 發送不帶認證的請求，丟棄響應正文
+func (client *Client) requestUnauthorized(ctx context.Context, method string, target *url.URL) (*http.Response, error) {
+	res := client.Request.Request(method, target.String(), nil, request.WithContext(ctx))
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	drain(res.Response.Body)
+	return res.Response, nil
+}
+
+// authorization 返回請求使用的 Authorization 標頭
+func (client *Client) authorization(method string, target *url.URL) string {
+	if client.Auth != AuthDigest {
+		return "Basic " + base64.StdEncoding.EncodeToString(
+			[]byte(client.Policy.AccessKey+":"+client.Policy.SecretKey),
+		)
+	}
+
+	client.mu.Lock()
+	client.digest.nc++
+	challenge := *client.digest
+	client.mu.Unlock()
+
+	uri := target.RequestURI()
+	ha1 := md5Hex(client.Policy.AccessKey + ":" + challenge.realm + ":" + client.Policy.SecretKey)
+	ha2 := md5Hex(method + ":" + uri)
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s"`,
+		client.Policy.AccessKey, challenge.realm, challenge.nonce, uri)
+	if challenge.qop == "auth" {
+		nc := fmt.Sprintf("%08x", challenge.nc)
+		cnonce := util.RandStringRunes(16)
+		header += fmt.Sprintf(`, qop=auth, nc=%s, cnonce="%s", response="%s"`,
+			nc, cnonce, md5Hex(ha1+":"+challenge.nonce+":"+nc+":"+cnonce+":auth:"+ha2))
+	} else {
+		header += fmt.Sprintf(`, response="%s"`, md5Hex(ha1+":"+challenge.nonce+":"+ha2))
+	}
+	if challenge.opaque != "" {
+		header += fmt.Sprintf(`, opaque="%s"`, challenge.opaque)
+	}
+	if challenge.algorithm != "" {
+		header += ", algorithm=" + challenge.algorithm
+	}
+	return header
+}
@@ -0,0 +1,167 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+)
+
+const (
+	// AuthBasic 使用 HTTP Basic 認證
+	AuthBasic = "basic"
+	// AuthDigest 使用 HTTP Digest 認證
+	AuthDigest = "digest"
+)
+
+var (
+	// ErrInvalidEndpoint 無法解析 WebDAV 伺服器地址
+	ErrInvalidEndpoint = errors.New("無法解析 WebDAV 伺服器地址，須為 http 或 https 開頭的URL")
+	// ErrUnknownAuth 未知的認證方式
+	ErrUnknownAuth = errors.New("未知的 WebDAV 認證方式")
+	// ErrFileExisted 物理同名文件已存在
+	ErrFileExisted = errors.New("物理同名文件已存在")
+	// ErrObjectNotExist 遠端文件不存在
+	ErrObjectNotExist = errors.New("遠端文件不存在")
+)
+
+// StatusError 伺服器返回非預期的HTTP狀態
+type StatusError struct {
+	Method     string
+	StatusCode int
+}
+
+func (err StatusError) Error() string {
+	return fmt.Sprintf("WebDAV 伺服器對 %s 請求返回非正常HTTP狀態%d", err.Method, err.StatusCode)
+}
+
+// Client WebDAV 用戶端
+type Client struct {
+	Policy   *model.Policy
+	Endpoint *url.URL
+	Auth     string
+
+	Request request.Client
+
+	// digest 快取的 Digest 認證挑戰
+	mu     sync.Mutex
+	digest *digestChallenge
+}
+
+// NewClient 根據儲存策略獲取新的client
+func NewClient(policy *model.Policy) (*Client, error) {
+	endpoint, err := url.Parse(policy.Server)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, ErrInvalidEndpoint
+	}
+
+	auth := policy.OptionsSerialized.WebDAVAuth
+	switch auth {
+	case "":
+		auth = AuthBasic
+	case AuthBasic, AuthDigest:
+	default:
+		return nil, ErrUnknownAuth
+	}
+
+	return &Client{
+		Policy:   policy,
+		Endpoint: endpoint,
+		Auth:     auth,
+		Request:  request.HTTPClient{},
+	}, nil
+}
+
+// objectURL 返回遠端路徑對應的URL，目錄以 / 結尾
+func (client *Client) objectURL(p string, isDir bool) *url.URL {
+	target := *client.Endpoint
+	target.Path = path.Join("/", client.Endpoint.Path, p)
+	if isDir && !strings.HasSuffix(target.Path, "/") {
+		target.Path += "/"
+	}
+	target.RawPath = ""
+	return &target
+}
+
+// request 發送經過認證的請求。Digest 認證的挑戰過期時，
+// 正文可以重新讀取的請求會以新的挑戰重試一次
+func (client *Client) request(ctx context.Context, method string, target *url.URL, body io.Reader, header http.Header, option ...request.Option) (*http.Response, error) {
+	if client.Auth == AuthDigest && client.challenge() == nil {
+		if err := client.refreshChallenge(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for retried := false; ; retried = true {
+		reqHeader := http.Header{}
+		for k, v := range header {
+			reqHeader[k] = v
+		}
+		reqHeader.Set("Authorization", client.authorization(method, target))
+
+		res := client.Request.Request(
+			method,
+			target.String(),
+			body,
+			append(option, request.WithHeader(reqHeader), request.WithContext(ctx))...,
+		)
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		if res.Response.StatusCode == http.StatusUnauthorized && client.Auth == AuthDigest && !retried {
+			challenge, err := parseChallenge(res.Response.Header.Get("WWW-Authenticate"))
+			drain(res.Response.Body)
+			if err != nil || !rewind(body) {
+				return nil, StatusError{Method: method, StatusCode: http.StatusUnauthorized}
+			}
+			client.setChallenge(challenge)
+			continue
+		}
+
+		return res.Response, nil
+	}
+}
+
+// do 發送請求並檢查狀態碼，丟棄響應正文
+func (client *Client) do(ctx context.Context, method string, target *url.URL, body io.Reader, header http.Header, expected []int, option ...request.Option) (int, error) {
+	resp, err := client.request(ctx, method, target, body, header, option...)
+	if err != nil {
+		return 0, err
+	}
+	defer drain(resp.Body)
+
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return resp.StatusCode, nil
+		}
+	}
+	return resp.StatusCode, StatusError{Method: method, StatusCode: resp.StatusCode}
+}
+
+// drain 讀取並關閉響應正文，以便重複使用連線
+func drain(body io.ReadCloser) {
+	_, _ = io.Copy(ioutil.Discard, body)
+	body.Close()
+}
+
+// rewind 將請求正文恢復到開頭，返回正文是否可以重新讀取
+func rewind(body io.Reader) bool {
+	if body == nil {
+		return true
+	}
+	if seeker, ok := body.(io.Seeker); ok {
+		_, err := seeker.Seek(0, io.SeekStart)
+		return err == nil
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// Driver WebDAV 適配器，經由 HTTP 存取遠端 WebDAV 伺服器上的文件。
+// 文件內容經由伺服器中轉，縮圖由主機生成
+type Driver struct {
+	Policy *model.Policy
+	Client *Client
+}
+
+// Put 將文件流上傳到指定路徑
+func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	defer file.Close()
+
+	// 如果禁止了 Overwrite，則不能覆蓋同名文件
+	overwrite := ctx.Value(fsctx.DisableOverwrite) == nil
+	return handler.Client.Put(ctx, dst, file, size, overwrite)
+}
+
+// Delete 刪除一個或多個文件，
+// 返回未刪除的文件，及遇到的最後一個錯誤
+func (handler Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	deleteFailed := make([]string, 0, len(files))
+	var retErr error
+
+	for _, value := range files {
+		if err := handler.Client.Delete(ctx, value); err != nil {
+			util.Log().Warning("無法刪除文件，%s", err)
+			retErr = err
+			deleteFailed = append(deleteFailed, value)
+		}
+
+		// 嘗試刪除文件的縮圖（如果有）
+		_ = handler.Client.Delete(ctx, value+conf.ThumbConfig.FileSuffix)
+	}
+
+	return deleteFailed, retErr
+}
+
+// rangeReader 按需發送範圍請求的遠端文件流，Seek 後從新位置重新請求
+type rangeReader struct {
+	ctx    context.Context
+	client *Client
+	path   string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+// Read 實現 io.Reader
+func (reader *rangeReader) Read(p []byte) (int, error) {
+	if reader.body == nil {
+		if reader.size >= 0 && reader.offset >= reader.size {
+			return 0, io.EOF
+		}
+		body, _, err := reader.client.Get(reader.ctx, reader.path, reader.offset)
+		if err != nil {
+			return 0, err
+		}
+		reader.body = body
+	}
+
+	n, err := reader.body.Read(p)
+	reader.offset += int64(n)
+	return n, err
+}
+
+// Seek 實現 io.Seeker，位置變更時關閉目前的請求
+func (reader *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += reader.offset
+	case io.SeekEnd:
+		offset += reader.size
+	}
+	if offset < 0 {
+		return 0, errors.New("無效的偏移量")
+	}
+
+	if offset != reader.offset && reader.body != nil {
+		reader.body.Close()
+		reader.body = nil
+	}
+	reader.offset = offset
+	return offset, nil
+}
+
+// Close 實現 io.Closer
+func (reader *rangeReader) Close() error {
+	if reader.body == nil {
+		return nil
+	}
+	err := reader.body.Close()
+	reader.body = nil
+	return err
+}
+
+// Get 獲取文件內容，支援隨機存取
+func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	body, size, err := handler.Client.Get(ctx, path, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// 伺服器未返回大小時使用文件記錄中的大小
+	if size < 0 {
+		if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+			size = int64(file.Size)
+		}
+	}
+
+	return &rangeReader{
+		ctx:    ctx,
+		client: handler.Client,
+		path:   path,
+		size:   size,
+		body:   body,
+	}, nil
+}
+
+// Thumb 獲取由主機生成並存放在遠端的縮圖
+func (handler Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	file, err := handler.Get(ctx, path+conf.ThumbConfig.FileSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.ContentResponse{
+		Redirect: false,
+		Content:  file,
+	}, nil
+}
+
+// Source 獲取由主機中轉的外鏈URL
+func (handler Driver) Source(
+	ctx context.Context,
+	path string,
+	baseURL url.URL,
+	ttl int64,
+	isDownload bool,
+	speed int,
+) (string, error) {
+	return local.Driver{Policy: handler.Policy}.Source(ctx, path, baseURL, ttl, isDownload, speed)
+}
+
+// Token WebDAV 儲存策略經由伺服器中轉上傳，直接返回空值
+func (handler Driver) Token(ctx context.Context, ttl int64, key string) (serializer.UploadCredential, error) {
+	return serializer.UploadCredential{}, nil
+}
+
+// List 列取遠端路徑下文件、目錄，不包含path本身，
+// 返回的物件路徑以path作為起始根目錄。伺服器不允許
+// Depth: infinity 時逐層列取
+func (handler Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	root := strings.Trim(path.Clean("/"+base), "/")
+
+	var (
+		entries []Entry
+		err     error
+	)
+	if recursive {
+		entries, err = handler.Client.PropFind(ctx, root, DepthInfinity)
+		if statusErr, ok := err.(StatusError); ok && statusErr.StatusCode == http.StatusForbidden {
+			entries, err = handler.listEach(ctx, root)
+		}
+	} else {
+		entries, err = handler.Client.PropFind(ctx, root, DepthOne)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]response.Object, 0, len(entries))
+	for _, entry := range entries {
+		rel := strings.TrimPrefix(strings.TrimPrefix(entry.Path, root), "/")
+		if rel == "" || (root != "" && !strings.HasPrefix(entry.Path, root+"/")) {
+			continue
+		}
+
+		res = append(res, response.Object{
+			Name:         path.Base(entry.Path),
+			RelativePath: rel,
+			Source:       entry.Path,
+			Size:         entry.Size,
+			IsDir:        entry.IsDir,
+			LastModify:   entry.LastModify,
+		})
+	}
+
+	return res, nil
+}
+
+// listEach 以 Depth: 1 逐層遞迴列取目錄
+func (handler Driver) listEach(ctx context.Context, dir string) ([]Entry, error) {
+	entries, err := handler.Client.PropFind(ctx, dir, DepthOne)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Path == dir {
+			continue
+		}
+		res = append(res, entry)
+		if entry.IsDir {
+			children, err := handler.listEach(ctx, entry.Path)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, children...)
+		}
+	}
+	return res, nil
+}
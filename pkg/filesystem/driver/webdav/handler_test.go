@@ -0,0 +1,361 @@
+package webdav
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/stretchr/testify/assert"
+	xwebdav "golang.org/x/net/webdav"
+)
+
+// testServer 以 golang.org/x/net/webdav 實現的測試伺服器，
+// 記錄收到的請求並按設定驗證認證訊息
+type testServer struct {
+	*httptest.Server
+	root string
+
+	mu       sync.Mutex
+	nonce    string
+	requests []*http.Request
+	// noInfinity 拒絕 Depth: infinity 的 PROPFIND 請求
+	noInfinity bool
+}
+
+func newTestServer(t *testing.T, authType string) *testServer {
+	root, err := ioutil.TempDir("", "webdav")
+	assert.NoError(t, err)
+
+	server := &testServer{root: root, nonce: "nonce1"}
+	dav := &xwebdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: xwebdav.Dir(root),
+		LockSystem: xwebdav.NewMemLS(),
+	}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		server.requests = append(server.requests, r)
+		nonce := server.nonce
+		noInfinity := server.noInfinity
+		server.mu.Unlock()
+
+		if !server.authorized(r, authType, nonce) {
+			if authType == AuthDigest {
+				w.Header().Set("WWW-Authenticate", `Digest realm="dav", nonce="`+nonce+`", qop="auth,auth-int", opaque="op", algorithm=MD5`)
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == "PROPFIND" && r.Header.Get("Depth") == "infinity" && noInfinity {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	return server
+}
+
+// authorized 驗證請求的認證訊息
+func (server *testServer) authorized(r *http.Request, authType, nonce string) bool {
+	if authType == AuthBasic {
+		user, pass, ok := r.BasicAuth()
+		return ok && user == "user" && pass == "pass"
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Digest ") {
+		return false
+	}
+	params := make(map[string]string)
+	for _, param := range splitParams(header[len("Digest "):]) {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			params[strings.TrimSpace(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		}
+	}
+
+	ha1 := md5Hex("user:dav:pass")
+	ha2 := md5Hex(r.Method + ":" + params["uri"])
+	expected := md5Hex(ha1 + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+	return params["username"] == "user" && params["nonce"] == nonce && params["opaque"] == "op" &&
+		params["uri"] == r.URL.RequestURI() && params["response"] == expected
+}
+
+// methods 返回收到的請求方法，並清空記錄
+func (server *testServer) methods() []string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	res := make([]string, 0, len(server.requests))
+	for _, r := range server.requests {
+		res = append(res, r.Method)
+	}
+	server.requests = nil
+	return res
+}
+
+func (server *testServer) close() {
+	server.Close()
+	os.RemoveAll(server.root)
+}
+
+func newTestDriver(t *testing.T, server *testServer, authType string) Driver {
+	policy := &model.Policy{
+		Type:      "webdav",
+		Server:    server.URL + "/dav/",
+		AccessKey: "user",
+		SecretKey: "pass",
+		OptionsSerialized: model.PolicyOption{
+			WebDAVAuth: authType,
+		},
+	}
+	client, err := NewClient(policy)
+	assert.NoError(t, err)
+	return Driver{Policy: policy, Client: client}
+}
+
+func TestNewClient(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 伺服器地址無效
+	{
+		_, err := NewClient(&model.Policy{Server: "nas:5005"})
+		asserts.Equal(ErrInvalidEndpoint, err)
+		_, err = NewClient(&model.Policy{Server: "ftp://nas/dav"})
+		asserts.Equal(ErrInvalidEndpoint, err)
+	}
+
+	// 未知的認證方式
+	{
+		_, err := NewClient(&model.Policy{
+			Server:            "https://nas/dav",
+			OptionsSerialized: model.PolicyOption{WebDAVAuth: "ntlm"},
+		})
+		asserts.Equal(ErrUnknownAuth, err)
+	}
+
+	// 預設使用 Basic 認證
+	{
+		client, err := NewClient(&model.Policy{Server: "https://nas/dav"})
+		asserts.NoError(err)
+		asserts.Equal(AuthBasic, client.Auth)
+		asserts.Equal("https://nas/dav/1/%E6%96%87%E4%BB%B6%20a.txt", client.objectURL("/1/文件 a.txt", false).String())
+		asserts.Equal("https://nas/dav/1/", client.objectURL("1", true).String())
+	}
+}
+
+func TestDriver_PutGetDelete(t *testing.T) {
+	for _, authType := range []string{AuthBasic, AuthDigest} {
+		asserts := assert.New(t)
+		server := newTestServer(t, authType)
+		handler := newTestDriver(t, server, authType)
+		ctx := context.Background()
+
+		// 上傳到不存在的目錄
+		{
+			err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("hello world")), "1/文件 夾/1.txt", 11)
+			asserts.NoError(err, authType)
+			content, err := ioutil.ReadFile(filepath.Join(server.root, "1/文件 夾/1.txt"))
+			asserts.NoError(err)
+			asserts.Equal("hello world", string(content))
+		}
+
+		// 覆蓋已有文件
+		{
+			err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("hello webdav")), "1/文件 夾/1.txt", 12)
+			asserts.NoError(err)
+		}
+
+		// 禁止覆蓋時不能覆蓋同名文件
+		{
+			overwriteCtx := context.WithValue(ctx, fsctx.DisableOverwrite, true)
+			err := handler.Put(overwriteCtx, ioutil.NopCloser(strings.NewReader("new")), "1/文件 夾/1.txt", 3)
+			asserts.Equal(ErrFileExisted, err)
+		}
+
+		// 隨機讀取時發送範圍請求
+		{
+			server.methods()
+			rs, err := handler.Get(ctx, "1/文件 夾/1.txt")
+			asserts.NoError(err)
+			size, err := rs.Seek(0, io.SeekEnd)
+			asserts.NoError(err)
+			asserts.EqualValues(12, size)
+			_, err = rs.Seek(6, io.SeekStart)
+			asserts.NoError(err)
+			content, err := ioutil.ReadAll(rs)
+			asserts.NoError(err)
+			asserts.Equal("webdav", string(content))
+			asserts.NoError(rs.Close())
+			asserts.Equal([]string{"GET", "GET"}, server.methods())
+		}
+
+		// 文件不存在
+		{
+			rs, err := handler.Get(ctx, "not_exist.txt")
+			asserts.Equal(ErrObjectNotExist, err)
+			asserts.Nil(rs)
+		}
+
+		// 縮圖
+		{
+			asserts.NoError(handler.Put(ctx, ioutil.NopCloser(strings.NewReader("thumb")), "1/文件 夾/1.txt"+conf.ThumbConfig.FileSuffix, 5))
+			res, err := handler.Thumb(ctx, "1/文件 夾/1.txt")
+			asserts.NoError(err)
+			asserts.False(res.Redirect)
+			content, _ := ioutil.ReadAll(res.Content)
+			asserts.Equal("thumb", string(content))
+			res.Content.Close()
+		}
+
+		// 刪除文件及其縮圖，不存在的文件視為刪除成功
+		{
+			failed, err := handler.Delete(ctx, []string{"1/文件 夾/1.txt", "not_exist.txt"})
+			asserts.NoError(err)
+			asserts.Empty(failed)
+			_, err = os.Stat(filepath.Join(server.root, "1/文件 夾/1.txt"))
+			asserts.True(os.IsNotExist(err))
+			_, err = os.Stat(filepath.Join(server.root, "1/文件 夾/1.txt"+conf.ThumbConfig.FileSuffix))
+			asserts.True(os.IsNotExist(err))
+		}
+
+		server.close()
+	}
+}
+
+func TestDriver_Auth(t *testing.T) {
+	asserts := assert.New(t)
+	server := newTestServer(t, AuthDigest)
+	defer server.close()
+	handler := newTestDriver(t, server, AuthDigest)
+	ctx := context.Background()
+	asserts.NoError(ioutil.WriteFile(filepath.Join(server.root, "1.txt"), []byte("1"), 0644))
+
+	// 首次請求前獲取認證挑戰
+	{
+		_, err := handler.List(ctx, "/", false)
+		asserts.NoError(err)
+		asserts.Equal([]string{"OPTIONS", "PROPFIND"}, server.methods())
+	}
+
+	// 挑戰過期後以新的挑戰重試
+	{
+		server.mu.Lock()
+		server.nonce = "nonce2"
+		server.mu.Unlock()
+		rs, err := handler.Get(ctx, "1.txt")
+		asserts.NoError(err)
+		rs.Close()
+		asserts.Equal([]string{"GET", "GET"}, server.methods())
+	}
+
+	// 密碼錯誤
+	{
+		handler.Policy.SecretKey = "wrong"
+		_, err := handler.Get(ctx, "1.txt")
+		asserts.Equal(StatusError{Method: "GET", StatusCode: http.StatusUnauthorized}, err)
+	}
+
+	// Basic 認證密碼錯誤
+	{
+		basicServer := newTestServer(t, AuthBasic)
+		defer basicServer.close()
+		handler := newTestDriver(t, basicServer, AuthBasic)
+		handler.Policy.SecretKey = "wrong"
+		_, err := handler.List(ctx, "/", true)
+		asserts.Equal(StatusError{Method: "PROPFIND", StatusCode: http.StatusUnauthorized}, err)
+	}
+}
+
+func TestDriver_List(t *testing.T) {
+	asserts := assert.New(t)
+	server := newTestServer(t, AuthBasic)
+	defer server.close()
+	handler := newTestDriver(t, server, AuthBasic)
+	ctx := context.Background()
+
+	asserts.NoError(os.MkdirAll(filepath.Join(server.root, "import/sub"), 0755))
+	asserts.NoError(ioutil.WriteFile(filepath.Join(server.root, "import/1.txt"), []byte("1"), 0644))
+	asserts.NoError(ioutil.WriteFile(filepath.Join(server.root, "import/sub/2 文件.txt"), []byte("22"), 0644))
+	expected := map[string]bool{"1.txt": false, "sub": true, "sub/2 文件.txt": false}
+
+	// 遞迴列取
+	{
+		res, err := handler.List(ctx, "/import", true)
+		asserts.NoError(err)
+		asserts.Len(res, 3)
+		objects := make(map[string]bool)
+		for _, object := range res {
+			objects[object.RelativePath] = object.IsDir
+			if object.RelativePath == "sub/2 文件.txt" {
+				asserts.Equal("import/sub/2 文件.txt", object.Source)
+				asserts.Equal("2 文件.txt", object.Name)
+				asserts.EqualValues(2, object.Size)
+				asserts.False(object.LastModify.IsZero())
+			}
+		}
+		asserts.Equal(expected, objects)
+	}
+
+	// 伺服器不允許 Depth: infinity 時逐層列取
+	{
+		server.mu.Lock()
+		server.noInfinity = true
+		server.mu.Unlock()
+		res, err := handler.List(ctx, "/import", true)
+		asserts.NoError(err)
+		objects := make(map[string]bool)
+		for _, object := range res {
+			objects[object.RelativePath] = object.IsDir
+		}
+		asserts.Equal(expected, objects)
+	}
+
+	// 非遞迴列取
+	{
+		res, err := handler.List(ctx, "/import", false)
+		asserts.NoError(err)
+		asserts.Len(res, 2)
+	}
+
+	// 目錄不存在
+	{
+		_, err := handler.List(ctx, "/not_exist", true)
+		asserts.Equal(ErrObjectNotExist, err)
+	}
+}
+
+func TestDriver_SourceAndToken(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{Policy: &model.Policy{Type: "webdav"}}
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, model.File{Name: "1.txt"})
+	auth.General = auth.HMACAuth{SecretKey: []byte("test")}
+
+	// 由主機中轉
+	{
+		baseURL, _ := url.Parse("https://cloudreve.org")
+		sourceURL, err := handler.Source(ctx, "1.txt", *baseURL, 0, false, 0)
+		asserts.NoError(err)
+		asserts.Contains(sourceURL, "https://cloudreve.org")
+		asserts.Contains(sourceURL, "sign=")
+	}
+
+	// 上傳憑證為空
+	{
+		credential, err := handler.Token(ctx, 10, "key")
+		asserts.NoError(err)
+		asserts.Empty(credential)
+	}
+}
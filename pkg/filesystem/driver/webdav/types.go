@@ -0,0 +1,47 @@
+package webdav
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// propfindBody PROPFIND 請求正文，只請求列取文件需要的屬性
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>` +
+	`<D:propfind xmlns:D="DAV:"><D:prop>` +
+	`<D:resourcetype/><D:getcontentlength/><D:getlastmodified/>` +
+	`</D:prop></D:propfind>`
+
+// Entry 遠端文件或目錄
+type Entry struct {
+	// Path 相對於伺服器根目錄的路徑，不以 / 開頭或結尾
+	Path       string
+	Size       uint64
+	IsDir      bool
+	LastModify time.Time
+}
+
+// multistatus PROPFIND 的響應
+type multistatus struct {
+	XMLName   xml.Name       `xml:"DAV: multistatus"`
+	Responses []propResponse `xml:"DAV: response"`
+}
+
+type propResponse struct {
+	Href     string     `xml:"DAV: href"`
+	Propstat []propstat `xml:"DAV: propstat"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"DAV: prop"`
+	Status string `xml:"DAV: status"`
+}
+
+type prop struct {
+	ResourceType  resourceType `xml:"DAV: resourcetype"`
+	ContentLength uint64       `xml:"DAV: getcontentlength"`
+	LastModified  string       `xml:"DAV: getlastmodified"`
+}
+
+type resourceType struct {
+	Collection *struct{} `xml:"DAV: collection"`
+}
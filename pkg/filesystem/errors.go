@@ -14,6 +14,11 @@ var (
 	ErrIllegalObjectName       = errors.New("目標名稱非法")
 	ErrClientCanceled          = errors.New("用戶端取消操作")
 	ErrRootProtected           = errors.New("無法對根目錄進行操作")
+	ErrUnknownArchiveFormat    = errors.New("不支援的壓縮文件格式")
+	ErrUnsafeArchive           = errors.New("壓縮文件超出安全限制")
+	ErrPolicyUnavailable       = errors.New("儲存策略容量已滿或無法使用")
+	ErrMirrorNoMember          = errors.New("鏡像儲存策略未設定成員儲存策略")
+	ErrMirrorNested            = errors.New("鏡像儲存策略的成員不能是鏡像儲存策略")
 	ErrInsertFileRecord        = serializer.NewError(serializer.CodeDBError, "無法插入文件記錄", nil)
 	ErrFileExisted             = serializer.NewError(serializer.CodeObjectExist, "同名文件或目錄已存在", nil)
 	ErrFolderExisted           = serializer.NewError(serializer.CodeObjectExist, "同名目錄已存在", nil)
@@ -22,4 +27,8 @@ var (
 	ErrIO                      = serializer.NewError(serializer.CodeIOFailed, "無法讀取文件資料", nil)
 	ErrDBListObjects           = serializer.NewError(serializer.CodeDBError, "無法列取物件記錄", nil)
 	ErrDBDeleteObjects         = serializer.NewError(serializer.CodeDBError, "無法刪除物件記錄", nil)
+	ErrUploadSessionNotExist   = serializer.NewError(serializer.CodeNotFound, "上傳工作階段不存在或已過期", nil)
+	ErrChunkIndexOutOfRange    = serializer.NewError(serializer.CodeParamErr, "分片序號超出範圍", nil)
+	ErrChunkSizeMismatch       = serializer.NewError(serializer.CodeParamErr, "分片大小與預期不符", nil)
+	ErrChunkMissing            = serializer.NewError(serializer.CodeParamErr, "仍有分片未上傳", nil)
 )
@@ -65,6 +65,10 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *model.Folder) (*model
 		PolicyID:   fs.User.Policy.ID,
 	}
 
+	if hash, ok := ctx.Value(fsctx.FileHashCtx).(string); ok {
+		newFile.Hash = hash
+	}
+
 	if fs.User.Policy.IsThumbExist(file.GetFileName()) {
 		newFile.PicInfo = "1,1"
 	}
@@ -145,6 +149,7 @@ func (fs *FileSystem) GetDownloadContent(ctx context.Context, id uint) (response
 	if err != nil {
 		return nil, err
 	}
+	updateAccessTime(&fs.FileTarget[0])
 
 	// 返回限速處理後的文件流
 	return fs.withSpeedLimit(rs), nil
@@ -272,6 +277,13 @@ func (fs *FileSystem) GetSource(ctx context.Context, fileID uint) (string, error
 	return source, nil
 }
 
+// updateAccessTime 記錄文件的最後存取時間，失敗時不影響文件的存取
+func updateAccessTime(file *model.File) {
+	if err := file.UpdateAccessTime(); err != nil {
+		util.Log().Warning("無法更新文件 [%d] 的最後存取時間, %s", file.ID, err)
+	}
+}
+
 // SignURL 簽名文件原始 URL
 func (fs *FileSystem) SignURL(ctx context.Context, file *model.File, ttl int64, isDownload bool) (string, error) {
 	fs.FileTarget = []model.File{*file}
@@ -290,6 +302,9 @@ func (fs *FileSystem) SignURL(ctx context.Context, file *model.File, ttl int64,
 		return "", serializer.NewError(serializer.CodeNotSet, "無法獲取外鏈", err)
 	}
 
+	// 重定向類儲存策略的文件不經過服務端，在簽名時記錄存取
+	updateAccessTime(&fs.FileTarget[0])
+
 	return source, nil
 }
 
@@ -58,6 +58,15 @@ func TestFileSystem_AddFile(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 	asserts.Equal("/Uploads/1_sad.png", f.SourceName)
 	asserts.NotEmpty(f.PicInfo)
+
+	// 上下文中指定了內容雜湊值
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	f, err = fs.AddFile(context.WithValue(ctx, fsctx.FileHashCtx, "hash"), &folder)
+	asserts.NoError(err)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal("hash", f.Hash)
 }
 
 func TestFileSystem_GetContent(t *testing.T) {
@@ -138,6 +147,10 @@ func TestFileSystem_GetDownloadContent(t *testing.T) {
 	cache.Deletes([]string{"599"}, "policy_")
 	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "policy_id", "source_name"}).AddRow(1, "TestFileSystem_GetDownloadContent.txt", 599, "TestFileSystem_GetDownloadContent.txt"))
 	mock.ExpectQuery("SELECT(.+)poli(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(1, "local"))
+	// 更新最後存取時間
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)files(.+)accessed_at(.+)").WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	// 無限速
 	cache.Deletes([]string{"599"}, "policy_")
@@ -150,6 +163,9 @@ func TestFileSystem_GetDownloadContent(t *testing.T) {
 	cache.Deletes([]string{"599"}, "policy_")
 	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "policy_id", "source_name"}).AddRow(1, "TestFileSystem_GetDownloadContent.txt", 599, "TestFileSystem_GetDownloadContent.txt"))
 	mock.ExpectQuery("SELECT(.+)poli(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(1, "local"))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)files(.+)accessed_at(.+)").WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	fs.User.Group.SpeedLimit = 1
 	_, err = fs.GetDownloadContent(ctx, 1)
@@ -294,6 +310,10 @@ func TestFileSystem_GetSource(t *testing.T) {
 			)
 		// 尋找站點URL
 		mock.ExpectQuery("SELECT(.+)").WithArgs("siteURL").WillReturnRows(sqlmock.NewRows([]string{"id", "value"}).AddRow(1, "https://cloudreve.org"))
+		// 更新最後存取時間
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)accessed_at(.+)").WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
 		sourceURL, err := fs.GetSource(ctx, 2)
 		asserts.NoError(mock.ExpectationsWereMet())
@@ -407,7 +427,10 @@ func TestFileSystem_GetDownloadURL(t *testing.T) {
 				sqlmock.NewRows([]string{"id", "type", "is_origin_link_enable"}).
 					AddRow(35, "local", true),
 			)
-		// 相關設定
+		// 更新最後存取時間
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)accessed_at(.+)").WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 		downloadURL, err := fs.GetDownloadURL(ctx, 1, "download_timeout")
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
@@ -553,7 +576,12 @@ func TestFileSystem_Preview(t *testing.T) {
 			},
 		}
 		asserts.NoError(cache.Set("setting_preview_timeout", "233", 0))
+		// 更新最後存取時間
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)accessed_at(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 		resp, err := fs.Preview(ctx, 1, false)
+		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
 		asserts.NotNil(resp)
 		asserts.True(resp.Redirect)
@@ -11,14 +11,20 @@ import (
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/azblob"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/cos"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/encrypt"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/mirror"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/onedrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/oss"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/qiniu"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/readcache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/remote"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/s3"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/sftp"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/upyun"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/webdav"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
@@ -158,9 +164,57 @@ func NewAnonymousFileSystem() (*FileSystem, error) {
 	return fs, nil
 }
 
-// DispatchHandler 根據儲存策略分配文件適配器
-// TODO 完善測試
+// BaseHandler 返回去除讀取快取包裝後的儲存策略適配器，
+// 用於需要存取適配器特有方法的場合
+func (fs *FileSystem) BaseHandler() Handler {
+	if cached, ok := fs.Handler.(readcache.Driver); ok {
+		return cached.Unwrap()
+	}
+	return fs.Handler
+}
+
+// DispatchHandler 根據儲存策略分配文件適配器，啟用加密的儲存策略以加密適配器包裝
 func (fs *FileSystem) DispatchHandler() error {
+	if err := fs.dispatchHandler(); err != nil {
+		return err
+	}
+
+	currentPolicy := fs.Policy
+	if currentPolicy == nil {
+		currentPolicy = &fs.User.Policy
+	}
+	if currentPolicy.Type == "mock" || currentPolicy.Type == "anonymous" {
+		return nil
+	}
+
+	// 讀取快取位於加密之下，快取中存放的是加密後的內容
+	if currentPolicy.IsReadCacheEnabled() && readcache.Default != nil {
+		fs.Handler = readcache.Driver{
+			Policy:  currentPolicy,
+			Handler: fs.Handler,
+			Store:   readcache.Default,
+		}
+	}
+
+	if !currentPolicy.OptionsSerialized.Encrypt {
+		return nil
+	}
+
+	key, err := encrypt.LoadMasterKey()
+	if err != nil {
+		return err
+	}
+	fs.Handler = encrypt.Driver{
+		Policy:    currentPolicy,
+		Handler:   fs.Handler,
+		MasterKey: key,
+	}
+	return nil
+}
+
+// dispatchHandler 根據儲存策略類型分配文件適配器
+// TODO 完善測試
+func (fs *FileSystem) dispatchHandler() error {
 	var policyType string
 	var currentPolicy *model.Policy
 
@@ -231,6 +285,31 @@ func (fs *FileSystem) DispatchHandler() error {
 			Policy: currentPolicy,
 		}
 		return nil
+	case "mirror":
+		members, err := MirrorMembers(currentPolicy)
+		fs.Handler = mirror.Driver{
+			Policy:  currentPolicy,
+			Members: members,
+		}
+		return err
+	case "sftp":
+		fs.Handler = sftp.Driver{
+			Policy: currentPolicy,
+		}
+		return nil
+	case "azblob":
+		fs.Handler = azblob.Driver{
+			Policy:     currentPolicy,
+			HTTPClient: request.HTTPClient{},
+		}
+		return nil
+	case "webdav":
+		client, err := webdav.NewClient(currentPolicy)
+		fs.Handler = webdav.Driver{
+			Policy: currentPolicy,
+			Client: client,
+		}
+		return err
 	default:
 		return ErrUnknownPolicyType
 	}
@@ -265,6 +344,9 @@ func NewFileSystemFromCallback(c *gin.Context) (*FileSystem, error) {
 	if err != nil {
 		return nil, err
 	}
+	if policy.OptionsSerialized.Encrypt {
+		return nil, errors.New("加密的儲存策略不接受用戶端直接上傳")
+	}
 	fs.Policy = &policy
 	fs.User.Policy = policy
 	err = fs.DispatchHandler()
@@ -1,13 +1,20 @@
 package filesystem
 
 import (
+	"encoding/base64"
+	"io/ioutil"
 	"net/http/httptest"
+	"os"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/encrypt"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/readcache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/remote"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/s3"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -107,6 +114,86 @@ func TestDispatchHandler(t *testing.T) {
 	fs.Policy = &model.Policy{Type: "s3"}
 	err = fs.DispatchHandler()
 	asserts.NoError(err)
+
+	fs.Policy = &model.Policy{Type: "sftp"}
+	err = fs.DispatchHandler()
+	asserts.NoError(err)
+
+	fs.Policy = &model.Policy{Type: "webdav", Server: "https://nas/dav"}
+	err = fs.DispatchHandler()
+	asserts.NoError(err)
+
+	fs.Policy = &model.Policy{Type: "azblob"}
+	err = fs.DispatchHandler()
+	asserts.NoError(err)
+}
+
+func TestDispatchHandler_Encrypt(t *testing.T) {
+	asserts := assert.New(t)
+	defer func() { conf.EncryptionConfig.MasterKey = "" }()
+	policy := &model.Policy{Type: "local"}
+	policy.OptionsSerialized.Encrypt = true
+	fs := &FileSystem{User: &model.User{}, Policy: policy}
+
+	// 未設定主金鑰
+	{
+		conf.EncryptionConfig.MasterKey = ""
+		asserts.Equal(encrypt.ErrMasterKeyNotSet, fs.DispatchHandler())
+	}
+
+	// 以加密適配器包裝
+	{
+		conf.EncryptionConfig.MasterKey = base64.StdEncoding.EncodeToString(make([]byte, 32))
+		asserts.NoError(fs.DispatchHandler())
+		handler, ok := fs.Handler.(encrypt.Driver)
+		asserts.True(ok)
+		asserts.IsType(local.Driver{}, handler.Handler)
+	}
+}
+
+func TestDispatchHandler_ReadCache(t *testing.T) {
+	asserts := assert.New(t)
+	dir, _ := ioutil.TempDir("", "readcache")
+	defer os.RemoveAll(dir)
+	store, err := readcache.NewStore(dir, 100, 10)
+	asserts.NoError(err)
+	readcache.Default = store
+	defer func() { readcache.Default = nil }()
+
+	// 以快取適配器包裝
+	{
+		policy := &model.Policy{Type: "s3"}
+		policy.OptionsSerialized.ReadCache = true
+		fs := &FileSystem{User: &model.User{}, Policy: policy}
+		asserts.NoError(fs.DispatchHandler())
+		handler, ok := fs.Handler.(readcache.Driver)
+		asserts.True(ok)
+		asserts.IsType(s3.Driver{}, handler.Handler)
+	}
+
+	// 回調等場合可以獲取底層適配器
+	{
+		policy := &model.Policy{Type: "s3"}
+		policy.OptionsSerialized.ReadCache = true
+		fs := &FileSystem{User: &model.User{}, Policy: policy}
+		asserts.NoError(fs.DispatchHandler())
+		_, ok := fs.BaseHandler().(s3.Driver)
+		asserts.True(ok)
+
+		policy.OptionsSerialized.ReadCache = false
+		asserts.NoError(fs.DispatchHandler())
+		_, ok = fs.BaseHandler().(s3.Driver)
+		asserts.True(ok)
+	}
+
+	// 本機儲存策略不使用快取
+	{
+		policy := &model.Policy{Type: "local"}
+		policy.OptionsSerialized.ReadCache = true
+		fs := &FileSystem{User: &model.User{}, Policy: policy}
+		asserts.NoError(fs.DispatchHandler())
+		asserts.IsType(local.Driver{}, fs.Handler)
+	}
 }
 
 func TestNewFileSystemFromCallback(t *testing.T) {
@@ -41,4 +41,6 @@ const (
 	ValidateCapacityOnceCtx
 	// 禁止上傳時同名覆蓋操作
 	DisableOverwrite
+	// FileHashCtx 文件內容的雜湊值
+	FileHashCtx
 )
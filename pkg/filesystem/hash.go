@@ -0,0 +1,37 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+)
+
+// hashReader 讀取文件流的同時計算內容的 SHA-256 雜湊值
+type hashReader struct {
+	FileHeader
+	hash hash.Hash
+	read uint64
+}
+
+func newHashReader(file FileHeader) *hashReader {
+	return &hashReader{
+		FileHeader: file,
+		hash:       sha256.New(),
+	}
+}
+
+// Read 讀取文件流並更新雜湊值
+func (r *hashReader) Read(p []byte) (int, error) {
+	n, err := r.FileHeader.Read(p)
+	r.hash.Write(p[:n])
+	r.read += uint64(n)
+	return n, err
+}
+
+// Sum 返回十六進位表示的雜湊值，文件流未被完整讀取時返回空字串
+func (r *hashReader) Sum() string {
+	if r.read != r.GetSize() {
+		return ""
+	}
+	return hex.EncodeToString(r.hash.Sum(nil))
+}
@@ -0,0 +1,37 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashReader(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 完整讀取
+	{
+		reader := newHashReader(local.FileStream{
+			File: ioutil.NopCloser(strings.NewReader("123")),
+			Size: 3,
+		})
+		content, err := ioutil.ReadAll(reader)
+		asserts.NoError(err)
+		asserts.Equal("123", string(content))
+		asserts.Equal("a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", reader.Sum())
+	}
+
+	// 未完整讀取
+	{
+		reader := newHashReader(local.FileStream{
+			File: ioutil.NopCloser(strings.NewReader("123")),
+			Size: 3,
+		})
+		_, err := reader.Read(make([]byte, 1))
+		asserts.NoError(err)
+		asserts.Empty(reader.Sum())
+	}
+}
@@ -123,6 +123,12 @@ func HookResetPolicy(ctx context.Context, fs *FileSystem) error {
 	return fs.DispatchHandler()
 }
 
+// HookRoutePolicy 按使用者群組的上傳規則為新文件選擇儲存策略
+func HookRoutePolicy(ctx context.Context, fs *FileSystem) error {
+	file := ctx.Value(fsctx.FileHeaderCtx).(FileHeader)
+	return fs.RoutePolicy(file.GetFileName(), file.GetMIMEType(), file.GetSize(), file.GetVirtualPath())
+}
+
 // HookValidateCapacity 驗證並扣除使用者容量，包含資料庫操作
 func HookValidateCapacity(ctx context.Context, fs *FileSystem) error {
 	file := ctx.Value(fsctx.FileHeaderCtx).(FileHeader)
@@ -245,6 +251,14 @@ func GenericAfterUpdate(ctx context.Context, fs *FileSystem) error {
 		return err
 	}
 
+	// 更新文件內容雜湊值，無法取得時清空原有值
+	hash, _ := ctx.Value(fsctx.FileHashCtx).(string)
+	if hash != originFile.Hash {
+		if err := originFile.UpdateHash(hash); err != nil {
+			return err
+		}
+	}
+
 	// 嘗試清空原有縮圖並重新生成
 	if originFile.GetPolicy().IsThumbGenerateNeeded() {
 		fs.recycleLock.Lock()
@@ -283,6 +297,7 @@ func SlaveAfterUpload(ctx context.Context, fs *FileSystem) error {
 		PicInfo:    file.PicInfo,
 		Size:       fileHeader.GetSize(),
 	}
+	callbackBody.Hash, _ = ctx.Value(fsctx.FileHashCtx).(string)
 	return request.RemoteCallback(policy.CallbackURL, callbackBody)
 }
 
@@ -1,12 +1,15 @@
 package filesystem
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
 	"strconv"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
 	"github.com/cloudreve/Cloudreve/v3/pkg/thumb"
@@ -35,19 +38,21 @@ func (fs *FileSystem) GetThumb(ctx context.Context, id uint) (*response.ContentR
 	ctx = context.WithValue(ctx, fsctx.ThumbSizeCtx, [2]uint{w, h})
 	ctx = context.WithValue(ctx, fsctx.FileModelCtx, fs.FileTarget[0])
 	res, err := fs.Handler.Thumb(ctx, fs.FileTarget[0].SourceName)
-	if err == nil && conf.SystemConfig.Mode == "master" {
-		res.MaxAge = model.GetIntSetting("preview_timeout", 60)
-	}
 
-	// 本機儲存策略出錯時重新生成縮圖
-	if err != nil && fs.Policy.Type == "local" {
+	// 由主機生成縮圖的儲存策略出錯時重新生成縮圖
+	if err != nil && fs.Policy.IsThumbGenerateNeeded() {
 		fs.GenerateThumbnail(ctx, &fs.FileTarget[0])
+		res, err = fs.Handler.Thumb(ctx, fs.FileTarget[0].SourceName)
+	}
+
+	if err == nil && conf.SystemConfig.Mode == "master" {
+		res.MaxAge = model.GetIntSetting("preview_timeout", 60)
 	}
 
 	return res, err
 }
 
-// GenerateThumbnail 嘗試為本機策略文件生成縮圖並獲取圖像原始大小
+// GenerateThumbnail 嘗試為文件生成縮圖並獲取圖像原始大小
 // TODO 失敗時，如果之前還有圖像訊息，則清除
 func (fs *FileSystem) GenerateThumbnail(ctx context.Context, file *model.File) {
 	// 判斷是否可以生成縮圖
@@ -64,9 +69,10 @@ func (fs *FileSystem) GenerateThumbnail(ctx context.Context, file *model.File) {
 	if err != nil {
 		return
 	}
-	defer source.Close()
 
+	// 解碼後立即關閉資料流，部分適配器在儲存縮圖時需要另外佔用連線
 	image, err := thumb.NewThumbFromFile(source, file.Name)
+	source.Close()
 	if err != nil {
 		util.Log().Warning("生成縮圖時無法解析 [%s] 圖像資料：%s", file.SourceName, err)
 		return
@@ -78,7 +84,7 @@ func (fs *FileSystem) GenerateThumbnail(ctx context.Context, file *model.File) {
 	// 生成縮圖
 	image.GetThumb(fs.GenerateThumbnailSize(w, h))
 	// 儲存到文件
-	err = image.Save(util.RelativePath(file.SourceName + conf.ThumbConfig.FileSuffix))
+	err = fs.saveThumbnail(newCtx, image, file.SourceName+conf.ThumbConfig.FileSuffix)
 	if err != nil {
 		util.Log().Warning("無法儲存縮圖：%s", err)
 		return
@@ -97,6 +103,20 @@ func (fs *FileSystem) GenerateThumbnail(ctx context.Context, file *model.File) {
 	}
 }
 
+// saveThumbnail 儲存縮圖。本機儲存策略直接寫入磁碟，
+// 其他由主機生成縮圖的儲存策略經由適配器上傳
+func (fs *FileSystem) saveThumbnail(ctx context.Context, image *thumb.Thumb, dst string) error {
+	if _, ok := fs.Handler.(local.Driver); ok {
+		return image.Save(util.RelativePath(dst))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := image.Encode(buf); err != nil {
+		return err
+	}
+	return fs.Handler.Put(ctx, ioutil.NopCloser(buf), dst, uint64(buf.Len()))
+}
+
 // GenerateThumbnailSize 獲取要生成的縮圖的尺寸
 func (fs *FileSystem) GenerateThumbnailSize(w, h int) (uint, uint) {
 	if conf.SystemConfig.Mode == "master" {
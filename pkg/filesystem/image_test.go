@@ -1,11 +1,16 @@
 package filesystem
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"image"
+	"image/png"
 	"testing"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
 	"github.com/stretchr/testify/assert"
 	testMock "github.com/stretchr/testify/mock"
@@ -37,4 +42,55 @@ func TestFileSystem_GetThumb(t *testing.T) {
 		asserts.NoError(err)
 		asserts.EqualValues(50, res.MaxAge)
 	}
+
+	// 縮圖不存在時重新生成後再次獲取
+	{
+		conf.SystemConfig.Mode = "slave"
+		defer func() { conf.SystemConfig.Mode = "master" }()
+		testHandller := new(FileHeaderMock)
+		testHandller.On("Thumb", testMock.Anything, "1.txt").Return(&response.ContentResponse{}, errors.New("not found")).Once()
+		testHandller.On("Thumb", testMock.Anything, "1.txt").Return(&response.ContentResponse{}, nil).Once()
+		fs.CleanTargets()
+		fs.SetTargetFile(&[]model.File{{Name: "1.txt", SourceName: "1.txt", PicInfo: "1,1"}})
+		fs.Policy = &model.Policy{Type: "local"}
+		fs.Handler = testHandller
+		_, err := fs.GetThumb(context.Background(), 1)
+		asserts.NoError(err)
+		testHandller.AssertExpectations(t)
+	}
+}
+
+// closeTracker 記錄是否已被關閉的資料流
+type closeTracker struct {
+	*bytes.Reader
+	closed bool
+}
+
+func (file *closeTracker) Close() error {
+	file.closed = true
+	return nil
+}
+
+func TestFileSystem_GenerateThumbnail(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Set("setting_thumb_width", "10", 0)
+	cache.Set("setting_thumb_height", "10", 0)
+
+	buf := &bytes.Buffer{}
+	asserts.NoError(png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 20, 30))))
+	source := &closeTracker{Reader: bytes.NewReader(buf.Bytes())}
+
+	// 儲存縮圖前關閉原文件資料流
+	testHandler := new(FileHeaderMock)
+	testHandler.On("Get", testMock.Anything, "1.png").Return(source, nil)
+	testHandler.On("Put", testMock.Anything, testMock.Anything, "1.png"+conf.ThumbConfig.FileSuffix, testMock.Anything).
+		Run(func(args testMock.Arguments) {
+			asserts.True(source.closed)
+		}).Return(nil)
+	fs := &FileSystem{User: &model.User{}, Handler: testHandler}
+
+	file := &model.File{Name: "1.png", SourceName: "1.png"}
+	fs.GenerateThumbnail(context.Background(), file)
+	testHandler.AssertExpectations(t)
+	asserts.Equal("20,30", file.PicInfo)
 }
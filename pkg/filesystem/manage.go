@@ -21,14 +21,15 @@ import (
 
 // Object 文件或者目錄
 type Object struct {
-	ID   string    `json:"id"`
-	Name string    `json:"name"`
-	Path string    `json:"path"`
-	Pic  string    `json:"pic"`
-	Size uint64    `json:"size"`
-	Type string    `json:"type"`
-	Date time.Time `json:"date"`
-	Key  string    `json:"key,omitempty"`
+	ID     string    `json:"id"`
+	Name   string    `json:"name"`
+	Path   string    `json:"path"`
+	Pic    string    `json:"pic"`
+	Size   uint64    `json:"size"`
+	Type   string    `json:"type"`
+	Date   time.Time `json:"date"`
+	Key    string    `json:"key,omitempty"`
+	Broken bool      `json:"broken,omitempty"` // 物理文件遺失或損壞
 }
 
 // Rename 重新命名物件
@@ -49,6 +50,9 @@ func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string)
 		if err != nil {
 			return ErrFileExisted
 		}
+
+		// 副檔名可能改變，重新索引文件內容
+		IndexContent(fileObject[0])
 		return nil
 	}
 
@@ -135,16 +139,6 @@ func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst str
 
 // Delete 遞迴刪除物件, force 為 true 時強制刪除文件記錄，忽略物理刪除是否成功
 func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool) error {
-	// 已刪除的總容量,map用於去重
-	var deletedStorage = make(map[uint]uint64)
-	var totalStorage = make(map[uint]uint64)
-	// 已刪除的文件ID
-	var deletedFileIDs = make([]uint, 0, len(fs.FileTarget))
-	// 刪除失敗的文件的父目錄ID
-
-	// 所有文件的ID
-	var allFileIDs = make([]uint, 0, len(fs.FileTarget))
-
 	// 列出要刪除的目錄
 	if len(dirs) > 0 {
 		err := fs.ListDeleteDirs(ctx, dirs)
@@ -161,6 +155,20 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool
 		}
 	}
 
+	return fs.deleteTargets(ctx, force)
+}
+
+// deleteTargets 刪除目前目標中的所有文件和目錄
+func (fs *FileSystem) deleteTargets(ctx context.Context, force bool) error {
+	// 已刪除的總容量,map用於去重
+	var deletedStorage = make(map[uint]uint64)
+	var totalStorage = make(map[uint]uint64)
+	// 已刪除的文件ID
+	var deletedFileIDs = make([]uint, 0, len(fs.FileTarget))
+
+	// 所有文件的ID
+	var allFileIDs = make([]uint, 0, len(fs.FileTarget))
+
 	// 去除待刪除文件中包含軟連接的部分
 	filesToBeDelete, err := model.RemoveFilesWithSoftLinks(fs.FileTarget)
 	if err != nil {
@@ -200,6 +208,20 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool
 	// 刪除文件記錄對應的分享記錄
 	model.DeleteShareBySourceIDs(deletedFileIDs, false)
 
+	// 刪除文件的全文索引
+	RemoveContentIndex(deletedFileIDs...)
+
+	// 刪除文件的歷史版本
+	if len(deletedFileIDs) > 0 {
+		versions, err := model.GetVersionsByFileIDs(deletedFileIDs)
+		if err == nil {
+			err = fs.DeleteVersions(ctx, versions)
+		}
+		if err != nil {
+			util.Log().Warning("無法刪除文件的歷史版本，%s", err)
+		}
+	}
+
 	// 歸還容量
 	var total uint64
 	for _, value := range deletedStorage {
@@ -364,13 +386,14 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 		}
 
 		newFile := Object{
-			ID:   hashid.HashID(file.ID, hashid.FileID),
-			Name: file.Name,
-			Path: processedPath,
-			Pic:  file.PicInfo,
-			Size: file.Size,
-			Type: "file",
-			Date: file.CreatedAt,
+			ID:     hashid.HashID(file.ID, hashid.FileID),
+			Name:   file.Name,
+			Path:   processedPath,
+			Pic:    file.PicInfo,
+			Size:   file.Size,
+			Type:   "file",
+			Date:   file.CreatedAt,
+			Broken: file.Broken,
 		}
 		if shareKey != "" {
 			newFile.Key = shareKey
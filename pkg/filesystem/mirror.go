@@ -0,0 +1,45 @@
+package filesystem
+
+import (
+	"sort"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/mirror"
+)
+
+// MirrorMembers 建立鏡像儲存策略各成員的適配器，按設定的順序排列，
+// 最近一次健康檢查失敗的成員排在最後
+func MirrorMembers(policy *model.Policy) ([]mirror.Member, error) {
+	ids := policy.OptionsSerialized.MirrorPolicies
+	if len(ids) == 0 {
+		return nil, ErrMirrorNoMember
+	}
+
+	members := make([]mirror.Member, 0, len(ids))
+	for _, id := range ids {
+		memberPolicy, err := model.GetPolicyByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if memberPolicy.Type == "mirror" {
+			return nil, ErrMirrorNested
+		}
+
+		fs := &FileSystem{User: &model.User{Policy: memberPolicy}}
+		if err := fs.DispatchHandler(); err != nil {
+			return nil, err
+		}
+		members = append(members, mirror.Member{Policy: &fs.User.Policy, Handler: fs.Handler})
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return isPolicyHealthy(members[i].Policy.ID) && !isPolicyHealthy(members[j].Policy.ID)
+	})
+	return members, nil
+}
+
+// isPolicyHealthy 返回儲存策略最近一次健康檢查是否通過，尚未檢查時視為正常
+func isPolicyHealthy(id uint) bool {
+	health, ok := GetPolicyHealth(id)
+	return !ok || health.Healthy
+}
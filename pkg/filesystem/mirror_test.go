@@ -0,0 +1,67 @@
+package filesystem
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/mirror"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirrorMembers(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Set("policy_21", model.Policy{Model: gorm.Model{ID: 21}, Type: "local"}, 0)
+	cache.Set("policy_22", model.Policy{Model: gorm.Model{ID: 22}, Type: "mock"}, 0)
+	cache.Set("policy_23", model.Policy{Model: gorm.Model{ID: 23}, Type: "mirror"}, 0)
+	defer cache.Deletes([]string{"21", "22", "23", "health_21", "health_22"}, "policy_")
+
+	// 按設定的順序排列
+	{
+		policy := &model.Policy{Type: "mirror"}
+		policy.OptionsSerialized.MirrorPolicies = []uint{21, 22}
+		members, err := MirrorMembers(policy)
+		asserts.NoError(err)
+		asserts.Len(members, 2)
+		asserts.EqualValues(21, members[0].Policy.ID)
+		asserts.IsType(local.Driver{}, members[0].Handler)
+		asserts.EqualValues(22, members[1].Policy.ID)
+	}
+
+	// 健康檢查失敗的成員排在最後
+	{
+		cache.Set("policy_health_21", PolicyHealth{Healthy: false, CheckedAt: time.Now()}, 0)
+		cache.Set("policy_health_22", PolicyHealth{Healthy: true, CheckedAt: time.Now()}, 0)
+		policy := &model.Policy{Type: "mirror"}
+		policy.OptionsSerialized.MirrorPolicies = []uint{21, 22}
+		members, err := MirrorMembers(policy)
+		asserts.NoError(err)
+		asserts.EqualValues(22, members[0].Policy.ID)
+		asserts.EqualValues(21, members[1].Policy.ID)
+	}
+
+	// 未設定成員
+	{
+		_, err := MirrorMembers(&model.Policy{Type: "mirror"})
+		asserts.Equal(ErrMirrorNoMember, err)
+	}
+
+	// 成員不能是鏡像儲存策略
+	{
+		policy := &model.Policy{Type: "mirror"}
+		policy.OptionsSerialized.MirrorPolicies = []uint{21, 23}
+		_, err := MirrorMembers(policy)
+		asserts.Equal(ErrMirrorNested, err)
+	}
+
+	// 分配適配器
+	{
+		fs := &FileSystem{User: &model.User{Policy: model.Policy{Type: "mirror"}}}
+		fs.User.Policy.OptionsSerialized.MirrorPolicies = []uint{21}
+		asserts.NoError(fs.DispatchHandler())
+		asserts.IsType(mirror.Driver{}, fs.Handler)
+	}
+}
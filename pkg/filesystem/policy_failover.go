@@ -0,0 +1,117 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/gob"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// policyHealthTimeout 健康檢查的超時時間
+const policyHealthTimeout = 30 * time.Second
+
+// PolicyHealth 儲存策略最近一次健康檢查的結果
+type PolicyHealth struct {
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+func init() {
+	gob.Register(PolicyHealth{})
+}
+
+// policyHealthKey 返回儲存策略健康檢查結果的快取鍵
+func policyHealthKey(id uint) string {
+	return "policy_health_" + strconv.FormatUint(uint64(id), 10)
+}
+
+// GetPolicyHealth 獲取儲存策略最近一次健康檢查的結果，尚未檢查時 ok 為 false
+func GetPolicyHealth(id uint) (health PolicyHealth, ok bool) {
+	if res, exist := cache.Get(policyHealthKey(id)); exist {
+		health, ok = res.(PolicyHealth)
+	}
+	return health, ok
+}
+
+// CheckPolicyHealth 上傳並刪除一個探測文件，檢查儲存策略是否可用，結果寫入快取
+func CheckPolicyHealth(policy *model.Policy) error {
+	err := probePolicy(policy)
+	health := PolicyHealth{Healthy: err == nil, CheckedAt: time.Now()}
+	if err != nil {
+		health.Error = err.Error()
+	}
+
+	if cacheErr := cache.Set(policyHealthKey(policy.ID), health, 0); cacheErr != nil {
+		return cacheErr
+	}
+	return err
+}
+
+// probePolicy 在儲存策略的根目錄下寫入並刪除探測文件
+func probePolicy(policy *model.Policy) error {
+	fs, err := NewFileSystem(&model.User{Policy: *policy})
+	if err != nil {
+		return err
+	}
+	defer fs.Recycle()
+
+	ctx, cancel := context.WithTimeout(context.Background(), policyHealthTimeout)
+	defer cancel()
+
+	probe := path.Join(policy.GeneratePath(0, ""), ".health_"+util.RandStringRunes(16))
+	content := "ok"
+	if err := fs.Handler.Put(ctx, ioutil.NopCloser(strings.NewReader(content)), probe, uint64(len(content))); err != nil {
+		return err
+	}
+
+	_, err = fs.Handler.Delete(ctx, []string{probe})
+	return err
+}
+
+// failoverPolicy 目前儲存策略容量已滿或健康檢查失敗時，沿備用儲存策略依序切換，
+// 直到找到可用的儲存策略。均不可用時返回 ErrPolicyUnavailable
+func (fs *FileSystem) failoverPolicy(size uint64) error {
+	policy := fs.User.Policy
+	visited := make(map[uint]bool)
+	for {
+		visited[policy.ID] = true
+
+		full, err := policy.IsFull(size)
+		if err != nil {
+			return err
+		}
+		health, checked := GetPolicyHealth(policy.ID)
+		if !full && (!checked || health.Healthy) {
+			break
+		}
+
+		fallback := policy.OptionsSerialized.FallbackPolicy
+		if fallback == 0 || visited[fallback] {
+			if !full {
+				// 沒有可切換的備用儲存策略時，仍嘗試使用未滿的儲存策略
+				break
+			}
+			return ErrPolicyUnavailable
+		}
+
+		util.Log().Info("儲存策略 [%s] 容量已滿或無法使用，改用備用儲存策略 %d", policy.Name, fallback)
+		if policy, err = model.GetPolicyByID(fallback); err != nil {
+			return err
+		}
+	}
+
+	if policy.ID == fs.User.Policy.ID {
+		return nil
+	}
+	fs.Policy = &policy
+	fs.User.Policy = policy
+	return fs.DispatchHandler()
+}
@@ -0,0 +1,119 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPolicyHealth(t *testing.T) {
+	asserts := assert.New(t)
+	defer os.RemoveAll(util.RelativePath("TestCheckPolicyHealth"))
+
+	// 可用
+	{
+		policy := &model.Policy{Model: gorm.Model{ID: 1}, Type: "local", DirNameRule: "TestCheckPolicyHealth/{uid}"}
+		asserts.NoError(CheckPolicyHealth(policy))
+		health, ok := GetPolicyHealth(1)
+		asserts.True(ok)
+		asserts.True(health.Healthy)
+
+		// 探測文件已刪除
+		files, err := ioutil.ReadDir(util.RelativePath("TestCheckPolicyHealth/0"))
+		asserts.NoError(err)
+		asserts.Empty(files)
+	}
+
+	// 無法寫入
+	{
+		asserts.NoError(ioutil.WriteFile(util.RelativePath("TestCheckPolicyHealth/file"), []byte("1"), 0644))
+		policy := &model.Policy{Model: gorm.Model{ID: 2}, Type: "local", DirNameRule: "TestCheckPolicyHealth/file"}
+		asserts.Error(CheckPolicyHealth(policy))
+		health, ok := GetPolicyHealth(2)
+		asserts.True(ok)
+		asserts.False(health.Healthy)
+		asserts.NotEmpty(health.Error)
+	}
+
+	// 尚未檢查
+	{
+		_, ok := GetPolicyHealth(3)
+		asserts.False(ok)
+	}
+}
+
+func TestFileSystem_failoverPolicy(t *testing.T) {
+	asserts := assert.New(t)
+	primary := model.Policy{Model: gorm.Model{ID: 11}, Type: "mock"}
+	primary.OptionsSerialized.Capacity = 100
+	primary.OptionsSerialized.FallbackPolicy = 12
+	fallback := model.Policy{Model: gorm.Model{ID: 12}, Type: "mock"}
+	fallback.OptionsSerialized.FallbackPolicy = 11
+	cache.Set("policy_12", fallback, 0)
+	defer cache.Deletes([]string{"12", "health_11", "health_12"}, "policy_")
+
+	expectUsage := func(id uint, size int) {
+		mock.ExpectQuery("SELECT(.+)files(.+)file_versions(.+)").WithArgs(id, id).WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(size))
+	}
+
+	// 可用
+	{
+		fs := &FileSystem{User: &model.User{Policy: primary}}
+		expectUsage(11, 50)
+		asserts.NoError(fs.failoverPolicy(10))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(fs.Policy)
+	}
+
+	// 容量已滿，改用備用儲存策略
+	{
+		fs := &FileSystem{User: &model.User{Policy: primary}}
+		expectUsage(11, 95)
+		asserts.NoError(fs.failoverPolicy(10))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(12, fs.Policy.ID)
+		asserts.EqualValues(12, fs.User.Policy.ID)
+	}
+
+	// 健康檢查失敗，改用備用儲存策略
+	{
+		cache.Set("policy_health_11", PolicyHealth{Healthy: false}, 0)
+		fs := &FileSystem{User: &model.User{Policy: primary}}
+		expectUsage(11, 0)
+		asserts.NoError(fs.failoverPolicy(10))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(12, fs.User.Policy.ID)
+		cache.Deletes([]string{"health_11"}, "policy_")
+	}
+
+	// 備用儲存策略也無法使用，仍嘗試使用鏈上最後一個未滿的儲存策略
+	{
+		cache.Set("policy_health_12", PolicyHealth{Healthy: false}, 0)
+		cache.Set("policy_health_11", PolicyHealth{Healthy: false}, 0)
+		fs := &FileSystem{User: &model.User{Policy: primary}}
+		expectUsage(11, 0)
+		asserts.NoError(fs.failoverPolicy(10))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(12, fs.User.Policy.ID)
+		cache.Deletes([]string{"health_11"}, "policy_")
+	}
+
+	// 主儲存策略已滿，備用儲存策略無法使用且沒有其他選擇
+	{
+		fallback.OptionsSerialized.FallbackPolicy = 0
+		fallback.OptionsSerialized.Capacity = 10
+		cache.Set("policy_12", fallback, 0)
+		fs := &FileSystem{User: &model.User{Policy: primary}}
+		expectUsage(11, 100)
+		expectUsage(12, 5)
+		asserts.Equal(ErrPolicyUnavailable, fs.failoverPolicy(10))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"mime"
+	"path"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// RoutePolicy 按使用者群組的上傳規則為新文件選擇儲存策略，dir 為文件所在的虛擬目錄。
+// 沒有匹配的規則時使用使用者群組的預設儲存策略，所選儲存策略容量已滿或無法使用時改用備用儲存策略
+func (fs *FileSystem) RoutePolicy(name, mimeType string, size uint64, dir string) error {
+	group := &fs.User.Group
+	target := fs.User.GetPolicyID(0)
+	if target == 0 {
+		target = fs.User.Policy.ID
+	}
+
+	for i := range group.OptionsSerialized.PolicyRules {
+		rule := &group.OptionsSerialized.PolicyRules[i]
+		if !util.ContainsUint(group.PolicyList, rule.PolicyID) {
+			continue
+		}
+
+		matched, err := matchPolicyRule(rule, name, mimeType, size, dir)
+		if err != nil {
+			return err
+		}
+		if matched {
+			target = rule.PolicyID
+			break
+		}
+	}
+
+	if target != fs.User.Policy.ID || (fs.Policy != nil && fs.Policy.ID != target) {
+		policy, err := model.GetPolicyByID(target)
+		if err != nil {
+			return err
+		}
+		fs.Policy = &policy
+		fs.User.Policy = policy
+		if err := fs.DispatchHandler(); err != nil {
+			return err
+		}
+	}
+
+	return fs.failoverPolicy(size)
+}
+
+// matchPolicyRule 判斷文件是否滿足規則的所有條件，儲存策略的已用容量最後檢查
+func matchPolicyRule(rule *model.PolicyRule, name, mimeType string, size uint64, dir string) (bool, error) {
+	if len(rule.Extensions) > 0 {
+		ext := strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")
+		matched := false
+		for _, value := range rule.Extensions {
+			if strings.TrimPrefix(strings.ToLower(value), ".") == ext {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	if len(rule.MIMETypes) > 0 && !matchMIMEType(rule.MIMETypes, ruleMIMEType(name, mimeType)) {
+		return false, nil
+	}
+
+	if size < rule.MinSize || (rule.MaxSize > 0 && size > rule.MaxSize) {
+		return false, nil
+	}
+
+	if rule.Folder != "" {
+		folder := path.Clean("/" + rule.Folder)
+		dir = path.Clean("/" + dir)
+		if folder != "/" && dir != folder && !strings.HasPrefix(dir, folder+"/") {
+			return false, nil
+		}
+	}
+
+	if rule.Capacity > 0 {
+		usage, err := model.GetPolicyUsage(rule.PolicyID)
+		if err != nil {
+			return false, err
+		}
+		if usage+size > rule.Capacity {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// ruleMIMEType 返回文件的 MIME 類型，未提供或無法確定時按副檔名推測
+func ruleMIMEType(name, mimeType string) string {
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = mime.TypeByExtension(path.Ext(name))
+	}
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// matchMIMEType 判斷 MIME 類型是否符合給定的模式之一，模式支援 video/* 形式
+func matchMIMEType(patterns []string, mimeType string) bool {
+	if mimeType == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if strings.HasSuffix(pattern, "/*") {
+			if strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == mimeType {
+			return true
+		}
+	}
+	return false
+}
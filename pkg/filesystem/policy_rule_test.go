@@ -0,0 +1,170 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func TestMatchPolicyRule(t *testing.T) {
+	asserts := assert.New(t)
+
+	testCases := []struct {
+		rule     model.PolicyRule
+		name     string
+		mimeType string
+		size     uint64
+		dir      string
+		expect   bool
+	}{
+		{model.PolicyRule{}, "1.txt", "", 0, "/", true},
+		{model.PolicyRule{Extensions: []string{".MP4", "mkv"}}, "1.mp4", "", 0, "/", true},
+		{model.PolicyRule{Extensions: []string{"mp4"}}, "1.txt", "", 0, "/", false},
+		{model.PolicyRule{MIMETypes: []string{"video/*"}}, "1.mp4", "", 0, "/", true},
+		{model.PolicyRule{MIMETypes: []string{"video/*"}}, "1.bin", "video/webm; codecs=vp8", 0, "/", true},
+		{model.PolicyRule{MIMETypes: []string{"image/png"}}, "1", "", 0, "/", false},
+		{model.PolicyRule{MinSize: 500}, "1.mp4", "", 499, "/", false},
+		{model.PolicyRule{MinSize: 500}, "1.mp4", "", 500, "/", true},
+		{model.PolicyRule{MaxSize: 500}, "1.mp4", "", 501, "/", false},
+		{model.PolicyRule{Folder: "/videos/"}, "1.mp4", "", 0, "/videos", true},
+		{model.PolicyRule{Folder: "videos"}, "1.mp4", "", 0, "/videos/2020", true},
+		{model.PolicyRule{Folder: "/videos"}, "1.mp4", "", 0, "/videos2", false},
+		{model.PolicyRule{Folder: "/"}, "1.mp4", "", 0, "/any", true},
+	}
+
+	for i, testCase := range testCases {
+		matched, err := matchPolicyRule(&testCase.rule, testCase.name, testCase.mimeType, testCase.size, testCase.dir)
+		asserts.NoError(err)
+		asserts.Equal(testCase.expect, matched, "Test case #%d", i)
+	}
+
+	// 已用容量
+	{
+		rule := &model.PolicyRule{PolicyID: 2, Capacity: 100}
+		mock.ExpectQuery("SELECT(.+)files(.+)file_versions(.+)").WithArgs(2, 2).WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(90))
+		matched, err := matchPolicyRule(rule, "1.txt", "", 10, "/")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.True(matched)
+
+		mock.ExpectQuery("SELECT(.+)files(.+)file_versions(.+)").WithArgs(2, 2).WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(91))
+		matched, err = matchPolicyRule(rule, "1.txt", "", 10, "/")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.False(matched)
+
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		_, err = matchPolicyRule(rule, "1.txt", "", 10, "/")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+// newRoutingFS 建立使用者群組設定了上傳規則的文件系統，影片上傳到儲存策略 2，其他文件使用儲存策略 1
+func newRoutingFS() *FileSystem {
+	cache.Set("policy_1", model.Policy{Model: gorm.Model{ID: 1}, Type: "mock"}, 0)
+	cache.Set("policy_2", model.Policy{Model: gorm.Model{ID: 2}, Type: "mock", MaxSize: 1000}, 0)
+	cache.Set("policy_3", model.Policy{Model: gorm.Model{ID: 3}, Type: "mock"}, 0)
+
+	user := &model.User{Model: gorm.Model{ID: 1}}
+	user.Group.PolicyList = []uint{1, 2}
+	user.Group.OptionsSerialized.PolicyRules = []model.PolicyRule{
+		{PolicyID: 3, Extensions: []string{"txt"}},
+		{PolicyID: 2, MIMETypes: []string{"video/*"}, MinSize: 500},
+	}
+	user.Policy = model.Policy{Model: gorm.Model{ID: 1}, Type: "mock"}
+	return &FileSystem{User: user}
+}
+
+func TestFileSystem_RoutePolicy(t *testing.T) {
+	asserts := assert.New(t)
+	defer cache.Deletes([]string{"1", "2", "3"}, "policy_")
+
+	// 未設定規則
+	{
+		fs := &FileSystem{User: &model.User{}}
+		asserts.NoError(fs.RoutePolicy("1.mp4", "", 1000, "/"))
+		asserts.Nil(fs.Policy)
+	}
+
+	// 匹配規則，不可用的儲存策略被忽略
+	{
+		fs := newRoutingFS()
+		asserts.NoError(fs.RoutePolicy("1.mp4", "", 600, "/"))
+		asserts.EqualValues(2, fs.Policy.ID)
+		asserts.EqualValues(2, fs.User.Policy.ID)
+
+		// 不再匹配時回到預設儲存策略
+		asserts.NoError(fs.RoutePolicy("1.txt", "", 600, "/"))
+		asserts.EqualValues(1, fs.Policy.ID)
+		asserts.EqualValues(1, fs.User.Policy.ID)
+	}
+
+	// 未匹配規則，保持預設儲存策略
+	{
+		fs := newRoutingFS()
+		asserts.NoError(fs.RoutePolicy("1.mp4", "", 100, "/"))
+		asserts.Nil(fs.Policy)
+		asserts.EqualValues(1, fs.User.Policy.ID)
+	}
+}
+
+func TestHookRoutePolicy(t *testing.T) {
+	asserts := assert.New(t)
+	defer cache.Deletes([]string{"1", "2", "3"}, "policy_")
+
+	fs := newRoutingFS()
+	ctx := context.WithValue(context.Background(), fsctx.FileHeaderCtx, local.FileStream{
+		Name:        "1.bin",
+		MIMEType:    "video/mp4",
+		Size:        800,
+		VirtualPath: "/videos",
+	})
+	asserts.NoError(HookRoutePolicy(ctx, fs))
+	asserts.EqualValues(2, fs.User.Policy.ID)
+}
+
+func TestFileSystem_GetUploadToken_Route(t *testing.T) {
+	asserts := assert.New(t)
+	defer cache.Deletes([]string{"1", "2", "3"}, "policy_")
+	cache.SetSettings(map[string]string{
+		"upload_credential_timeout": "10",
+		"upload_session_timeout":    "10",
+	}, "setting_")
+
+	// 按規則選擇的儲存策略建立上傳工作階段
+	{
+		fs := newRoutingFS()
+		var callbackKey string
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Token", testMock.Anything, int64(10), testMock.Anything).
+			Run(func(args testMock.Arguments) { callbackKey = args.String(2) }).
+			Return(serializer.UploadCredential{Token: "test"}, nil)
+		fs.Handler = testHandler
+
+		res, err := fs.GetUploadToken(context.Background(), "/", 600, "1.mp4")
+		asserts.NoError(err)
+		asserts.Equal("mock", res.PolicyType)
+		session, ok := cache.Get("callback_" + callbackKey)
+		asserts.True(ok)
+		asserts.EqualValues(2, session.(serializer.UploadSession).PolicyID)
+	}
+
+	// 檢查所選儲存策略的檔案大小限制
+	{
+		fs := newRoutingFS()
+		fs.Handler = new(FileHeaderMock)
+		_, err := fs.GetUploadToken(context.Background(), "/", 1001, "1.mp4")
+		asserts.Equal(ErrFileSizeTooBig, err)
+	}
+}
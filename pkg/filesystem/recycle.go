@@ -0,0 +1,241 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+)
+
+/* ============
+	 回收站相關
+   ============
+*/
+
+// IsRecycleEnabled 返回目前使用者群組是否啟用了回收站
+func (fs *FileSystem) IsRecycleEnabled() bool {
+	return fs.User.Group.OptionsSerialized.RecycleDays > 0
+}
+
+// Remove 刪除物件，使用者群組啟用回收站時將物件移入回收站，否則直接刪除
+func (fs *FileSystem) Remove(ctx context.Context, dirs, files []uint) error {
+	if fs.IsRecycleEnabled() {
+		return fs.Trash(ctx, dirs, files)
+	}
+	return fs.Delete(ctx, dirs, files, false)
+}
+
+// Trash 將目錄和文件移入回收站，物件佔用的容量在徹底刪除前不會歸還
+func (fs *FileSystem) Trash(ctx context.Context, dirs, files []uint) error {
+	folders, err := model.GetFoldersByIDs(dirs, fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	fileObjects, err := model.GetFilesByIDs(files, fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	for i := 0; i < len(folders); i++ {
+		if err := fs.trashFolder(ctx, &folders[i]); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < len(fileObjects); i++ {
+		if err := fs.trashFile(ctx, &fileObjects[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// trashFolder 將目錄及其所有子物件移入回收站
+func (fs *FileSystem) trashFolder(ctx context.Context, folder *model.Folder) error {
+	// 根目錄無法刪除
+	if folder.ParentID == nil {
+		return ErrRootProtected
+	}
+
+	// 記錄原始路徑
+	if err := folder.TraceRoot(); err != nil {
+		return ErrPathNotExist.WithError(err)
+	}
+
+	// 列出所有子目錄、子文件
+	folders, err := model.GetRecursiveChildFolder([]uint{folder.ID}, fs.User.ID, true)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+	files, err := model.GetChildFilesOfFolders(&folders)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	record := &model.Recycle{
+		UserID:     fs.User.ID,
+		Name:       folder.Name,
+		IsDir:      true,
+		ObjectID:   folder.ID,
+		OriginPath: folder.Position,
+	}
+
+	folderIDs := make([]uint, 0, len(folders))
+	for _, value := range folders {
+		folderIDs = append(folderIDs, value.ID)
+	}
+	fileIDs := make([]uint, 0, len(files))
+	for _, value := range files {
+		fileIDs = append(fileIDs, value.ID)
+		record.Size += value.Size
+	}
+
+	parent := &model.Folder{OwnerID: fs.User.ID}
+	parent.ID = *folder.ParentID
+
+	return fs.moveToRecycle(record, parent, folderIDs, fileIDs)
+}
+
+// trashFile 將文件移入回收站
+func (fs *FileSystem) trashFile(ctx context.Context, file *model.File) error {
+	// 記錄原始路徑
+	parents, err := model.GetFoldersByIDs([]uint{file.FolderID}, fs.User.ID)
+	if err != nil || len(parents) == 0 {
+		return ErrPathNotExist.WithError(err)
+	}
+	if err := parents[0].TraceRoot(); err != nil {
+		return ErrPathNotExist.WithError(err)
+	}
+
+	record := &model.Recycle{
+		UserID:     fs.User.ID,
+		Name:       file.Name,
+		ObjectID:   file.ID,
+		OriginPath: path.Join(parents[0].Position, parents[0].Name),
+		Size:       file.Size,
+	}
+
+	return fs.moveToRecycle(record, &parents[0], nil, []uint{file.ID})
+}
+
+// moveToRecycle 將物件移入一個新的回收站容器目錄，並軟刪除物件及其子物件。
+// 使用容器目錄可以避免與原目錄下新建立的同名物件衝突
+func (fs *FileSystem) moveToRecycle(record *model.Recycle, parent *model.Folder, folderIDs, fileIDs []uint) error {
+	if err := record.Trash(parent.ID, folderIDs, fileIDs); err != nil {
+		return serializer.NewError(serializer.CodeDBError, "無法移動物件到回收站", err)
+	}
+	return nil
+}
+
+// Restore 將回收站中的物件復原到 dst 目錄，dst 為空時復原到原始位置
+func (fs *FileSystem) Restore(ctx context.Context, recycles []model.Recycle, dst string) error {
+	for i := 0; i < len(recycles); i++ {
+		if err := fs.restoreRecycle(ctx, &recycles[i], dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreRecycle 復原單個回收站項目
+func (fs *FileSystem) restoreRecycle(ctx context.Context, record *model.Recycle, dst string) error {
+	// 尋找目的目錄
+	target := dst
+	if target == "" {
+		target = record.OriginPath
+	}
+	exist, folder := fs.IsPathExist(target)
+	if !exist {
+		if dst != "" {
+			return ErrPathNotExist
+		}
+
+		// 原始目錄已不存在時，重新建立
+		var err error
+		folder, err = fs.CreateDirectory(context.WithValue(ctx, fsctx.IgnoreDirectoryConflictCtx, true), target)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 檢查是否重名
+	if _, err := folder.GetChild(record.Name); err == nil {
+		return ErrFileExisted
+	}
+	if ok, _ := fs.IsChildFileExist(folder, record.Name); ok {
+		return ErrFileExisted
+	}
+
+	// 列出容器中的所有物件
+	folders, err := model.GetTrashedChildFolder(record.FolderID, fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+	files, err := model.GetTrashedChildFilesOfFolders(&folders)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	folderIDs := make([]uint, 0, len(folders))
+	for _, value := range folders {
+		if value.ID != record.FolderID {
+			folderIDs = append(folderIDs, value.ID)
+		}
+	}
+	fileIDs := make([]uint, 0, len(files))
+	for _, value := range files {
+		fileIDs = append(fileIDs, value.ID)
+	}
+
+	// 取消軟刪除並移回目的目錄
+	if err := record.Restore(folder.ID, folderIDs, fileIDs); err != nil {
+		return serializer.NewError(serializer.CodeDBError, "無法復原物件", err)
+	}
+
+	return nil
+}
+
+// DeleteRecycles 徹底刪除回收站中的物件，並歸還容量
+func (fs *FileSystem) DeleteRecycles(ctx context.Context, recycles []model.Recycle) error {
+	failed := 0
+	for i := 0; i < len(recycles); i++ {
+		fs.CleanTargets()
+
+		// 列出容器中的所有物件
+		folders, err := model.GetTrashedChildFolder(recycles[i].FolderID, fs.User.ID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+		files, err := model.GetTrashedChildFilesOfFolders(&folders)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+		fs.SetTargetDir(&folders)
+		fs.SetTargetFile(&files)
+
+		// 有文件未能刪除時，保留回收站記錄以便下次重試
+		if err := fs.deleteTargets(ctx, false); err != nil {
+			failed++
+			continue
+		}
+
+		if err := recycles[i].Delete(); err != nil {
+			return ErrDBDeleteObjects.WithError(err)
+		}
+	}
+
+	if failed > 0 {
+		return serializer.NewError(
+			serializer.CodeNotFullySuccess,
+			fmt.Sprintf("有 %d 個項目未能徹底刪除", failed),
+			nil,
+		)
+	}
+
+	return nil
+}
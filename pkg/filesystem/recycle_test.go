@@ -0,0 +1,49 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_IsRecycleEnabled(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	asserts.False(fs.IsRecycleEnabled())
+
+	fs.User.Group.OptionsSerialized.RecycleDays = 7
+	asserts.True(fs.IsRecycleEnabled())
+}
+
+func TestFileSystem_Trash(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{
+		Model: gorm.Model{ID: 1},
+	}}
+	fs.User.Group.OptionsSerialized.RecycleDays = 7
+	ctx := context.Background()
+
+	// 列出目錄失敗
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnError(errors.New("error"))
+		err := fs.Remove(ctx, []uint{1}, []uint{})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 根目錄受保護
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(1, nil))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		err := fs.Remove(ctx, []uint{1}, []uint{})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrRootProtected, err)
+	}
+}
@@ -0,0 +1,99 @@
+package filesystem
+
+import (
+	"context"
+	"strconv"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/fulltext"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+)
+
+// SearchOption 搜尋結果的排序及分頁選項
+type SearchOption struct {
+	OrderBy  string // 排序欄位
+	Order    string // ASC 或 DESC
+	Page     int    // 頁碼，從 1 開始
+	PageSize int    // 每頁數量，為 0 時不分頁
+}
+
+// SearchQuery 根據查詢語法樹搜尋文件，返回目前頁的結果及符合條件的總數
+func (fs *FileSystem) SearchQuery(ctx context.Context, query *search.Node, option SearchOption) ([]Object, int, error) {
+	condition, args, err := search.Compile(query, &searchResolver{fs: fs})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order := ""
+	if option.OrderBy != "" {
+		order = option.OrderBy + " " + option.Order
+	}
+	if option.Page < 1 {
+		option.Page = 1
+	}
+
+	files, total, err := model.SearchFiles(fs.User.ID, condition, args, order, option.Page, option.PageSize)
+	if err != nil {
+		return nil, 0, ErrDBListObjects.WithError(err)
+	}
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, nil, nil), total, nil
+}
+
+// searchResolver 從使用者的文件系統中解析查詢條件
+type searchResolver struct {
+	fs *FileSystem
+}
+
+// Folders 返回給定路徑的目錄及其所有子目錄的ID
+func (resolver *searchResolver) Folders(path string) ([]uint, error) {
+	exist, folder := resolver.fs.IsPathExist(path)
+	if !exist {
+		return nil, ErrPathNotExist
+	}
+
+	folders, err := model.GetRecursiveChildFolder([]uint{folder.ID}, resolver.fs.User.ID, true)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+
+	ids := make([]uint, 0, len(folders))
+	for _, folder := range folders {
+		ids = append(ids, folder.ID)
+	}
+	return ids, nil
+}
+
+// Tag 返回給定名稱的文件分類標籤表達式
+func (resolver *searchResolver) Tag(name string) (string, bool, error) {
+	tag, err := model.GetFileTagByName(name, resolver.fs.User.ID)
+	if err != nil {
+		return "", false, serializer.NewError(serializer.CodeNotFound, "標籤 "+name+" 不存在", err)
+	}
+	return tag.Expression, tag.Query, nil
+}
+
+// Content 返回使用者文件中內容包含給定文字的文件ID
+func (resolver *searchResolver) Content(text string) ([]uint, error) {
+	if !fulltext.IsEnabled() {
+		return nil, serializer.NewError(serializer.CodeNoPermissionErr, "未啟用全文搜尋", nil)
+	}
+	return fulltext.Default.Search(resolver.fs.User.ID, text), nil
+}
+
+// Policy 返回給定ID或名稱的儲存策略ID，名稱只在使用者群組可用的策略中尋找
+func (resolver *searchResolver) Policy(name string) (uint, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint(id), nil
+	}
+
+	for _, id := range resolver.fs.User.Group.PolicyList {
+		if policy, err := model.GetPolicyByID(id); err == nil && policy.Name == name {
+			return policy.ID, nil
+		}
+	}
+
+	return 0, serializer.NewError(serializer.CodeNotFound, "儲存策略 "+name+" 不存在", nil)
+}
@@ -0,0 +1,98 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_SearchQuery(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{User: &model.User{
+		Model: gorm.Model{ID: 1},
+	}}
+
+	// 成功
+	{
+		query, err := search.Parse("in:/ -name:*.tmp")
+		asserts.NoError(err)
+
+		// 根目錄
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		// 遞迴列出子目錄
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(2, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
+		// 搜尋文件
+		mock.ExpectQuery("SELECT count(.+)files(.+)").
+			WithArgs(1, 1, 2, "%.tmp").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)files(.+)ORDER BY name ASC LIMIT 2 OFFSET 0").
+			WithArgs(1, 1, 2, "%.tmp").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "a.txt").AddRow(2, "b.txt"))
+
+		res, total, err := fs.SearchQuery(ctx, query, SearchOption{OrderBy: "name", Order: "ASC", PageSize: 2})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(3, total)
+		asserts.Len(res, 2)
+		asserts.Equal("a.txt", res[0].Name)
+	}
+
+	// 目錄不存在
+	{
+		query, _ := search.Parse("in:/not_exist")
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
+		_, _, err := fs.SearchQuery(ctx, query, SearchOption{})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrPathNotExist, err)
+	}
+
+	// 標籤不存在
+	{
+		query, _ := search.Parse("tag:work")
+		mock.ExpectQuery("SELECT(.+)tags(.+)").
+			WithArgs(1, model.FileTagType, "work").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, _, err := fs.SearchQuery(ctx, query, SearchOption{})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestSearchResolver_Policy(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	fs.User.Group.PolicyList = []uint{101}
+	cache.Set("policy_101", model.Policy{Model: gorm.Model{ID: 101}, Name: "main"}, 0)
+	defer cache.Deletes([]string{"101"}, "policy_")
+	resolver := &searchResolver{fs: fs}
+
+	id, err := resolver.Policy("5")
+	asserts.NoError(err)
+	asserts.EqualValues(5, id)
+
+	id, err = resolver.Policy("main")
+	asserts.NoError(err)
+	asserts.EqualValues(101, id)
+
+	_, err = resolver.Policy("other")
+	asserts.Error(err)
+}
@@ -45,12 +45,16 @@ func (fs *FileSystem) Upload(ctx context.Context, file FileHeader) (err error) {
 	// 處理用戶端未完成上傳時，關閉連接
 	go fs.CancelUpload(ctx, savePath, file)
 
-	// 儲存文件
-	err = fs.Handler.Put(ctx, file, savePath, file.GetSize())
+	// 儲存文件，同時計算內容雜湊值
+	hashed := newHashReader(file)
+	err = fs.Handler.Put(ctx, hashed, savePath, file.GetSize())
 	if err != nil {
 		fs.Trigger(ctx, "AfterUploadFailed")
 		return err
 	}
+	if hash := hashed.Sum(); hash != "" {
+		ctx = context.WithValue(ctx, fsctx.FileHashCtx, hash)
+	}
 
 	// 上傳完成後的鉤子
 	err = fs.Trigger(ctx, "AfterUpload")
@@ -76,6 +80,44 @@ func (fs *FileSystem) Upload(ctx context.Context, file FileHeader) (err error) {
 	return nil
 }
 
+// InstantUpload 秒傳，以內容相同的已有物理文件建立新文件，不傳輸文件內容
+func (fs *FileSystem) InstantUpload(ctx context.Context, origin *model.File, file FileHeader) (err error) {
+	// 新文件使用已有物理文件所在的儲存策略
+	fs.Policy = origin.GetPolicy()
+	fs.User.Policy = *fs.Policy
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+
+	ctx = context.WithValue(ctx, fsctx.FileHeaderCtx, file)
+	ctx = context.WithValue(ctx, fsctx.SavePathCtx, origin.SourceName)
+	ctx = context.WithValue(ctx, fsctx.FileHashCtx, origin.Hash)
+
+	// 上傳前的鉤子
+	err = fs.Trigger(ctx, "BeforeUpload")
+	if err != nil {
+		return err
+	}
+
+	// 上傳完成後的鉤子
+	err = fs.Trigger(ctx, "AfterUpload")
+	if err != nil {
+		if followUpErr := fs.Trigger(ctx, "AfterValidateFailed"); followUpErr != nil {
+			util.Log().Debug("AfterValidateFailed 鉤子執行失敗，%s", followUpErr)
+		}
+		return err
+	}
+
+	util.Log().Info(
+		"新文件秒傳:%s , 大小:%d, 上傳者:%s",
+		file.GetFileName(),
+		file.GetSize(),
+		fs.User.Nick,
+	)
+
+	return nil
+}
+
 // GenerateSavePath 生成要存放文件的路徑
 // TODO 完善測試
 func (fs *FileSystem) GenerateSavePath(ctx context.Context, file FileHeader) string {
@@ -152,7 +194,11 @@ func (fs *FileSystem) GetUploadToken(ctx context.Context, path string, size uint
 	credentialTTL := model.GetIntSetting("upload_credential_timeout", 3600)
 	callBackSessionTTL := model.GetIntSetting("upload_session_timeout", 86400)
 
-	var err error
+	// 按上傳規則選擇儲存策略
+	err := fs.RoutePolicy(name, "", size, path)
+	if err != nil {
+		return nil, err
+	}
 
 	// 檢查檔案大小
 	if fs.User.Policy.MaxSize != 0 {
@@ -175,6 +221,7 @@ func (fs *FileSystem) GetUploadToken(ctx context.Context, path string, size uint
 	if err != nil {
 		return nil, serializer.NewError(serializer.CodeEncryptError, "無法獲取上傳憑證", err)
 	}
+	credential.PolicyType = fs.User.Policy.ClientType()
 
 	// 建立回調工作階段
 	err = cache.Set(
@@ -182,7 +229,7 @@ func (fs *FileSystem) GetUploadToken(ctx context.Context, path string, size uint
 		serializer.UploadSession{
 			Key:         callbackKey,
 			UID:         fs.User.ID,
-			PolicyID:    fs.User.GetPolicyID(0),
+			PolicyID:    fs.User.Policy.ID,
 			VirtualPath: path,
 			Name:        name,
 			Size:        size,
@@ -212,11 +259,13 @@ func (fs *FileSystem) UploadFromStream(ctx context.Context, src io.ReadCloser, d
 	// 給文件系統分配鉤子
 	fs.Lock.Lock()
 	if fs.Hooks == nil {
+		fs.Use("BeforeUpload", HookRoutePolicy)
 		fs.Use("BeforeUpload", HookValidateFile)
 		fs.Use("BeforeUpload", HookValidateCapacity)
 		fs.Use("AfterUploadCanceled", HookDeleteTempFile)
 		fs.Use("AfterUploadCanceled", HookGiveBackCapacity)
 		fs.Use("AfterUpload", GenericAfterUpload)
+		fs.Use("AfterUpload", HookIndexContent)
 		fs.Use("AfterValidateFailed", HookDeleteTempFile)
 		fs.Use("AfterValidateFailed", HookGiveBackCapacity)
 		fs.Use("AfterUploadFailed", HookGiveBackCapacity)
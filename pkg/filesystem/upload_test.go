@@ -236,3 +236,58 @@ func TestFileSystem_UploadFromPath(t *testing.T) {
 		asserts.Error(err)
 	}
 }
+
+func TestFileSystem_InstantUpload(t *testing.T) {
+	asserts := assert.New(t)
+	origin := &model.File{
+		SourceName: "origin.txt",
+		Hash:       "hash",
+		PolicyID:   2,
+		Policy:     model.Policy{Model: gorm.Model{ID: 2}, Type: "local"},
+	}
+	file := local.FileStream{
+		Size:        5,
+		VirtualPath: "/",
+		Name:        "1.txt",
+	}
+
+	// 成功
+	{
+		fs := &FileSystem{User: &model.User{Policy: model.Policy{Model: gorm.Model{ID: 1}}}}
+		fs.Use("AfterUpload", func(ctx context.Context, fs *FileSystem) error {
+			asserts.Equal("origin.txt", ctx.Value(fsctx.SavePathCtx))
+			asserts.Equal("hash", ctx.Value(fsctx.FileHashCtx))
+			asserts.EqualValues(2, fs.User.Policy.ID)
+			return nil
+		})
+		asserts.NoError(fs.InstantUpload(context.Background(), origin, file))
+	}
+
+	// BeforeUpload 鉤子失敗
+	{
+		fs := &FileSystem{User: &model.User{}}
+		fs.Use("BeforeUpload", func(ctx context.Context, fs *FileSystem) error {
+			return errors.New("error")
+		})
+		fs.Use("AfterUpload", func(ctx context.Context, fs *FileSystem) error {
+			asserts.Fail("AfterUpload should not be triggered")
+			return nil
+		})
+		asserts.Error(fs.InstantUpload(context.Background(), origin, file))
+	}
+
+	// AfterUpload 鉤子失敗
+	{
+		fs := &FileSystem{User: &model.User{}}
+		failed := false
+		fs.Use("AfterUpload", func(ctx context.Context, fs *FileSystem) error {
+			return errors.New("error")
+		})
+		fs.Use("AfterValidateFailed", func(ctx context.Context, fs *FileSystem) error {
+			failed = true
+			return nil
+		})
+		asserts.Error(fs.InstantUpload(context.Background(), origin, file))
+		asserts.True(failed)
+	}
+}
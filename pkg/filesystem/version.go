@@ -0,0 +1,142 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ==============
+	 歷史版本相關
+   ==============
+*/
+
+// IsVersionEnabled 返回目前使用者群組是否保留文件的歷史版本
+func (fs *FileSystem) IsVersionEnabled() bool {
+	return fs.User.Group.OptionsSerialized.MaxVersions > 0
+}
+
+// HookKeepVersion 將文件被覆蓋前的內容保存為歷史版本，
+// 並將文件指向新上傳的物理文件
+func HookKeepVersion(ctx context.Context, fs *FileSystem) error {
+	originFile, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+	if !ok {
+		return ErrObjectNotExist
+	}
+
+	// 上下文中的文件已指向新的物理文件，需從資料庫讀取覆蓋前的訊息
+	files, err := model.GetFilesByIDs([]uint{originFile.ID}, originFile.UserID)
+	if err != nil || len(files) == 0 {
+		return ErrObjectNotExist
+	}
+
+	// 保存歷史版本與切換文件內容需同時完成，避免文件或歷史版本指向錯誤的物理文件
+	size := originFile.Size
+	if header, ok := ctx.Value(fsctx.FileHeaderCtx).(FileHeader); ok {
+		size = header.GetSize()
+	}
+	hash, _ := ctx.Value(fsctx.FileHashCtx).(string)
+	version := model.NewVersionFromFile(&files[0])
+	if err := files[0].KeepVersion(version, originFile.SourceName, size, hash); err != nil {
+		return serializer.NewError(serializer.CodeDBError, "無法建立歷史版本", err)
+	}
+
+	// 清理超出數量限制的舊版本，需刪除物理文件，無法與上述記錄一同回滾
+	if err := fs.pruneVersions(ctx, originFile.ID); err != nil {
+		util.Log().Warning("無法清理文件[%d]的舊版本，%s", originFile.ID, err)
+	}
+
+	return nil
+}
+
+// pruneVersions 刪除超出使用者群組限制的最舊的歷史版本
+func (fs *FileSystem) pruneVersions(ctx context.Context, fileID uint) error {
+	versions, err := model.GetVersionsByFileID(fileID)
+	if err != nil {
+		return err
+	}
+
+	max := fs.User.Group.OptionsSerialized.MaxVersions
+	if len(versions) <= max {
+		return nil
+	}
+
+	return fs.DeleteVersions(ctx, versions[max:])
+}
+
+// RestoreVersion 將文件內容還原為給定的歷史版本，目前內容會被保存為新的歷史版本
+func (fs *FileSystem) RestoreVersion(ctx context.Context, file *model.File, version *model.FileVersion) error {
+	current := model.NewVersionFromFile(file)
+	if err := file.RestoreVersion(current, version); err != nil {
+		return serializer.NewError(serializer.CodeDBError, "無法還原歷史版本", err)
+	}
+
+	IndexContent(*file)
+	return nil
+}
+
+// DeleteVersions 刪除歷史版本及其物理文件，並歸還容量
+func (fs *FileSystem) DeleteVersions(ctx context.Context, versions []model.FileVersion) error {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	// 去除物理文件仍被引用的版本
+	versionsToBeDelete, err := model.RemoveVersionsWithSoftLinks(versions)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	files := make([]model.File, 0, len(versionsToBeDelete))
+	for _, version := range versionsToBeDelete {
+		files = append(files, model.File{
+			SourceName: version.SourceName,
+			PolicyID:   version.PolicyID,
+		})
+	}
+
+	// 按照儲存策略分組刪除物件
+	failed := fs.deleteGroupedFile(ctx, fs.GroupFileByPolicy(ctx, files))
+
+	// 整理刪除結果
+	var total uint64
+	deletedIDs := make([]uint, 0, len(versions))
+	for _, version := range versions {
+		if !util.ContainsString(failed[version.PolicyID], version.SourceName) {
+			deletedIDs = append(deletedIDs, version.ID)
+			total += version.Size
+		}
+	}
+
+	if err := model.DeleteVersionByIDs(deletedIDs); err != nil {
+		return ErrDBDeleteObjects.WithError(err)
+	}
+
+	// 歸還容量
+	fs.User.DeductionStorage(total)
+
+	if notDeleted := len(versions) - len(deletedIDs); notDeleted > 0 {
+		return serializer.NewError(
+			serializer.CodeNotFullySuccess,
+			fmt.Sprintf("有 %d 個歷史版本未能成功刪除", notDeleted),
+			nil,
+		)
+	}
+
+	return nil
+}
+
+// GenerateVersionSavePath 為覆蓋上傳的新內容生成存放路徑，
+// 確保不會覆寫仍由歷史版本持有的原有物理文件
+func (fs *FileSystem) GenerateVersionSavePath(ctx context.Context, file FileHeader, origin *model.File) string {
+	savePath := fs.GenerateSavePath(ctx, file)
+	if savePath == origin.SourceName {
+		savePath = path.Join(path.Dir(savePath), util.RandStringRunes(8)+"_"+path.Base(savePath))
+	}
+	return savePath
+}
@@ -0,0 +1,174 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_IsVersionEnabled(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	asserts.False(fs.IsVersionEnabled())
+
+	fs.User.Group.OptionsSerialized.MaxVersions = 5
+	asserts.True(fs.IsVersionEnabled())
+}
+
+func TestHookKeepVersion(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{
+		Model: gorm.Model{ID: 1},
+	}}
+	fs.User.Group.OptionsSerialized.MaxVersions = 5
+
+	// 上下文不存在文件
+	{
+		err := HookKeepVersion(context.Background(), fs)
+		asserts.Error(err)
+	}
+
+	originFile := model.File{
+		Model:      gorm.Model{ID: 1},
+		UserID:     1,
+		SourceName: "new.txt",
+	}
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, originFile)
+
+	// 文件記錄不存在
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		err := HookKeepVersion(ctx, fs)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 無法建立歷史版本
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}).AddRow(1, "old.txt"))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		err := HookKeepVersion(ctx, fs)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 成功，未超出版本數量限制
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}).AddRow(1, "old.txt"))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs("", 0, "new.txt", sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		err := HookKeepVersion(ctx, fs)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+	}
+}
+
+func TestFileSystem_RestoreVersion(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{
+		Model: gorm.Model{ID: 1},
+	}}
+	file := &model.File{Model: gorm.Model{ID: 1}, SourceName: "new.txt"}
+	version := &model.FileVersion{Model: gorm.Model{ID: 2}, SourceName: "old.txt"}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		err := fs.RestoreVersion(context.Background(), file, version)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal("old.txt", file.SourceName)
+	}
+
+	// 無法建立歷史版本
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		err := fs.RestoreVersion(context.Background(), file, version)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestFileSystem_DeleteVersions(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{
+		Model:   gorm.Model{ID: 1},
+		Storage: 10,
+	}}
+
+	// 空列表
+	asserts.NoError(fs.DeleteVersions(context.Background(), []model.FileVersion{}))
+
+	// 物理文件仍被引用，只刪除記錄
+	{
+		versions := []model.FileVersion{
+			{Model: gorm.Model{ID: 1}, SourceName: "1.txt", PolicyID: 1, Size: 2},
+		}
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "policy_id"}).AddRow(5, "1.txt", 1))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)users(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		err := fs.DeleteVersions(context.Background(), versions)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(8, fs.User.Storage)
+	}
+}
+
+func TestFileSystem_GenerateVersionSavePath(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{
+		Model: gorm.Model{ID: 1},
+		Policy: model.Policy{
+			DirNameRule:  "uploads",
+			FileNameRule: "{originname}",
+		},
+	}}
+	file := local.FileStream{Name: "1.txt"}
+
+	// 不衝突
+	{
+		origin := &model.File{SourceName: "uploads/old.txt"}
+		asserts.Equal("uploads/1.txt", fs.GenerateVersionSavePath(context.Background(), file, origin))
+	}
+
+	// 與原有物理文件衝突
+	{
+		origin := &model.File{SourceName: "uploads/1.txt"}
+		savePath := fs.GenerateVersionSavePath(context.Background(), file, origin)
+		asserts.NotEqual("uploads/1.txt", savePath)
+		asserts.Contains(savePath, "uploads/")
+		asserts.Contains(savePath, "_1.txt")
+	}
+}
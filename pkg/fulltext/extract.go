@@ -0,0 +1,67 @@
+package fulltext
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// ErrUnsupportedType 不支援提取文字的文件類型
+var ErrUnsupportedType = errors.New("不支援提取此類文件的文字")
+
+// maxTextLength 從單個文件中提取的最大文字長度（位元組）
+const maxTextLength = 4 << 20
+
+// textExtensions 作為純文字提取的副檔名，包括 Markdown 及常見原始碼
+var textExtensions = []string{
+	"txt", "text", "md", "markdown", "rst", "csv", "tsv", "log", "json", "xml", "yaml", "yml",
+	"toml", "ini", "conf", "cfg", "properties", "html", "htm", "css", "scss", "less", "tex",
+	"go", "py", "js", "jsx", "ts", "tsx", "vue", "java", "kt", "scala", "c", "h", "cc", "cpp",
+	"hpp", "cs", "rb", "php", "rs", "swift", "m", "lua", "pl", "r", "sh", "bash", "bat", "ps1",
+	"sql", "dart", "groovy", "gradle", "makefile", "dockerfile",
+}
+
+// Supported 返回是否支援提取給定檔案名的文件文字
+func Supported(name string) bool {
+	ext := fileType(name)
+	switch ext {
+	case "pdf", "docx", "xlsx", "pptx":
+		return true
+	}
+	return util.ContainsString(textExtensions, ext)
+}
+
+// Extract 根據檔案名提取文件內容中的文字
+func Extract(name string, content []byte) (string, error) {
+	var (
+		text string
+		err  error
+	)
+
+	switch ext := fileType(name); {
+	case ext == "pdf":
+		text, err = extractPDF(content)
+	case ext == "docx" || ext == "xlsx" || ext == "pptx":
+		text, err = extractOOXML(ext, content)
+	case util.ContainsString(textExtensions, ext):
+		text = strings.ToValidUTF8(string(content), " ")
+	default:
+		return "", ErrUnsupportedType
+	}
+
+	if len(text) > maxTextLength {
+		text = strings.ToValidUTF8(text[:maxTextLength], "")
+	}
+	return text, err
+}
+
+// fileType 返回小寫的副檔名，沒有副檔名時返回檔案名，以識別 Makefile 等文件
+func fileType(name string) string {
+	name = strings.ToLower(path.Base(name))
+	if ext := path.Ext(name); ext != "" {
+		return ext[1:]
+	}
+	return name
+}
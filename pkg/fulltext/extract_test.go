@@ -0,0 +1,191 @@
+package fulltext
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupported(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.True(Supported("README.md"))
+	asserts.True(Supported("main.GO"))
+	asserts.True(Supported("Makefile"))
+	asserts.True(Supported("report.pdf"))
+	asserts.True(Supported("report.docx"))
+	asserts.False(Supported("image.png"))
+	asserts.False(Supported("report.doc"))
+}
+
+func TestExtract(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 純文字
+	{
+		text, err := Extract("a.txt", []byte("hello\xffworld"))
+		asserts.NoError(err)
+		asserts.Equal("hello world", text)
+	}
+
+	// 不支援的類型
+	{
+		_, err := Extract("a.png", []byte("hello"))
+		asserts.Equal(ErrUnsupportedType, err)
+	}
+
+	// 文字過長
+	{
+		text, err := Extract("a.txt", bytes.Repeat([]byte("a"), maxTextLength+10))
+		asserts.NoError(err)
+		asserts.Len(text, maxTextLength)
+	}
+}
+
+func zipFile(files map[string]string) []byte {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, _ := writer.Create(name)
+		w.Write([]byte(content))
+	}
+	writer.Close()
+	return buf.Bytes()
+}
+
+func TestExtractOOXML(t *testing.T) {
+	asserts := assert.New(t)
+
+	// docx
+	{
+		text, err := Extract("a.docx", zipFile(map[string]string{
+			"word/document.xml": `<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:t xml:space="preserve"> World</w:t></w:r></w:p><w:p><w:r><w:t>雲端</w:t></w:r></w:p></w:body></w:document>`,
+			"word/header1.xml":  `<w:hdr xmlns:w="w"><w:p><w:r><w:t>Header</w:t></w:r></w:p></w:hdr>`,
+			"word/styles.xml":   `<w:styles xmlns:w="w"><w:t>Ignored</w:t></w:styles>`,
+		}))
+		asserts.NoError(err)
+		asserts.Contains(text, "Hello World\n雲端\n")
+		asserts.Contains(text, "Header")
+		asserts.NotContains(text, "Ignored")
+	}
+
+	// xlsx
+	{
+		text, err := Extract("a.xlsx", zipFile(map[string]string{
+			"xl/sharedStrings.xml": `<sst><si><t>Cell A</t></si><si><r><t>Cell</t></r><r><t> B</t></r></si></sst>`,
+		}))
+		asserts.NoError(err)
+		asserts.Equal("Cell A\nCell B\n", text)
+	}
+
+	// pptx 按投影片順序
+	{
+		text, err := Extract("a.pptx", zipFile(map[string]string{
+			"ppt/slides/slide10.xml": `<p:sld xmlns:a="a" xmlns:p="p"><a:p><a:r><a:t>Ten</a:t></a:r></a:p></p:sld>`,
+			"ppt/slides/slide2.xml":  `<p:sld xmlns:a="a" xmlns:p="p"><a:p><a:r><a:t>Two</a:t></a:r></a:p></p:sld>`,
+		}))
+		asserts.NoError(err)
+		asserts.Equal("Two\nTen\n", text)
+	}
+
+	// 無效的文件
+	{
+		_, err := Extract("a.docx", []byte("not zip"))
+		asserts.Error(err)
+	}
+}
+
+// buildPDF 以給定的物件內容建立 PDF 文件
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, object := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+func flate(content string) string {
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+	writer.Write([]byte(content))
+	writer.Close()
+	return buf.String()
+}
+
+func TestExtractPDF(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 未壓縮的字面字串
+	{
+		content := `BT /F1 12 Tf 72 712 Td (Hello \(PDF\)) Tj 0 -14 Td [(Wor) -50 (ld) -300 (again)] TJ ET`
+		text, err := Extract("a.pdf", buildPDF(
+			"<< /Type /Catalog >>",
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		))
+		asserts.NoError(err)
+		asserts.Equal("\nHello (PDF)\nWorld again\n", text)
+	}
+
+	// 壓縮串流及 ToUnicode 對照表
+	{
+		cmap := flate(`/CIDInit /ProcSet findresource begin
+begincmap
+2 beginbfchar
+<0001> <96F2>
+<0002> <7AEF>
+endbfchar
+1 beginbfrange
+<0010> <0012> <0041>
+endbfrange
+endcmap`)
+		content := flate(`BT /F1 12 Tf <00010002> Tj T* <001000110012> Tj ET`)
+		image := flate("BT (image) Tj ET")
+		text, err := Extract("a.pdf", buildPDF(
+			fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(cmap), cmap),
+			fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(content), content),
+			fmt.Sprintf("<< /Subtype /Image /Filter /FlateDecode >>\nstream\n%s\nendstream", image),
+			"<< /Filter /DCTDecode >>\nstream\nBT (jpeg) Tj ET\nendstream",
+		))
+		asserts.NoError(err)
+		asserts.Equal("雲端\nABC\n", text)
+	}
+
+	// 非 PDF 文件
+	{
+		_, err := Extract("a.pdf", []byte("hello"))
+		asserts.Equal(ErrInvalidPDF, err)
+	}
+}
+
+func TestPDFStreams_Limit(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 解壓縮總大小不超過上限
+	{
+		bomb := flate(strings.Repeat("\x00", 16<<20))
+		objects := make([]string, 8)
+		for i := range objects {
+			objects[i] = fmt.Sprintf("<< /Filter /FlateDecode >>\nstream\n%s\nendstream", bomb)
+		}
+		total := 0
+		for _, stream := range pdfStreams(buildPDF(objects...)) {
+			total += len(stream)
+		}
+		asserts.Equal(maxDecodedSize, total)
+	}
+
+	// 串流數不超過上限
+	{
+		objects := make([]string, maxStreams+10)
+		for i := range objects {
+			objects[i] = "<< >>\nstream\nBT ET\nendstream"
+		}
+		asserts.Len(pdfStreams(buildPDF(objects...)), maxStreams)
+	}
+}
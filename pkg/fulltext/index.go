@@ -0,0 +1,236 @@
+package fulltext
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// maxDocTerms 單個文件最多索引的不重複詞數量
+const maxDocTerms = 200000
+
+// Default 預設的全文索引
+var Default = NewIndex("")
+
+// Doc 已索引的文件
+type Doc struct {
+	UserID uint     // 文件所有者
+	Terms  []string // 文件包含的不重複詞，用於刪除索引
+}
+
+// Index 記憶體中的倒排索引，可持久化到本機文件
+type Index struct {
+	mu    sync.RWMutex
+	path  string
+	dirty bool
+	docs  map[uint]*Doc
+	terms map[string]map[uint]struct{}
+}
+
+// NewIndex 建立索引，path 為持久化路徑，留空時不持久化
+func NewIndex(path string) *Index {
+	return &Index{
+		path:  path,
+		docs:  make(map[uint]*Doc),
+		terms: make(map[string]map[uint]struct{}),
+	}
+}
+
+// Init 從本機文件載入全文索引
+func Init() {
+	path := util.RelativePath(model.GetSettingByName("fulltext_index_path"))
+	index := NewIndex(path)
+	if err := index.Load(); err != nil {
+		util.Log().Warning("無法載入全文索引，將使用空白索引，%s", err)
+	}
+	Default = index
+}
+
+// IsEnabled 返回是否啟用了全文索引
+func IsEnabled() bool {
+	return model.IsTrueVal(model.GetSettingByName("fulltext_enabled"))
+}
+
+// Add 索引文件內容，已有的索引會被取代
+func (index *Index) Add(id, uid uint, text string) {
+	seen := make(map[string]struct{})
+	terms := make([]string, 0)
+	for _, term := range Tokenize(text) {
+		if _, ok := seen[term]; ok {
+			continue
+		}
+		if len(terms) >= maxDocTerms {
+			break
+		}
+		seen[term] = struct{}{}
+		terms = append(terms, term)
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	index.remove(id)
+	index.docs[id] = &Doc{UserID: uid, Terms: terms}
+	for _, term := range terms {
+		if _, ok := index.terms[term]; !ok {
+			index.terms[term] = make(map[uint]struct{})
+		}
+		index.terms[term][id] = struct{}{}
+	}
+	index.dirty = true
+}
+
+// Remove 刪除文件的索引
+func (index *Index) Remove(ids ...uint) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	for _, id := range ids {
+		index.remove(id)
+	}
+}
+
+func (index *Index) remove(id uint) {
+	doc, ok := index.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.Terms {
+		delete(index.terms[term], id)
+		if len(index.terms[term]) == 0 {
+			delete(index.terms, term)
+		}
+	}
+	delete(index.docs, id)
+	index.dirty = true
+}
+
+// Contains 返回文件是否已被索引
+func (index *Index) Contains(id uint) bool {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	_, ok := index.docs[id]
+	return ok
+}
+
+// Search 返回使用者文件中包含所有搜尋詞的文件ID，按ID升序排列
+func (index *Index) Search(uid uint, text string) []uint {
+	terms := queryTerms(text)
+	res := make([]uint, 0)
+	if len(terms) == 0 {
+		return res
+	}
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	// 從最短的倒排表開始求交集
+	postings := make([]map[uint]struct{}, 0, len(terms))
+	for _, term := range terms {
+		posting, ok := index.terms[term]
+		if !ok {
+			return res
+		}
+		postings = append(postings, posting)
+	}
+	sort.Slice(postings, func(i, j int) bool {
+		return len(postings[i]) < len(postings[j])
+	})
+
+	for id := range postings[0] {
+		if index.docs[id].UserID != uid {
+			continue
+		}
+
+		matched := true
+		for _, posting := range postings[1:] {
+			if _, ok := posting[id]; !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			res = append(res, id)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+// Load 從持久化文件載入索引，文件不存在時返回空白索引
+func (index *Index) Load() error {
+	if index.path == "" || !util.Exists(index.path) {
+		return nil
+	}
+
+	file, err := os.Open(index.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	docs := make(map[uint]*Doc)
+	if err := gob.NewDecoder(file).Decode(&docs); err != nil {
+		return err
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	index.docs = docs
+	index.terms = make(map[string]map[uint]struct{})
+	for id, doc := range docs {
+		for _, term := range doc.Terms {
+			if _, ok := index.terms[term]; !ok {
+				index.terms[term] = make(map[uint]struct{})
+			}
+			index.terms[term][id] = struct{}{}
+		}
+	}
+	index.dirty = false
+
+	return nil
+}
+
+// Save 將有變更的索引寫入持久化文件
+func (index *Index) Save() error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if index.path == "" || !index.dirty {
+		return nil
+	}
+
+	// 先寫入臨時文件再取代，避免寫入中途失敗時損壞原有索引
+	if err := os.MkdirAll(filepath.Dir(index.path), 0700); err != nil {
+		return err
+	}
+	tmp := index.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(file).Encode(index.docs); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, index.path); err != nil {
+		return err
+	}
+
+	index.dirty = false
+	return nil
+}
@@ -0,0 +1,74 @@
+package fulltext
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndex(t *testing.T) {
+	asserts := assert.New(t)
+	index := NewIndex("")
+
+	index.Add(1, 1, "The quick brown fox")
+	index.Add(2, 1, "quick silver 雲端硬碟")
+	index.Add(3, 2, "quick brown")
+	asserts.True(index.Contains(1))
+	asserts.False(index.Contains(4))
+
+	// 所有詞都需匹配
+	asserts.Equal([]uint{1, 2}, index.Search(1, "QUICK"))
+	asserts.Equal([]uint{1}, index.Search(1, "brown quick"))
+	asserts.Empty(index.Search(1, "quick missing"))
+	asserts.Empty(index.Search(1, ""))
+
+	// 中文
+	asserts.Equal([]uint{2}, index.Search(1, "硬碟"))
+	asserts.Equal([]uint{2}, index.Search(1, "雲"))
+	asserts.Empty(index.Search(1, "端雲"))
+
+	// 只返回使用者自己的文件
+	asserts.Equal([]uint{3}, index.Search(2, "brown"))
+
+	// 重新索引
+	index.Add(1, 1, "lazy dog")
+	asserts.Equal([]uint{2}, index.Search(1, "quick"))
+	asserts.Equal([]uint{1}, index.Search(1, "dog"))
+
+	// 刪除
+	index.Remove(1, 5)
+	asserts.Empty(index.Search(1, "dog"))
+	asserts.False(index.Contains(1))
+	_, ok := index.terms["dog"]
+	asserts.False(ok)
+}
+
+func TestIndex_SaveLoad(t *testing.T) {
+	asserts := assert.New(t)
+	path := util.RelativePath("tests/fulltext.idx")
+	defer os.RemoveAll(util.RelativePath("tests"))
+
+	// 索引文件不存在
+	index := NewIndex(path)
+	asserts.NoError(index.Load())
+
+	// 沒有變更時不寫入
+	asserts.NoError(index.Save())
+	asserts.False(util.Exists(path))
+
+	index.Add(1, 2, "hello world")
+	asserts.NoError(index.Save())
+	asserts.True(util.Exists(path))
+
+	loaded := NewIndex(path)
+	asserts.NoError(loaded.Load())
+	asserts.Equal([]uint{1}, loaded.Search(2, "world"))
+
+	// 文件損壞
+	file, _ := os.Create(path)
+	file.WriteString("broken")
+	file.Close()
+	asserts.Error(NewIndex(path).Load())
+}
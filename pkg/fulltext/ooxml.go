@@ -0,0 +1,103 @@
+package fulltext
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+)
+
+// extractOOXML 提取 Office Open XML 文件（docx、xlsx、pptx）中的文字
+func extractOOXML(ext string, content []byte) (string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", err
+	}
+
+	// 依類型選出包含文字的部件
+	var parts []*zip.File
+	for _, file := range reader.File {
+		name := file.Name
+		switch ext {
+		case "docx":
+			if name == "word/document.xml" ||
+				(strings.HasPrefix(name, "word/") && (strings.HasPrefix(name[5:], "header") ||
+					strings.HasPrefix(name[5:], "footer") || name == "word/footnotes.xml")) {
+				parts = append(parts, file)
+			}
+		case "xlsx":
+			if name == "xl/sharedStrings.xml" {
+				parts = append(parts, file)
+			}
+		case "pptx":
+			if strings.HasPrefix(name, "ppt/slides/slide") && strings.HasSuffix(name, ".xml") {
+				parts = append(parts, file)
+			}
+		}
+	}
+
+	// 投影片按序號排列
+	sort.Slice(parts, func(i, j int) bool {
+		if len(parts[i].Name) != len(parts[j].Name) {
+			return len(parts[i].Name) < len(parts[j].Name)
+		}
+		return parts[i].Name < parts[j].Name
+	})
+
+	var text strings.Builder
+	for _, part := range parts {
+		if text.Len() >= maxTextLength {
+			break
+		}
+
+		file, err := part.Open()
+		if err != nil {
+			return "", err
+		}
+		err = extractXMLText(file, &text)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return text.String(), nil
+}
+
+// extractXMLText 提取 XML 中 <t> 元素的文字，段落結束時換行
+func extractXMLText(r io.Reader, text *strings.Builder) error {
+	decoder := xml.NewDecoder(io.LimitReader(r, maxTextLength*4))
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteString("\t")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p", "si":
+				text.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package fulltext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrInvalidPDF 無效的 PDF 文件
+var ErrInvalidPDF = errors.New("不是有效的 PDF 文件")
+
+const (
+	// maxStreamSize 解壓縮單個 PDF 串流的最大大小
+	maxStreamSize = 32 << 20
+	// maxDecodedSize 每個 PDF 文件解壓縮串流的總大小上限
+	maxDecodedSize = 64 << 20
+	// maxStreams 每個 PDF 文件最多處理的串流數
+	maxStreams = 4096
+)
+
+// extractPDF 提取 PDF 文件中的文字。僅處理未壓縮或以 FlateDecode 壓縮的串流，
+// 不支援加密文件；字型的 ToUnicode 對照表會合併後套用到所有文字
+func extractPDF(content []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(content, " \r\n\t"), []byte("%PDF")) {
+		return "", ErrInvalidPDF
+	}
+
+	streams := pdfStreams(content)
+
+	// 收集字碼到 Unicode 的對照
+	cmap := make(map[string]string)
+	for _, stream := range streams {
+		if bytes.Contains(stream, []byte("begincmap")) {
+			parseCMap(stream, cmap)
+		}
+	}
+
+	var text strings.Builder
+	for _, stream := range streams {
+		if text.Len() >= maxTextLength {
+			break
+		}
+		if bytes.Contains(stream, []byte("BT")) && !bytes.Contains(stream, []byte("begincmap")) {
+			extractPDFText(stream, cmap, &text)
+		}
+	}
+
+	return text.String(), nil
+}
+
+// pdfStreams 返回 PDF 中可解碼的非圖片串流內容，
+// 超過 maxStreams 個串流或解壓縮總大小達到 maxDecodedSize 後不再處理
+func pdfStreams(content []byte) [][]byte {
+	var (
+		streams [][]byte
+		pos     = 0
+		decoded = 0
+	)
+
+	for len(streams) < maxStreams {
+		idx := bytes.Index(content[pos:], []byte("stream"))
+		if idx < 0 {
+			break
+		}
+		idx += pos
+		pos = idx + len("stream")
+
+		// 略過 endstream
+		if idx >= 3 && string(content[idx-3:idx]) == "end" {
+			continue
+		}
+
+		// 串流內容從換行後開始
+		start := pos
+		if start < len(content) && content[start] == '\r' {
+			start++
+		}
+		if start < len(content) && content[start] == '\n' {
+			start++
+		}
+		end := bytes.Index(content[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		end += start
+		pos = end + len("endstream")
+
+		// 串流所屬的字典位於前一個 obj 關鍵字與 stream 之間
+		dictStart := bytes.LastIndex(content[:idx], []byte("obj"))
+		if dictStart < 0 {
+			dictStart = 0
+		}
+		dict := content[dictStart:idx]
+		if bytes.Contains(dict, []byte("/Image")) {
+			continue
+		}
+
+		data := bytes.TrimRight(content[start:end], "\r\n")
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			limit := maxDecodedSize - decoded
+			if limit <= 0 {
+				break
+			}
+			if limit > maxStreamSize {
+				limit = maxStreamSize
+			}
+
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+			// 部分文件的串流結尾有多餘資料，保留已解壓縮的部分
+			data, _ = ioutil.ReadAll(io.LimitReader(reader, int64(limit)))
+			reader.Close()
+			decoded += len(data)
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+
+		streams = append(streams, data)
+	}
+
+	return streams
+}
+
+var (
+	bfCharSection  = regexp.MustCompile(`(?s)beginbfchar(.*?)endbfchar`)
+	bfRangeSection = regexp.MustCompile(`(?s)beginbfrange(.*?)endbfrange`)
+	bfCharEntry    = regexp.MustCompile(`<([0-9A-Fa-f\s]+)>\s*<([0-9A-Fa-f\s]*)>`)
+	bfRangeEntry   = regexp.MustCompile(`<([0-9A-Fa-f\s]+)>\s*<([0-9A-Fa-f\s]+)>\s*(<[0-9A-Fa-f\s]*>|\[[^\]]*\])`)
+	hexString      = regexp.MustCompile(`<([0-9A-Fa-f\s]*)>`)
+)
+
+// parseCMap 解析 ToUnicode 對照表中的 bfchar 及 bfrange 定義
+func parseCMap(stream []byte, cmap map[string]string) {
+	for _, section := range bfCharSection.FindAllSubmatch(stream, -1) {
+		for _, entry := range bfCharEntry.FindAllSubmatch(section[1], -1) {
+			cmap[string(hexBytes(entry[1]))] = utf16BE(hexBytes(entry[2]))
+		}
+	}
+
+	for _, section := range bfRangeSection.FindAllSubmatch(stream, -1) {
+		for _, entry := range bfRangeEntry.FindAllSubmatch(section[1], -1) {
+			lo, hi := hexBytes(entry[1]), hexBytes(entry[2])
+			if len(lo) != len(hi) || len(lo) == 0 || len(lo) > 4 {
+				continue
+			}
+			from, to := bytesToUint(lo), bytesToUint(hi)
+			if to < from || to-from > 0xffff {
+				continue
+			}
+
+			// 目的為陣列時逐個對應
+			if entry[3][0] == '[' {
+				for i, dst := range hexString.FindAllSubmatch(entry[3], -1) {
+					if from+uint32(i) > to {
+						break
+					}
+					cmap[string(uintToBytes(from+uint32(i), len(lo)))] = utf16BE(hexBytes(dst[1]))
+				}
+				continue
+			}
+
+			// 目的為起始值時依序遞增最後一個字元
+			dst := hexBytes(entry[3][1 : len(entry[3])-1])
+			if len(dst) < 2 {
+				continue
+			}
+			for code := from; code <= to; code++ {
+				value := make([]byte, len(dst))
+				copy(value, dst)
+				last := uint32(value[len(value)-2])<<8 | uint32(value[len(value)-1])
+				last += code - from
+				value[len(value)-2], value[len(value)-1] = byte(last>>8), byte(last)
+				cmap[string(uintToBytes(code, len(lo)))] = utf16BE(value)
+			}
+		}
+	}
+}
+
+// extractPDFText 提取內容串流中文字物件的文字
+func extractPDFText(stream []byte, cmap map[string]string, text *strings.Builder) {
+	var (
+		operands []interface{}
+		inText   bool
+		i        = 0
+	)
+
+	for i < len(stream) && text.Len() < maxTextLength {
+		c := stream[i]
+		switch {
+		case isPDFSpace(c):
+			i++
+		case c == '%':
+			for i < len(stream) && stream[i] != '\n' && stream[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			var str []byte
+			str, i = pdfLiteral(stream, i)
+			operands = append(operands, str)
+		case c == '<' && i+1 < len(stream) && stream[i+1] == '<':
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(stream[i:], '>')
+			if end < 0 {
+				return
+			}
+			operands = append(operands, hexBytes(stream[i+1:i+end]))
+			i += end + 1
+		case c == '>' || c == '[' || c == ']' || c == '{' || c == '}':
+			i++
+		case c == '/':
+			i++
+			for i < len(stream) && !isPDFSpace(stream[i]) && !isPDFDelimiter(stream[i]) {
+				i++
+			}
+		default:
+			start := i
+			for i < len(stream) && !isPDFSpace(stream[i]) && !isPDFDelimiter(stream[i]) {
+				i++
+			}
+			if i == start {
+				i++
+				continue
+			}
+
+			word := string(stream[start:i])
+			if num, err := strconv.ParseFloat(word, 64); err == nil {
+				operands = append(operands, num)
+				continue
+			}
+
+			// 遇到運算子
+			switch word {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				text.WriteString("\n")
+			case "Tj", "TJ", "'", "\"":
+				if !inText {
+					break
+				}
+				if word == "'" || word == "\"" {
+					text.WriteString("\n")
+				}
+				for _, operand := range operands {
+					switch v := operand.(type) {
+					case []byte:
+						text.WriteString(decodePDFString(v, cmap))
+					case float64:
+						// TJ 中較大的字距調整通常代表單詞間隔
+						if word == "TJ" && v < -200 {
+							text.WriteString(" ")
+						}
+					}
+				}
+			case "T*":
+				text.WriteString("\n")
+			case "Td", "TD":
+				if len(operands) == 2 {
+					if ty, ok := operands[1].(float64); ok && ty != 0 {
+						text.WriteString("\n")
+						break
+					}
+				}
+				text.WriteString(" ")
+			}
+			operands = operands[:0]
+		}
+	}
+}
+
+// pdfLiteral 解析從 start 開始的字面字串，返回內容及結束後的位置
+func pdfLiteral(stream []byte, start int) ([]byte, int) {
+	var (
+		str   []byte
+		depth = 0
+		i     = start + 1
+	)
+
+	for i < len(stream) {
+		c := stream[i]
+		switch {
+		case c == '\\' && i+1 < len(stream):
+			i++
+			switch e := stream[i]; e {
+			case 'n':
+				str = append(str, '\n')
+			case 'r':
+				str = append(str, '\r')
+			case 't':
+				str = append(str, '\t')
+			case 'b':
+				str = append(str, '\b')
+			case 'f':
+				str = append(str, '\f')
+			case '\r', '\n':
+				// 換行接續
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					j := 0
+					for ; j < 3 && i+j < len(stream) && stream[i+j] >= '0' && stream[i+j] <= '7'; j++ {
+						n = n*8 + int(stream[i+j]-'0')
+					}
+					str = append(str, byte(n))
+					i += j
+					continue
+				}
+				str = append(str, e)
+			}
+		case c == '(':
+			depth++
+			str = append(str, c)
+		case c == ')':
+			if depth == 0 {
+				return str, i + 1
+			}
+			depth--
+			str = append(str, c)
+		default:
+			str = append(str, c)
+		}
+		i++
+	}
+
+	return str, i
+}
+
+// decodePDFString 將 PDF 字串解碼為 Unicode 文字
+func decodePDFString(str []byte, cmap map[string]string) string {
+	// 以 BOM 開頭的 UTF-16 字串
+	if len(str) >= 2 && str[0] == 0xfe && str[1] == 0xff {
+		return utf16BE(str[2:])
+	}
+
+	// 嘗試以兩位元組或單位元組字碼套用對照表
+	if len(cmap) > 0 {
+		for _, size := range []int{2, 1} {
+			if len(str)%size != 0 {
+				continue
+			}
+
+			var (
+				res     strings.Builder
+				matched = true
+			)
+			for i := 0; i < len(str); i += size {
+				value, ok := cmap[string(str[i:i+size])]
+				if !ok {
+					matched = false
+					break
+				}
+				res.WriteString(value)
+			}
+			if matched {
+				return res.String()
+			}
+		}
+	}
+
+	// 視為 PDFDocEncoding，略過控制字元
+	res := make([]rune, 0, len(str))
+	for _, c := range str {
+		if c >= 0x20 || c == '\n' || c == '\t' {
+			res = append(res, rune(c))
+		}
+	}
+	return string(res)
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return c == '(' || c == ')' || c == '<' || c == '>' || c == '[' || c == ']' ||
+		c == '{' || c == '}' || c == '/' || c == '%'
+}
+
+// hexBytes 解碼十六進位字串，忽略空白，奇數長度時補 0
+func hexBytes(str []byte) []byte {
+	clean := make([]byte, 0, len(str)+1)
+	for _, c := range str {
+		if !isPDFSpace(c) {
+			clean = append(clean, c)
+		}
+	}
+	if len(clean)%2 == 1 {
+		clean = append(clean, '0')
+	}
+
+	res, err := hex.DecodeString(string(clean))
+	if err != nil {
+		return nil
+	}
+	return res
+}
+
+// utf16BE 解碼 UTF-16BE 位元組
+func utf16BE(str []byte) string {
+	units := make([]uint16, 0, len(str)/2)
+	for i := 0; i+1 < len(str); i += 2 {
+		units = append(units, uint16(str[i])<<8|uint16(str[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+func bytesToUint(b []byte) uint32 {
+	var res uint32
+	for _, c := range b {
+		res = res<<8 | uint32(c)
+	}
+	return res
+}
+
+func uintToBytes(v uint32, size int) []byte {
+	res := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		res[i] = byte(v)
+		v >>= 8
+	}
+	return res
+}
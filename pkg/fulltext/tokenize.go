@@ -0,0 +1,100 @@
+package fulltext
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxTermLength 單個詞的最大長度，過長的詞不會被索引
+const maxTermLength = 64
+
+// isCJK 判斷字元是否屬於沒有空格分詞的中日韓文字
+func isCJK(c rune) bool {
+	return unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// Tokenize 將文字切分為索引詞。拉丁字母等以非字母數字字元分隔並轉為小寫，
+// 中日韓文字沒有分隔符號，以單字及相鄰兩字組成的詞索引
+func Tokenize(text string) []string {
+	var (
+		terms []string
+		word  strings.Builder
+		prev  rune
+	)
+
+	flush := func() {
+		if word.Len() > 0 && word.Len() <= maxTermLength {
+			terms = append(terms, word.String())
+		}
+		word.Reset()
+	}
+
+	for _, c := range text {
+		switch {
+		case isCJK(c):
+			flush()
+			terms = append(terms, string(c))
+			if prev != 0 {
+				terms = append(terms, string([]rune{prev, c}))
+			}
+			prev = c
+			continue
+		case unicode.IsLetter(c) || unicode.IsDigit(c):
+			word.WriteRune(unicode.ToLower(c))
+		default:
+			flush()
+		}
+		prev = 0
+	}
+	flush()
+
+	return terms
+}
+
+// queryTerms 將搜尋文字切分為需要全部匹配的索引詞。
+// 連續的中日韓文字只需匹配相鄰兩字組成的詞，單個字時匹配單字
+func queryTerms(text string) []string {
+	var (
+		terms []string
+		run   []rune
+	)
+
+	flushRun := func() {
+		switch len(run) {
+		case 0:
+		case 1:
+			terms = append(terms, string(run))
+		default:
+			for i := 1; i < len(run); i++ {
+				terms = append(terms, string(run[i-1:i+1]))
+			}
+		}
+		run = run[:0]
+	}
+
+	var word strings.Builder
+	flushWord := func() {
+		if word.Len() > 0 {
+			terms = append(terms, word.String())
+		}
+		word.Reset()
+	}
+
+	for _, c := range text {
+		switch {
+		case isCJK(c):
+			flushWord()
+			run = append(run, c)
+		case unicode.IsLetter(c) || unicode.IsDigit(c):
+			flushRun()
+			word.WriteRune(unicode.ToLower(c))
+		default:
+			flushRun()
+			flushWord()
+		}
+	}
+	flushRun()
+	flushWord()
+
+	return terms
+}
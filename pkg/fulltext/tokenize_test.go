@@ -0,0 +1,24 @@
+package fulltext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal([]string{"hello", "world", "42"}, Tokenize("Hello, WORLD! 42"))
+	asserts.Equal([]string{"go", "雲", "端", "雲端", "v3"}, Tokenize("Go雲端v3"))
+	asserts.Equal([]string{"a", "b"}, Tokenize("a "+strings.Repeat("x", maxTermLength+1)+" b"))
+	asserts.Empty(Tokenize(" ,.- "))
+}
+
+func TestQueryTerms(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal([]string{"hello", "world"}, queryTerms("Hello world"))
+	asserts.Equal([]string{"雲端", "端硬", "硬碟"}, queryTerms("雲端硬碟"))
+	asserts.Equal([]string{"雲", "go"}, queryTerms("雲 Go"))
+	asserts.Empty(queryTerms("!!"))
+}
@@ -9,12 +9,14 @@ import (
 
 // ID類型
 const (
-	ShareID  = iota // 分享
-	UserID          // 使用者
-	FileID          // 文件ID
-	FolderID        // 目錄ID
-	TagID           // 標籤ID
-	PolicyID        // 儲存策略ID
+	ShareID   = iota // 分享
+	UserID           // 使用者
+	FileID           // 文件ID
+	FolderID         // 目錄ID
+	TagID            // 標籤ID
+	PolicyID         // 儲存策略ID
+	RecycleID        // 回收站項目ID
+	VersionID        // 歷史版本ID
 )
 
 var (
@@ -0,0 +1,200 @@
+package search
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrTagRecursion 文件分類標籤互相引用
+	ErrTagRecursion = errors.New("文件分類標籤存在循環引用")
+)
+
+// Resolver 解析查詢中依賴使用者資料的條件
+type Resolver interface {
+	// Folders 返回給定路徑的目錄及其所有子目錄的ID
+	Folders(path string) ([]uint, error)
+	// Tag 返回給定名稱的文件分類標籤表達式，以及表達式是否為查詢語法
+	Tag(name string) (string, bool, error)
+	// Policy 返回給定ID或名稱的儲存策略ID
+	Policy(name string) (uint, error)
+	// Content 返回內容包含給定文字的文件ID
+	Content(text string) ([]uint, error)
+}
+
+// maxTagDepth 標籤查詢中引用其他標籤的最大層數
+const maxTagDepth = 8
+
+// Compile 將查詢語法樹轉換為 files 表上的 SQL 條件及其參數
+func Compile(node *Node, resolver Resolver) (string, []interface{}, error) {
+	c := &compiler{resolver: resolver}
+	return c.compile(node)
+}
+
+type compiler struct {
+	resolver Resolver
+	tags     []string
+}
+
+func (c *compiler) compile(node *Node) (string, []interface{}, error) {
+	switch node.Op {
+	case OpAnd, OpOr:
+		sep := " and "
+		if node.Op == OpOr {
+			sep = " or "
+		}
+
+		var (
+			conditions = make([]string, 0, len(node.Children))
+			args       []interface{}
+		)
+		for _, child := range node.Children {
+			condition, childArgs, err := c.compile(child)
+			if err != nil {
+				return "", nil, err
+			}
+			conditions = append(conditions, condition)
+			args = append(args, childArgs...)
+		}
+
+		if len(conditions) == 0 {
+			return "1 = 0", nil, nil
+		}
+		return "(" + strings.Join(conditions, sep) + ")", args, nil
+	case OpNot:
+		condition, args, err := c.compile(node.Children[0])
+		if err != nil {
+			return "", nil, err
+		}
+		return "not (" + condition + ")", args, nil
+	default:
+		return c.term(node)
+	}
+}
+
+// term 轉換單個查詢條件
+func (c *compiler) term(node *Node) (string, []interface{}, error) {
+	switch node.Field {
+	case fieldPattern:
+		return "name like ?", []interface{}{node.Value}, nil
+	case FieldName:
+		return "name like ?", []interface{}{wildcard(node.Value)}, nil
+	case FieldExt:
+		return c.compile(extensions(strings.Split(node.Value, ",")))
+	case FieldType:
+		return c.compile(extensions(TypeExtensions[node.Value]))
+	case FieldSize:
+		return rangeCondition("size", node)
+	case FieldModified:
+		return rangeCondition("updated_at", node)
+	case FieldCreated:
+		return rangeCondition("created_at", node)
+	case FieldIn:
+		folders, err := c.resolver.Folders(node.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(folders) == 0 {
+			return "1 = 0", nil, nil
+		}
+		return "folder_id in (?)", []interface{}{folders}, nil
+	case FieldPolicy:
+		policy, err := c.resolver.Policy(node.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		return "policy_id = ?", []interface{}{policy}, nil
+	case FieldTag:
+		return c.tag(node.Value)
+	case FieldContent:
+		files, err := c.resolver.Content(node.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(files) == 0 {
+			return "1 = 0", nil, nil
+		}
+		return "id in (?)", []interface{}{files}, nil
+	}
+
+	return "", nil, &SyntaxError{Msg: "未知的查詢欄位 " + node.Field}
+}
+
+// tag 將文件分類標籤展開為其表達式對應的條件
+func (c *compiler) tag(name string) (string, []interface{}, error) {
+	for _, tag := range c.tags {
+		if tag == name {
+			return "", nil, ErrTagRecursion
+		}
+	}
+	if len(c.tags) >= maxTagDepth {
+		return "", nil, ErrTagRecursion
+	}
+
+	expression, isQuery, err := c.resolver.Tag(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 舊版標籤的表達式為逐行的檔案名匹配規則
+	if !isQuery {
+		return c.compile(Patterns(strings.Split(expression, "\n")...))
+	}
+
+	node, err := Parse(expression)
+	if err != nil {
+		return "", nil, err
+	}
+
+	c.tags = append(c.tags, name)
+	defer func() {
+		c.tags = c.tags[:len(c.tags)-1]
+	}()
+	return c.compile(node)
+}
+
+// extensions 建立匹配任一副檔名的查詢
+func extensions(exts []string) *Node {
+	patterns := make([]string, 0, len(exts))
+	for _, ext := range exts {
+		if ext = strings.TrimPrefix(strings.TrimSpace(ext), "."); ext != "" {
+			patterns = append(patterns, "%."+ext)
+		}
+	}
+	return Patterns(patterns...)
+}
+
+// wildcard 將萬用字元轉換為 SQL LIKE 表達式，不含萬用字元時為包含匹配
+func wildcard(value string) string {
+	if !strings.ContainsAny(value, "*?") {
+		return "%" + value + "%"
+	}
+	return strings.NewReplacer("*", "%", "?", "_").Replace(value)
+}
+
+// rangeCondition 轉換範圍條件
+func rangeCondition(column string, node *Node) (string, []interface{}, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if node.Lower != nil {
+		op := " > ?"
+		if node.Lower.Inclusive {
+			op = " >= ?"
+		}
+		conditions = append(conditions, column+op)
+		args = append(args, node.Lower.Value)
+	}
+	if node.Upper != nil {
+		op := " < ?"
+		if node.Upper.Inclusive {
+			op = " <= ?"
+		}
+		conditions = append(conditions, column+op)
+		args = append(args, node.Upper.Value)
+	}
+
+	return "(" + strings.Join(conditions, " and ") + ")", args, nil
+}
@@ -0,0 +1,122 @@
+package search
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type resolverMock struct {
+	tags map[string]string
+}
+
+func (resolver *resolverMock) Folders(path string) ([]uint, error) {
+	switch path {
+	case "/docs":
+		return []uint{2, 3}, nil
+	case "/empty":
+		return []uint{}, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (resolver *resolverMock) Tag(name string) (string, bool, error) {
+	if name == "legacy" {
+		return "%.jpg\n%.png", false, nil
+	}
+	if exp, ok := resolver.tags[name]; ok {
+		return exp, true, nil
+	}
+	return "", false, errors.New("not found")
+}
+
+func (resolver *resolverMock) Policy(name string) (uint, error) {
+	if name == "main" {
+		return 1, nil
+	}
+	return 0, errors.New("not found")
+}
+
+func (resolver *resolverMock) Content(text string) ([]uint, error) {
+	if text == "hello world" {
+		return []uint{5}, nil
+	}
+	return []uint{}, nil
+}
+
+func TestCompile(t *testing.T) {
+	asserts := assert.New(t)
+	resolver := &resolverMock{tags: map[string]string{
+		"work":  "in:/docs size:>1KB",
+		"loopA": "tag:loopB",
+		"loopB": "a OR tag:loopA",
+	}}
+
+	testCases := []struct {
+		query     string
+		condition string
+		args      []interface{}
+	}{
+		{"report", "name like ?", []interface{}{"%report%"}},
+		{"name:*.tar.?z", "name like ?", []interface{}{"%.tar._z"}},
+		{"ext:.pdf,docx", "(name like ? or name like ?)", []interface{}{"%.pdf", "%.docx"}},
+		{"type:audio", "(name like ? or name like ? or name like ? or name like ? or name like ? or name like ? or name like ? or name like ?)",
+			[]interface{}{"%.mp3", "%.flac", "%.ape", "%.wav", "%.acc", "%.ogg", "%.midi", "%.mid"}},
+		{"size:10..20", "(size >= ? and size <= ?)", []interface{}{uint64(10), uint64(20)}},
+		{"a -b", "(name like ? and not (name like ?))", []interface{}{"%a%", "%b%"}},
+		{"a OR (b c)", "(name like ? or (name like ? and name like ?))", []interface{}{"%a%", "%b%", "%c%"}},
+		{"in:/docs", "folder_id in (?)", []interface{}{[]uint{2, 3}}},
+		{"in:/empty", "1 = 0", nil},
+		{"policy:main", "policy_id = ?", []interface{}{uint(1)}},
+		{`content:"hello world"`, "id in (?)", []interface{}{[]uint{5}}},
+		{"content:nothing", "1 = 0", nil},
+		{"tag:legacy", "(name like ? or name like ?)", []interface{}{"%.jpg", "%.png"}},
+		{"tag:work", "(folder_id in (?) and (size > ?))", []interface{}{[]uint{2, 3}, uint64(1024)}},
+	}
+
+	for _, testCase := range testCases {
+		node, err := Parse(testCase.query)
+		asserts.NoError(err, testCase.query)
+		condition, args, err := Compile(node, resolver)
+		asserts.NoError(err, testCase.query)
+		asserts.Equal(testCase.condition, condition, testCase.query)
+		asserts.Equal(testCase.args, args, testCase.query)
+	}
+
+	// 修改日期與建立日期
+	{
+		node, _ := Parse("modified:>=2021-01-01 created:<2021-01-01")
+		condition, args, err := Compile(node, resolver)
+		asserts.NoError(err)
+		asserts.Equal("((updated_at >= ?) and (created_at < ?))", condition)
+		asserts.Len(args, 2)
+	}
+
+	// 解析失敗
+	for _, query := range []string{"in:/missing", "policy:other", "tag:missing", "tag:loopA"} {
+		node, err := Parse(query)
+		asserts.NoError(err)
+		_, _, err = Compile(node, resolver)
+		asserts.Error(err, query)
+	}
+
+	// 標籤循環引用
+	{
+		node, _ := Parse("tag:loopA")
+		_, _, err := Compile(node, resolver)
+		asserts.Equal(ErrTagRecursion, err)
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	asserts := assert.New(t)
+	condition, args, err := Compile(Patterns("%.jpg", "%.png"), nil)
+	asserts.NoError(err)
+	asserts.Equal("(name like ? or name like ?)", condition)
+	asserts.Equal([]interface{}{"%.jpg", "%.png"}, args)
+
+	condition, _, err = Compile(Patterns(), nil)
+	asserts.NoError(err)
+	asserts.Equal("1 = 0", condition)
+}
@@ -0,0 +1,503 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Op 查詢節點類型
+type Op int
+
+const (
+	// OpTerm 單個查詢條件
+	OpTerm Op = iota
+	// OpAnd 所有子條件均需滿足
+	OpAnd
+	// OpOr 任一子條件滿足即可
+	OpOr
+	// OpNot 子條件不滿足
+	OpNot
+)
+
+// 可用的查詢欄位
+const (
+	// FieldName 檔案名，支援 * 和 ? 萬用字元，不含萬用字元時為包含匹配
+	FieldName = "name"
+	// FieldExt 副檔名，多個副檔名以逗號分隔
+	FieldExt = "ext"
+	// FieldType 文件類型，如 image、video、audio、doc
+	FieldType = "type"
+	// FieldSize 檔案大小
+	FieldSize = "size"
+	// FieldModified 修改日期
+	FieldModified = "modified"
+	// FieldCreated 建立日期
+	FieldCreated = "created"
+	// FieldIn 限定在給定目錄及其子目錄中
+	FieldIn = "in"
+	// FieldTag 匹配文件分類標籤
+	FieldTag = "tag"
+	// FieldPolicy 儲存策略ID或名稱
+	FieldPolicy = "policy"
+	// FieldContent 文件內容，多個詞需全部出現
+	FieldContent = "content"
+	// fieldPattern 原始的 SQL LIKE 匹配表達式，僅供內部使用
+	fieldPattern = "pattern"
+)
+
+// TypeExtensions 各文件類型包含的副檔名
+var TypeExtensions = map[string][]string{
+	"image": {"bmp", "iff", "png", "gif", "jpg", "jpeg", "psd", "svg", "webp"},
+	"video": {"mp4", "flv", "avi", "wmv", "mkv", "rm", "rmvb", "mov", "ogv"},
+	"audio": {"mp3", "flac", "ape", "wav", "acc", "ogg", "midi", "mid"},
+	"doc":   {"txt", "md", "pdf", "doc", "docx", "ppt", "pptx", "xls", "xlsx", "pub"},
+}
+
+// Node 查詢語法樹節點
+type Node struct {
+	Op       Op
+	Children []*Node
+
+	// 以下僅用於 OpTerm
+	Field string
+	Value string
+	Lower *Bound // 範圍下界，nil 表示不限
+	Upper *Bound // 範圍上界，nil 表示不限
+}
+
+// Bound 範圍條件的邊界
+type Bound struct {
+	Value     interface{}
+	Inclusive bool
+}
+
+// SyntaxError 查詢語法錯誤
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("查詢語法錯誤（第 %d 個字元）：%s", err.Pos+1, err.Msg)
+}
+
+// Patterns 建立匹配任一給定 SQL LIKE 表達式的查詢
+func Patterns(patterns ...string) *Node {
+	node := &Node{Op: OpOr}
+	for _, pattern := range patterns {
+		node.Children = append(node.Children, &Node{Op: OpTerm, Field: fieldPattern, Value: pattern})
+	}
+	return node
+}
+
+// Parse 解析查詢語句。
+//
+// 查詢由空格分隔的條件組成，條件之間預設為 AND 關係，可使用 OR、NOT
+// （或前綴 -）以及括號組合。條件的形式為 欄位:值，省略欄位時匹配檔案名，
+// 含空格的值使用雙引號包圍。範圍條件支援 >、>=、<、<= 及 a..b 形式，例如：
+//
+//	report ext:pdf,docx size:>10MB modified:2021-01-01..2021-06-30
+//	in:"/My Documents" (tag:Work OR type:image) -name:*.tmp created:<7d
+//
+// 日期可使用 7d、24h、2w 等距今時長，比較的是距今時間的長短，
+// 如 created:<7d 表示 7 天內建立，單獨的 modified:30d 同樣表示 30 天內修改
+func Parse(query string) (*Node, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, &SyntaxError{Pos: 0, Msg: "查詢語句為空"}
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: "多餘的右括號"}
+	}
+
+	return node, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type token struct {
+	kind  tokenKind
+	pos   int
+	field string
+	value string
+}
+
+// lex 將查詢語句切分為記號
+func lex(query string) ([]token, error) {
+	var (
+		tokens []token
+		runes  = []rune(query)
+		i      = 0
+	)
+
+	for i < len(runes) {
+		switch c := runes[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, pos: i})
+			i++
+		case c == '-' && i+1 < len(runes) && !isDelimiter(runes[i+1]):
+			tokens = append(tokens, token{kind: tokenNot, pos: i})
+			i++
+		default:
+			start := i
+			var (
+				word   strings.Builder
+				quoted bool
+				field  string
+			)
+			for i < len(runes) && !isDelimiter(runes[i]) {
+				switch {
+				case runes[i] == '"':
+					quoted = true
+					i++
+					for i < len(runes) && runes[i] != '"' {
+						if runes[i] == '\\' && i+1 < len(runes) {
+							i++
+						}
+						word.WriteRune(runes[i])
+						i++
+					}
+					if i >= len(runes) {
+						return nil, &SyntaxError{Pos: start, Msg: "引號未閉合"}
+					}
+					i++
+				case runes[i] == ':' && field == "" && !quoted:
+					field = strings.ToLower(word.String())
+					word.Reset()
+					i++
+				default:
+					word.WriteRune(runes[i])
+					i++
+				}
+			}
+
+			tok := token{kind: tokenWord, pos: start, field: field, value: word.String()}
+			if field == "" && !quoted {
+				switch tok.value {
+				case "AND":
+					tok.kind = tokenAnd
+				case "OR", "|":
+					tok.kind = tokenOr
+				case "NOT":
+					tok.kind = tokenNot
+				}
+			}
+			tokens = append(tokens, tok)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+func isDelimiter(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// parseOr or := and ("OR" and)*
+func (p *parser) parseOr() (*Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &Node{Op: OpOr, Children: []*Node{left}}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, right)
+	}
+
+	if len(node.Children) == 1 {
+		return left, nil
+	}
+	return node, nil
+}
+
+// parseAnd and := unary (["AND"] unary)*
+func (p *parser) parseAnd() (*Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &Node{Op: OpAnd, Children: []*Node{left}}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenWord, tokenNot, tokenLParen:
+		default:
+			if len(node.Children) == 1 {
+				return left, nil
+			}
+			return node, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, right)
+	}
+}
+
+// parseUnary unary := ("NOT" | "-") unary | "(" or ")" | term
+func (p *parser) parseUnary() (*Node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNot:
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Op: OpNot, Children: []*Node{child}}, nil
+	case tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRParen {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: "括號未閉合"}
+		}
+		return node, nil
+	case tokenWord:
+		return parseTerm(tok)
+	case tokenEOF:
+		return nil, &SyntaxError{Pos: tok.pos, Msg: "查詢語句不完整"}
+	default:
+		return nil, &SyntaxError{Pos: tok.pos, Msg: "此處需要查詢條件"}
+	}
+}
+
+// parseTerm 解析並驗證單個查詢條件
+func parseTerm(tok token) (*Node, error) {
+	node := &Node{Op: OpTerm, Field: tok.field, Value: tok.value}
+	if node.Field == "" {
+		node.Field = FieldName
+	}
+
+	if node.Value == "" {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("條件 %s 的值為空", node.Field)}
+	}
+
+	var err error
+	switch node.Field {
+	case FieldName, FieldExt, FieldIn, FieldTag, FieldPolicy, FieldContent:
+	case FieldType:
+		if _, ok := TypeExtensions[node.Value]; !ok {
+			err = fmt.Errorf("未知的文件類型 %s", node.Value)
+		}
+	case FieldSize:
+		node.Lower, node.Upper, err = parseRange(node.Value, parseSize)
+	case FieldModified, FieldCreated:
+		node.Lower, node.Upper, err = parseRange(node.Value, parseTime)
+	default:
+		err = fmt.Errorf("未知的查詢欄位 %s", node.Field)
+	}
+
+	if err != nil {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: err.Error()}
+	}
+	return node, nil
+}
+
+// 範圍條件中值的類型
+type valueKind int
+
+const (
+	// kindExact 精確值
+	kindExact valueKind = iota
+	// kindDay 僅精確到日的日期，表示當天範圍
+	kindDay
+	// kindAge 相對於目前時間的時長，比較的是距今的時間長短
+	kindAge
+)
+
+// parseRange 解析範圍條件
+func parseRange(value string, parse func(string) (interface{}, valueKind, error)) (*Bound, *Bound, error) {
+	var (
+		lower, upper *Bound
+		kind         valueKind
+		err          error
+	)
+
+	single := func(raw string) interface{} {
+		var v interface{}
+		v, kind, err = parse(raw)
+		return v
+	}
+
+	switch {
+	case strings.HasPrefix(value, ">="):
+		lower = &Bound{Value: single(value[2:]), Inclusive: true}
+	case strings.HasPrefix(value, "<="):
+		upper = &Bound{Value: single(value[2:]), Inclusive: true}
+	case strings.HasPrefix(value, ">"):
+		lower = &Bound{Value: single(value[1:])}
+	case strings.HasPrefix(value, "<"):
+		upper = &Bound{Value: single(value[1:])}
+	case strings.Contains(value, ".."):
+		parts := strings.SplitN(value, "..", 2)
+		if parts[0] == "" && parts[1] == "" {
+			return nil, nil, fmt.Errorf("無效的範圍 %s", value)
+		}
+
+		lowerKind := valueKind(-1)
+		if parts[0] != "" {
+			lower = &Bound{Value: single(parts[0]), Inclusive: true}
+			if err != nil {
+				return nil, nil, err
+			}
+			lowerKind = kind
+		}
+		if parts[1] != "" {
+			upper = &Bound{Value: single(parts[1]), Inclusive: true}
+			if err == nil && lowerKind >= 0 && (lowerKind == kindAge) != (kind == kindAge) {
+				return nil, nil, fmt.Errorf("範圍 %s 兩端的格式不一致", value)
+			}
+		}
+	default:
+		// 單獨的距今時長表示在此時長以內
+		v := single(value)
+		upper = &Bound{Value: v, Inclusive: true}
+		if kind != kindAge {
+			lower = &Bound{Value: v, Inclusive: true}
+		}
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch kind {
+	case kindDay:
+		// 日期的上界延伸至當天結束，下界為不含當天時從次日開始
+		if upper != nil && upper.Value != nil {
+			if day, ok := upper.Value.(time.Time); ok && isDay(day) {
+				if upper.Inclusive {
+					upper = &Bound{Value: day.AddDate(0, 0, 1)}
+				}
+			}
+		}
+		if lower != nil && !lower.Inclusive {
+			lower = &Bound{Value: lower.Value.(time.Time).AddDate(0, 0, 1), Inclusive: true}
+		}
+	case kindAge:
+		// 距今越久，時間越早
+		lower, upper = upper, lower
+	}
+
+	return lower, upper, nil
+}
+
+// isDay 判斷時間是否為某天的零點
+func isDay(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+var sizeUnits = map[string]float64{
+	"":   1,
+	"b":  1,
+	"k":  1 << 10,
+	"kb": 1 << 10,
+	"m":  1 << 20,
+	"mb": 1 << 20,
+	"g":  1 << 30,
+	"gb": 1 << 30,
+	"t":  1 << 40,
+	"tb": 1 << 40,
+}
+
+// parseSize 解析檔案大小，如 100、1.5MB、2G
+func parseSize(value string) (interface{}, valueKind, error) {
+	value = strings.ToLower(value)
+	i := strings.IndexFunc(value, func(c rune) bool {
+		return (c < '0' || c > '9') && c != '.'
+	})
+	if i < 0 {
+		i = len(value)
+	}
+
+	unit, ok := sizeUnits[value[i:]]
+	num, err := strconv.ParseFloat(value[:i], 64)
+	if !ok || err != nil || num < 0 || num*unit > math.MaxInt64 {
+		return nil, kindExact, fmt.Errorf("無效的檔案大小 %s", value)
+	}
+
+	return uint64(num * unit), kindExact, nil
+}
+
+var relativeUnits = map[byte]time.Duration{
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// parseTime 解析日期，支援 2006-01-02、2006-01-02T15:04:05 以及
+// 7d、24h、2w 等距今時長的形式
+func parseTime(value string) (interface{}, valueKind, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, kindDay, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", value, time.Local); err == nil {
+		return t, kindExact, nil
+	}
+
+	if len(value) > 1 {
+		if unit, ok := relativeUnits[value[len(value)-1]]; ok {
+			if num, err := strconv.Atoi(value[:len(value)-1]); err == nil && num >= 0 {
+				return time.Now().Add(-time.Duration(num) * unit), kindAge, nil
+			}
+		}
+	}
+
+	return nil, kindExact, fmt.Errorf("無效的日期 %s", value)
+}
@@ -0,0 +1,163 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 單個關鍵字
+	{
+		node, err := Parse("report")
+		asserts.NoError(err)
+		asserts.Equal(&Node{Op: OpTerm, Field: FieldName, Value: "report"}, node)
+	}
+
+	// 預設 AND，OR 優先順序低於 AND
+	{
+		node, err := Parse(`a b OR ext:pdf AND -c`)
+		asserts.NoError(err)
+		asserts.Equal(OpOr, node.Op)
+		asserts.Len(node.Children, 2)
+		asserts.Equal(OpAnd, node.Children[0].Op)
+		asserts.Len(node.Children[0].Children, 2)
+		asserts.Equal(OpAnd, node.Children[1].Op)
+		asserts.Equal(FieldExt, node.Children[1].Children[0].Field)
+		asserts.Equal(OpNot, node.Children[1].Children[1].Op)
+		asserts.Equal("c", node.Children[1].Children[1].Children[0].Value)
+	}
+
+	// 括號與 NOT
+	{
+		node, err := Parse(`NOT (a OR b) c`)
+		asserts.NoError(err)
+		asserts.Equal(OpAnd, node.Op)
+		asserts.Equal(OpNot, node.Children[0].Op)
+		asserts.Equal(OpOr, node.Children[0].Children[0].Op)
+	}
+
+	// 引號
+	{
+		node, err := Parse(`in:"/My Documents" "OR" "say \"hi\""`)
+		asserts.NoError(err)
+		asserts.Equal(OpAnd, node.Op)
+		asserts.Equal(FieldIn, node.Children[0].Field)
+		asserts.Equal("/My Documents", node.Children[0].Value)
+		asserts.Equal("OR", node.Children[1].Value)
+		asserts.Equal(`say "hi"`, node.Children[2].Value)
+	}
+
+	// 欄位名稱不區分大小寫
+	{
+		node, err := Parse("Type:image")
+		asserts.NoError(err)
+		asserts.Equal(FieldType, node.Field)
+	}
+
+	// 語法錯誤
+	for _, query := range []string{
+		"",
+		"  ",
+		"(a b",
+		"a b)",
+		"a OR",
+		"NOT",
+		`"abc`,
+		"unknown:a",
+		"type:unknown",
+		"name:",
+		"size:abc",
+		"size:10XB",
+		"modified:2021-13-01",
+		"created:..",
+		"created:2021-01-01..7d",
+		"a OR OR b",
+	} {
+		_, err := Parse(query)
+		asserts.Error(err, query)
+		asserts.IsType(&SyntaxError{}, err, query)
+	}
+}
+
+func TestParse_Size(t *testing.T) {
+	asserts := assert.New(t)
+	testCases := []struct {
+		query        string
+		lower, upper *Bound
+	}{
+		{"size:100", &Bound{uint64(100), true}, &Bound{uint64(100), true}},
+		{"size:>1.5KB", &Bound{uint64(1536), false}, nil},
+		{"size:>=2m", &Bound{uint64(2 << 20), true}, nil},
+		{"size:<1G", nil, &Bound{uint64(1 << 30), false}},
+		{"size:<=1t", nil, &Bound{uint64(1 << 40), true}},
+		{"size:1MB..10MB", &Bound{uint64(1 << 20), true}, &Bound{uint64(10 << 20), true}},
+		{"size:..10", nil, &Bound{uint64(10), true}},
+	}
+
+	for _, testCase := range testCases {
+		node, err := Parse(testCase.query)
+		asserts.NoError(err, testCase.query)
+		asserts.Equal(testCase.lower, node.Lower, testCase.query)
+		asserts.Equal(testCase.upper, node.Upper, testCase.query)
+	}
+}
+
+func TestParse_Time(t *testing.T) {
+	asserts := assert.New(t)
+	day := func(d string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02", d, time.Local)
+		return t
+	}
+
+	// 日期
+	testCases := []struct {
+		query        string
+		lower, upper *Bound
+	}{
+		{"modified:2021-01-01", &Bound{day("2021-01-01"), true}, &Bound{day("2021-01-02"), false}},
+		{"modified:>2021-01-01", &Bound{day("2021-01-02"), true}, nil},
+		{"modified:>=2021-01-01", &Bound{day("2021-01-01"), true}, nil},
+		{"modified:<2021-01-01", nil, &Bound{day("2021-01-01"), false}},
+		{"modified:<=2021-01-01", nil, &Bound{day("2021-01-02"), false}},
+		{"created:2021-01-01..2021-01-31", &Bound{day("2021-01-01"), true}, &Bound{day("2021-02-01"), false}},
+		{
+			"created:2021-01-01T10:00:00..2021-01-01T12:00:00",
+			&Bound{day("2021-01-01").Add(10 * time.Hour), true},
+			&Bound{day("2021-01-01").Add(12 * time.Hour), true},
+		},
+	}
+	for _, testCase := range testCases {
+		node, err := Parse(testCase.query)
+		asserts.NoError(err, testCase.query)
+		asserts.Equal(testCase.lower, node.Lower, testCase.query)
+		asserts.Equal(testCase.upper, node.Upper, testCase.query)
+	}
+
+	// 距今時長
+	{
+		node, err := Parse("created:<7d")
+		asserts.NoError(err)
+		asserts.Nil(node.Upper)
+		asserts.WithinDuration(time.Now().AddDate(0, 0, -7), node.Lower.Value.(time.Time), time.Minute)
+
+		node, err = Parse("modified:>2w")
+		asserts.NoError(err)
+		asserts.Nil(node.Lower)
+		asserts.WithinDuration(time.Now().AddDate(0, 0, -14), node.Upper.Value.(time.Time), time.Minute)
+
+		node, err = Parse("modified:24h")
+		asserts.NoError(err)
+		asserts.Nil(node.Upper)
+		asserts.True(node.Lower.Inclusive)
+		asserts.WithinDuration(time.Now().Add(-24*time.Hour), node.Lower.Value.(time.Time), time.Minute)
+
+		node, err = Parse("modified:1d..7d")
+		asserts.NoError(err)
+		asserts.WithinDuration(time.Now().AddDate(0, 0, -7), node.Lower.Value.(time.Time), time.Minute)
+		asserts.WithinDuration(time.Now().AddDate(0, 0, -1), node.Upper.Value.(time.Time), time.Minute)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 func init() {
 	gob.Register(ObjectProps{})
+	gob.Register(ArchiveSession{})
 }
 
 // ObjectProps 文件、目錄物件的詳細屬性訊息
@@ -21,3 +22,13 @@ type ObjectProps struct {
 
 	QueryDate time.Time `json:"query_date"`
 }
+
+// ArchiveSession 串流打包下載工作階段，下載時才讀取並壓縮文件
+type ArchiveSession struct {
+	UID      uint
+	ParentID uint // 限制待打包物件的父目錄，0 為不限制
+	Dirs     []uint
+	Items    []uint
+	Format   string
+	Store    bool // 僅歸檔不壓縮
+}
@@ -0,0 +1,43 @@
+package serializer
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+)
+
+// RecycleResponse 回收站項目
+type RecycleResponse struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	Size       uint64    `json:"size"`
+	OriginPath string    `json:"origin_path"`
+	DeletedAt  time.Time `json:"deleted_at"`
+	ExpireAt   time.Time `json:"expire_at"`
+}
+
+// BuildRecycleListResponse 構建回收站列表，days 為回收站保留天數
+func BuildRecycleListResponse(recycles []model.Recycle, days int) Response {
+	res := make([]RecycleResponse, 0, len(recycles))
+	for i := 0; i < len(recycles); i++ {
+		item := RecycleResponse{
+			ID:         hashid.HashID(recycles[i].ID, hashid.RecycleID),
+			Name:       recycles[i].Name,
+			Type:       "file",
+			Size:       recycles[i].Size,
+			OriginPath: recycles[i].OriginPath,
+			DeletedAt:  recycles[i].CreatedAt,
+			ExpireAt:   recycles[i].ExpireAt(days),
+		}
+		if recycles[i].IsDir {
+			item.Type = "dir"
+		}
+		res = append(res, item)
+	}
+
+	return Response{
+		Data: res,
+	}
+}
@@ -18,13 +18,14 @@ type UploadPolicy struct {
 
 // UploadCredential 返回給用戶端的上傳憑證
 type UploadCredential struct {
-	Token     string `json:"token"`
-	Policy    string `json:"policy"`
-	Path      string `json:"path"` // 儲存路徑
-	AccessKey string `json:"ak"`
-	KeyTime   string `json:"key_time,omitempty"` // COS用有效期
-	Callback  string `json:"callback,omitempty"` // 回調地址
-	Key       string `json:"key,omitempty"`      // 文件標識符，通常為回調key
+	Token      string `json:"token"`
+	Policy     string `json:"policy"`
+	Path       string `json:"path"` // 儲存路徑
+	AccessKey  string `json:"ak"`
+	KeyTime    string `json:"key_time,omitempty"`    // COS用有效期
+	Callback   string `json:"callback,omitempty"`    // 回調地址
+	Key        string `json:"key,omitempty"`         // 文件標識符，通常為回調key
+	PolicyType string `json:"policy_type,omitempty"` // 按上傳規則選擇的儲存策略類型
 }
 
 // UploadSession 上傳工作階段
@@ -36,6 +37,15 @@ type UploadSession struct {
 	Name        string
 	Size        uint64
 	SavePath    string
+	ChunkSize   uint64 // 分片大小，僅用於分片上傳
+}
+
+// ChunkUploadStatus 分片上傳工作階段狀態
+type ChunkUploadStatus struct {
+	SessionID  string `json:"session_id"`
+	ChunkSize  uint64 `json:"chunk_size"`
+	ChunkCount int    `json:"chunk_count"`
+	Uploaded   []int  `json:"uploaded"`
 }
 
 // UploadCallback 上傳回調正文
@@ -44,6 +54,7 @@ type UploadCallback struct {
 	SourceName string `json:"source_name"`
 	PicInfo    string `json:"pic_info"`
 	Size       uint64 `json:"size"`
+	Hash       string `json:"hash,omitempty"`
 }
 
 // GeneralUploadCallbackFailed 儲存策略上傳回調失敗響應
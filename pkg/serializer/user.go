@@ -99,10 +99,10 @@ func BuildUser(user model.User) User {
 		PreferredTheme: user.OptionsSerialized.PreferredTheme,
 		Anonymous:      user.IsAnonymous(),
 		Policy: policy{
-			SaveType:       user.Policy.Type,
+			SaveType:       user.Policy.ClientType(),
 			MaxSize:        fmt.Sprintf("%.2fmb", float64(user.Policy.MaxSize)/(1024*1024)),
 			AllowedType:    user.Policy.OptionsSerialized.FileType,
-			UploadURL:      user.Policy.GetUploadURL(),
+			UploadURL:      user.Policy.ClientUploadURL(),
 			AllowGetSource: user.Policy.IsOriginLinkEnable,
 		},
 		Group: group{
@@ -0,0 +1,31 @@
+package serializer
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+)
+
+// VersionResponse 文件歷史版本
+type VersionResponse struct {
+	ID        string    `json:"id"`
+	Size      uint64    `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BuildVersionListResponse 構建文件歷史版本列表
+func BuildVersionListResponse(versions []model.FileVersion) Response {
+	res := make([]VersionResponse, 0, len(versions))
+	for i := 0; i < len(versions); i++ {
+		res = append(res, VersionResponse{
+			ID:        hashid.HashID(versions[i].ID, hashid.VersionID),
+			Size:      versions[i].Size,
+			CreatedAt: versions[i].CreatedAt,
+		})
+	}
+
+	return Response{
+		Data: res,
+	}
+}
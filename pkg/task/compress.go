@@ -22,9 +22,10 @@ type CompressTask struct {
 
 // CompressProps 壓縮任務屬性
 type CompressProps struct {
-	Dirs  []uint `json:"dirs"`
-	Files []uint `json:"files"`
-	Dst   string `json:"dst"`
+	Dirs   []uint `json:"dirs"`
+	Files  []uint `json:"files"`
+	Dst    string `json:"dst"`
+	Format string `json:"format,omitempty"`
 }
 
 // Props 獲取任務屬性
@@ -93,9 +94,13 @@ func (job *CompressTask) Do() {
 	util.Log().Debug("開始壓縮文件")
 	job.TaskModel.SetProgress(CompressingProgress)
 
-	// 開始壓縮
+	// 開始壓縮，未指定格式時為zip
+	format := job.TaskProps.Format
+	if format == "" {
+		format = filesystem.ArchiveZip
+	}
 	ctx := context.Background()
-	zipFile, err := fs.Compress(ctx, job.TaskProps.Dirs, job.TaskProps.Files, false)
+	zipFile, err := fs.Compress(ctx, job.TaskProps.Dirs, job.TaskProps.Files, format, false)
 	if err != nil {
 		job.SetErrorMsg(err.Error())
 		return
@@ -116,13 +121,14 @@ func (job *CompressTask) Do() {
 }
 
 // NewCompressTask 建立壓縮任務
-func NewCompressTask(user *model.User, dst string, dirs, files []uint) (Job, error) {
+func NewCompressTask(user *model.User, dst, format string, dirs, files []uint) (Job, error) {
 	newTask := &CompressTask{
 		User: user,
 		TaskProps: CompressProps{
-			Dirs:  dirs,
-			Files: files,
-			Dst:   dst,
+			Dirs:   dirs,
+			Files:  files,
+			Dst:    dst,
+			Format: format,
 		},
 	}
 
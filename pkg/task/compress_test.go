@@ -156,7 +156,7 @@ func TestNewCompressTask(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
-		job, err := NewCompressTask(&model.User{}, "/", []uint{12}, []uint{})
+		job, err := NewCompressTask(&model.User{}, "/", "zip", []uint{12}, []uint{})
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NotNil(job)
 		asserts.NoError(err)
@@ -167,7 +167,7 @@ func TestNewCompressTask(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
 		mock.ExpectRollback()
-		job, err := NewCompressTask(&model.User{}, "/", []uint{12}, []uint{})
+		job, err := NewCompressTask(&model.User{}, "/", "zip", []uint{12}, []uint{})
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Nil(job)
 		asserts.Error(err)
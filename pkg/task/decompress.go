@@ -3,6 +3,7 @@ package task
 import (
 	"context"
 	"encoding/json"
+	"errors"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
@@ -21,8 +22,10 @@ type DecompressTask struct {
 
 // DecompressProps 壓縮任務屬性
 type DecompressProps struct {
-	Src string `json:"src"`
-	Dst string `json:"dst"`
+	Src      string   `json:"src"`
+	Dst      string   `json:"dst"`
+	Encoding string   `json:"encoding,omitempty"`
+	Entries  []string `json:"entries,omitempty"`
 }
 
 // Props 獲取任務屬性
@@ -87,21 +90,27 @@ func (job *DecompressTask) Do() {
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, fsctx.DisableOverwrite, true)
 
-	err = fs.Decompress(ctx, job.TaskProps.Src, job.TaskProps.Dst)
+	err = fs.Decompress(ctx, job.TaskProps.Src, job.TaskProps.Dst, job.TaskProps.Encoding, job.TaskProps.Entries)
 	if err != nil {
+		if errors.Is(err, filesystem.ErrUnsafeArchive) {
+			job.SetErrorMsg("壓縮文件超出安全限制，已中止解壓縮", err)
+			return
+		}
 		job.SetErrorMsg("解壓縮失敗", err)
 		return
 	}
 
 }
 
-// NewDecompressTask 建立壓縮任務
-func NewDecompressTask(user *model.User, src, dst string) (Job, error) {
+// NewDecompressTask 建立壓縮任務，entries 為要解壓縮的條目，為空時解壓縮所有條目
+func NewDecompressTask(user *model.User, src, dst, encoding string, entries []string) (Job, error) {
 	newTask := &DecompressTask{
 		User: user,
 		TaskProps: DecompressProps{
-			Src: src,
-			Dst: dst,
+			Src:      src,
+			Dst:      dst,
+			Encoding: encoding,
+			Entries:  entries,
 		},
 	}
 
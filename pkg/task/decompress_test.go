@@ -1,11 +1,22 @@
 package task
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 )
@@ -91,6 +102,128 @@ func TestDecompressTask_Do(t *testing.T) {
 	}
 }
 
+// archiveEntry 測試用的壓縮文件條目
+type archiveEntry struct {
+	name    string
+	content []byte
+}
+
+// buildZip 以給定條目建立 zip 壓縮文件
+func buildZip(t *testing.T, entries ...archiveEntry) []byte {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: entry.name, Method: zip.Deflate})
+		assert.NoError(t, err)
+		w.Write(entry.content)
+	}
+	assert.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+// buildTarGz 以給定條目建立 tar.gz 壓縮文件
+func buildTarGz(t *testing.T, entries ...archiveEntry) []byte {
+	var buf bytes.Buffer
+	compressor := gzip.NewWriter(&buf)
+	writer := tar.NewWriter(compressor)
+	for _, entry := range entries {
+		assert.NoError(t, writer.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.name,
+			Size:     int64(len(entry.content)),
+			Mode:     0644,
+		}))
+		writer.Write(entry.content)
+	}
+	assert.NoError(t, writer.Close())
+	assert.NoError(t, compressor.Close())
+	return buf.Bytes()
+}
+
+func TestDecompressTask_Do_MaliciousArchive(t *testing.T) {
+	asserts := assert.New(t)
+	dir := util.RelativePath("tests/malicious")
+	defer os.RemoveAll(dir)
+	asserts.NoError(os.MkdirAll(dir, 0744))
+
+	asserts.NoError(cache.Set("setting_temp_path", "tests", 0))
+	asserts.NoError(cache.Set("policy_1", model.Policy{Type: "local"}, 0))
+	defer cache.Deletes([]string{"1"}, "policy_")
+	defer cache.Deletes([]string{"decompress_max_size", "decompress_max_entries",
+		"decompress_max_depth", "decompress_max_ratio"}, "setting_")
+
+	manyEntries := make([]archiveEntry, 0, 20)
+	for i := 0; i < 20; i++ {
+		manyEntries = append(manyEntries, archiveEntry{name: fmt.Sprintf("%d.txt", i)})
+	}
+
+	testCases := []struct {
+		desc    string
+		name    string
+		content []byte
+		reason  string
+	}{
+		{"目錄穿越", "slip.zip", buildZip(t, archiveEntry{"ok.txt", []byte("ok")},
+			archiveEntry{"../../evil.txt", []byte("evil")}), "超出目標目錄"},
+		{"反斜線目錄穿越", "slip_win.zip", buildZip(t, archiveEntry{"a\\..\\..\\evil.txt", []byte("evil")}), "超出目標目錄"},
+		{"絕對路徑", "absolute.zip", buildZip(t, archiveEntry{"/etc/cron.d/evil", []byte("evil")}), "絕對路徑"},
+		{"tar 目錄穿越", "slip.tar.gz", buildTarGz(t, archiveEntry{"../evil.sh", []byte("evil")}), "超出目標目錄"},
+		{"條目過多", "many.zip", buildZip(t, manyEntries...), "條目數量"},
+		{"目錄過深", "deep.tar.gz", buildTarGz(t, archiveEntry{"a/b/c/d/e/f.txt", []byte("deep")}), "目錄層數"},
+		{"總大小過大", "large.zip", buildZip(t, archiveEntry{"1.bin", make([]byte, 1024)},
+			archiveEntry{"2.bin", make([]byte, 1024)}), "解壓縮後的大小"},
+		{"壓縮炸彈", "bomb.zip", buildZip(t, archiveEntry{"zero.bin", make([]byte, 10<<20)}), "壓縮率"},
+	}
+
+	for _, testCase := range testCases {
+		source := filepath.Join(dir, testCase.name)
+		asserts.NoError(ioutil.WriteFile(source, testCase.content, 0644))
+		asserts.NoError(cache.Set("setting_decompress_max_size", "2000", 0))
+		asserts.NoError(cache.Set("setting_decompress_max_entries", "10", 0))
+		asserts.NoError(cache.Set("setting_decompress_max_depth", "4", 0))
+		asserts.NoError(cache.Set("setting_decompress_max_ratio", "100", 0))
+		if testCase.reason == "壓縮率" {
+			asserts.NoError(cache.Set("setting_decompress_max_size", "0", 0))
+		}
+
+		task := &DecompressTask{
+			User: &model.User{
+				Model:  gorm.Model{ID: 1},
+				Policy: model.Policy{Type: "local"},
+				Group:  model.Group{MaxStorage: 1 << 30},
+			},
+			TaskModel: &model.Task{Model: gorm.Model{ID: 1}},
+			TaskProps: DecompressProps{Src: "/" + testCase.name, Dst: "/"},
+		}
+
+		// 設定進度
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 尋找壓縮文件
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "source_name", "policy_id", "size"}).
+				AddRow(1, testCase.name, source, 1, len(testCase.content)))
+		// 設定錯誤，不應建立任何文件或目錄
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet(), testCase.desc)
+		if asserts.NotNil(task.GetError(), testCase.desc) {
+			asserts.Equal("壓縮文件超出安全限制，已中止解壓縮", task.GetError().Msg, testCase.desc)
+			asserts.True(strings.Contains(task.GetError().Error, testCase.reason), testCase.desc)
+		}
+	}
+
+	// 沒有殘留臨時文件
+	tempFiles, _ := ioutil.ReadDir(util.RelativePath("tests/decompress"))
+	asserts.Empty(tempFiles)
+}
+
 func TestNewDecompressTask(t *testing.T) {
 	asserts := assert.New(t)
 
@@ -99,7 +232,7 @@ func TestNewDecompressTask(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
-		job, err := NewDecompressTask(&model.User{}, "/", "/")
+		job, err := NewDecompressTask(&model.User{}, "/", "/", "", nil)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NotNil(job)
 		asserts.NoError(err)
@@ -110,7 +243,7 @@ func TestNewDecompressTask(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
 		mock.ExpectRollback()
-		job, err := NewDecompressTask(&model.User{}, "/", "/")
+		job, err := NewDecompressTask(&model.User{}, "/", "/", "", nil)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Nil(job)
 		asserts.Error(err)
@@ -5,4 +5,8 @@ import "errors"
 var (
 	// ErrUnknownTaskType 未知任務類型
 	ErrUnknownTaskType = errors.New("未知任務類型")
+	// ErrReconcileRootUnknown 無法確定匯入孤立文件的起始目錄
+	ErrReconcileRootUnknown = errors.New("無法確定孤立文件所在的共同上級目錄")
+	// ErrReconcileRootInvalid 本機儲存策略的儲存路徑為檔案系統根目錄
+	ErrReconcileRootInvalid = errors.New("儲存策略的儲存路徑為檔案系統根目錄，無法對帳")
 )
@@ -0,0 +1,386 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	// migrateBatchSize 每批次遷移的記錄數量
+	migrateBatchSize = 100
+	// maxMigrateFailures 報告中最多記錄的失敗條目數
+	maxMigrateFailures = 1000
+)
+
+// ErrMigrateSamePolicy 來源與目標儲存策略相同
+var ErrMigrateSamePolicy = errors.New("來源與目標儲存策略不能相同")
+
+// MigrateTask 儲存策略間的文件遷移任務
+type MigrateTask struct {
+	UID       uint
+	TaskModel *model.Task
+	TaskProps MigrateProps
+	Err       *JobError
+}
+
+// MigrateProps 遷移任務屬性
+type MigrateProps struct {
+	SrcPolicy     uint           `json:"src_policy"`       // 來源儲存策略ID
+	DstPolicy     uint           `json:"dst_policy"`       // 目標儲存策略ID
+	UserID        uint           `json:"user_id"`          // 只遷移此使用者的文件，為0時不篩選
+	GroupID       uint           `json:"group_id"`         // 只遷移此使用者群組的文件，為0時不篩選
+	IdleBefore    *time.Time     `json:"idle_before"`      // 只遷移最後存取時間早於此時間的文件，為空時不篩選
+	DeleteSource  bool           `json:"delete_source"`    // 遷移後是否刪除不再被引用的來源物理文件
+	AfterFileID   uint           `json:"after_file_id"`    // 已處理的最後一個文件ID，用於復原任務
+	AfterVersion  uint           `json:"after_version"`    // 已處理的最後一個歷史版本ID，用於復原任務
+	VersionsPhase bool           `json:"versions_phase"`   // 是否已進入歷史版本的遷移階段
+	Report        *MigrateReport `json:"report,omitempty"` // 遷移報告
+}
+
+// MigrateReport 遷移報告
+type MigrateReport struct {
+	Migrated int              `json:"migrated"` // 已遷移的物理文件數量
+	Failed   int              `json:"failed"`   // 遷移失敗的物理文件數量
+	Size     uint64           `json:"size"`     // 已遷移的位元組數
+	Failures []MigrateFailure `json:"failures"` // 最多記錄 maxMigrateFailures 條
+}
+
+// MigrateFailure 遷移失敗的物理文件
+type MigrateFailure struct {
+	FileID     uint   `json:"file_id"`
+	VersionID  uint   `json:"version_id,omitempty"`
+	SourceName string `json:"source_name"`
+	Error      string `json:"error"`
+}
+
+// Props 獲取任務屬性
+func (job *MigrateTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 獲取任務類型
+func (job *MigrateTask) Type() int {
+	return MigrateTaskType
+}
+
+// Creator 獲取建立者ID
+func (job *MigrateTask) Creator() uint {
+	return job.UID
+}
+
+// Model 獲取任務的資料庫模型
+func (job *MigrateTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 設定狀態
+func (job *MigrateTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 設定任務失敗訊息
+func (job *MigrateTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 設定任務失敗訊息
+func (job *MigrateTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任務失敗訊息
+func (job *MigrateTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 開始執行任務。先遷移文件，再遷移剩餘的歷史版本，每批次處理完成後儲存進度，
+// 任務中斷後可從上次的位置繼續
+func (job *MigrateTask) Do() {
+	if job.TaskProps.SrcPolicy == job.TaskProps.DstPolicy {
+		job.SetErrorMsg(ErrMigrateSamePolicy.Error(), nil)
+		return
+	}
+
+	src, err := model.GetPolicyByID(job.TaskProps.SrcPolicy)
+	if err != nil {
+		job.SetErrorMsg("來源儲存策略不存在", err)
+		return
+	}
+	dst, err := model.GetPolicyByID(job.TaskProps.DstPolicy)
+	if err != nil {
+		job.SetErrorMsg("目標儲存策略不存在", err)
+		return
+	}
+
+	srcFS, err := filesystem.NewFileSystem(&model.User{Policy: src})
+	if err != nil {
+		job.SetErrorMsg("無法初始化來源儲存策略", err)
+		return
+	}
+	defer srcFS.Recycle()
+	dstFS, err := filesystem.NewFileSystem(&model.User{Policy: dst})
+	if err != nil {
+		job.SetErrorMsg("無法初始化目標儲存策略", err)
+		return
+	}
+	defer dstFS.Recycle()
+
+	job.TaskModel.SetProgress(MigratingProgress)
+	if job.TaskProps.Report == nil {
+		job.TaskProps.Report = &MigrateReport{Failures: make([]MigrateFailure, 0)}
+	}
+
+	if !job.TaskProps.VersionsPhase {
+		if err := job.migrateFiles(srcFS, dstFS); err != nil {
+			job.SetErrorMsg("無法遷移文件", err)
+			return
+		}
+		job.TaskProps.VersionsPhase = true
+	}
+
+	if err := job.migrateVersions(srcFS, dstFS); err != nil {
+		job.SetErrorMsg("無法遷移歷史版本", err)
+		return
+	}
+
+	report := job.TaskProps.Report
+	util.Log().Info(
+		"儲存策略 [%s] 到 [%s] 的遷移完成，共遷移 %d 個物理文件（%d 位元組），失敗 %d 個",
+		src.Name, dst.Name, report.Migrated, report.Size, report.Failed,
+	)
+}
+
+// migrateFiles 按ID順序遷移來源儲存策略下的文件
+func (job *MigrateTask) migrateFiles(srcFS, dstFS *filesystem.FileSystem) error {
+	for {
+		files, err := model.GetFilesToMigrate(
+			srcFS.User.Policy.ID,
+			job.filter(),
+			job.TaskProps.AfterFileID,
+			migrateBatchSize,
+		)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return nil
+		}
+
+		// 同一批次中引用相同物理文件的記錄只需遷移一次
+		migrated := make(map[string]bool)
+		for _, file := range files {
+			if migrated[file.SourceName] {
+				continue
+			}
+			migrated[file.SourceName] = true
+
+			if err := job.migrateSource(srcFS, dstFS, file); err != nil {
+				job.addFailure(MigrateFailure{FileID: file.ID, SourceName: file.SourceName, Error: err.Error()})
+			}
+		}
+
+		job.TaskProps.AfterFileID = files[len(files)-1].ID
+		if err := job.TaskModel.SetProps(job.Props()); err != nil {
+			return err
+		}
+	}
+}
+
+// migrateVersions 按ID順序遷移來源儲存策略下剩餘的歷史版本，
+// 與文件共用物理文件的歷史版本已在遷移文件時一併遷移
+func (job *MigrateTask) migrateVersions(srcFS, dstFS *filesystem.FileSystem) error {
+	for {
+		versions, err := model.GetVersionsToMigrate(
+			srcFS.User.Policy.ID,
+			job.filter(),
+			job.TaskProps.AfterVersion,
+			migrateBatchSize,
+		)
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			return nil
+		}
+
+		// 生成物理文件名需要所屬文件的使用者和檔案名
+		fileIDs := make([]uint, 0, len(versions))
+		for _, version := range versions {
+			fileIDs = append(fileIDs, version.FileID)
+		}
+		var owners []model.File
+		if err := model.DB.Unscoped().Where("id in (?)", fileIDs).Find(&owners).Error; err != nil {
+			return err
+		}
+		ownerMap := make(map[uint]*model.File, len(owners))
+		for i := range owners {
+			ownerMap[owners[i].ID] = &owners[i]
+		}
+
+		migrated := make(map[string]bool)
+		for _, version := range versions {
+			if migrated[version.SourceName] {
+				continue
+			}
+			migrated[version.SourceName] = true
+
+			failure := MigrateFailure{FileID: version.FileID, VersionID: version.ID, SourceName: version.SourceName}
+			owner, ok := ownerMap[version.FileID]
+			if !ok {
+				failure.Error = "歷史版本所屬的文件不存在"
+				job.addFailure(failure)
+				continue
+			}
+
+			if err := job.migrateSource(srcFS, dstFS, version.AsFile(owner)); err != nil {
+				failure.Error = err.Error()
+				job.addFailure(failure)
+			}
+		}
+
+		job.TaskProps.AfterVersion = versions[len(versions)-1].ID
+		if err := job.TaskModel.SetProps(job.Props()); err != nil {
+			return err
+		}
+	}
+}
+
+// migrateSource 將文件的物理文件複製到目標儲存策略，並將引用此物理文件的記錄一併切換到新位置。
+// 來源物理文件仍被未遷移的記錄引用時予以保留
+func (job *MigrateTask) migrateSource(srcFS, dstFS *filesystem.FileSystem, file model.File) error {
+	ctx := context.Background()
+	dst := &dstFS.User.Policy
+	newSource := path.Join(dst.GeneratePath(file.UserID, ""), dst.GenerateFileName(file.UserID, file.Name))
+
+	// 不能覆蓋共用同一儲存空間的儲存策略中已被引用的物理文件
+	referenced, err := referencedSources(dst, newSource)
+	if err != nil {
+		return err
+	}
+	if len(referenced) > 0 {
+		return fmt.Errorf("目標物理文件 %q 已被其他文件使用", newSource)
+	}
+
+	// 目標位置已有的物理文件未被引用，通常是上次中斷的遷移在更新記錄前留下的，直接覆蓋
+	rs, err := srcFS.Handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, file), file.SourceName)
+	if err != nil {
+		return err
+	}
+	err = dstFS.Handler.Put(ctx, rs, newSource, file.Size)
+	rs.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := model.MigrateSource(
+		srcFS.User.Policy.ID,
+		file.SourceName,
+		job.filter(),
+		dst.ID,
+		newSource,
+	); err != nil {
+		if _, delErr := dstFS.Handler.Delete(ctx, []string{newSource}); delErr != nil {
+			util.Log().Warning("無法刪除已複製的物理文件 %q, %s", newSource, delErr)
+		}
+		return err
+	}
+
+	job.TaskProps.Report.Migrated++
+	job.TaskProps.Report.Size += file.Size
+
+	if job.TaskProps.DeleteSource {
+		referenced, err := referencedSources(&srcFS.User.Policy, file.SourceName)
+		if err != nil {
+			util.Log().Warning("無法查詢物理文件 %q 的引用, %s", file.SourceName, err)
+		} else if len(referenced) == 0 {
+			if _, err := srcFS.Handler.Delete(ctx, []string{file.SourceName}); err != nil {
+				util.Log().Warning("無法刪除來源物理文件 %q, %s", file.SourceName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// referencedSources 查詢物理文件是否仍被共用同一儲存空間的儲存策略引用
+func referencedSources(policy *model.Policy, source string) ([]string, error) {
+	policies, err := model.GetSiblingPolicyIDs(policy)
+	if err != nil {
+		return nil, err
+	}
+	return model.GetReferencedSources(policies, []string{source})
+}
+
+// filter 返回任務的文件篩選條件
+func (job *MigrateTask) filter() model.MigrateFilter {
+	filter := model.MigrateFilter{UserID: job.TaskProps.UserID, GroupID: job.TaskProps.GroupID}
+	if job.TaskProps.IdleBefore != nil {
+		filter.IdleBefore = *job.TaskProps.IdleBefore
+	}
+	return filter
+}
+
+// addFailure 記錄遷移失敗的物理文件
+func (job *MigrateTask) addFailure(failure MigrateFailure) {
+	report := job.TaskProps.Report
+	report.Failed++
+	if len(report.Failures) < maxMigrateFailures {
+		report.Failures = append(report.Failures, failure)
+	}
+}
+
+// NewMigrateTask 建立遷移任務，filter 用於篩選要遷移的文件
+func NewMigrateTask(uid, srcPolicy, dstPolicy uint, filter model.MigrateFilter, deleteSource bool) (Job, error) {
+	newTask := &MigrateTask{
+		UID: uid,
+		TaskProps: MigrateProps{
+			SrcPolicy:    srcPolicy,
+			DstPolicy:    dstPolicy,
+			UserID:       filter.UserID,
+			GroupID:      filter.GroupID,
+			DeleteSource: deleteSource,
+		},
+	}
+	if !filter.IdleBefore.IsZero() {
+		newTask.TaskProps.IdleBefore = &filter.IdleBefore
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewMigrateTaskFromModel 從資料庫記錄中復原遷移任務，已處理的進度一併復原
+func NewMigrateTaskFromModel(task *model.Task) (Job, error) {
+	newTask := &MigrateTask{
+		UID:       task.UserID,
+		TaskModel: task,
+	}
+
+	err := json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
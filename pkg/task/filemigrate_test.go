@@ -0,0 +1,241 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &MigrateTask{TaskProps: MigrateProps{SrcPolicy: 1, DstPolicy: 2}}
+	asserts.Contains(task.Props(), `"src_policy":1`)
+	asserts.Equal(MigrateTaskType, task.Type())
+	asserts.Nil(task.GetError())
+}
+
+func TestMigrateTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Set("policy_201", model.Policy{
+		Model:       gorm.Model{ID: 201},
+		Type:        "local",
+		DirNameRule: "TestMigrateTask_Do/src",
+	}, 0)
+	cache.Set("policy_202", model.Policy{
+		Model:       gorm.Model{ID: 202},
+		Type:        "local",
+		DirNameRule: "TestMigrateTask_Do/dst/{uid}",
+	}, 0)
+	defer cache.Deletes([]string{"201", "202"}, "policy_")
+	defer os.RemoveAll(util.RelativePath("TestMigrateTask_Do"))
+
+	// 來源與目標相同
+	{
+		task := &MigrateTask{
+			TaskModel: &model.Task{Model: gorm.Model{ID: 1}},
+			TaskProps: MigrateProps{SrcPolicy: 201, DstPolicy: 201},
+		}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrMigrateSamePolicy.Error(), task.Err.Msg)
+	}
+
+	// 成功遷移，覆蓋上次中斷時留下的目標物理文件，物理文件不再被引用時刪除
+	{
+		file, err := util.CreatNestedFile(util.RelativePath("TestMigrateTask_Do/src/1.txt"))
+		asserts.NoError(err)
+		file.WriteString("hello")
+		file.Close()
+		file, err = util.CreatNestedFile(util.RelativePath("TestMigrateTask_Do/dst/1/1.txt"))
+		asserts.NoError(err)
+		file.WriteString("stale")
+		file.Close()
+
+		task := &MigrateTask{
+			TaskModel: &model.Task{Model: gorm.Model{ID: 1}},
+			TaskProps: MigrateProps{SrcPolicy: 201, DstPolicy: 202, UserID: 1, DeleteSource: true},
+		}
+
+		// 設定遷移中狀態
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 列出文件，兩個文件引用相同的物理文件
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 201, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "source_name", "size", "policy_id"}).
+				AddRow(1, 1, "1.txt", "TestMigrateTask_Do/src/1.txt", 5, 201).
+				AddRow(2, 1, "2.txt", "TestMigrateTask_Do/src/1.txt", 5, 201))
+		// 目標物理文件未被使用
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WithArgs("local").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(201).AddRow(202))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(201, 202, "TestMigrateTask_Do/dst/1/1.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}))
+		// 切換記錄
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs(202, "TestMigrateTask_Do/dst/1/1.txt", 1, 201, "TestMigrateTask_Do/src/1.txt").
+			WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectExec("UPDATE(.+)file_versions(.+)").
+			WillReturnResult(sqlmock.NewResult(1, 0))
+		mock.ExpectCommit()
+		// 來源物理文件不再被引用
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WithArgs("local").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(201).AddRow(202))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(201, 202, "TestMigrateTask_Do/src/1.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}))
+		// 儲存進度
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)props(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 201, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 列出歷史版本
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(1, 201, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(task.Err)
+		asserts.Equal(1, task.TaskProps.Report.Migrated)
+		asserts.EqualValues(5, task.TaskProps.Report.Size)
+		asserts.EqualValues(2, task.TaskProps.AfterFileID)
+		asserts.True(task.TaskProps.VersionsPhase)
+
+		content, err := ioutil.ReadFile(util.RelativePath("TestMigrateTask_Do/dst/1/1.txt"))
+		asserts.NoError(err)
+		asserts.Equal("hello", string(content))
+		asserts.False(util.Exists(util.RelativePath("TestMigrateTask_Do/src/1.txt")))
+	}
+
+	// 從歷史版本階段復原，更新記錄失敗時刪除已複製的物理文件
+	{
+		file, err := util.CreatNestedFile(util.RelativePath("TestMigrateTask_Do/src/v1.txt"))
+		asserts.NoError(err)
+		file.WriteString("old")
+		file.Close()
+
+		task := &MigrateTask{
+			TaskModel: &model.Task{Model: gorm.Model{ID: 1}},
+			TaskProps: MigrateProps{
+				SrcPolicy:     201,
+				DstPolicy:     202,
+				VersionsPhase: true,
+				AfterVersion:  3,
+				Report:        &MigrateReport{Migrated: 1},
+			},
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(201, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "file_id", "source_name", "size", "policy_id"}).
+				AddRow(4, 1, "TestMigrateTask_Do/src/v1.txt", 3, 201).
+				AddRow(5, 9, "TestMigrateTask_Do/src/v9.txt", 3, 201))
+		// 所屬文件
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 9).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name"}).AddRow(1, 2, "1.txt"))
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WithArgs("local").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(202))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(202, "TestMigrateTask_Do/dst/2/1.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)props(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(201, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(task.Err)
+		report := task.TaskProps.Report
+		asserts.Equal(1, report.Migrated)
+		asserts.Equal(2, report.Failed)
+		asserts.EqualValues(4, report.Failures[0].VersionID)
+		asserts.EqualValues(5, report.Failures[1].VersionID)
+		asserts.False(util.Exists(util.RelativePath("TestMigrateTask_Do/dst/2/1.txt")))
+		asserts.True(util.Exists(util.RelativePath("TestMigrateTask_Do/src/v1.txt")))
+	}
+}
+
+func TestNewMigrateTask(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		idleBefore := time.Now()
+		job, err := NewMigrateTask(1, 2, 3, model.MigrateFilter{GroupID: 4, IdleBefore: idleBefore}, true)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(4, job.(*MigrateTask).TaskProps.GroupID)
+		asserts.Equal(idleBefore, *job.(*MigrateTask).TaskProps.IdleBefore)
+		asserts.Equal(model.MigrateFilter{GroupID: 4, IdleBefore: idleBefore}, job.(*MigrateTask).filter())
+	}
+
+	// 失敗
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		job, err := NewMigrateTask(1, 2, 3, model.MigrateFilter{GroupID: 4}, true)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
+
+func TestNewMigrateTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 復原進度
+	{
+		props, _ := json.Marshal(MigrateProps{SrcPolicy: 1, DstPolicy: 2, AfterFileID: 10})
+		job, err := GetJobFromModel(&model.Task{Type: MigrateTaskType, Props: string(props)})
+		asserts.NoError(err)
+		asserts.EqualValues(10, job.(*MigrateTask).TaskProps.AfterFileID)
+	}
+
+	// 屬性無效
+	{
+		job, err := NewMigrateTaskFromModel(&model.Task{Props: "?"})
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
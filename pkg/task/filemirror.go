@@ -0,0 +1,337 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/mirror"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	// mirrorRepairBatchSize 每批次檢查的記錄數量
+	mirrorRepairBatchSize = 100
+	// maxMirrorRepairFailures 報告中最多記錄的失敗條目數
+	maxMirrorRepairFailures = 1000
+)
+
+var (
+	// ErrNotMirrorPolicy 儲存策略不是鏡像儲存策略
+	ErrNotMirrorPolicy = errors.New("儲存策略不是鏡像儲存策略")
+	// errNoMirrorCopy 沒有成員包含此物理文件
+	errNoMirrorCopy = errors.New("沒有可用的成員儲存策略包含此物理文件")
+)
+
+// MirrorRepairTask 鏡像儲存策略的修復任務，將成員中缺少的物理文件從其他成員複製補齊
+type MirrorRepairTask struct {
+	UID       uint
+	TaskModel *model.Task
+	TaskProps MirrorRepairProps
+	Err       *JobError
+}
+
+// MirrorRepairProps 修復任務屬性
+type MirrorRepairProps struct {
+	PolicyID      uint                `json:"policy_id"`        // 鏡像儲存策略ID
+	AfterFileID   uint                `json:"after_file_id"`    // 已處理的最後一個文件ID，用於復原任務
+	AfterVersion  uint                `json:"after_version"`    // 已處理的最後一個歷史版本ID，用於復原任務
+	VersionsPhase bool                `json:"versions_phase"`   // 是否已進入歷史版本的檢查階段
+	Report        *MirrorRepairReport `json:"report,omitempty"` // 修復報告
+}
+
+// MirrorRepairReport 修復報告
+type MirrorRepairReport struct {
+	Checked  int                   `json:"checked"`  // 已檢查的物理文件數量
+	Repaired int                   `json:"repaired"` // 已補齊的副本數量
+	Failed   int                   `json:"failed"`   // 無法補齊的副本數量
+	Failures []MirrorRepairFailure `json:"failures"` // 最多記錄 maxMirrorRepairFailures 條
+}
+
+// MirrorRepairFailure 無法補齊的副本
+type MirrorRepairFailure struct {
+	SourceName string `json:"source_name"`
+	PolicyID   uint   `json:"policy_id,omitempty"` // 缺少副本的成員儲存策略ID
+	Error      string `json:"error"`
+}
+
+// Props 獲取任務屬性
+func (job *MirrorRepairTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 獲取任務類型
+func (job *MirrorRepairTask) Type() int {
+	return MirrorRepairTaskType
+}
+
+// Creator 獲取建立者ID
+func (job *MirrorRepairTask) Creator() uint {
+	return job.UID
+}
+
+// Model 獲取任務的資料庫模型
+func (job *MirrorRepairTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 設定狀態
+func (job *MirrorRepairTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 設定任務失敗訊息
+func (job *MirrorRepairTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 設定任務失敗訊息
+func (job *MirrorRepairTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任務失敗訊息
+func (job *MirrorRepairTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 開始執行任務。依序檢查文件和歷史版本引用的物理文件，每批次處理完成後儲存進度，
+// 任務中斷後可從上次的位置繼續
+func (job *MirrorRepairTask) Do() {
+	policy, err := model.GetPolicyByID(job.TaskProps.PolicyID)
+	if err != nil {
+		job.SetErrorMsg("儲存策略不存在", err)
+		return
+	}
+	if policy.Type != "mirror" {
+		job.SetErrorMsg(ErrNotMirrorPolicy.Error(), nil)
+		return
+	}
+
+	members, err := filesystem.MirrorMembers(&policy)
+	if err != nil {
+		job.SetErrorMsg("無法初始化成員儲存策略", err)
+		return
+	}
+
+	job.TaskModel.SetProgress(RepairingProgress)
+	if job.TaskProps.Report == nil {
+		job.TaskProps.Report = &MirrorRepairReport{Failures: make([]MirrorRepairFailure, 0)}
+	}
+
+	if !job.TaskProps.VersionsPhase {
+		if err := job.repairFiles(policy.ID, members); err != nil {
+			job.SetErrorMsg("無法修復文件", err)
+			return
+		}
+		job.TaskProps.VersionsPhase = true
+	}
+
+	if err := job.repairVersions(policy.ID, members); err != nil {
+		job.SetErrorMsg("無法修復歷史版本", err)
+		return
+	}
+
+	report := job.TaskProps.Report
+	util.Log().Info(
+		"鏡像儲存策略 [%s] 修復完成，共檢查 %d 個物理文件，補齊 %d 個副本，失敗 %d 個",
+		policy.Name, report.Checked, report.Repaired, report.Failed,
+	)
+}
+
+// repairFiles 按ID順序檢查儲存策略下文件引用的物理文件
+func (job *MirrorRepairTask) repairFiles(policyID uint, members []mirror.Member) error {
+	for {
+		files, err := model.GetFilesByPolicy(policyID, job.TaskProps.AfterFileID, mirrorRepairBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return nil
+		}
+
+		sources := make([]string, 0, len(files))
+		for _, file := range files {
+			sources = append(sources, file.SourceName)
+		}
+		job.repairSources(members, sources)
+
+		job.TaskProps.AfterFileID = files[len(files)-1].ID
+		if err := job.TaskModel.SetProps(job.Props()); err != nil {
+			return err
+		}
+	}
+}
+
+// repairVersions 按ID順序檢查儲存策略下歷史版本引用的物理文件
+func (job *MirrorRepairTask) repairVersions(policyID uint, members []mirror.Member) error {
+	for {
+		versions, err := model.GetVersionsToMigrate(
+			policyID,
+			model.MigrateFilter{},
+			job.TaskProps.AfterVersion,
+			mirrorRepairBatchSize,
+		)
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			return nil
+		}
+
+		sources := make([]string, 0, len(versions))
+		for _, version := range versions {
+			sources = append(sources, version.SourceName)
+		}
+		job.repairSources(members, sources)
+
+		job.TaskProps.AfterVersion = versions[len(versions)-1].ID
+		if err := job.TaskModel.SetProps(job.Props()); err != nil {
+			return err
+		}
+	}
+}
+
+// repairSources 按所在目錄列取各成員中的物理文件，將缺少的物理文件從包含它的第一個成員複製補齊
+func (job *MirrorRepairTask) repairSources(members []mirror.Member, sources []string) {
+	ctx := context.Background()
+
+	// 同一批次中重複的物理文件只需檢查一次
+	checked := make(map[string]bool)
+	dirs := make(map[string]bool)
+	unique := make([]string, 0, len(sources))
+	for _, source := range sources {
+		if checked[source] {
+			continue
+		}
+		checked[source] = true
+		unique = append(unique, source)
+		dirs[scrubDir(source)] = true
+	}
+
+	// 列取各成員中物理文件所在的目錄
+	objects := make([]map[string]uint64, len(members))
+	listErrors := make([]map[string]error, len(members))
+	for i, member := range members {
+		objects[i] = make(map[string]uint64)
+		listErrors[i] = make(map[string]error)
+		for dir := range dirs {
+			res, err := member.Handler.List(ctx, dir, false)
+			if err != nil {
+				listErrors[i][dir] = err
+				continue
+			}
+			for _, object := range res {
+				if !object.IsDir {
+					objects[i][path.Join(dir, object.RelativePath)] = object.Size
+				}
+			}
+		}
+	}
+
+	for _, source := range unique {
+		job.TaskProps.Report.Checked++
+		dir := scrubDir(source)
+		key := path.Join(dir, path.Base(source))
+
+		donor := -1
+		for i := range members {
+			if _, ok := objects[i][key]; ok {
+				donor = i
+				break
+			}
+		}
+		if donor < 0 {
+			job.addFailure(MirrorRepairFailure{SourceName: source, Error: errNoMirrorCopy.Error()})
+			continue
+		}
+
+		for i, member := range members {
+			if _, ok := objects[i][key]; ok {
+				continue
+			}
+
+			failure := MirrorRepairFailure{SourceName: source, PolicyID: member.Policy.ID}
+			err := listErrors[i][dir]
+			if err == nil {
+				err = copyMirrorSource(ctx, members[donor], member, source, objects[donor][key])
+			}
+			if err != nil {
+				failure.Error = err.Error()
+				job.addFailure(failure)
+				continue
+			}
+
+			job.TaskProps.Report.Repaired++
+		}
+	}
+}
+
+// copyMirrorSource 將物理文件從一個成員複製到另一個成員的相同位置
+func copyMirrorSource(ctx context.Context, src, dst mirror.Member, source string, size uint64) error {
+	file := model.File{Name: path.Base(source), SourceName: source, Size: size}
+	rs, err := src.Handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, file), source)
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+
+	// 部分適配器需要先定位一次才能正常讀取
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return dst.Handler.Put(context.WithValue(ctx, fsctx.DisableOverwrite, true), rs, source, size)
+}
+
+// addFailure 記錄無法補齊的副本
+func (job *MirrorRepairTask) addFailure(failure MirrorRepairFailure) {
+	report := job.TaskProps.Report
+	report.Failed++
+	if len(report.Failures) < maxMirrorRepairFailures {
+		report.Failures = append(report.Failures, failure)
+	}
+}
+
+// NewMirrorRepairTask 建立鏡像儲存策略修復任務
+func NewMirrorRepairTask(uid, policyID uint) (Job, error) {
+	newTask := &MirrorRepairTask{
+		UID:       uid,
+		TaskProps: MirrorRepairProps{PolicyID: policyID},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewMirrorRepairTaskFromModel 從資料庫記錄中復原修復任務，已處理的進度一併復原
+func NewMirrorRepairTaskFromModel(task *model.Task) (Job, error) {
+	newTask := &MirrorRepairTask{
+		UID:       task.UserID,
+		TaskModel: task,
+	}
+
+	err := json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
@@ -0,0 +1,248 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/mirror"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryHandler 將文件存放在記憶體中的適配器
+type memoryHandler struct {
+	files map[string][]byte
+}
+
+type nopSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+func (handler *memoryHandler) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	handler.files[dst] = content
+	return nil
+}
+
+func (handler *memoryHandler) Delete(ctx context.Context, files []string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (handler *memoryHandler) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	content, ok := handler.files[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return nopSeekCloser{bytes.NewReader(content)}, nil
+}
+
+func (handler *memoryHandler) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func (handler *memoryHandler) Source(ctx context.Context, path string, url url.URL, ttl int64, isDownload bool, speed int) (string, error) {
+	return "", nil
+}
+
+func (handler *memoryHandler) Token(ctx context.Context, ttl int64, callbackKey string) (serializer.UploadCredential, error) {
+	return serializer.UploadCredential{}, nil
+}
+
+func (handler *memoryHandler) List(ctx context.Context, dir string, recursive bool) ([]response.Object, error) {
+	var objects []response.Object
+	for name, content := range handler.files {
+		if path.Dir(name) == dir {
+			objects = append(objects, response.Object{RelativePath: path.Base(name), Size: uint64(len(content))})
+		}
+	}
+	return objects, nil
+}
+
+func TestMirrorRepairTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &MirrorRepairTask{TaskProps: MirrorRepairProps{PolicyID: 1}}
+	asserts.Contains(task.Props(), `"policy_id":1`)
+	asserts.Equal(MirrorRepairTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestMirrorRepairTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Set("policy_221", model.Policy{Model: gorm.Model{ID: 221}, Type: "local"}, 0)
+	cache.Set("policy_222", model.Policy{Model: gorm.Model{ID: 222}, Type: "local"}, 0)
+	mirrorPolicy := model.Policy{Model: gorm.Model{ID: 223}, Type: "mirror"}
+	mirrorPolicy.OptionsSerialized.MirrorPolicies = []uint{221, 222}
+	cache.Set("policy_223", mirrorPolicy, 0)
+	defer cache.Deletes([]string{"221", "222", "223"}, "policy_")
+	defer os.RemoveAll(util.RelativePath("TestMirrorRepairTask_Do"))
+
+	// 不是鏡像儲存策略
+	{
+		task := &MirrorRepairTask{
+			TaskModel: &model.Task{Model: gorm.Model{ID: 1}},
+			TaskProps: MirrorRepairProps{PolicyID: 221},
+		}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrNotMirrorPolicy.Error(), task.Err.Msg)
+	}
+
+	// 檢查文件及歷史版本
+	{
+		file, err := util.CreatNestedFile(util.RelativePath("TestMirrorRepairTask_Do/1.txt"))
+		asserts.NoError(err)
+		file.WriteString("hello")
+		file.Close()
+
+		task := &MirrorRepairTask{
+			TaskModel: &model.Task{Model: gorm.Model{ID: 1}},
+			TaskProps: MirrorRepairProps{PolicyID: 223},
+		}
+
+		// 設定修復中狀態
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(223, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}).
+				AddRow(1, "TestMirrorRepairTask_Do/1.txt"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)props(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(223, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 歷史版本的物理文件已遺失
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(223, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}).
+				AddRow(2, "TestMirrorRepairTask_Do/2.txt"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)props(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(223, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(task.Err)
+		asserts.True(task.TaskProps.VersionsPhase)
+		asserts.EqualValues(2, task.TaskProps.AfterVersion)
+		asserts.Equal(2, task.TaskProps.Report.Checked)
+		asserts.Equal(0, task.TaskProps.Report.Repaired)
+		asserts.Equal(1, task.TaskProps.Report.Failed)
+		asserts.Equal("TestMirrorRepairTask_Do/2.txt", task.TaskProps.Report.Failures[0].SourceName)
+	}
+}
+
+func TestMirrorRepairTask_repairSources(t *testing.T) {
+	asserts := assert.New(t)
+	defer os.RemoveAll(util.RelativePath("TestMirrorRepairTask_repairSources"))
+
+	file, err := util.CreatNestedFile(util.RelativePath("TestMirrorRepairTask_repairSources/1.txt"))
+	asserts.NoError(err)
+	file.WriteString("hello")
+	file.Close()
+
+	memory := &memoryHandler{files: map[string][]byte{
+		"TestMirrorRepairTask_repairSources/2.txt": []byte("world"),
+	}}
+	members := []mirror.Member{
+		{Policy: &model.Policy{Model: gorm.Model{ID: 1}, Type: "local"}, Handler: local.Driver{}},
+		{Policy: &model.Policy{Model: gorm.Model{ID: 2}}, Handler: memory},
+	}
+	task := &MirrorRepairTask{TaskProps: MirrorRepairProps{Report: &MirrorRepairReport{}}}
+
+	task.repairSources(members, []string{
+		"TestMirrorRepairTask_repairSources/1.txt",
+		"TestMirrorRepairTask_repairSources/1.txt",
+		"TestMirrorRepairTask_repairSources/2.txt",
+		"TestMirrorRepairTask_repairSources/3.txt",
+	})
+
+	report := task.TaskProps.Report
+	asserts.Equal(3, report.Checked)
+	asserts.Equal(2, report.Repaired)
+	asserts.Equal(1, report.Failed)
+	asserts.Equal(errNoMirrorCopy.Error(), report.Failures[0].Error)
+
+	// 兩個成員互相補齊
+	asserts.Equal("hello", string(memory.files["TestMirrorRepairTask_repairSources/1.txt"]))
+	content, err := ioutil.ReadFile(util.RelativePath("TestMirrorRepairTask_repairSources/2.txt"))
+	asserts.NoError(err)
+	asserts.Equal("world", string(content))
+}
+
+func TestNewMirrorRepairTask(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		job, err := NewMirrorRepairTask(1, 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(2, job.(*MirrorRepairTask).TaskProps.PolicyID)
+	}
+
+	// 失敗
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		job, err := NewMirrorRepairTask(1, 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
+
+func TestNewMirrorRepairTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 復原進度
+	{
+		props, _ := json.Marshal(MirrorRepairProps{PolicyID: 1, AfterFileID: 10})
+		job, err := GetJobFromModel(&model.Task{Type: MirrorRepairTaskType, Props: string(props)})
+		asserts.NoError(err)
+		asserts.EqualValues(10, job.(*MirrorRepairTask).TaskProps.AfterFileID)
+	}
+
+	// 屬性無效
+	{
+		job, err := NewMirrorRepairTaskFromModel(&model.Task{Props: "?"})
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
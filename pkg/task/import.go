@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"path"
+	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 )
 
@@ -22,10 +24,11 @@ type ImportTask struct {
 
 // ImportProps 匯入任務屬性
 type ImportProps struct {
-	PolicyID  uint   `json:"policy_id"`    // 儲存策略ID
-	Src       string `json:"src"`          // 原始路徑
-	Recursive bool   `json:"is_recursive"` // 是否遞迴匯入
-	Dst       string `json:"dst"`          // 目的目錄
+	PolicyID  uint     `json:"policy_id"`         // 儲存策略ID
+	Src       string   `json:"src"`               // 原始路徑
+	Recursive bool     `json:"is_recursive"`      // 是否遞迴匯入
+	Dst       string   `json:"dst"`               // 目的目錄
+	Sources   []string `json:"sources,omitempty"` // 僅匯入給定的物理文件，留空表示全部匯入
 }
 
 // Props 獲取任務屬性
@@ -110,6 +113,11 @@ func (job *ImportTask) Do() {
 		return
 	}
 
+	// 只保留指定的物理文件
+	if len(job.TaskProps.Sources) > 0 {
+		objects = job.filterSources(objects)
+	}
+
 	job.TaskModel.SetProgress(InsertingProgress)
 
 	// 虛擬目錄路徑與folder物件ID的對應
@@ -162,7 +170,7 @@ func (job *ImportTask) Do() {
 			}
 
 			// 插入文件記錄
-			_, err := fs.AddFile(addFileCtx, parentFolder)
+			file, err := fs.AddFile(addFileCtx, parentFolder)
 			if err != nil {
 				util.Log().Warning("匯入任務無法創插入文件[%s], %s",
 					object.RelativePath, err)
@@ -170,27 +178,61 @@ func (job *ImportTask) Do() {
 					job.SetErrorMsg("容量不足", err)
 					return
 				}
+				continue
 			}
+			filesystem.IndexContent(*file)
 
 		}
 	}
 }
 
+// filterSources 過濾列取結果，只保留指定的物理文件
+func (job *ImportTask) filterSources(objects []response.Object) []response.Object {
+	sources := make(map[string]bool, len(job.TaskProps.Sources))
+	for _, source := range job.TaskProps.Sources {
+		sources[strings.TrimPrefix(source, "/")] = true
+	}
+
+	filtered := make([]response.Object, 0, len(job.TaskProps.Sources))
+	for _, object := range objects {
+		source := strings.TrimPrefix(path.Join(job.TaskProps.Src, object.RelativePath), "/")
+		if !object.IsDir && sources[source] {
+			filtered = append(filtered, object)
+		}
+	}
+	return filtered
+}
+
 // NewImportTask 建立匯入任務
 func NewImportTask(user, policy uint, src, dst string, recursive bool) (Job, error) {
+	return newImportTask(user, ImportProps{
+		PolicyID:  policy,
+		Recursive: recursive,
+		Src:       src,
+		Dst:       dst,
+	})
+}
+
+// NewImportSourcesTask 建立只匯入src目錄下給定物理文件的匯入任務
+func NewImportSourcesTask(user, policy uint, src, dst string, sources []string) (Job, error) {
+	return newImportTask(user, ImportProps{
+		PolicyID:  policy,
+		Recursive: true,
+		Src:       src,
+		Dst:       dst,
+		Sources:   sources,
+	})
+}
+
+func newImportTask(user uint, props ImportProps) (Job, error) {
 	creator, err := model.GetActiveUserByID(user)
 	if err != nil {
 		return nil, err
 	}
 
 	newTask := &ImportTask{
-		User: &creator,
-		TaskProps: ImportProps{
-			PolicyID:  policy,
-			Recursive: recursive,
-			Src:       src,
-			Dst:       dst,
-		},
+		User:      &creator,
+		TaskProps: props,
 	}
 
 	record, err := Record(newTask)
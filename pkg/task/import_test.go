@@ -7,6 +7,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
@@ -192,6 +193,26 @@ func TestImportTask_Do(t *testing.T) {
 	}
 }
 
+func TestImportTask_filterSources(t *testing.T) {
+	asserts := assert.New(t)
+	task := &ImportTask{
+		TaskProps: ImportProps{
+			Src:     "/uploads",
+			Sources: []string{"uploads/1/a.txt", "/uploads/2/b.txt"},
+		},
+	}
+
+	res := task.filterSources([]response.Object{
+		{RelativePath: "1", IsDir: true},
+		{RelativePath: "1/a.txt"},
+		{RelativePath: "1/c.txt"},
+		{RelativePath: "2/b.txt"},
+	})
+	asserts.Len(res, 2)
+	asserts.Equal("1/a.txt", res[0].RelativePath)
+	asserts.Equal("2/b.txt", res[1].RelativePath)
+}
+
 func TestNewImportTask(t *testing.T) {
 	asserts := assert.New(t)
 
@@ -0,0 +1,916 @@
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	// scrubBatchSize 每批次檢查的文件數量
+	scrubBatchSize = 500
+	// maxScrubIssues 報告中最多記錄的問題條目數
+	maxScrubIssues = 1000
+)
+
+// 完整性檢查發現的問題類型
+const (
+	// ScrubMissing 物理文件不存在
+	ScrubMissing = "missing"
+	// ScrubSizeMismatch 大小不符
+	ScrubSizeMismatch = "size_mismatch"
+	// ScrubHashMismatch 內容雜湊值不符
+	ScrubHashMismatch = "hash_mismatch"
+	// ScrubUnchecked 無法完成檢查
+	ScrubUnchecked = "unchecked"
+)
+
+// ScrubTask 儲存完整性檢查任務
+type ScrubTask struct {
+	UID       uint
+	TaskModel *model.Task
+	TaskProps ScrubProps
+	Err       *JobError
+}
+
+// ScrubProps 完整性檢查任務屬性
+type ScrubProps struct {
+	PolicyIDs  []uint       `json:"policy_ids"`       // 要檢查的儲存策略，留空表示全部
+	VerifyHash bool         `json:"verify_hash"`      // 是否讀取文件內容校驗雜湊值
+	MarkBroken bool         `json:"mark_broken"`      // 是否標記有問題的文件
+	Report     *ScrubReport `json:"report,omitempty"` // 檢查報告
+}
+
+// ScrubReport 完整性檢查報告
+type ScrubReport struct {
+	Checked      int          `json:"checked"`
+	Missing      int          `json:"missing"`
+	SizeMismatch int          `json:"size_mismatch"`
+	HashMismatch int          `json:"hash_mismatch"`
+	Unchecked    int          `json:"unchecked"`
+	Issues       []ScrubIssue `json:"issues"` // 問題文件，最多記錄 maxScrubIssues 條
+}
+
+// ScrubIssue 完整性檢查發現的問題
+type ScrubIssue struct {
+	FileID     uint   `json:"file_id"`
+	VersionID  uint   `json:"version_id,omitempty"` // 問題屬於歷史版本時為歷史版本ID
+	UserID     uint   `json:"user_id"`
+	PolicyID   uint   `json:"policy_id"`
+	Name       string `json:"name"`
+	SourceName string `json:"source_name"`
+	Type       string `json:"type"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// Props 獲取任務屬性
+func (job *ScrubTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 獲取任務類型
+func (job *ScrubTask) Type() int {
+	return ScrubTaskType
+}
+
+// Creator 獲取建立者ID
+func (job *ScrubTask) Creator() uint {
+	return job.UID
+}
+
+// Model 獲取任務的資料庫模型
+func (job *ScrubTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 設定狀態
+func (job *ScrubTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 設定任務失敗訊息
+func (job *ScrubTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 設定任務失敗訊息
+func (job *ScrubTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任務失敗訊息
+func (job *ScrubTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 開始執行任務
+func (job *ScrubTask) Do() {
+	var policies []model.Policy
+	tx := model.DB
+	if len(job.TaskProps.PolicyIDs) > 0 {
+		tx = tx.Where("id in (?)", job.TaskProps.PolicyIDs)
+	}
+	if err := tx.Find(&policies).Error; err != nil {
+		job.SetErrorMsg("無法列取儲存策略", err)
+		return
+	}
+
+	job.TaskModel.SetProgress(ScrubbingProgress)
+	report := &ScrubReport{Issues: make([]ScrubIssue, 0)}
+	for i := range policies {
+		if err := job.scrubPolicy(&policies[i], report); err != nil {
+			job.SetErrorMsg(fmt.Sprintf("無法檢查儲存策略 [%s]", policies[i].Name), err)
+			return
+		}
+	}
+
+	// 寫入檢查報告
+	job.TaskProps.Report = report
+	if err := job.TaskModel.SetProps(job.Props()); err != nil {
+		job.SetErrorMsg("無法儲存檢查報告", err)
+		return
+	}
+
+	util.Log().Info(
+		"完整性檢查完成，共檢查 %d 個文件及歷史版本，遺失 %d 個，大小不符 %d 個，雜湊值不符 %d 個，無法檢查 %d 個",
+		report.Checked, report.Missing, report.SizeMismatch, report.HashMismatch, report.Unchecked,
+	)
+}
+
+// scrubPolicy 檢查儲存策略下的所有文件及歷史版本
+func (job *ScrubTask) scrubPolicy(policy *model.Policy, report *ScrubReport) error {
+	fs, err := filesystem.NewFileSystem(&model.User{Policy: *policy})
+	if err != nil {
+		return err
+	}
+	defer fs.Recycle()
+
+	var afterID uint
+	for {
+		files, err := model.GetFilesByPolicy(policy.ID, afterID, scrubBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			break
+		}
+		afterID = files[len(files)-1].ID
+
+		job.scrubFiles(fs, files, report)
+	}
+
+	versions, err := model.GetVersionsByPolicy(policy.ID)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(versions); i += scrubBatchSize {
+		batch := versions[i:]
+		if len(batch) > scrubBatchSize {
+			batch = batch[:scrubBatchSize]
+		}
+		job.scrubVersions(fs, batch, report)
+	}
+
+	return nil
+}
+
+// scrubTarget 待檢查的文件或歷史版本
+type scrubTarget struct {
+	issue ScrubIssue // 發現問題時記錄的條目，Type 留空
+	size  uint64
+	hash  string
+}
+
+// scrubFiles 檢查一批文件，並按需標記損壞的文件
+func (job *ScrubTask) scrubFiles(fs *filesystem.FileSystem, files []model.File, report *ScrubReport) {
+	targets := make([]scrubTarget, len(files))
+	for i, file := range files {
+		targets[i] = scrubTarget{
+			issue: ScrubIssue{
+				FileID:     file.ID,
+				UserID:     file.UserID,
+				PolicyID:   file.PolicyID,
+				Name:       file.Name,
+				SourceName: file.SourceName,
+			},
+			size: file.Size,
+			hash: file.Hash,
+		}
+	}
+
+	types := job.scrubTargets(fs, targets, report)
+	if !job.TaskProps.MarkBroken {
+		return
+	}
+
+	broken := make([]uint, 0)
+	healthy := make([]uint, 0, len(files))
+	for i, file := range files {
+		switch types[i] {
+		case "":
+			healthy = append(healthy, file.ID)
+		case ScrubUnchecked:
+		default:
+			broken = append(broken, file.ID)
+		}
+	}
+
+	if err := model.SetFilesBroken(broken, true); err != nil {
+		util.Log().Warning("無法標記損壞的文件, %s", err)
+	}
+	if err := model.SetFilesBroken(healthy, false); err != nil {
+		util.Log().Warning("無法清除文件的損壞標記, %s", err)
+	}
+}
+
+// scrubVersions 檢查一批歷史版本，歷史版本沒有損壞標記，只記錄到檢查報告中
+func (job *ScrubTask) scrubVersions(fs *filesystem.FileSystem, versions []model.FileVersion, report *ScrubReport) {
+	targets := make([]scrubTarget, len(versions))
+	for i, version := range versions {
+		targets[i] = scrubTarget{
+			issue: ScrubIssue{
+				FileID:     version.FileID,
+				VersionID:  version.ID,
+				PolicyID:   version.PolicyID,
+				SourceName: version.SourceName,
+			},
+			size: version.Size,
+			hash: version.Hash,
+		}
+	}
+
+	job.scrubTargets(fs, targets, report)
+}
+
+// scrubTargets 按所在目錄列取物理文件後逐一比對，返回每個檢查目標發現的問題類型，沒有問題時為空
+func (job *ScrubTask) scrubTargets(fs *filesystem.FileSystem, targets []scrubTarget, report *ScrubReport) []string {
+	ctx := context.Background()
+
+	// 列取物理文件所在的目錄
+	listed := make(map[string]bool)
+	objects := make(map[string]uint64)
+	listErrors := make(map[string]error)
+	for _, target := range targets {
+		dir := scrubDir(target.issue.SourceName)
+		if listed[dir] {
+			continue
+		}
+		listed[dir] = true
+
+		res, err := fs.Handler.List(ctx, dir, false)
+		if err != nil {
+			listErrors[dir] = err
+			continue
+		}
+		for _, object := range res {
+			if !object.IsDir {
+				objects[path.Join(dir, object.RelativePath)] = object.Size
+			}
+		}
+	}
+
+	types := make([]string, len(targets))
+	for i, target := range targets {
+		report.Checked++
+		issue := target.issue
+
+		dir := scrubDir(issue.SourceName)
+		size, exist := objects[path.Join(dir, path.Base(issue.SourceName))]
+		switch {
+		case listErrors[dir] != nil:
+			issue.Type = ScrubUnchecked
+			issue.Detail = listErrors[dir].Error()
+			report.Unchecked++
+		case !exist:
+			issue.Type = ScrubMissing
+			report.Missing++
+		case size != target.size:
+			issue.Type = ScrubSizeMismatch
+			issue.Detail = fmt.Sprintf("expected %d, got %d", target.size, size)
+			report.SizeMismatch++
+		case job.TaskProps.VerifyHash && target.hash != "":
+			hash, err := scrubHash(ctx, fs, issue.SourceName)
+			if err != nil {
+				issue.Type = ScrubUnchecked
+				issue.Detail = err.Error()
+				report.Unchecked++
+			} else if hash != target.hash {
+				issue.Type = ScrubHashMismatch
+				issue.Detail = fmt.Sprintf("expected %s, got %s", target.hash, hash)
+				report.HashMismatch++
+			}
+		}
+
+		types[i] = issue.Type
+		if issue.Type != "" && len(report.Issues) < maxScrubIssues {
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	return types
+}
+
+// scrubDir 返回物理文件所在的目錄
+func scrubDir(source string) string {
+	dir := path.Dir(source)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// scrubHash 讀取物理文件並計算內容雜湊值
+func scrubHash(ctx context.Context, fs *filesystem.FileSystem, source string) (string, error) {
+	rs, err := fs.Handler.Get(ctx, source)
+	if err != nil {
+		return "", err
+	}
+	defer rs.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, rs); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// NewScrubTask 建立完整性檢查任務，uid 為 0 表示由系統發起
+func NewScrubTask(uid uint, policies []uint, verifyHash, markBroken bool) (Job, error) {
+	newTask := &ScrubTask{
+		UID: uid,
+		TaskProps: ScrubProps{
+			PolicyIDs:  policies,
+			VerifyHash: verifyHash,
+			MarkBroken: markBroken,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewScrubTaskFromModel 從資料庫記錄中復原完整性檢查任務
+func NewScrubTaskFromModel(task *model.Task) (Job, error) {
+	newTask := &ScrubTask{
+		UID:       task.UserID,
+		TaskModel: task,
+	}
+
+	err := json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
+
+// ReconcileTask 物理文件與資料庫記錄對帳任務
+type ReconcileTask struct {
+	UID       uint
+	TaskModel *model.Task
+	TaskProps ReconcileProps
+	Err       *JobError
+}
+
+// ReconcileProps 對帳任務屬性
+type ReconcileProps struct {
+	PolicyID uint             `json:"policy_id"`        // 儲存策略ID
+	Report   *ReconcileReport `json:"report,omitempty"` // 對帳報告
+}
+
+// ReconcileReport 對帳報告
+type ReconcileReport struct {
+	Root          string              `json:"root"`           // 列取物理文件的起始目錄
+	Objects       int                 `json:"objects"`        // 物理文件數量
+	Records       int                 `json:"records"`        // 文件記錄數量
+	OrphanCount   int                 `json:"orphan_count"`   // 沒有對應記錄的物理文件數量
+	DanglingCount int                 `json:"dangling_count"` // 物理文件已遺失的記錄數量
+	Orphans       []ReconcileOrphan   `json:"orphans"`        // 最多記錄 maxReconcileItems 條
+	Dangling      []ReconcileDangling `json:"dangling"`       // 最多記錄 maxReconcileItems 條
+}
+
+// ReconcileOrphan 沒有對應記錄的物理文件
+type ReconcileOrphan struct {
+	Source     string    `json:"source"`
+	Size       uint64    `json:"size"`
+	LastModify time.Time `json:"last_modify"`
+}
+
+// ReconcileDangling 物理文件已遺失的文件記錄
+type ReconcileDangling struct {
+	FileID     uint   `json:"file_id"`
+	UserID     uint   `json:"user_id"`
+	Name       string `json:"name"`
+	SourceName string `json:"source_name"`
+	Size       uint64 `json:"size"`
+}
+
+const (
+	// maxReconcileItems 對帳報告中每類問題最多記錄的條目數
+	maxReconcileItems = 10000
+	// reconcileGracePeriod 最近修改的物理文件可能屬於進行中的上傳，不視為孤立文件
+	reconcileGracePeriod = 24 * time.Hour
+)
+
+// Props 獲取任務屬性
+func (job *ReconcileTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 獲取任務類型
+func (job *ReconcileTask) Type() int {
+	return ReconcileTaskType
+}
+
+// Creator 獲取建立者ID
+func (job *ReconcileTask) Creator() uint {
+	return job.UID
+}
+
+// Model 獲取任務的資料庫模型
+func (job *ReconcileTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 設定狀態
+func (job *ReconcileTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 設定任務失敗訊息
+func (job *ReconcileTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 設定任務失敗訊息
+func (job *ReconcileTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任務失敗訊息
+func (job *ReconcileTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 開始執行任務
+func (job *ReconcileTask) Do() {
+	ctx := context.Background()
+
+	// 尋找儲存策略
+	policy, err := model.GetPolicyByID(job.TaskProps.PolicyID)
+	if err != nil {
+		job.SetErrorMsg("找不到儲存策略", err)
+		return
+	}
+
+	scope, err := newReconcileScope(&policy)
+	if err != nil {
+		job.SetErrorMsg(err.Error(), nil)
+		return
+	}
+
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystem(&model.User{Policy: policy})
+	if err != nil {
+		job.SetErrorMsg(err.Error(), nil)
+		return
+	}
+	defer fs.Recycle()
+
+	// 遞迴列取儲存策略下的所有物理文件
+	job.TaskModel.SetProgress(ListingProgress)
+	objects, err := scope.list(ctx, fs.Handler)
+	if err != nil {
+		job.SetErrorMsg("無法列取文件", err)
+		return
+	}
+
+	job.TaskModel.SetProgress(ReconcilingProgress)
+	report := &ReconcileReport{
+		Root:     scope.root,
+		Objects:  len(objects),
+		Orphans:  make([]ReconcileOrphan, 0),
+		Dangling: make([]ReconcileDangling, 0),
+	}
+
+	existed := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		existed[reconcileKey(&policy, object.Source)] = true
+	}
+
+	// 歷史版本持有的物理文件不屬於孤立文件
+	referenced := make(map[string]bool)
+	versions, err := model.GetVersionsByPolicy(policy.ID)
+	if err != nil {
+		job.SetErrorMsg("無法列取歷史版本記錄", err)
+		return
+	}
+	for _, version := range versions {
+		referenced[reconcileKey(&policy, version.SourceName)] = true
+	}
+
+	// 比對文件記錄，不在列取範圍內的記錄不做判斷
+	var afterID uint
+	for {
+		files, err := model.GetFilesByPolicy(policy.ID, afterID, scrubBatchSize)
+		if err != nil {
+			job.SetErrorMsg("無法列取文件記錄", err)
+			return
+		}
+		if len(files) == 0 {
+			break
+		}
+		afterID = files[len(files)-1].ID
+
+		for _, file := range files {
+			report.Records++
+			key := reconcileKey(&policy, file.SourceName)
+			referenced[key] = true
+			if !scope.contains(file.SourceName) || existed[key] {
+				continue
+			}
+
+			report.DanglingCount++
+			if len(report.Dangling) < maxReconcileItems {
+				report.Dangling = append(report.Dangling, ReconcileDangling{
+					FileID:     file.ID,
+					UserID:     file.UserID,
+					Name:       file.Name,
+					SourceName: file.SourceName,
+					Size:       file.Size,
+				})
+			}
+		}
+	}
+
+	// 找出未被本策略引用的物理文件，略過已有文件的縮圖及最近修改的文件
+	candidates := make([]ReconcileOrphan, 0)
+	for _, object := range objects {
+		key := reconcileKey(&policy, object.Source)
+		if referenced[key] ||
+			(strings.HasSuffix(key, conf.ThumbConfig.FileSuffix) &&
+				referenced[strings.TrimSuffix(key, conf.ThumbConfig.FileSuffix)]) ||
+			time.Since(object.LastModify) < reconcileGracePeriod {
+			continue
+		}
+		candidates = append(candidates, object)
+	}
+
+	// 共用同一儲存空間的其他儲存策略仍可能引用這些文件
+	for i := 0; i < len(candidates); i += scrubBatchSize {
+		batch := candidates[i:]
+		if len(batch) > scrubBatchSize {
+			batch = batch[:scrubBatchSize]
+		}
+
+		sources := make([]string, len(batch))
+		for j, object := range batch {
+			sources[j] = object.Source
+		}
+		unreferenced, err := UnreferencedSources(&policy, sources)
+		if err != nil {
+			job.SetErrorMsg("無法查詢文件引用", err)
+			return
+		}
+
+		orphans := make(map[string]bool, len(unreferenced))
+		for _, source := range unreferenced {
+			orphans[source] = true
+		}
+		for _, object := range batch {
+			if !orphans[object.Source] {
+				continue
+			}
+			report.OrphanCount++
+			if len(report.Orphans) < maxReconcileItems {
+				report.Orphans = append(report.Orphans, object)
+			}
+		}
+	}
+
+	// 寫入對帳報告
+	job.TaskProps.Report = report
+	if err := job.TaskModel.SetProps(job.Props()); err != nil {
+		job.SetErrorMsg("無法儲存對帳報告", err)
+		return
+	}
+
+	util.Log().Info(
+		"儲存策略 [%s] 對帳完成，孤立文件 %d 個，遺失物理文件的記錄 %d 個",
+		policy.Name, report.OrphanCount, report.DanglingCount,
+	)
+}
+
+// reconcileScope 對帳時列取物理文件的範圍
+type reconcileScope struct {
+	policy   *model.Policy
+	root     string   // 列取的起始目錄
+	rootKey  string   // 起始目錄的比對標識
+	excluded []string // 本機策略中需略過的程式自身文件及目錄的絕對路徑
+}
+
+// newReconcileScope 確定儲存策略的對帳範圍。遠端策略從根目錄列取；
+// 本機策略從儲存路徑規則的固定前綴目錄列取，並略過程式自身的文件
+func newReconcileScope(policy *model.Policy) (*reconcileScope, error) {
+	if policy.Type != "local" {
+		return &reconcileScope{policy: policy, root: "/"}, nil
+	}
+
+	root := policy.DirNamePrefix()
+	rootKey := reconcileKey(policy, root)
+	if filepath.Dir(rootKey) == rootKey {
+		return nil, ErrReconcileRootInvalid
+	}
+
+	settings := model.GetSettingByNames("temp_path", "avatar_path")
+	excluded := make([]string, 0)
+	for _, name := range []string{
+		conf.ConfigPath,
+		conf.DatabaseConfig.DBFile,
+		conf.EncryptionConfig.MasterKeyFile,
+		conf.ReadCacheConfig.Path,
+		"statics",
+		settings["temp_path"],
+		settings["avatar_path"],
+	} {
+		if name != "" {
+			excluded = append(excluded, filepath.Clean(util.RelativePath(name)))
+		}
+	}
+	if exe, err := os.Executable(); err == nil {
+		excluded = append(excluded, filepath.Clean(exe))
+	}
+
+	return &reconcileScope{policy: policy, root: root, rootKey: rootKey, excluded: excluded}, nil
+}
+
+// contains 返回物理文件是否在對帳範圍內
+func (scope *reconcileScope) contains(source string) bool {
+	if scope.policy.Type != "local" {
+		return true
+	}
+
+	key := reconcileKey(scope.policy, source)
+	if !reconcileWithin(key, scope.rootKey) {
+		return false
+	}
+	for _, excluded := range scope.excluded {
+		if reconcileWithin(key, excluded) {
+			return false
+		}
+	}
+	return true
+}
+
+// list 遞迴列取對帳範圍內的物理文件，返回結果中的 Source 為物理文件在儲存策略中的路徑
+func (scope *reconcileScope) list(ctx context.Context, handler filesystem.Handler) ([]ReconcileOrphan, error) {
+	objects, err := handler.List(ctx, scope.root, true)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]ReconcileOrphan, 0, len(objects))
+	for _, object := range objects {
+		if object.IsDir {
+			continue
+		}
+
+		source := path.Join(scope.root, object.RelativePath)
+		if scope.policy.Type != "local" {
+			source = strings.TrimPrefix(source, "/")
+		} else if !scope.contains(source) {
+			continue
+		}
+		res = append(res, ReconcileOrphan{
+			Source:     source,
+			Size:       object.Size,
+			LastModify: object.LastModify,
+		})
+	}
+
+	return res, nil
+}
+
+// reconcileWithin 返回絕對路徑 target 是否為 dir 本身或位於 dir 之下
+func reconcileWithin(target, dir string) bool {
+	rel, err := filepath.Rel(dir, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// reconcileDir 返回物理文件所在的目錄
+func reconcileDir(policy *model.Policy, source string) string {
+	dir := path.Dir(filepath.ToSlash(source))
+	if dir == "." {
+		if policy.Type != "local" {
+			return "/"
+		}
+		return ""
+	}
+	return dir
+}
+
+// MissingSources 非遞迴地重新列取物理文件所在的目錄，返回給定物理文件中確實不存在的部分
+func MissingSources(ctx context.Context, handler filesystem.Handler, policy *model.Policy, sources []string) ([]string, error) {
+	dirs := make(map[string]string)
+	for _, source := range sources {
+		dir := reconcileDir(policy, source)
+		dirs[reconcileKey(policy, dir)] = dir
+	}
+
+	keys := make([]string, 0, len(dirs))
+	for key := range dirs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	existed := make(map[string]bool)
+	for _, key := range keys {
+		dir := dirs[key]
+		objects, err := handler.List(ctx, dir, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			if !object.IsDir {
+				existed[reconcileKey(policy, path.Join(dir, object.RelativePath))] = true
+			}
+		}
+	}
+
+	res := make([]string, 0, len(sources))
+	for _, source := range sources {
+		if !existed[reconcileKey(policy, source)] {
+			res = append(res, source)
+		}
+	}
+	return res, nil
+}
+
+// UnreferencedSources 從給定的物理文件中去除仍被共用同一儲存空間的儲存策略引用的部分，
+// 已有文件的縮圖同樣視為被引用
+func UnreferencedSources(policy *model.Policy, sources []string) ([]string, error) {
+	policies, err := model.GetSiblingPolicyIDs(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]string, 0, len(sources)*2)
+	for _, source := range sources {
+		candidates = append(candidates, reconcileForms(policy, source)...)
+		if strings.HasSuffix(source, conf.ThumbConfig.FileSuffix) {
+			candidates = append(candidates,
+				reconcileForms(policy, strings.TrimSuffix(source, conf.ThumbConfig.FileSuffix))...)
+		}
+	}
+
+	referenced, err := model.GetReferencedSources(policies, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(referenced))
+	for _, source := range referenced {
+		keys[reconcileKey(policy, source)] = true
+	}
+
+	res := make([]string, 0, len(sources))
+	for _, source := range sources {
+		key := reconcileKey(policy, source)
+		if keys[key] ||
+			(strings.HasSuffix(key, conf.ThumbConfig.FileSuffix) &&
+				keys[strings.TrimSuffix(key, conf.ThumbConfig.FileSuffix)]) {
+			continue
+		}
+		res = append(res, source)
+	}
+	return res, nil
+}
+
+// reconcileForms 返回物理文件在記錄中可能的寫法。本機策略的記錄可能使用相對或絕對路徑
+func reconcileForms(policy *model.Policy, source string) []string {
+	if policy.Type != "local" {
+		source = strings.TrimPrefix(source, "/")
+		return []string{source, "/" + source}
+	}
+
+	abs := util.RelativePath(filepath.FromSlash(source))
+	forms := []string{source, abs, filepath.ToSlash(abs)}
+	if rel, err := filepath.Rel(util.RelativePath(""), abs); err == nil && !strings.HasPrefix(rel, "..") {
+		forms = append(forms, rel, filepath.ToSlash(rel))
+	}
+	return forms
+}
+
+// ReconcileImportRoot 返回匯入孤立文件時列取物理文件的起始目錄，即各物理文件所在目錄的共同上級目錄。
+// 本機策略的起始目錄不能為檔案系統根目錄
+func ReconcileImportRoot(policy *model.Policy, sources []string) (string, error) {
+	var common []string
+	for i, source := range sources {
+		parts := strings.Split(path.Dir(filepath.ToSlash(source)), "/")
+		if i == 0 {
+			common = parts
+			continue
+		}
+
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+
+	root := strings.Join(common, "/")
+	if len(common) > 0 && common[0] == "" {
+		root = "/" + strings.Join(common[1:], "/")
+	}
+	if root == "." {
+		root = ""
+	}
+
+	if policy.Type != "local" {
+		if root == "" {
+			root = "/"
+		}
+		return root, nil
+	}
+
+	if key := reconcileKey(policy, root); filepath.Dir(key) == key {
+		return "", ErrReconcileRootUnknown
+	}
+	return root, nil
+}
+
+// reconcileKey 返回物理文件用於比對的標識。本機策略中的路徑可能為相對或絕對路徑，
+// 統一轉換為絕對路徑
+func reconcileKey(policy *model.Policy, source string) string {
+	if policy.Type == "local" {
+		return filepath.Clean(util.RelativePath(filepath.FromSlash(source)))
+	}
+	return strings.TrimPrefix(path.Clean("/"+source), "/")
+}
+
+// NewReconcileTask 建立對帳任務
+func NewReconcileTask(uid, policy uint) (Job, error) {
+	newTask := &ReconcileTask{
+		UID: uid,
+		TaskProps: ReconcileProps{
+			PolicyID: policy,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewReconcileTaskFromModel 從資料庫記錄中復原對帳任務
+func NewReconcileTaskFromModel(task *model.Task) (Job, error) {
+	newTask := &ReconcileTask{
+		UID:       task.UserID,
+		TaskModel: task,
+	}
+
+	err := json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
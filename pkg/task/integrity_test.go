@@ -0,0 +1,485 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrubTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &ScrubTask{UID: 1}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(ScrubTaskType, task.Type())
+	asserts.EqualValues(1, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestScrubTask_SetError(t *testing.T) {
+	asserts := assert.New(t)
+	task := &ScrubTask{
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	task.SetErrorMsg("error", errors.New("error"))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal("error", task.GetError().Msg)
+	asserts.Equal("error", task.GetError().Error)
+}
+
+func TestScrubTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	task := &ScrubTask{
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+		TaskProps: ScrubProps{
+			PolicyIDs:  []uint{1},
+			VerifyHash: true,
+			MarkBroken: true,
+		},
+	}
+
+	// 無法列取儲存策略
+	{
+		mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotEmpty(task.Err.Msg)
+		task.Err = nil
+	}
+
+	// 成功檢查
+	{
+		for name, content := range map[string]string{
+			"ok.txt":   "123",
+			"size.txt": "123",
+			"hash.txt": "456",
+			"v1.txt":   "123",
+		} {
+			file, err := util.CreatNestedFile(util.RelativePath("TestScrubTask_Do/" + name))
+			asserts.NoError(err)
+			file.WriteString(content)
+			file.Close()
+		}
+		defer os.RemoveAll(util.RelativePath("TestScrubTask_Do"))
+
+		hash := "a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3"
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(1, "local"))
+		// 設定檢查中狀態
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 列出文件
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "size", "hash", "policy_id"}).
+				AddRow(1, "TestScrubTask_Do/ok.txt", 3, hash, 1).
+				AddRow(2, "TestScrubTask_Do/missing.txt", 3, hash, 1).
+				AddRow(3, "TestScrubTask_Do/size.txt", 10, "", 1).
+				AddRow(4, "TestScrubTask_Do/hash.txt", 3, hash, 1))
+		// 標記損壞及正常的文件
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)broken(.+)").
+			WithArgs(true, 2, 3, 4).
+			WillReturnResult(sqlmock.NewResult(1, 3))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)broken(.+)").
+			WithArgs(false, 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 4).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 列出歷史版本，歷史版本不標記損壞
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "file_id", "source_name", "size", "hash", "policy_id"}).
+				AddRow(1, 1, "TestScrubTask_Do/v1.txt", 3, hash, 1).
+				AddRow(2, 1, "TestScrubTask_Do/v2.txt", 3, hash, 1))
+		// 寫入檢查報告
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)props(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(task.Err)
+
+		report := task.TaskProps.Report
+		asserts.Equal(6, report.Checked)
+		asserts.Equal(2, report.Missing)
+		asserts.Equal(1, report.SizeMismatch)
+		asserts.Equal(1, report.HashMismatch)
+		asserts.Equal(0, report.Unchecked)
+		asserts.Len(report.Issues, 4)
+		asserts.EqualValues(2, report.Issues[0].FileID)
+		asserts.Equal(ScrubMissing, report.Issues[0].Type)
+		asserts.EqualValues(1, report.Issues[3].FileID)
+		asserts.EqualValues(2, report.Issues[3].VersionID)
+		asserts.Equal(ScrubMissing, report.Issues[3].Type)
+	}
+}
+
+func TestNewScrubTask(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		job, err := NewScrubTask(0, []uint{1}, true, false)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotNil(job)
+		asserts.NoError(err)
+		asserts.EqualValues(0, job.Creator())
+	}
+
+	// 失敗
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		job, err := NewScrubTask(0, nil, false, false)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(job)
+		asserts.Error(err)
+	}
+}
+
+func TestNewScrubTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		props, _ := json.Marshal(ScrubProps{PolicyIDs: []uint{1}, VerifyHash: true})
+		job, err := NewScrubTaskFromModel(&model.Task{UserID: 2, Props: string(props)})
+		asserts.NoError(err)
+		asserts.EqualValues(2, job.Creator())
+		asserts.True(job.(*ScrubTask).TaskProps.VerifyHash)
+	}
+
+	// 屬性格式錯誤
+	{
+		job, err := NewScrubTaskFromModel(&model.Task{Props: "?"})
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
+
+func TestReconcileTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &ReconcileTask{UID: 1}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(ReconcileTaskType, task.Type())
+	asserts.EqualValues(1, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestReconcileTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	task := &ReconcileTask{
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+		TaskProps: ReconcileProps{PolicyID: 102},
+	}
+	cache.Set("policy_102", model.Policy{
+		Model:       gorm.Model{ID: 102},
+		Type:        "local",
+		DirNameRule: "TestReconcileTask_Do/{uid}",
+	}, 0)
+	cache.Set("policy_103", model.Policy{
+		Model:       gorm.Model{ID: 103},
+		Type:        "local",
+		DirNameRule: "/{uid}",
+	}, 0)
+	cache.Set("setting_temp_path", "TestReconcileTask_Do/temp", 0)
+	cache.Set("setting_avatar_path", "avatar", 0)
+	defer cache.Deletes([]string{"102", "103"}, "policy_")
+	defer cache.Deletes([]string{"temp_path", "avatar_path"}, "setting_")
+
+	// 儲存路徑為檔案系統根目錄
+	{
+		task.TaskProps.PolicyID = 103
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrReconcileRootInvalid.Error(), task.Err.Msg)
+		task.Err = nil
+		task.TaskProps.PolicyID = 102
+	}
+
+	// 無法列取文件記錄
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("無法列取文件記錄", task.Err.Msg)
+		task.Err = nil
+	}
+
+	// 成功對帳，沒有任何記錄的目錄中的文件同樣被列出
+	{
+		old := time.Now().Add(-2 * reconcileGracePeriod)
+		for _, name := range []string{
+			"1/ok.txt",
+			"1/ok.txt" + conf.ThumbConfig.FileSuffix,
+			"1/version.txt",
+			"1/orphan.txt",
+			"1/shared.txt",
+			"1/recent.txt",
+			"legacy/old.txt",
+			"new/deep/orphan.txt",
+			"temp/chunks/1",
+		} {
+			file, err := util.CreatNestedFile(util.RelativePath("TestReconcileTask_Do/" + name))
+			asserts.NoError(err)
+			file.WriteString("123")
+			file.Close()
+			if name != "1/recent.txt" {
+				os.Chtimes(util.RelativePath("TestReconcileTask_Do/"+name), old, old)
+			}
+		}
+		defer os.RemoveAll(util.RelativePath("TestReconcileTask_Do"))
+
+		// 設定列取中、對帳中狀態
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 歷史版本
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(102).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}).
+				AddRow(1, "TestReconcileTask_Do/1/version.txt"))
+		// 比對文件記錄，列取範圍外的記錄不做判斷
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(102, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "source_name", "size", "policy_id"}).
+				AddRow(1, 1, "TestReconcileTask_Do/1/ok.txt", 3, 102).
+				AddRow(2, 1, util.RelativePath("TestReconcileTask_Do/1/missing.txt"), 3, 102).
+				AddRow(3, 1, "TestReconcileTask_Do/legacy/old.txt", 3, 102).
+				AddRow(4, 1, "TestReconcileTask_Outside/1/missing.txt", 3, 102))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(102, 4).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 查詢共用儲存空間的其他儲存策略的引用
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WithArgs("local").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(102).AddRow(104))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}).AddRow("TestReconcileTask_Do/1/shared.txt"))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}))
+		// 寫入對帳報告
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)props(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(task.Err)
+
+		report := task.TaskProps.Report
+		asserts.Equal("TestReconcileTask_Do", report.Root)
+		asserts.Equal(8, report.Objects)
+		asserts.Equal(4, report.Records)
+		asserts.Equal(2, report.OrphanCount)
+		asserts.Equal("TestReconcileTask_Do/1/orphan.txt", report.Orphans[0].Source)
+		asserts.EqualValues(3, report.Orphans[0].Size)
+		asserts.Equal("TestReconcileTask_Do/new/deep/orphan.txt", report.Orphans[1].Source)
+		asserts.Equal(1, report.DanglingCount)
+		asserts.EqualValues(2, report.Dangling[0].FileID)
+	}
+}
+
+func TestReconcileScope(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Set("setting_temp_path", "temp", 0)
+	cache.Set("setting_avatar_path", "avatar", 0)
+	defer cache.Deletes([]string{"temp_path", "avatar_path"}, "setting_")
+
+	// 遠端策略從根目錄列取全部文件
+	{
+		scope, err := newReconcileScope(&model.Policy{Type: "s3", DirNameRule: "uploads/{uid}"})
+		asserts.NoError(err)
+		asserts.Equal("/", scope.root)
+		asserts.True(scope.contains("others/a.txt"))
+	}
+
+	// 本機策略儲存路徑以變數開頭時列取程式目錄，略過程式自身的文件
+	{
+		scope, err := newReconcileScope(&model.Policy{Type: "local", DirNameRule: "{uid}/{path}"})
+		asserts.NoError(err)
+		asserts.Equal("", scope.root)
+		asserts.True(scope.contains("1/a.txt"))
+		asserts.True(scope.contains(util.RelativePath("1/a.txt")))
+		asserts.False(scope.contains(conf.DatabaseConfig.DBFile))
+		asserts.False(scope.contains("statics/index.html"))
+		asserts.False(scope.contains("temp/chunks/1"))
+		asserts.False(scope.contains("avatar/avatar_1_1.png"))
+		asserts.False(scope.contains("/outside/a.txt"))
+	}
+}
+
+func TestMissingSources(t *testing.T) {
+	asserts := assert.New(t)
+	policy := &model.Policy{Type: "local"}
+	handler := local.Driver{Policy: policy}
+
+	file, err := util.CreatNestedFile(util.RelativePath("TestMissingSources/1/exist.txt"))
+	asserts.NoError(err)
+	file.Close()
+	defer os.RemoveAll(util.RelativePath("TestMissingSources"))
+
+	missing, err := MissingSources(context.Background(), handler, policy, []string{
+		"TestMissingSources/1/exist.txt",
+		util.RelativePath("TestMissingSources/1/missing.txt"),
+		"TestMissingSources/2/missing.txt",
+		"TestMissingSources_root.txt",
+	})
+	asserts.NoError(err)
+	asserts.Equal([]string{
+		util.RelativePath("TestMissingSources/1/missing.txt"),
+		"TestMissingSources/2/missing.txt",
+		"TestMissingSources_root.txt",
+	}, missing)
+}
+
+func TestUnreferencedSources(t *testing.T) {
+	asserts := assert.New(t)
+	policy := &model.Policy{Model: gorm.Model{ID: 1}, Type: "s3", Server: "https://s3", BucketName: "bucket"}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WithArgs("s3", "https://s3", "bucket").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}).AddRow("a.txt").AddRow("/b.jpg"))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"source_name"}))
+		res, err := UnreferencedSources(policy, []string{
+			"a.txt",
+			"b.jpg" + conf.ThumbConfig.FileSuffix,
+			"c.txt",
+		})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal([]string{"c.txt"}, res)
+	}
+
+	// 查詢失敗
+	{
+		mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnError(errors.New("error"))
+		_, err := UnreferencedSources(policy, []string{"a.txt"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestReconcileImportRoot(t *testing.T) {
+	asserts := assert.New(t)
+	testCases := []struct {
+		policy  model.Policy
+		sources []string
+		root    string
+		err     bool
+	}{
+		{model.Policy{Type: "s3"}, []string{"uploads/1/a.txt", "uploads/2/b.txt"}, "uploads", false},
+		{model.Policy{Type: "s3"}, []string{"uploads/1/a.txt", "a.txt"}, "/", false},
+		{model.Policy{Type: "local"}, []string{"uploads/1/a.txt", "uploads/1/b/c.txt"}, "uploads/1", false},
+		{model.Policy{Type: "local"}, []string{"/data/uploads/1/a.txt", "/data/uploads/2/b.txt"}, "/data/uploads", false},
+		{model.Policy{Type: "local"}, []string{"uploads/1/a.txt", "others/1/a.txt"}, "", false},
+		{model.Policy{Type: "local"}, []string{"/data/a.txt", "/var/a.txt"}, "", true},
+		{model.Policy{Type: "local"}, []string{"/a.txt"}, "", true},
+	}
+
+	for _, testCase := range testCases {
+		root, err := ReconcileImportRoot(&testCase.policy, testCase.sources)
+		asserts.Equal(testCase.err, err != nil, testCase.sources)
+		asserts.Equal(testCase.root, root)
+	}
+}
+
+func TestNewReconcileTask(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		job, err := NewReconcileTask(1, 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(1, job.Creator())
+	}
+
+	// 失敗
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		job, err := NewReconcileTask(1, 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(job)
+		asserts.Error(err)
+	}
+}
+
+func TestNewReconcileTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		props, _ := json.Marshal(ReconcileProps{PolicyID: 2})
+		job, err := NewReconcileTaskFromModel(&model.Task{UserID: 1, Props: string(props)})
+		asserts.NoError(err)
+		asserts.EqualValues(2, job.(*ReconcileTask).TaskProps.PolicyID)
+	}
+
+	// 屬性格式錯誤
+	{
+		job, err := NewReconcileTaskFromModel(&model.Task{Props: "?"})
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
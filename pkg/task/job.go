@@ -15,6 +15,14 @@ const (
 	TransferTaskType
 	// ImportTaskType 匯入任務
 	ImportTaskType
+	// ScrubTaskType 完整性檢查任務
+	ScrubTaskType
+	// ReconcileTaskType 對帳任務
+	ReconcileTaskType
+	// MigrateTaskType 儲存策略遷移任務
+	MigrateTaskType
+	// MirrorRepairTaskType 鏡像儲存策略修復任務
+	MirrorRepairTaskType
 )
 
 // 任務狀態
@@ -47,6 +55,14 @@ const (
 	ListingProgress
 	// InsertingProgress 插入中
 	InsertingProgress
+	// ScrubbingProgress 檢查中
+	ScrubbingProgress
+	// ReconcilingProgress 對帳中
+	ReconcilingProgress
+	// MigratingProgress 遷移中
+	MigratingProgress
+	// RepairingProgress 修復中
+	RepairingProgress
 )
 
 // Job 任務介面
@@ -111,6 +127,14 @@ func GetJobFromModel(task *model.Task) (Job, error) {
 		return NewTransferTaskFromModel(task)
 	case ImportTaskType:
 		return NewImportTaskFromModel(task)
+	case ScrubTaskType:
+		return NewScrubTaskFromModel(task)
+	case ReconcileTaskType:
+		return NewReconcileTaskFromModel(task)
+	case MigrateTaskType:
+		return NewMigrateTaskFromModel(task)
+	case MirrorRepairTaskType:
+		return NewMirrorRepairTaskFromModel(task)
 	default:
 		return nil, ErrUnknownTaskType
 	}
@@ -76,11 +76,15 @@ func (image *Thumb) Save(path string) (err error) {
 	}
 	defer out.Close()
 
-	err = png.Encode(out, image.src)
-	return err
+	return image.Encode(out)
 
 }
 
+// Encode 將圖像以 PNG 格式寫入到 w
+func (image *Thumb) Encode(w io.Writer) error {
+	return png.Encode(w, image.src)
+}
+
 // CreateAvatar 建立大頭貼
 func (image *Thumb) CreateAvatar(uid uint) error {
 	// 讀取大頭貼相關設定
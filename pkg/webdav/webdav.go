@@ -283,7 +283,7 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *files
 
 	// 嘗試作為文件刪除
 	if ok, file := fs.IsFileExist(reqPath); ok {
-		if err := fs.Delete(ctx, []uint{}, []uint{file.ID}, false); err != nil {
+		if err := fs.Remove(ctx, []uint{}, []uint{file.ID}); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
 		return http.StatusNoContent, nil
@@ -291,7 +291,7 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *files
 
 	// 嘗試作為目錄刪除
 	if ok, folder := fs.IsPathExist(reqPath); ok {
-		if err := fs.Delete(ctx, []uint{folder.ID}, []uint{}, false); err != nil {
+		if err := fs.Remove(ctx, []uint{folder.ID}, []uint{}); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
 		return http.StatusNoContent, nil
@@ -338,36 +338,57 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 	if exist {
 		// 已存在，為更新操作
 
-		// 檢查此文件是否有軟連結
-		fileList, err := model.RemoveFilesWithSoftLinks([]model.File{*originFile})
-		if err == nil && len(fileList) == 0 {
-			// 如果包含軟連接，應重新生成新文件副本，並更新source_name
-			originFile.SourceName = fs.GenerateSavePath(ctx, fileData)
-			fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
-			fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
-			fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
-		}
+		if fs.IsVersionEnabled() {
+			// 保留歷史版本，新內容寫入新的物理文件，原有物理文件由歷史版本持有
+			originFile.SourceName = fs.GenerateVersionSavePath(ctx, fileData, originFile)
+
+			fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+			fs.Use("BeforeUpload", filesystem.HookValidateFile)
+			fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+			fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
+			fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
+			fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
+			fs.Use("AfterUpload", filesystem.HookKeepVersion)
+			fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+			fs.Use("AfterUpload", filesystem.HookIndexContent)
+			fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+			fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+			fs.Use("AfterUploadFailed", filesystem.HookGiveBackCapacity)
+		} else {
+			// 檢查此文件是否有軟連結
+			fileList, err := model.RemoveFilesWithSoftLinks([]model.File{*originFile})
+			if err == nil && len(fileList) == 0 {
+				// 如果包含軟連接，應重新生成新文件副本，並更新source_name
+				originFile.SourceName = fs.GenerateSavePath(ctx, fileData)
+				fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
+				fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
+				fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
+			}
 
-		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
-		fs.Use("BeforeUpload", filesystem.HookValidateFile)
-		fs.Use("BeforeUpload", filesystem.HookChangeCapacity)
-		fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
-		fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
-		fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
-		fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
-		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
-		fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
-		fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
-		fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+			fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+			fs.Use("BeforeUpload", filesystem.HookValidateFile)
+			fs.Use("BeforeUpload", filesystem.HookChangeCapacity)
+			fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
+			fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
+			fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
+			fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
+			fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+			fs.Use("AfterUpload", filesystem.HookIndexContent)
+			fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
+			fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+			fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+		}
 		ctx = context.WithValue(ctx, fsctx.FileModelCtx, *originFile)
 	} else {
 		// 給文件系統分配鉤子
+		fs.Use("BeforeUpload", filesystem.HookRoutePolicy)
 		fs.Use("BeforeUpload", filesystem.HookValidateFile)
 		fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
 		fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
 		fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
 		fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
 		fs.Use("AfterUpload", filesystem.GenericAfterUpload)
+		fs.Use("AfterUpload", filesystem.HookIndexContent)
 		fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
 		fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
 		fs.Use("AfterUploadFailed", filesystem.HookGiveBackCapacity)
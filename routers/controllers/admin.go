@@ -415,6 +415,83 @@ func AdminCreateImportTask(c *gin.Context) {
 	}
 }
 
+// AdminCreateScrubTask 建立儲存完整性檢查任務
+func AdminCreateScrubTask(c *gin.Context) {
+	var service admin.ScrubTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminCreateMigrateTask 建立儲存策略間的文件遷移任務
+func AdminCreateMigrateTask(c *gin.Context) {
+	var service admin.MigrateTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminCreateMirrorRepairTask 建立鏡像儲存策略修復任務
+func AdminCreateMirrorRepairTask(c *gin.Context) {
+	var service admin.MirrorRepairTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminCreateReconcileTask 建立對帳任務
+func AdminCreateReconcileTask(c *gin.Context) {
+	var service admin.ReconcileTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminDeleteOrphans 刪除孤立的物理文件
+func AdminDeleteOrphans(c *gin.Context) {
+	var service admin.ReconcileOrphanService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Delete(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminImportOrphans 匯入孤立的物理文件
+func AdminImportOrphans(c *gin.Context) {
+	var service admin.ReconcileOrphanService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Import(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminDeleteDangling 刪除遺失物理文件的記錄
+func AdminDeleteDangling(c *gin.Context) {
+	var service admin.ReconcileDanglingService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Delete(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminListFolders 列出使用者或外部文件系統目錄
 func AdminListFolders(c *gin.Context) {
 	var service admin.ListFolderService
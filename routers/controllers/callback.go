@@ -121,3 +121,15 @@ func S3Callback(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// AzblobCallback Azure Blob 上傳完成用戶端回調
+func AzblobCallback(c *gin.Context) {
+	c.Header("Access-Control-Allow-Origin", "*")
+	var callbackBody callback.AzblobCallback
+	if err := c.ShouldBindQuery(&callbackBody); err == nil {
+		res := callbackBody.PreProcess(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
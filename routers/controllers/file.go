@@ -40,7 +40,7 @@ func Archive(c *gin.Context) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	var service explorer.ItemIDService
+	var service explorer.ItemArchiveService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.Archive(ctx, c)
 		c.JSON(200, res)
@@ -60,6 +60,53 @@ func Compress(c *gin.Context) {
 	}
 }
 
+// PreviewDecompress 預覽壓縮文件中解碼後的檔案名
+func PreviewDecompress(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.ItemDecompressPreviewService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Preview(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListArchiveEntries 列出壓縮文件的目錄結構
+func ListArchiveEntries(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.ArchiveEntryService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DownloadArchiveEntry 下載壓縮文件中的單個文件
+func DownloadArchiveEntry(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.ArchiveEntryService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Download(ctx, c)
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // Decompress 建立文件解壓縮任務
 func Decompress(c *gin.Context) {
 	var service explorer.ItemDecompressService
@@ -331,11 +378,13 @@ func FileUploadStream(c *gin.Context) {
 	}
 
 	// 給文件系統分配鉤子
+	fs.Use("BeforeUpload", filesystem.HookRoutePolicy)
 	fs.Use("BeforeUpload", filesystem.HookValidateFile)
 	fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
 	fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
 	fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
 	fs.Use("AfterUpload", filesystem.GenericAfterUpload)
+	fs.Use("AfterUpload", filesystem.HookIndexContent)
 	fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
 	fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
 	fs.Use("AfterUploadFailed", filesystem.HookGiveBackCapacity)
@@ -355,6 +404,85 @@ func FileUploadStream(c *gin.Context) {
 	})
 }
 
+// CheckUpload 上傳前檢查是否可秒傳
+func CheckUpload(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.UploadCheckService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Check(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// CreateUploadSession 建立分片上傳工作階段
+func CreateUploadSession(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.CreateUploadSessionService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// GetUploadSession 查詢分片上傳工作階段狀態
+func GetUploadSession(c *gin.Context) {
+	var service explorer.UploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Status(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// UploadChunk 上傳分片
+func UploadChunk(c *gin.Context) {
+	var service explorer.UploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Upload(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		request.BlackHole(c.Request.Body)
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// FinishUploadSession 完成分片上傳
+func FinishUploadSession(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.UploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Finish(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteUploadSession 取消分片上傳工作階段
+func DeleteUploadSession(c *gin.Context) {
+	var service explorer.UploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // GetUploadCredential 獲取上傳憑證
 func GetUploadCredential(c *gin.Context) {
 	// 建立上下文
@@ -373,7 +501,23 @@ func GetUploadCredential(c *gin.Context) {
 // SearchFile 搜尋文件
 func SearchFile(c *gin.Context) {
 	var service explorer.ItemSearchService
-	if err := c.ShouldBindUri(&service); err == nil {
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Search(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// QueryFile 使用查詢語法搜尋文件
+func QueryFile(c *gin.Context) {
+	var service explorer.ItemQueryService
+	if err := c.ShouldBindQuery(&service); err == nil {
 		res := service.Search(c)
 		c.JSON(200, res)
 	} else {
@@ -391,3 +535,96 @@ func CreateFile(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// ListVersions 列出文件的歷史版本
+func ListVersions(c *gin.Context) {
+	var service explorer.FileIDService
+	res := service.ListVersions(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// PreviewVersion 預覽文件的歷史版本
+func PreviewVersion(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Preview(ctx, c, false)
+		// 是否需要重定向
+		if res.Code == -301 {
+			c.Redirect(301, res.Data.(string))
+			return
+		}
+		// 是否有錯誤發生
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// PreviewVersionText 獲取文字文件歷史版本的內容
+func PreviewVersionText(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Preview(ctx, c, true)
+		// 是否有錯誤發生
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// CreateVersionDownloadSession 建立文件歷史版本的下載工作階段
+func CreateVersionDownloadSession(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.CreateDownloadSession(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RestoreVersion 將文件還原為歷史版本
+func RestoreVersion(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Restore(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteVersion 刪除文件的歷史版本
+func DeleteVersion(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -82,3 +82,40 @@ func GetProperty(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// ListRecycle 列出回收站
+func ListRecycle(c *gin.Context) {
+	var service explorer.RecycleListService
+	res := service.List(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// RestoreRecycle 復原回收站中的物件
+func RestoreRecycle(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.RecycleRestoreService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Restore(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteRecycle 徹底刪除回收站中的物件
+func DeleteRecycle(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.RecycleService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Delete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -112,6 +112,38 @@ func PreviewShare(c *gin.Context) {
 	}
 }
 
+// ListSharedArchive 列出分享的壓縮文件的目錄結構
+func ListSharedArchive(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service share.ArchiveEntryService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DownloadSharedArchiveEntry 下載分享的壓縮文件中的單個文件
+func DownloadSharedArchiveEntry(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service share.ArchiveEntryService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Download(ctx, c)
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // PreviewShareText 預覽文字文件
 func PreviewShareText(c *gin.Context) {
 	// 建立上下文
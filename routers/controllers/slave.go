@@ -14,35 +14,56 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// SlaveUpload 從機文件上傳
-func SlaveUpload(c *gin.Context) {
-	// 建立上下文
-	ctx, cancel := context.WithCancel(context.Background())
-	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
-	defer cancel()
-
+// slaveUploadFileSystem 建立從機上傳使用的匿名文件系統，並將請求攜帶的上傳策略放入上下文
+func slaveUploadFileSystem(ctx context.Context, c *gin.Context) (context.Context, *filesystem.FileSystem, *serializer.Response) {
 	// 建立匿名文件系統
 	fs, err := filesystem.NewAnonymousFileSystem()
 	if err != nil {
-		c.JSON(200, serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err))
-		return
+		res := serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+		return ctx, nil, &res
 	}
 	fs.Handler = local.Driver{}
 
 	// 從請求中取得上傳策略
 	uploadPolicyRaw := c.GetHeader("X-Policy")
 	if uploadPolicyRaw == "" {
-		c.JSON(200, serializer.ParamErr("未指定上傳策略", nil))
-		return
+		res := serializer.ParamErr("未指定上傳策略", nil)
+		return ctx, nil, &res
 	}
 
 	// 解析上傳策略
 	uploadPolicy, err := serializer.DecodeUploadPolicy(uploadPolicyRaw)
 	if err != nil {
-		c.JSON(200, serializer.ParamErr("上傳策略格式有誤", err))
+		res := serializer.ParamErr("上傳策略格式有誤", err)
+		return ctx, nil, &res
+	}
+
+	return context.WithValue(ctx, fsctx.UploadPolicyCtx, *uploadPolicy), fs, nil
+}
+
+// SlaveUpload 從機文件上傳
+func SlaveUpload(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	defer cancel()
+
+	ctx, fs, res := slaveUploadFileSystem(ctx, c)
+	if res != nil {
+		c.JSON(200, res)
+		return
+	}
+
+	// 分片上傳
+	if c.GetHeader("X-Upload-Session") != "" {
+		var service explorer.SlaveUploadSessionService
+		if err := c.ShouldBindHeader(&service); err == nil {
+			c.JSON(200, service.Upload(ctx, fs, c))
+		} else {
+			c.JSON(200, ErrorResponse(err))
+		}
 		return
 	}
-	ctx = context.WithValue(ctx, fsctx.UploadPolicyCtx, *uploadPolicy)
 
 	// 取得檔案大小
 	fileSize, err := strconv.ParseUint(c.Request.Header.Get("Content-Length"), 10, 64)
@@ -88,6 +109,26 @@ func SlaveUpload(c *gin.Context) {
 	})
 }
 
+// SlaveCreateUploadSession 建立或查詢從機分片上傳工作階段
+func SlaveCreateUploadSession(c *gin.Context) {
+	// 建立上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctx, fs, res := slaveUploadFileSystem(ctx, c)
+	if res != nil {
+		c.JSON(200, res)
+		return
+	}
+
+	var service explorer.SlaveUploadSessionService
+	if err := c.ShouldBindHeader(&service); err == nil {
+		c.JSON(200, service.Create(ctx, fs))
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // SlaveDownload 從機文件下載,此請求返回的HTTP狀態碼不全為200
 func SlaveDownload(c *gin.Context) {
 	// 建立上下文
@@ -39,6 +39,8 @@ func InitSlaveRouter() *gin.Engine {
 		v3.POST("ping", controllers.SlavePing)
 		// 上傳
 		v3.POST("upload", controllers.SlaveUpload)
+		// 建立或查詢分片上傳工作階段
+		v3.PUT("upload", controllers.SlaveCreateUploadSession)
 		// 下載
 		v3.GET("download/:speed/:path/:name", controllers.SlaveDownload)
 		// 預覽 / 外鏈
@@ -168,7 +170,7 @@ func InitMasterRouter() *gin.Engine {
 				// 文件外鏈(301跳轉)
 				file.GET("source/:id/:name", controllers.AnonymousPermLink)
 				// 下載已經打包好的文件
-				file.GET("archive/:id/archive.zip", controllers.DownloadArchive)
+				file.GET("archive/:id/:name", controllers.DownloadArchive)
 				// 下載文件
 				file.GET("download/:id", controllers.Download)
 			}
@@ -227,6 +229,12 @@ func InitMasterRouter() *gin.Engine {
 				middleware.S3CallbackAuth(),
 				controllers.S3Callback,
 			)
+			// Azure Blob 策略上傳回調
+			callback.GET(
+				"azblob/:key",
+				middleware.AzblobCallbackAuth(),
+				controllers.AzblobCallback,
+			)
 		}
 
 		// 分享相關
@@ -272,6 +280,19 @@ func InitMasterRouter() *gin.Engine {
 				middleware.BeforeShareDownload(),
 				controllers.ArchiveShare,
 			)
+			// 瀏覽壓縮文件
+			share.GET("archive/entries/:id",
+				middleware.CheckShareUnlocked(),
+				middleware.ShareCanPreview(),
+				controllers.ListSharedArchive,
+			)
+			// 下載壓縮文件中的單個文件
+			share.GET("archive/entry/:id",
+				middleware.CheckShareUnlocked(),
+				middleware.ShareCanPreview(),
+				middleware.BeforeShareDownload(),
+				controllers.DownloadSharedArchiveEntry,
+			)
 			// 獲取README文字文件內容
 			share.GET("readme/:id",
 				middleware.CheckShareUnlocked(),
@@ -403,6 +424,20 @@ func InitMasterRouter() *gin.Engine {
 					task.POST("delete", controllers.AdminDeleteTask)
 					// 建立文件匯入任務
 					task.POST("import", controllers.AdminCreateImportTask)
+					// 建立儲存完整性檢查任務
+					task.POST("scrub", controllers.AdminCreateScrubTask)
+					// 建立儲存策略遷移任務
+					task.POST("migrate", controllers.AdminCreateMigrateTask)
+					// 建立鏡像儲存策略修復任務
+					task.POST("mirror/repair", controllers.AdminCreateMirrorRepairTask)
+					// 建立對帳任務
+					task.POST("reconcile", controllers.AdminCreateReconcileTask)
+					// 刪除孤立的物理文件
+					task.POST("reconcile/orphans/delete", controllers.AdminDeleteOrphans)
+					// 匯入孤立的物理文件
+					task.POST("reconcile/orphans/import", controllers.AdminImportOrphans)
+					// 刪除遺失物理文件的記錄
+					task.POST("reconcile/dangling/delete", controllers.AdminDeleteDangling)
 				}
 
 			}
@@ -450,6 +485,18 @@ func InitMasterRouter() *gin.Engine {
 				file.POST("upload", controllers.FileUploadStream)
 				// 獲取上傳憑證
 				file.GET("upload/credential", controllers.GetUploadCredential)
+				// 上傳前檢查是否可秒傳
+				file.POST("upload/check", controllers.CheckUpload)
+				// 建立分片上傳工作階段
+				file.PUT("upload/session", controllers.CreateUploadSession)
+				// 查詢分片上傳工作階段狀態
+				file.GET("upload/session/:sessionId", controllers.GetUploadSession)
+				// 上傳分片
+				file.POST("upload/session/:sessionId/:index", controllers.UploadChunk)
+				// 完成分片上傳
+				file.POST("upload/session/:sessionId", controllers.FinishUploadSession)
+				// 取消分片上傳
+				file.DELETE("upload/session/:sessionId", controllers.DeleteUploadSession)
 				// 更新文件
 				file.PUT("update/:id", controllers.PutContent)
 				// 建立空白文件
@@ -472,8 +519,28 @@ func InitMasterRouter() *gin.Engine {
 				file.POST("compress", controllers.Compress)
 				// 建立文件解壓縮任務
 				file.POST("decompress", controllers.Decompress)
+				// 預覽壓縮文件中解碼後的檔案名
+				file.GET("decompress/preview", controllers.PreviewDecompress)
+				// 瀏覽壓縮文件
+				file.GET("decompress/entries", controllers.ListArchiveEntries)
+				// 下載壓縮文件中的單個文件
+				file.GET("decompress/entry", controllers.DownloadArchiveEntry)
 				// 建立文件解壓縮任務
 				file.GET("search/:type/:keywords", controllers.SearchFile)
+				// 使用查詢語法搜尋文件
+				file.GET("search", controllers.QueryFile)
+				// 列出文件的歷史版本
+				file.GET("version/:id", controllers.ListVersions)
+				// 預覽文件的歷史版本
+				file.GET("version/:id/:version/preview", controllers.PreviewVersion)
+				// 獲取文字文件歷史版本的內容
+				file.GET("version/:id/:version/content", controllers.PreviewVersionText)
+				// 建立歷史版本下載工作階段
+				file.PUT("version/:id/:version/download", controllers.CreateVersionDownloadSession)
+				// 還原到歷史版本
+				file.POST("version/:id/:version/restore", controllers.RestoreVersion)
+				// 刪除歷史版本
+				file.DELETE("version/:id/:version", controllers.DeleteVersion)
 			}
 
 			// 離線下載任務
@@ -515,6 +582,12 @@ func InitMasterRouter() *gin.Engine {
 				object.POST("rename", controllers.Rename)
 				// 獲取物件屬性
 				object.GET("property/:id", controllers.GetProperty)
+				// 列出回收站
+				object.GET("recycle", controllers.ListRecycle)
+				// 復原回收站中的物件
+				object.POST("recycle/restore", controllers.RestoreRecycle)
+				// 徹底刪除回收站中的物件
+				object.DELETE("recycle", controllers.DeleteRecycle)
 			}
 
 			// 分享
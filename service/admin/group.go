@@ -5,6 +5,7 @@ import (
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 )
 
 // AddGroupService 使用者群組添加服務
@@ -56,6 +57,16 @@ func (service *GroupService) Delete() serializer.Response {
 
 // Add 添加使用者群組
 func (service *AddGroupService) Add() serializer.Response {
+	// 上傳規則只能選擇使用者群組可用的儲存策略
+	for _, rule := range service.Group.OptionsSerialized.PolicyRules {
+		if !util.ContainsUint(service.Group.PolicyList, rule.PolicyID) {
+			return serializer.ParamErr(fmt.Sprintf("上傳規則使用的儲存策略 %d 不在使用者群組可用的儲存策略中", rule.PolicyID), nil)
+		}
+		if rule.MaxSize > 0 && rule.MinSize > rule.MaxSize {
+			return serializer.ParamErr("上傳規則的檔案大小下限不能大於上限", nil)
+		}
+	}
+
 	if service.Group.ID > 0 {
 		if err := model.DB.Save(&service.Group).Error; err != nil {
 			return serializer.ParamErr("使用者群組儲存失敗", err)
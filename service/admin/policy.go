@@ -3,6 +3,7 @@ package admin
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,14 +13,21 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/azblob"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/cos"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/encrypt"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/onedrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/oss"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/readcache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/s3"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/sftp"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/webdav"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -86,6 +94,15 @@ func (service *PolicyService) Delete() serializer.Response {
 		return serializer.ParamErr(fmt.Sprintf("有 %d 個使用者群組綁定了此儲存策略，請先解除綁定", len(groups)), nil)
 	}
 
+	// 檢查鏡像儲存策略使用
+	var mirrors []model.Policy
+	model.DB.Where("type = ?", "mirror").Find(&mirrors)
+	for _, mirror := range mirrors {
+		if util.ContainsUint(mirror.OptionsSerialized.MirrorPolicies, service.ID) {
+			return serializer.ParamErr(fmt.Sprintf("鏡像儲存策略 [%s] 使用了此儲存策略，請先移除", mirror.Name), nil)
+		}
+	}
+
 	model.DB.Delete(&policy)
 	policy.ClearCache()
 
@@ -253,6 +270,127 @@ func (service *SlaveTestService) Test() serializer.Response {
 
 // Add 添加儲存策略
 func (service *AddPolicyService) Add() serializer.Response {
+	// 備用儲存策略須為其他已存在的儲存策略
+	if fallback := service.Policy.OptionsSerialized.FallbackPolicy; fallback > 0 {
+		if fallback == service.Policy.ID {
+			return serializer.ParamErr("備用儲存策略不能是自身", nil)
+		}
+		if _, err := model.GetPolicyByID(fallback); err != nil {
+			return serializer.Err(serializer.CodeNotFound, "備用儲存策略不存在", err)
+		}
+	}
+
+	// 生命週期規則
+	for _, rule := range service.Policy.OptionsSerialized.LifecycleRules {
+		if rule.Days <= 0 {
+			return serializer.ParamErr("生命週期規則的天數必須大於0", nil)
+		}
+		switch rule.Action {
+		case model.LifecycleMove:
+			if rule.TargetPolicy == 0 || rule.TargetPolicy == service.Policy.ID {
+				return serializer.ParamErr("生命週期規則的目標儲存策略不能是自身", nil)
+			}
+			if _, err := model.GetPolicyByID(rule.TargetPolicy); err != nil {
+				return serializer.Err(serializer.CodeNotFound, "生命週期規則的目標儲存策略不存在", err)
+			}
+		case model.LifecycleExpire:
+		default:
+			return serializer.ParamErr("未知的生命週期規則動作", nil)
+		}
+	}
+
+	// 鏡像儲存策略的成員
+	if service.Policy.Type == "mirror" {
+		members := service.Policy.OptionsSerialized.MirrorPolicies
+		if len(members) < 2 {
+			return serializer.ParamErr("鏡像儲存策略至少需要兩個成員儲存策略", nil)
+		}
+		added := make(map[uint]bool, len(members))
+		for _, id := range members {
+			if id == service.Policy.ID || added[id] {
+				return serializer.ParamErr("鏡像儲存策略的成員不能重複或是自身", nil)
+			}
+			added[id] = true
+
+			member, err := model.GetPolicyByID(id)
+			if err != nil {
+				return serializer.Err(serializer.CodeNotFound, "鏡像儲存策略的成員不存在", err)
+			}
+			if member.Type == "mirror" {
+				return serializer.ParamErr(filesystem.ErrMirrorNested.Error(), nil)
+			}
+		}
+	}
+
+	// SFTP 連線設定
+	if service.Policy.Type == "sftp" {
+		if service.Policy.Server == "" {
+			return serializer.ParamErr("SFTP 伺服器地址不能為空", nil)
+		}
+		// 至少保留兩個連線，避免上傳與生成縮圖等操作互相等待
+		if maxConns := service.Policy.OptionsSerialized.SFTPMaxConns; maxConns != 0 && maxConns < 2 {
+			return serializer.ParamErr("SFTP 最大連線數不能小於 2", nil)
+		}
+		if _, err := sftp.ClientConfig(&service.Policy); err != nil {
+			return serializer.ParamErr(err.Error(), err)
+		}
+	}
+
+	// WebDAV 伺服器地址及認證方式
+	if service.Policy.Type == "webdav" {
+		if _, err := webdav.NewClient(&service.Policy); err != nil {
+			return serializer.ParamErr(err.Error(), err)
+		}
+	}
+
+	// S3 服務端分塊上傳設定
+	if service.Policy.Type == "s3" {
+		partSize := service.Policy.OptionsSerialized.S3PartSize
+		if partSize != 0 && partSize < s3manager.MinUploadPartSize {
+			return serializer.ParamErr(fmt.Sprintf("分塊大小不能小於 %d 位元組", s3manager.MinUploadPartSize), nil)
+		}
+		if service.Policy.OptionsSerialized.S3PartConcurrency < 0 {
+			return serializer.ParamErr("分塊上傳的並行數不能小於0", nil)
+		}
+	}
+
+	// Azure Blob 帳戶金鑰
+	if service.Policy.Type == "azblob" {
+		if _, err := base64.StdEncoding.DecodeString(service.Policy.SecretKey); err != nil || service.Policy.SecretKey == "" {
+			return serializer.ParamErr(azblob.ErrInvalidAccountKey.Error(), err)
+		}
+	}
+
+	// 讀取快取
+	if service.Policy.OptionsSerialized.ReadCache {
+		if service.Policy.Type == "local" || service.Policy.Type == "mirror" {
+			return serializer.ParamErr("讀取快取僅對遠端儲存策略有效", nil)
+		}
+		if readcache.Default == nil {
+			return serializer.ParamErr("讀取快取未啟用，請在配置檔案 [ReadCache] 分區中設定 MaxSize", nil)
+		}
+	}
+
+	// 加密設定
+	if service.Policy.OptionsSerialized.Encrypt {
+		if _, err := encrypt.LoadMasterKey(); err != nil {
+			return serializer.ParamErr(err.Error(), err)
+		}
+	}
+	if service.Policy.ID > 0 {
+		origin, err := model.GetPolicyByID(service.Policy.ID)
+		if err == nil && origin.OptionsSerialized.Encrypt != service.Policy.OptionsSerialized.Encrypt {
+			// 已有文件的加密狀態與設定不符時將無法讀取
+			files, err := model.GetFilesByPolicy(service.Policy.ID, 0, 1)
+			if err != nil {
+				return serializer.DBErr("無法列取儲存策略中的文件", err)
+			}
+			if len(files) > 0 {
+				return serializer.ParamErr("儲存策略中已有文件，無法變更加密設定，請建立新的儲存策略後遷移文件", nil)
+			}
+		}
+	}
+
 	if service.Policy.Type != "local" && service.Policy.Type != "remote" {
 		service.Policy.DirNameRule = strings.TrimPrefix(service.Policy.DirNameRule, "/")
 	}
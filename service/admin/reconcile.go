@@ -0,0 +1,208 @@
+package admin
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// ReconcileTaskService 對帳任務
+type ReconcileTaskService struct {
+	PolicyID uint `json:"policy_id" binding:"required"`
+}
+
+// ReconcileOrphanService 處理對帳報告中的孤立文件
+type ReconcileOrphanService struct {
+	TaskID  uint     `json:"task_id" binding:"required"`
+	Sources []string `json:"sources" binding:"min=1"`
+	UID     uint     `json:"uid"`
+	Dst     string   `json:"dst" binding:"max=65535"`
+}
+
+// ReconcileDanglingService 處理對帳報告中遺失物理文件的記錄
+type ReconcileDanglingService struct {
+	ID []uint `json:"id" binding:"min=1"`
+}
+
+// Create 建立對帳任務
+func (service *ReconcileTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
+	if _, err := model.GetPolicyByID(service.PolicyID); err != nil {
+		return serializer.Err(serializer.CodeNotFound, "儲存策略不存在", err)
+	}
+
+	job, err := task.NewReconcileTask(user.ID, service.PolicyID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "任務建立失敗", err)
+	}
+	task.TaskPoll.Submit(job)
+	return serializer.Response{}
+}
+
+// orphans 確認待處理的文件均列於對帳報告的孤立文件中，並去除此後又被引用的部分，
+// 返回報告所屬的儲存策略
+func (service *ReconcileOrphanService) orphans() (*model.Policy, []string, serializer.Response) {
+	record, err := model.GetTasksByID(service.TaskID)
+	if err != nil || record.Type != task.ReconcileTaskType {
+		return nil, nil, serializer.Err(serializer.CodeNotFound, "對帳任務不存在", err)
+	}
+
+	job, err := task.NewReconcileTaskFromModel(record)
+	if err != nil {
+		return nil, nil, serializer.Err(serializer.CodeNotSet, "無法解析對帳任務", err)
+	}
+	props := job.(*task.ReconcileTask).TaskProps
+	if props.Report == nil {
+		return nil, nil, serializer.ParamErr("對帳任務尚未完成", nil)
+	}
+
+	listed := make(map[string]bool, len(props.Report.Orphans))
+	for _, orphan := range props.Report.Orphans {
+		listed[orphan.Source] = true
+	}
+	for _, source := range service.Sources {
+		if !listed[source] {
+			return nil, nil, serializer.ParamErr("文件 "+source+" 不在對帳報告的孤立文件中", nil)
+		}
+	}
+
+	policy, err := model.GetPolicyByID(props.PolicyID)
+	if err != nil {
+		return nil, nil, serializer.Err(serializer.CodeNotFound, "儲存策略不存在", err)
+	}
+
+	sources, err := task.UnreferencedSources(&policy, service.Sources)
+	if err != nil {
+		return nil, nil, serializer.DBErr("無法查詢文件引用", err)
+	}
+	if len(sources) == 0 {
+		return nil, nil, serializer.ParamErr("給定的文件均已被引用", nil)
+	}
+
+	return &policy, sources, serializer.Response{}
+}
+
+// Delete 刪除對帳報告中孤立的物理文件
+func (service *ReconcileOrphanService) Delete(c *gin.Context) serializer.Response {
+	policy, sources, res := service.orphans()
+	if res.Code != 0 {
+		return res
+	}
+
+	fs, err := filesystem.NewFileSystem(&model.User{Policy: *policy})
+	if err != nil {
+		return serializer.Err(serializer.CodeInternalSetting, "無法建立文件系統", err)
+	}
+	defer fs.Recycle()
+
+	failed, err := fs.Handler.Delete(context.Background(), sources)
+	if err != nil {
+		res := serializer.Err(serializer.CodeNotFullySuccess, "部分文件未能刪除", err)
+		res.Data = failed
+		return res
+	}
+
+	return serializer.Response{}
+}
+
+// Import 將對帳報告中孤立的物理文件匯入到使用者文件系統
+func (service *ReconcileOrphanService) Import(c *gin.Context) serializer.Response {
+	if service.UID == 0 || service.Dst == "" {
+		return serializer.ParamErr("請指定匯入的使用者及目錄", nil)
+	}
+
+	policy, sources, res := service.orphans()
+	if res.Code != 0 {
+		return res
+	}
+
+	root, err := task.ReconcileImportRoot(policy, sources)
+	if err != nil {
+		return serializer.Err(serializer.CodeInternalSetting, err.Error(), err)
+	}
+
+	job, err := task.NewImportSourcesTask(service.UID, policy.ID, root, service.Dst, sources)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "任務建立失敗", err)
+	}
+	task.TaskPoll.Submit(job)
+	return serializer.Response{}
+}
+
+// Delete 刪除遺失物理文件的記錄，並歸還使用者容量。
+// 刪除前重新確認物理文件不存在，回收站中的記錄需在回收站中徹底刪除
+func (service *ReconcileDanglingService) Delete(c *gin.Context) serializer.Response {
+	files, err := model.GetFilesByIDs(service.ID, 0)
+	if err != nil {
+		return serializer.DBErr("無法列出待刪除文件", err)
+	}
+
+	// 根據儲存策略分組，重新確認物理文件是否存在
+	policyFile := make(map[uint][]model.File)
+	for _, file := range files {
+		policyFile[file.PolicyID] = append(policyFile[file.PolicyID], file)
+	}
+
+	userFile := make(map[uint][]uint)
+	existed := make([]uint, 0)
+	for policyID, files := range policyFile {
+		policy, err := model.GetPolicyByID(policyID)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotFound, "儲存策略不存在", err)
+		}
+
+		fs, err := filesystem.NewFileSystem(&model.User{Policy: policy})
+		if err != nil {
+			return serializer.Err(serializer.CodeInternalSetting, "無法建立文件系統", err)
+		}
+
+		sources := make([]string, len(files))
+		for i, file := range files {
+			sources[i] = file.SourceName
+		}
+		missing, err := task.MissingSources(context.Background(), fs.Handler, &policy, sources)
+		fs.Recycle()
+		if err != nil {
+			return serializer.Err(serializer.CodeNotSet, "無法列取物理文件", err)
+		}
+
+		for _, file := range files {
+			if util.ContainsString(missing, file.SourceName) {
+				userFile[file.UserID] = append(userFile[file.UserID], file.ID)
+			} else {
+				existed = append(existed, file.ID)
+			}
+		}
+	}
+
+	for uid, ids := range userFile {
+		user, err := model.GetUserByID(uid)
+		if err != nil {
+			continue
+		}
+
+		fs, err := filesystem.NewFileSystem(&user)
+		if err != nil {
+			return serializer.Err(serializer.CodeInternalSetting, "無法建立文件系統", err)
+		}
+
+		// 物理文件已不存在，強制刪除記錄
+		err = fs.Delete(context.Background(), []uint{}, ids, true)
+		fs.Recycle()
+		if err != nil {
+			return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+		}
+	}
+
+	if len(existed) > 0 {
+		res := serializer.Err(serializer.CodeNotFullySuccess, "部分文件的物理文件仍然存在，未刪除記錄", nil)
+		res.Data = existed
+		return res
+	}
+
+	return serializer.Response{}
+}
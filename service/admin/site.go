@@ -7,6 +7,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/email"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 )
 
@@ -98,6 +99,12 @@ func (service *NoParamService) Summary() serializer.Response {
 	model.DB.Model(&model.Share{}).Where("password = ?", "").Count(&publicShareTotal)
 	model.DB.Model(&model.Share{}).Where("password <> ?", "").Count(&secretShareTotal)
 
+	// 統計儲存策略用量
+	policies, err := policyUsageSummary()
+	if err != nil {
+		return serializer.DBErr("無法統計儲存策略用量", err)
+	}
+
 	// 獲取版本訊息
 	versions := map[string]string{
 		"backend": conf.BackendVersion,
@@ -118,6 +125,49 @@ func (service *NoParamService) Summary() serializer.Response {
 			"userTotal":        userTotal,
 			"publicShareTotal": publicShareTotal,
 			"secretShareTotal": secretShareTotal,
+			"policies":         policies,
 		},
 	}
 }
+
+// PolicyUsage 儲存策略的用量
+type PolicyUsage struct {
+	ID       uint                     `json:"id"`
+	Name     string                   `json:"name"`
+	Type     string                   `json:"type"`
+	Used     uint64                   `json:"used"`
+	Capacity uint64                   `json:"capacity"`
+	Fallback uint                     `json:"fallback"`
+	Health   *filesystem.PolicyHealth `json:"health,omitempty"`
+}
+
+// policyUsageSummary 統計所有儲存策略的已用容量及最近一次健康檢查結果
+func policyUsageSummary() ([]PolicyUsage, error) {
+	var policies []model.Policy
+	if err := model.DB.Find(&policies).Error; err != nil {
+		return nil, err
+	}
+
+	usage, err := model.GetPoliciesUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]PolicyUsage, 0, len(policies))
+	for _, policy := range policies {
+		item := PolicyUsage{
+			ID:       policy.ID,
+			Name:     policy.Name,
+			Type:     policy.Type,
+			Used:     usage[policy.ID],
+			Capacity: policy.OptionsSerialized.Capacity,
+			Fallback: policy.OptionsSerialized.FallbackPolicy,
+		}
+		if health, ok := filesystem.GetPolicyHealth(policy.ID); ok {
+			item.Health = &health
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
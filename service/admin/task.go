@@ -2,6 +2,7 @@ package admin
 
 import (
 	"strings"
+	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
@@ -34,6 +35,78 @@ func (service *ImportTaskService) Create(c *gin.Context, user *model.User) seria
 	return serializer.Response{}
 }
 
+// ScrubTaskService 儲存完整性檢查任務
+type ScrubTaskService struct {
+	PolicyIDs  []uint `json:"policy_ids"`
+	VerifyHash bool   `json:"verify_hash"`
+	MarkBroken bool   `json:"mark_broken"`
+}
+
+// Create 建立儲存完整性檢查任務
+func (service *ScrubTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
+	job, err := task.NewScrubTask(user.ID, service.PolicyIDs, service.VerifyHash, service.MarkBroken)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "任務建立失敗", err)
+	}
+	task.TaskPoll.Submit(job)
+	return serializer.Response{}
+}
+
+// MigrateTaskService 儲存策略間的文件遷移任務
+type MigrateTaskService struct {
+	SrcPolicy    uint `json:"src_policy" binding:"required"`
+	DstPolicy    uint `json:"dst_policy" binding:"required,nefield=SrcPolicy"`
+	UserID       uint `json:"user_id"`
+	GroupID      uint `json:"group_id"`
+	IdleDays     int  `json:"idle_days" binding:"min=0"`
+	DeleteSource bool `json:"delete_source"`
+}
+
+// Create 建立文件遷移任務
+func (service *MigrateTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
+	for _, id := range []uint{service.SrcPolicy, service.DstPolicy} {
+		if _, err := model.GetPolicyByID(id); err != nil {
+			return serializer.Err(serializer.CodeNotFound, "儲存策略不存在", err)
+		}
+	}
+
+	// 設定閒置天數時只遷移超過天數未存取的文件
+	filter := model.MigrateFilter{UserID: service.UserID, GroupID: service.GroupID}
+	if service.IdleDays > 0 {
+		filter.IdleBefore = time.Now().AddDate(0, 0, -service.IdleDays)
+	}
+
+	job, err := task.NewMigrateTask(user.ID, service.SrcPolicy, service.DstPolicy, filter, service.DeleteSource)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "任務建立失敗", err)
+	}
+	task.TaskPoll.Submit(job)
+	return serializer.Response{}
+}
+
+// MirrorRepairTaskService 鏡像儲存策略修復任務
+type MirrorRepairTaskService struct {
+	PolicyID uint `json:"policy_id" binding:"required"`
+}
+
+// Create 建立鏡像儲存策略修復任務
+func (service *MirrorRepairTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
+	policy, err := model.GetPolicyByID(service.PolicyID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "儲存策略不存在", err)
+	}
+	if policy.Type != "mirror" {
+		return serializer.ParamErr(task.ErrNotMirrorPolicy.Error(), nil)
+	}
+
+	job, err := task.NewMirrorRepairTask(user.ID, service.PolicyID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "任務建立失敗", err)
+	}
+	task.TaskPoll.Submit(job)
+	return serializer.Response{}
+}
+
 // Delete 刪除任務
 func (service *TaskBatchService) Delete(c *gin.Context) serializer.Response {
 	if err := model.DB.Where("id in (?)", service.ID).Delete(&model.Download{}).Error; err != nil {
@@ -68,6 +68,11 @@ func (service *UserBatchService) Delete() serializer.Response {
 		}
 		fs.Delete(context.Background(), []uint{root.ID}, []uint{}, false)
 
+		// 清空回收站
+		if recycles, err := model.GetRecyclesByUID(uid); err == nil {
+			fs.DeleteRecycles(context.Background(), recycles)
+		}
+
 		// 刪除相關任務
 		model.DB.Where("user_id = ?", uid).Delete(&model.Download{})
 		model.DB.Where("user_id = ?", uid).Delete(&model.Task{})
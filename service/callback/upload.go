@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/azblob"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/cos"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/onedrive"
@@ -68,6 +69,10 @@ type S3Callback struct {
 	Key    string `form:"key"`
 }
 
+// AzblobCallback Azure Blob 用戶端回調正文
+type AzblobCallback struct {
+}
+
 // GetBody 返回回調正文
 func (service UpyunCallbackService) GetBody(session *serializer.UploadSession) serializer.UploadCallback {
 	res := serializer.UploadCallback{
@@ -126,6 +131,16 @@ func (service S3Callback) GetBody(session *serializer.UploadSession) serializer.
 	}
 }
 
+// GetBody 返回回調正文
+func (service AzblobCallback) GetBody(session *serializer.UploadSession) serializer.UploadCallback {
+	return serializer.UploadCallback{
+		Name:       session.Name,
+		SourceName: session.SavePath,
+		PicInfo:    "",
+		Size:       session.Size,
+	}
+}
+
 // ProcessCallback 處理上傳結果回調
 func ProcessCallback(service CallbackProcessService, c *gin.Context) serializer.Response {
 	// 建立文件系統
@@ -160,6 +175,9 @@ func ProcessCallback(service CallbackProcessService, c *gin.Context) serializer.
 	// 生成上下文
 	ctx := context.WithValue(context.Background(), fsctx.FileHeaderCtx, fileHeader)
 	ctx = context.WithValue(ctx, fsctx.SavePathCtx, callbackBody.SourceName)
+	if callbackBody.Hash != "" {
+		ctx = context.WithValue(ctx, fsctx.FileHashCtx, callbackBody.Hash)
+	}
 
 	// 添加鉤子
 	fs.Use("BeforeAddFile", filesystem.HookValidateFile)
@@ -181,6 +199,9 @@ func ProcessCallback(service CallbackProcessService, c *gin.Context) serializer.
 		}
 	}
 
+	// 索引文件內容
+	filesystem.IndexContent(*file)
+
 	return serializer.Response{
 		Code: 0,
 	}
@@ -200,7 +221,11 @@ func (service *OneDriveCallback) PreProcess(c *gin.Context) serializer.Response
 	callbackSession := callbackSessionRaw.(*serializer.UploadSession)
 
 	// 獲取文件訊息
-	info, err := fs.Handler.(onedrive.Driver).Client.Meta(context.Background(), service.ID, "")
+	handler, ok := fs.BaseHandler().(onedrive.Driver)
+	if !ok {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "儲存策略不符", nil)
+	}
+	info, err := handler.Client.Meta(context.Background(), service.ID, "")
 	if err != nil {
 		return serializer.Err(serializer.CodeUploadFailed, "文件元訊息查詢失敗", err)
 	}
@@ -216,7 +241,7 @@ func (service *OneDriveCallback) PreProcess(c *gin.Context) serializer.Response
 	}
 
 	if isSizeCheckFailed || info.GetSourcePath() != actualPath {
-		fs.Handler.(onedrive.Driver).Client.Delete(context.Background(), []string{info.GetSourcePath()})
+		handler.Client.Delete(context.Background(), []string{info.GetSourcePath()})
 		return serializer.Err(serializer.CodeUploadFailed, "文件訊息不一致", err)
 	}
 	service.Meta = info
@@ -237,7 +262,11 @@ func (service *COSCallback) PreProcess(c *gin.Context) serializer.Response {
 	callbackSession := callbackSessionRaw.(*serializer.UploadSession)
 
 	// 獲取文件訊息
-	info, err := fs.Handler.(cos.Driver).Meta(context.Background(), callbackSession.SavePath)
+	handler, ok := fs.BaseHandler().(cos.Driver)
+	if !ok {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "儲存策略不符", nil)
+	}
+	info, err := handler.Meta(context.Background(), callbackSession.SavePath)
 	if err != nil {
 		return serializer.Err(serializer.CodeUploadFailed, "文件訊息不一致", err)
 	}
@@ -264,7 +293,11 @@ func (service *S3Callback) PreProcess(c *gin.Context) serializer.Response {
 	callbackSession := callbackSessionRaw.(*serializer.UploadSession)
 
 	// 獲取文件訊息
-	info, err := fs.Handler.(s3.Driver).Meta(context.Background(), callbackSession.SavePath)
+	handler, ok := fs.BaseHandler().(s3.Driver)
+	if !ok {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "儲存策略不符", nil)
+	}
+	info, err := handler.Meta(context.Background(), callbackSession.SavePath)
 	if err != nil {
 		return serializer.Err(serializer.CodeUploadFailed, "文件訊息不一致", err)
 	}
@@ -276,3 +309,35 @@ func (service *S3Callback) PreProcess(c *gin.Context) serializer.Response {
 
 	return ProcessCallback(service, c)
 }
+
+// PreProcess 對 Azure Blob 用戶端回調進行預處理
+func (service *AzblobCallback) PreProcess(c *gin.Context) serializer.Response {
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromCallback(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	// 獲取回調工作階段
+	callbackSessionRaw, _ := c.Get("callbackSession")
+	callbackSession := callbackSessionRaw.(*serializer.UploadSession)
+
+	// 獲取文件訊息
+	handler, ok := fs.BaseHandler().(azblob.Driver)
+	if !ok {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "儲存策略不符", nil)
+	}
+	info, err := handler.Meta(context.Background(), callbackSession.SavePath)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, "文件訊息不一致", err)
+	}
+
+	// 驗證實際文件訊息與回調工作階段中是否一致，SAS 無法限制檔案大小，不一致時刪除文件
+	if callbackSession.Size != info.Size {
+		handler.Delete(context.Background(), []string{callbackSession.SavePath})
+		return serializer.Err(serializer.CodeUploadFailed, "文件訊息不一致", nil)
+	}
+
+	return ProcessCallback(service, c)
+}
@@ -0,0 +1,266 @@
+package explorer
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateUploadSessionService 建立分片上傳工作階段服務
+type CreateUploadSessionService struct {
+	Path string `json:"path" binding:"required"`
+	Size uint64 `json:"size" binding:"min=0"`
+	Name string `json:"name" binding:"required"`
+}
+
+// UploadSessionService 分片上傳工作階段相關服務
+type UploadSessionService struct {
+	ID    string `uri:"sessionId" binding:"required"`
+	Index int    `uri:"index" binding:"min=0"`
+}
+
+// Create 建立分片上傳工作階段
+func (service *CreateUploadSessionService) Create(ctx context.Context, c *gin.Context) serializer.Response {
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	if fs.User.Policy.ClientType() != "local" {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "目前儲存策略不支援分片上傳", nil)
+	}
+
+	// 預先檢查文件及容量
+	fileData := local.FileStream{
+		Size:        service.Size,
+		Name:        service.Name,
+		VirtualPath: service.Path,
+	}
+	ctx = context.WithValue(ctx, fsctx.FileHeaderCtx, fileData)
+	if err := filesystem.HookValidateFile(ctx, fs); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	// 暫存分片不計入已用容量，需一併為使用者其他未完成的工作階段預留容量
+	reserved := local.FileStream{Size: service.Size + filesystem.PendingChunkSize(fs.User.ID)}
+	if err := filesystem.HookValidateCapacityWithoutIncrease(
+		context.WithValue(ctx, fsctx.FileHeaderCtx, reserved), fs); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	session, err := filesystem.NewChunkSession(serializer.UploadSession{
+		UID:         fs.User.ID,
+		PolicyID:    fs.User.Policy.ID,
+		VirtualPath: service.Path,
+		Name:        service.Name,
+		Size:        service.Size,
+	})
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{Data: filesystem.ChunkStatus(session)}
+}
+
+// session 獲取屬於使用者的分片上傳工作階段
+func (service *UploadSessionService) session(user *model.User) (*serializer.UploadSession, serializer.Response) {
+	session, err := filesystem.GetChunkSession(service.ID)
+	if err != nil || session.UID != user.ID {
+		return nil, serializer.Err(serializer.CodeNotFound, filesystem.ErrUploadSessionNotExist.Error(), err)
+	}
+	return session, serializer.Response{}
+}
+
+// Status 查詢分片上傳工作階段狀態
+func (service *UploadSessionService) Status(c *gin.Context, user *model.User) serializer.Response {
+	session, res := service.session(user)
+	if res.Code != 0 {
+		return res
+	}
+
+	return serializer.Response{Data: filesystem.ChunkStatus(session)}
+}
+
+// Upload 上傳單個分片，分片內容為請求正文
+func (service *UploadSessionService) Upload(c *gin.Context, user *model.User) serializer.Response {
+	session, res := service.session(user)
+	if res.Code != 0 {
+		return res
+	}
+
+	if err := filesystem.SaveChunk(session, service.Index, c.Request.Body); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// Finish 合併所有分片並完成上傳
+func (service *UploadSessionService) Finish(ctx context.Context, c *gin.Context) serializer.Response {
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	session, res := service.session(fs.User)
+	if res.Code != 0 {
+		return res
+	}
+
+	if session.PolicyID != fs.User.Policy.ID {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "儲存策略已變更，請重新上傳", nil)
+	}
+
+	chunks, err := filesystem.OpenChunks(session)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+	defer chunks.Close()
+
+	fileData := local.FileStream{
+		File:        chunks,
+		Size:        session.Size,
+		Name:        session.Name,
+		VirtualPath: session.VirtualPath,
+	}
+
+	// 給文件系統分配鉤子
+	fs.Use("BeforeUpload", filesystem.HookRoutePolicy)
+	fs.Use("BeforeUpload", filesystem.HookValidateFile)
+	fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+	fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
+	fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
+	fs.Use("AfterUpload", filesystem.GenericAfterUpload)
+	fs.Use("AfterUpload", filesystem.HookIndexContent)
+	fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+	fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+	fs.Use("AfterUploadFailed", filesystem.HookGiveBackCapacity)
+
+	// 執行上傳
+	ctx = context.WithValue(ctx, fsctx.ValidateCapacityOnceCtx, &sync.Once{})
+	ctx = context.WithValue(ctx, fsctx.DisableOverwrite, true)
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.Upload(ctx, fileData); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	filesystem.DeleteChunkSession(session)
+	return serializer.Response{}
+}
+
+// Delete 取消分片上傳工作階段
+func (service *UploadSessionService) Delete(c *gin.Context, user *model.User) serializer.Response {
+	session, res := service.session(user)
+	if res.Code != 0 {
+		return res
+	}
+
+	filesystem.DeleteChunkSession(session)
+	return serializer.Response{}
+}
+
+// SlaveUploadSessionService 從機分片上傳服務
+type SlaveUploadSessionService struct {
+	ID    string `header:"X-Upload-Session"`
+	Index string `header:"X-Chunk-Index"`
+	Name  string `header:"X-FileName"`
+	Size  uint64 `header:"X-FileSize"`
+}
+
+// Create 建立從機分片上傳工作階段，已指定工作階段時返回其狀態
+func (service *SlaveUploadSessionService) Create(ctx context.Context, fs *filesystem.FileSystem) serializer.Response {
+	if service.ID != "" {
+		session, err := filesystem.GetChunkSession(service.ID)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotFound, err.Error(), err)
+		}
+		return serializer.Response{Data: filesystem.ChunkStatus(session)}
+	}
+
+	// 從機沒有定時任務，建立新工作階段時順帶清理過期的分片
+	filesystem.CollectChunkSessions()
+
+	// 解碼檔案名
+	fileName, err := url.QueryUnescape(service.Name)
+	if err != nil {
+		return serializer.ParamErr("檔案名格式有誤", err)
+	}
+
+	// 預先檢查文件
+	fileData := local.FileStream{
+		Name: fileName,
+		Size: service.Size,
+	}
+	ctx = context.WithValue(ctx, fsctx.FileHeaderCtx, fileData)
+	if err := filesystem.HookSlaveUploadValidate(ctx, fs); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	session, err := filesystem.NewChunkSession(serializer.UploadSession{
+		Name: fileName,
+		Size: service.Size,
+	})
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{Data: filesystem.ChunkStatus(session)}
+}
+
+// Upload 上傳分片，未指定分片序號時合併所有分片並完成上傳
+func (service *SlaveUploadSessionService) Upload(ctx context.Context, fs *filesystem.FileSystem, c *gin.Context) serializer.Response {
+	session, err := filesystem.GetChunkSession(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, err.Error(), err)
+	}
+
+	// 上傳單個分片
+	if service.Index != "" {
+		index, err := strconv.Atoi(service.Index)
+		if err != nil {
+			return serializer.ParamErr("分片序號格式有誤", err)
+		}
+
+		if err := filesystem.SaveChunk(session, index, c.Request.Body); err != nil {
+			return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+		}
+		return serializer.Response{}
+	}
+
+	chunks, err := filesystem.OpenChunks(session)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+	defer chunks.Close()
+
+	fileData := local.FileStream{
+		File: chunks,
+		Name: session.Name,
+		Size: session.Size,
+	}
+
+	// 給文件系統分配鉤子
+	fs.Use("BeforeUpload", filesystem.HookSlaveUploadValidate)
+	fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
+	fs.Use("AfterUpload", filesystem.SlaveAfterUpload)
+	fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+
+	if err := fs.Upload(ctx, fileData); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	filesystem.DeleteChunkSession(session)
+	return serializer.Response{}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 )
@@ -81,8 +82,10 @@ func (service *SingleFileService) Create(c *gin.Context) serializer.Response {
 	ctx = context.WithValue(ctx, fsctx.DisableOverwrite, true)
 
 	// 給文件系統分配鉤子
+	fs.Use("BeforeUpload", filesystem.HookRoutePolicy)
 	fs.Use("BeforeUpload", filesystem.HookValidateFile)
 	fs.Use("AfterUpload", filesystem.GenericAfterUpload)
+	fs.Use("AfterUpload", filesystem.HookIndexContent)
 
 	// 上傳空文件
 	err = fs.Upload(ctx, local.FileStream{
@@ -133,6 +136,16 @@ func (service *DownloadService) DownloadArchived(ctx context.Context, c *gin.Con
 		return serializer.Err(404, "歸檔文件不存在", nil)
 	}
 
+	if fs.User.Group.OptionsSerialized.OneTimeDownload {
+		// 清理資源，刪除暫存檔
+		defer cache.Deletes([]string{service.ID}, "archive_")
+	}
+
+	// 串流下載
+	if session, ok := zipPath.(serializer.ArchiveSession); ok {
+		return downloadArchiveStream(ctx, c, &session)
+	}
+
 	// 獲取文件流
 	rs, err := fs.GetPhysicalFileContent(ctx, zipPath.(string))
 	defer rs.Close()
@@ -140,11 +153,6 @@ func (service *DownloadService) DownloadArchived(ctx context.Context, c *gin.Con
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
 
-	if fs.User.Group.OptionsSerialized.OneTimeDownload {
-		// 清理資源，刪除暫存檔
-		_ = cache.Deletes([]string{service.ID}, "archive_")
-	}
-
 	c.Header("Content-Disposition", "attachment;")
 	c.Header("Content-Type", "application/zip")
 	http.ServeContent(c.Writer, c.Request, "", time.Now(), rs)
@@ -155,6 +163,62 @@ func (service *DownloadService) DownloadArchived(ctx context.Context, c *gin.Con
 
 }
 
+// archiveContentTypes 各壓縮文件格式的 MIME 類型
+var archiveContentTypes = map[string]string{
+	filesystem.ArchiveZip:    "application/zip",
+	filesystem.ArchiveTar:    "application/x-tar",
+	filesystem.ArchiveTarGz:  "application/gzip",
+	filesystem.ArchiveTarBz2: "application/x-bzip2",
+	filesystem.ArchiveTarXz:  "application/x-xz",
+}
+
+// downloadArchiveStream 讀取待打包的文件，直接將壓縮文件寫入響應
+func downloadArchiveStream(ctx context.Context, c *gin.Context, session *serializer.ArchiveSession) serializer.Response {
+	// 以打包者的身份讀取文件
+	user, err := model.GetActiveUserByID(session.UID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "使用者不存在", err)
+	}
+	fs, err := filesystem.NewFileSystem(&user)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	if session.ParentID != 0 {
+		ctx = context.WithValue(ctx, fsctx.LimitParentCtx, &model.Folder{Model: gorm.Model{ID: session.ParentID}})
+	}
+
+	files, err := fs.ListArchiveFiles(ctx, session.Dirs, session.Items)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "無法列出待打包文件", err)
+	}
+
+	// 僅歸檔時可預先計算壓縮文件大小
+	isArchive := session.Store || session.Format == filesystem.ArchiveTar
+	if isArchive {
+		size, err := filesystem.ArchiveSize(files, session.Format)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotSet, "無法計算壓縮文件大小", err)
+		}
+		c.Header("Content-Length", strconv.FormatInt(size, 10))
+	}
+
+	c.Header("Content-Disposition", "attachment;")
+	c.Header("Content-Type", archiveContentTypes[session.Format])
+	c.Status(http.StatusOK)
+
+	// 響應已經開始，出錯時只能中斷傳輸
+	if err := fs.WriteArchive(ctx, c.Writer, files, session.Format, isArchive); err != nil {
+		util.Log().Warning("無法完成串流打包下載，%s", err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+	}
+}
+
 // Download 簽名的匿名文件下載
 func (service *FileAnonymousGetService) Download(ctx context.Context, c *gin.Context) serializer.Response {
 	fs, err := filesystem.NewAnonymousFileSystem()
@@ -271,6 +335,12 @@ func (service *FileIDService) CreateDownloadSession(ctx context.Context, c *gin.
 	// 獲取物件id
 	objectID, _ := c.Get("object_id")
 
+	// 如果上下文中已有File物件，則重設目標
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(*model.File); ok {
+		fs.SetTargetFile(&[]model.File{*file})
+		objectID = uint(0)
+	}
+
 	// 獲取下載網址
 	downloadURL, err := fs.GetDownloadURL(ctx, objectID.(uint), "download_timeout")
 	if err != nil {
@@ -416,27 +486,46 @@ func (service *FileIDService) PutContent(ctx context.Context, c *gin.Context) se
 	}
 	fileData.Name = originFile[0].Name
 
-	// 檢查此文件是否有軟連結
-	fileList, err := model.RemoveFilesWithSoftLinks([]model.File{originFile[0]})
-	if err == nil && len(fileList) == 0 {
-		// 如果包含軟連接，應重新生成新文件副本，並更新source_name
-		originFile[0].SourceName = fs.GenerateSavePath(uploadCtx, fileData)
-		fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
-		fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
-		fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
-	}
+	if fs.IsVersionEnabled() {
+		// 保留歷史版本，新內容寫入新的物理文件，原有物理文件由歷史版本持有
+		originFile[0].SourceName = fs.GenerateVersionSavePath(uploadCtx, fileData, &originFile[0])
+
+		// 給文件系統分配鉤子
+		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+		fs.Use("BeforeUpload", filesystem.HookValidateFile)
+		fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+		fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
+		fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
+		fs.Use("AfterUpload", filesystem.HookKeepVersion)
+		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+		fs.Use("AfterUpload", filesystem.HookIndexContent)
+		fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+		fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+		fs.Use("AfterUploadFailed", filesystem.HookGiveBackCapacity)
+	} else {
+		// 檢查此文件是否有軟連結
+		fileList, err := model.RemoveFilesWithSoftLinks([]model.File{originFile[0]})
+		if err == nil && len(fileList) == 0 {
+			// 如果包含軟連接，應重新生成新文件副本，並更新source_name
+			originFile[0].SourceName = fs.GenerateSavePath(uploadCtx, fileData)
+			fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
+			fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
+			fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
+		}
 
-	// 給文件系統分配鉤子
-	fs.Use("BeforeUpload", filesystem.HookResetPolicy)
-	fs.Use("BeforeUpload", filesystem.HookValidateFile)
-	fs.Use("BeforeUpload", filesystem.HookChangeCapacity)
-	fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
-	fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
-	fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
-	fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
-	fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
-	fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
-	fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+		// 給文件系統分配鉤子
+		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+		fs.Use("BeforeUpload", filesystem.HookValidateFile)
+		fs.Use("BeforeUpload", filesystem.HookChangeCapacity)
+		fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
+		fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
+		fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
+		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+		fs.Use("AfterUpload", filesystem.HookIndexContent)
+		fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
+		fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+		fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+	}
 
 	// 執行上傳
 	uploadCtx = context.WithValue(uploadCtx, fsctx.FileModelCtx, originFile[0])
@@ -539,7 +628,11 @@ func (service *SlaveFileService) Thumb(ctx context.Context, c *gin.Context) seri
 	if err != nil {
 		return serializer.ParamErr("無法解析的文件地址", err)
 	}
-	fs.FileTarget = []model.File{{SourceName: string(fileSource), PicInfo: "1,1"}}
+	fs.FileTarget = []model.File{{
+		Name:       path.Base(string(fileSource)),
+		SourceName: string(fileSource),
+		PicInfo:    "1,1",
+	}}
 
 	// 獲取縮圖
 	resp, err := fs.GetThumb(ctx, 0)
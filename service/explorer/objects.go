@@ -3,10 +3,11 @@ package explorer
 import (
 	"context"
 	"fmt"
+	"io"
 	"math"
 	"net/url"
 	"path"
-	"strings"
+	"strconv"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
@@ -47,17 +48,43 @@ type ItemIDService struct {
 	Source *ItemService
 }
 
+// ItemArchiveService 打包下載服務
+type ItemArchiveService struct {
+	ItemIDService
+	Format string `json:"format" binding:"omitempty,eq=zip|eq=tar|eq=tar.gz|eq=tar.bz2|eq=tar.xz"`
+	// Stream 下載時直接將壓縮文件寫入響應，不建立臨時文件
+	Stream bool `json:"stream"`
+	// Store 僅歸檔不壓縮，串流下載時可預先得知大小
+	Store bool `json:"store"`
+}
+
 // ItemCompressService 文件壓縮任務服務
 type ItemCompressService struct {
-	Src  ItemIDService `json:"src"`
-	Dst  string        `json:"dst" binding:"required,min=1,max=65535"`
-	Name string        `json:"name" binding:"required,min=1,max=255"`
+	Src    ItemIDService `json:"src"`
+	Dst    string        `json:"dst" binding:"required,min=1,max=65535"`
+	Name   string        `json:"name" binding:"required,min=1,max=255"`
+	Format string        `json:"format" binding:"omitempty,eq=zip|eq=tar|eq=tar.gz|eq=tar.bz2|eq=tar.xz"`
 }
 
-// ItemDecompressService 文件解壓縮任務服務
+// ItemDecompressService 文件解壓縮任務服務，Entries 為空時解壓縮所有條目
 type ItemDecompressService struct {
-	Src string `json:"src"`
-	Dst string `json:"dst" binding:"required,min=1,max=65535"`
+	Src      string   `json:"src"`
+	Dst      string   `json:"dst" binding:"required,min=1,max=65535"`
+	Encoding string   `json:"encoding" binding:"omitempty,eq=auto|eq=utf-8|eq=gb18030|eq=big5|eq=shift_jis|eq=euc-kr|eq=cp437"`
+	Entries  []string `json:"entries" binding:"max=1000"`
+}
+
+// ItemDecompressPreviewService 預覽壓縮文件內容服務
+type ItemDecompressPreviewService struct {
+	Src      string `form:"src" binding:"required,min=1,max=65535"`
+	Encoding string `form:"encoding" binding:"omitempty,eq=auto|eq=utf-8|eq=gb18030|eq=big5|eq=shift_jis|eq=euc-kr|eq=cp437"`
+}
+
+// ArchiveEntryService 瀏覽壓縮文件條目服務，Name 為要下載的條目路徑
+type ArchiveEntryService struct {
+	Src      string `form:"src" binding:"max=65535"`
+	Name     string `form:"name" binding:"max=65535"`
+	Encoding string `form:"encoding" binding:"omitempty,eq=auto|eq=utf-8|eq=gb18030|eq=big5|eq=shift_jis|eq=euc-kr|eq=cp437"`
 }
 
 // ItemPropertyService 獲取物件屬性服務
@@ -112,32 +139,161 @@ func (service *ItemDecompressService) CreateDecompressTask(c *gin.Context) seria
 		return serializer.Err(serializer.CodeNotFound, "存放路徑不存在", nil)
 	}
 
+	// 檢查壓縮包
+	if err := checkDecompressSource(fs, service.Src); err != nil {
+		return serializer.Err(serializer.CodeParamErr, err.Error(), err)
+	}
+
+	// 建立任務
+	job, err := task.NewDecompressTask(fs.User, service.Src, service.Dst, service.Encoding, service.Entries)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "任務建立失敗", err)
+	}
+	task.TaskPoll.Submit(job)
+
+	return serializer.Response{}
+
+}
+
+// Preview 列出壓縮文件中解碼後的檔案名
+func (service *ItemDecompressPreviewService) Preview(ctx context.Context, c *gin.Context) serializer.Response {
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	// 檢查使用者群組權限
+	if !fs.User.Group.OptionsSerialized.ArchiveTask {
+		return serializer.Err(serializer.CodeGroupNotAllowed, "目前使用者群組無法進行此操作", nil)
+	}
+
+	// 檢查壓縮包
+	if err := checkDecompressSource(fs, service.Src); err != nil {
+		return serializer.Err(serializer.CodeParamErr, err.Error(), err)
+	}
+
+	preview, err := fs.PreviewArchive(ctx, service.Src, service.Encoding)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "無法讀取壓縮文件", err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: preview,
+	}
+}
+
+// List 列出壓縮文件的目錄結構
+func (service *ArchiveEntryService) List(ctx context.Context, c *gin.Context) serializer.Response {
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	if err := service.resetTarget(ctx, fs); err != nil {
+		return serializer.Err(serializer.CodeParamErr, err.Error(), err)
+	}
+
+	listing, err := fs.ListArchive(ctx, service.Src, service.Encoding)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "無法讀取壓縮文件", err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: listing,
+	}
+}
+
+// Download 下載壓縮文件中的單個文件
+func (service *ArchiveEntryService) Download(ctx context.Context, c *gin.Context) serializer.Response {
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	if err := service.resetTarget(ctx, fs); err != nil {
+		return serializer.Err(serializer.CodeParamErr, err.Error(), err)
+	}
+
+	if service.Name == "" {
+		return serializer.ParamErr("未指定要下載的文件", nil)
+	}
+
+	rc, entry, err := fs.OpenArchiveEntry(ctx, service.Src, service.Name, service.Encoding)
+	if err == filesystem.ErrObjectNotExist {
+		return serializer.Err(serializer.CodeNotFound, "壓縮文件中不存在此文件", err)
+	}
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "無法讀取壓縮文件", err)
+	}
+	defer rc.Close()
+
+	c.Header("Content-Disposition", "attachment; filename=\""+url.PathEscape(entry.Name)+"\"")
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Length", strconv.FormatInt(entry.Size, 10))
+	if _, err := io.Copy(c.Writer, rc); err != nil {
+		util.Log().Warning("無法傳輸壓縮文件中的文件 %s，%s", entry.Path, err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+	}
+}
+
+// resetTarget 找到要瀏覽的壓縮文件，上下文中已有分享的文件或目錄時以其為準
+func (service *ArchiveEntryService) resetTarget(ctx context.Context, fs *filesystem.FileSystem) error {
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(*model.File); ok {
+		fs.SetTargetFile(&[]model.File{*file})
+	} else {
+		if folder, ok := ctx.Value(fsctx.FolderModelCtx).(*model.Folder); ok {
+			fs.Root = folder
+			service.Src = ctx.Value(fsctx.PathCtx).(string)
+		} else if !fs.User.Group.OptionsSerialized.ArchiveTask {
+			return serializer.NewError(serializer.CodeGroupNotAllowed, "目前使用者群組無法進行此操作", nil)
+		}
+
+		exist, file := fs.IsFileExist(service.Src)
+		if !exist {
+			return serializer.NewError(serializer.CodeNotFound, "文件不存在", nil)
+		}
+		fs.SetTargetFile(&[]model.File{*file})
+	}
+
+	// 必須是支援的壓縮包格式，實際格式會在讀取時根據文件內容判斷
+	if filesystem.ArchiveFormatFromName(fs.FileTarget[0].Name) == "" {
+		return serializer.NewError(serializer.CodeParamErr, "只能瀏覽 ZIP 或 TAR 格式的壓縮文件", nil)
+	}
+
+	return nil
+}
+
+// checkDecompressSource 檢查壓縮包是否存在、大小是否超過使用者群組限制及格式是否支援
+func checkDecompressSource(fs *filesystem.FileSystem, src string) error {
 	// 壓縮包是否存在
-	exist, file := fs.IsFileExist(service.Src)
+	exist, file := fs.IsFileExist(src)
 	if !exist {
-		return serializer.Err(serializer.CodeNotFound, "文件不存在", nil)
+		return serializer.NewError(serializer.CodeNotFound, "文件不存在", nil)
 	}
 
 	// 文件尺寸限制
 	if fs.User.Group.OptionsSerialized.DecompressSize != 0 && file.Size > fs.User.Group.
 		OptionsSerialized.DecompressSize {
-		return serializer.Err(serializer.CodeParamErr, "文件太大", nil)
+		return serializer.NewError(serializer.CodeParamErr, "文件太大", nil)
 	}
 
-	// 必須是zip壓縮包
-	if !strings.HasSuffix(file.Name, ".zip") {
-		return serializer.Err(serializer.CodeParamErr, "只能解壓 ZIP 格式的壓縮文件", nil)
+	// 必須是支援的壓縮包格式，實際格式會在解壓縮時根據文件內容判斷
+	if filesystem.ArchiveFormatFromName(file.Name) == "" {
+		return serializer.NewError(serializer.CodeParamErr, "只能解壓 ZIP 或 TAR 格式的壓縮文件", nil)
 	}
 
-	// 建立任務
-	job, err := task.NewDecompressTask(fs.User, service.Src, service.Dst)
-	if err != nil {
-		return serializer.Err(serializer.CodeNotSet, "任務建立失敗", err)
-	}
-	task.TaskPoll.Submit(job)
-
-	return serializer.Response{}
-
+	return nil
 }
 
 // CreateCompressTask 建立文件壓縮任務
@@ -154,9 +310,17 @@ func (service *ItemCompressService) CreateCompressTask(c *gin.Context) serialize
 		return serializer.Err(serializer.CodeGroupNotAllowed, "目前使用者群組無法進行此操作", nil)
 	}
 
+	// 未指定格式時根據檔案名判斷，預設為zip
+	if service.Format == "" {
+		service.Format = filesystem.ArchiveFormatFromName(service.Name)
+		if service.Format == "" {
+			service.Format = filesystem.ArchiveZip
+		}
+	}
+
 	// 補齊壓縮文件副檔名（如果沒有）
-	if !strings.HasSuffix(service.Name, ".zip") {
-		service.Name += ".zip"
+	if filesystem.ArchiveFormatFromName(service.Name) != service.Format {
+		service.Name += "." + service.Format
 	}
 
 	// 存放目錄是否存在，是否重名
@@ -194,7 +358,7 @@ func (service *ItemCompressService) CreateCompressTask(c *gin.Context) serialize
 	}
 
 	// 文件尺寸限制
-	if fs.User.Group.OptionsSerialized.DecompressSize != 0 && totalSize > fs.User.Group.
+	if fs.User.Group.OptionsSerialized.CompressSize != 0 && totalSize > fs.User.Group.
 		OptionsSerialized.CompressSize {
 		return serializer.Err(serializer.CodeParamErr, "文件太大", nil)
 	}
@@ -207,7 +371,7 @@ func (service *ItemCompressService) CreateCompressTask(c *gin.Context) serialize
 	}
 
 	// 建立任務
-	job, err := task.NewCompressTask(fs.User, path.Join(service.Dst, service.Name), service.Src.Raw().Dirs,
+	job, err := task.NewCompressTask(fs.User, path.Join(service.Dst, service.Name), service.Format, service.Src.Raw().Dirs,
 		service.Src.Raw().Items)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, "任務建立失敗", err)
@@ -219,7 +383,7 @@ func (service *ItemCompressService) CreateCompressTask(c *gin.Context) serialize
 }
 
 // Archive 建立歸檔
-func (service *ItemIDService) Archive(ctx context.Context, c *gin.Context) serializer.Response {
+func (service *ItemArchiveService) Archive(ctx context.Context, c *gin.Context) serializer.Response {
 	// 建立文件系統
 	fs, err := filesystem.NewFileSystemFromContext(c)
 	if err != nil {
@@ -232,12 +396,36 @@ func (service *ItemIDService) Archive(ctx context.Context, c *gin.Context) seria
 		return serializer.Err(serializer.CodeGroupNotAllowed, "目前使用者群組無法進行此操作", nil)
 	}
 
-	// 開始壓縮
-	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	if service.Format == "" {
+		service.Format = filesystem.ArchiveZip
+	}
+	if service.Store && service.Format != filesystem.ArchiveZip && service.Format != filesystem.ArchiveTar {
+		return serializer.ParamErr("僅 ZIP 及 TAR 格式支援僅歸檔", nil)
+	}
+
 	items := service.Raw()
-	zipFile, err := fs.Compress(ctx, items.Dirs, items.Items, true)
-	if err != nil {
-		return serializer.Err(serializer.CodeNotSet, "無法建立壓縮文件", err)
+	var session interface{}
+	if service.Stream {
+		// 串流下載時記錄待打包物件，下載時再壓縮
+		archiveSession := serializer.ArchiveSession{
+			UID:    fs.User.ID,
+			Dirs:   items.Dirs,
+			Items:  items.Items,
+			Format: service.Format,
+			Store:  service.Store,
+		}
+		if parent, ok := ctx.Value(fsctx.LimitParentCtx).(*model.Folder); ok {
+			archiveSession.ParentID = parent.ID
+		}
+		session = archiveSession
+	} else {
+		// 開始壓縮
+		ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+		zipFile, err := fs.Compress(ctx, items.Dirs, items.Items, service.Format, true)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotSet, "無法建立壓縮文件", err)
+		}
+		session = zipFile
 	}
 
 	// 生成一次性壓縮文件下載網址
@@ -249,13 +437,13 @@ func (service *ItemIDService) Archive(ctx context.Context, c *gin.Context) seria
 	ttl := model.GetIntSetting("archive_timeout", 30)
 	signedURI, err := auth.SignURI(
 		auth.General,
-		fmt.Sprintf("/api/v3/file/archive/%s/archive.zip", zipID),
+		fmt.Sprintf("/api/v3/file/archive/%s/archive.%s", zipID, service.Format),
 		time.Now().Unix()+int64(ttl),
 	)
 	finalURL := siteURL.ResolveReference(signedURI).String()
 
 	// 將壓縮文件記錄存入快取
-	err = cache.Set("archive_"+zipID, zipFile, ttl)
+	err = cache.Set("archive_"+zipID, session, ttl)
 	if err != nil {
 		return serializer.Err(serializer.CodeIOFailed, "無法寫入快取", err)
 	}
@@ -275,9 +463,9 @@ func (service *ItemIDService) Delete(ctx context.Context, c *gin.Context) serial
 	}
 	defer fs.Recycle()
 
-	// 刪除物件
+	// 刪除物件，啟用回收站時移入回收站
 	items := service.Raw()
-	err = fs.Delete(ctx, items.Dirs, items.Items, false)
+	err = fs.Remove(ctx, items.Dirs, items.Items)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
@@ -0,0 +1,99 @@
+package explorer
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// RecycleListService 列出回收站服務
+type RecycleListService struct {
+}
+
+// RecycleService 回收站項目批次操作服務
+type RecycleService struct {
+	Items []string `json:"items" binding:"min=1"`
+}
+
+// RecycleRestoreService 復原回收站項目服務
+type RecycleRestoreService struct {
+	Items []string `json:"items" binding:"min=1"`
+	Dst   string   `json:"dst" binding:"max=65535"`
+}
+
+// Raw 批次解碼HashID，獲取原始ID
+func (service *RecycleService) Raw() []uint {
+	return decodeRecycleIDs(service.Items)
+}
+
+// Raw 批次解碼HashID，獲取原始ID
+func (service *RecycleRestoreService) Raw() []uint {
+	return decodeRecycleIDs(service.Items)
+}
+
+func decodeRecycleIDs(items []string) []uint {
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		id, err := hashid.DecodeHashID(item, hashid.RecycleID)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// List 列出回收站中的項目
+func (service *RecycleListService) List(c *gin.Context, user *model.User) serializer.Response {
+	recycles, err := model.GetRecyclesByUID(user.ID)
+	if err != nil {
+		return serializer.DBErr("無法列出回收站", err)
+	}
+
+	return serializer.BuildRecycleListResponse(recycles, user.Group.OptionsSerialized.RecycleDays)
+}
+
+// Restore 復原回收站項目
+func (service *RecycleRestoreService) Restore(ctx context.Context, c *gin.Context) serializer.Response {
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	recycles, err := model.GetRecyclesByIDs(service.Raw(), fs.User.ID)
+	if err != nil || len(recycles) == 0 {
+		return serializer.Err(serializer.CodeNotFound, "回收站項目不存在", err)
+	}
+
+	if err := fs.Restore(ctx, recycles, service.Dst); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// Delete 徹底刪除回收站項目
+func (service *RecycleService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	recycles, err := model.GetRecyclesByIDs(service.Raw(), fs.User.ID)
+	if err != nil || len(recycles) == 0 {
+		return serializer.Err(serializer.CodeNotFound, "回收站項目不存在", err)
+	}
+
+	if err := fs.DeleteRecycles(ctx, recycles); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
@@ -7,6 +7,7 @@ import (
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/gin-gonic/gin"
 )
@@ -15,6 +16,19 @@ import (
 type ItemSearchService struct {
 	Type     string `uri:"type" binding:"required"`
 	Keywords string `uri:"keywords" binding:"required"`
+	OrderBy  string `form:"order_by" binding:"omitempty,eq=name|eq=size|eq=created_at|eq=updated_at"`
+	Order    string `form:"order" binding:"omitempty,eq=DESC|eq=ASC"`
+	Page     int    `form:"page" binding:"min=0"`
+	PageSize int    `form:"page_size" binding:"min=0,max=1000"`
+}
+
+// ItemQueryService 使用查詢語法搜尋文件的服務
+type ItemQueryService struct {
+	Query    string `form:"q" binding:"required,max=65535"`
+	OrderBy  string `form:"order_by" binding:"omitempty,eq=name|eq=size|eq=created_at|eq=updated_at"`
+	Order    string `form:"order" binding:"omitempty,eq=DESC|eq=ASC"`
+	Page     int    `form:"page" binding:"min=0"`
+	PageSize int    `form:"page_size" binding:"min=0,max=1000"`
 }
 
 // Search 執行搜尋
@@ -26,27 +40,30 @@ func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 	}
 	defer fs.Recycle()
 
+	option := filesystem.SearchOption{
+		OrderBy:  service.OrderBy,
+		Order:    service.Order,
+		Page:     service.Page,
+		PageSize: service.PageSize,
+	}
+
+	var query *search.Node
 	switch service.Type {
 	case "keywords":
-		return service.SearchKeywords(c, fs, "%"+service.Keywords+"%")
-	case "image":
-		return service.SearchKeywords(c, fs, "%.bmp", "%.iff", "%.png", "%.gif", "%.jpg", "%.jpeg", "%.psd", "%.svg", "%.webp")
-	case "video":
-		return service.SearchKeywords(c, fs, "%.mp4", "%.flv", "%.avi", "%.wmv", "%.mkv", "%.rm", "%.rmvb", "%.mov", "%.ogv")
-	case "audio":
-		return service.SearchKeywords(c, fs, "%.mp3", "%.flac", "%.ape", "%.wav", "%.acc", "%.ogg", "%.midi", "%.mid")
-	case "doc":
-		return service.SearchKeywords(c, fs, "%.txt", "%.md", "%.pdf", "%.doc", "%.docx", "%.ppt", "%.pptx", "%.xls", "%.xlsx", "%.pub")
+		query = search.Patterns("%" + service.Keywords + "%")
+	case "content":
+		query = &search.Node{Op: search.OpTerm, Field: search.FieldContent, Value: service.Keywords}
+	case "image", "video", "audio", "doc":
+		query = &search.Node{Op: search.OpTerm, Field: search.FieldType, Value: service.Type}
+	case "query":
+		if query, err = search.Parse(service.Keywords); err != nil {
+			return serializer.ParamErr(err.Error(), err)
+		}
 	case "tag":
 		if tid, err := hashid.DecodeHashID(service.Keywords, hashid.TagID); err == nil {
 			if tag, err := model.GetTagsByID(tid, fs.User.ID); err == nil {
 				if tag.Type == model.FileTagType {
-					exp := strings.Split(tag.Expression, "\n")
-					expInput := make([]interface{}, len(exp))
-					for i := 0; i < len(exp); i++ {
-						expInput[i] = exp[i]
-					}
-					return service.SearchKeywords(c, fs, expInput...)
+					return searchTag(fs, tag, option)
 				}
 			}
 		}
@@ -54,16 +71,53 @@ func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 	default:
 		return serializer.ParamErr("未知搜尋類型", nil)
 	}
+
+	return searchQuery(fs, query, option)
+}
+
+// searchTag 搜尋符合文件分類標籤的文件
+func searchTag(fs *filesystem.FileSystem, tag *model.Tag, option filesystem.SearchOption) serializer.Response {
+	// 舊版標籤的表達式為逐行的檔案名匹配規則
+	if !tag.Query {
+		return searchQuery(fs, search.Patterns(strings.Split(tag.Expression, "\n")...), option)
+	}
+
+	query, err := search.Parse(tag.Expression)
+	if err != nil {
+		return serializer.ParamErr(err.Error(), err)
+	}
+	return searchQuery(fs, query, option)
+}
+
+// Search 使用查詢語法搜尋文件
+func (service *ItemQueryService) Search(c *gin.Context) serializer.Response {
+	query, err := search.Parse(service.Query)
+	if err != nil {
+		return serializer.ParamErr(err.Error(), err)
+	}
+
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	return searchQuery(fs, query, filesystem.SearchOption{
+		OrderBy:  service.OrderBy,
+		Order:    service.Order,
+		Page:     service.Page,
+		PageSize: service.PageSize,
+	})
 }
 
-// SearchKeywords 根據關鍵字搜尋文件
-func (service *ItemSearchService) SearchKeywords(c *gin.Context, fs *filesystem.FileSystem, keywords ...interface{}) serializer.Response {
+// searchQuery 執行查詢並返回結果
+func searchQuery(fs *filesystem.FileSystem, query *search.Node, option filesystem.SearchOption) serializer.Response {
 	// 上下文
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// 獲取子項目
-	objects, err := fs.Search(ctx, keywords...)
+	objects, total, err := fs.SearchQuery(ctx, query, option)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
@@ -73,6 +127,7 @@ func (service *ItemSearchService) SearchKeywords(c *gin.Context, fs *filesystem.
 		Data: map[string]interface{}{
 			"parent":  0,
 			"objects": objects,
+			"total":   total,
 		},
 	}
 }
@@ -6,6 +6,7 @@ import (
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +17,7 @@ type FilterTagCreateService struct {
 	Icon       string `json:"icon" binding:"required,min=1,max=255"`
 	Name       string `json:"name" binding:"required,min=1,max=255"`
 	Color      string `json:"color" binding:"hexcolor|rgb|rgba|hsl"`
+	Query      bool   `json:"query"`
 }
 
 // LinkTagCreateService 目錄捷徑標籤建立服務
@@ -59,13 +61,22 @@ func (service *LinkTagCreateService) Create(c *gin.Context, user *model.User) se
 
 // Create 建立標籤
 func (service *FilterTagCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
-	// 分割表達式，將萬用字元轉換為SQL內的%
-	expressions := strings.Split(service.Expression, "\n")
-	for i := 0; i < len(expressions); i++ {
-		expressions[i] = strings.ReplaceAll(expressions[i], "*", "%")
-		if expressions[i] == "" {
-			return serializer.ParamErr(fmt.Sprintf("第 %d 行包含空的匹配表達式", i+1), nil)
+	expression := service.Expression
+	if service.Query {
+		// 驗證查詢語法
+		if _, err := search.Parse(expression); err != nil {
+			return serializer.ParamErr(err.Error(), err)
 		}
+	} else {
+		// 分割表達式，將萬用字元轉換為SQL內的%
+		expressions := strings.Split(service.Expression, "\n")
+		for i := 0; i < len(expressions); i++ {
+			expressions[i] = strings.ReplaceAll(expressions[i], "*", "%")
+			if expressions[i] == "" {
+				return serializer.ParamErr(fmt.Sprintf("第 %d 行包含空的匹配表達式", i+1), nil)
+			}
+		}
+		expression = strings.Join(expressions, "\n")
 	}
 
 	// 建立標籤
@@ -74,7 +85,8 @@ func (service *FilterTagCreateService) Create(c *gin.Context, user *model.User)
 		Icon:       service.Icon,
 		Color:      service.Color,
 		Type:       model.FileTagType,
-		Expression: strings.Join(expressions, "\n"),
+		Expression: expression,
+		Query:      service.Query,
 		UserID:     user.ID,
 	}
 	id, err := tag.Create()
@@ -2,6 +2,11 @@ package explorer
 
 import (
 	"context"
+	"strings"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
 
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
@@ -17,6 +22,14 @@ type UploadCredentialService struct {
 	Type string `form:"type"`
 }
 
+// UploadCheckService 上傳前依內容雜湊值檢查是否可秒傳的服務
+type UploadCheckService struct {
+	Path string `json:"path" binding:"required"`
+	Name string `json:"name" binding:"required"`
+	Size uint64 `json:"size" binding:"min=0"`
+	Hash string `json:"hash" binding:"required,len=64,hexadecimal"`
+}
+
 // Get 獲取新的上傳憑證
 func (service *UploadCredentialService) Get(ctx context.Context, c *gin.Context) serializer.Response {
 	// 建立文件系統
@@ -27,7 +40,7 @@ func (service *UploadCredentialService) Get(ctx context.Context, c *gin.Context)
 
 	// 儲存策略是否一致
 	if service.Type != "" {
-		if service.Type != fs.User.Policy.Type {
+		if service.Type != fs.User.Policy.ClientType() {
 			return serializer.Err(serializer.CodePolicyNotAllowed, "儲存策略已變更，請重新整理頁面", nil)
 		}
 	}
@@ -43,3 +56,45 @@ func (service *UploadCredentialService) Get(ctx context.Context, c *gin.Context)
 		Data: credential,
 	}
 }
+
+// Check 檢查是否已存在內容相同的物理文件，存在時直接建立文件，返回是否秒傳成功
+func (service *UploadCheckService) Check(ctx context.Context, c *gin.Context) serializer.Response {
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	// 僅憑雜湊值與大小即可取得他人的文件內容，需由管理員明確啟用
+	if !model.IsTrueVal(model.GetSettingByName("instant_upload")) {
+		return serializer.Response{Data: false}
+	}
+
+	// 只在使用者可用的儲存策略中尋找
+	origin, err := model.GetFileByHash(strings.ToLower(service.Hash), service.Size, fs.User.Group.PolicyList)
+	if err != nil {
+		return serializer.Response{Data: false}
+	}
+
+	fileData := local.FileStream{
+		Size:        service.Size,
+		Name:        service.Name,
+		VirtualPath: service.Path,
+	}
+
+	// 給文件系統分配鉤子，物理文件由其他文件共用，失敗時不可刪除
+	fs.Use("BeforeUpload", filesystem.HookValidateFile)
+	fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+	fs.Use("AfterUpload", filesystem.GenericAfterUpload)
+	fs.Use("AfterUpload", filesystem.HookIndexContent)
+	fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+
+	ctx = context.WithValue(ctx, fsctx.ValidateCapacityOnceCtx, &sync.Once{})
+	ctx = context.WithValue(ctx, fsctx.DisableOverwrite, true)
+	if err := fs.InstantUpload(ctx, origin, fileData); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	return serializer.Response{Data: true}
+}
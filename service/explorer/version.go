@@ -0,0 +1,132 @@
+package explorer
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// FileVersionService 文件歷史版本相關服務
+type FileVersionService struct {
+	Version string `uri:"version" binding:"required"`
+}
+
+// ListVersions 列出文件的歷史版本
+func (service *FileIDService) ListVersions(c *gin.Context, user *model.User) serializer.Response {
+	fileID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{fileID.(uint)}, user.ID)
+	if err != nil || len(files) == 0 {
+		return serializer.Err(serializer.CodeNotFound, "文件不存在", err)
+	}
+
+	versions, err := model.GetVersionsByFileID(files[0].ID)
+	if err != nil {
+		return serializer.DBErr("無法列出歷史版本", err)
+	}
+
+	return serializer.BuildVersionListResponse(versions)
+}
+
+// findVersion 尋找使用者的文件及給定的歷史版本
+func (service *FileVersionService) findVersion(c *gin.Context, uid uint) (*model.File, *model.FileVersion, serializer.Response) {
+	fileID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{fileID.(uint)}, uid)
+	if err != nil || len(files) == 0 {
+		return nil, nil, serializer.Err(serializer.CodeNotFound, "文件不存在", err)
+	}
+
+	versionID, err := hashid.DecodeHashID(service.Version, hashid.VersionID)
+	if err != nil {
+		return nil, nil, serializer.Err(serializer.CodeNotFound, "歷史版本不存在", err)
+	}
+
+	version, err := model.GetVersionByID(versionID, files[0].ID)
+	if err != nil {
+		return nil, nil, serializer.Err(serializer.CodeNotFound, "歷史版本不存在", err)
+	}
+
+	return &files[0], version, serializer.Response{}
+}
+
+// versionContext 將歷史版本構造的文件物件放入上下文
+func (service *FileVersionService) versionContext(ctx context.Context, c *gin.Context) (context.Context, serializer.Response) {
+	userCtx, _ := c.Get("user")
+	user := userCtx.(*model.User)
+
+	file, version, res := service.findVersion(c, user.ID)
+	if res.Code != 0 {
+		return nil, res
+	}
+
+	versionFile := version.AsFile(file)
+	return context.WithValue(ctx, fsctx.FileModelCtx, &versionFile), res
+}
+
+// Preview 預覽歷史版本，isText - 是否為文字文件
+func (service *FileVersionService) Preview(ctx context.Context, c *gin.Context, isText bool) serializer.Response {
+	ctx, res := service.versionContext(ctx, c)
+	if res.Code != 0 {
+		return res
+	}
+
+	fileService := FileIDService{}
+	return fileService.PreviewContent(ctx, c, isText)
+}
+
+// CreateDownloadSession 建立歷史版本的下載工作階段
+func (service *FileVersionService) CreateDownloadSession(ctx context.Context, c *gin.Context) serializer.Response {
+	ctx, res := service.versionContext(ctx, c)
+	if res.Code != 0 {
+		return res
+	}
+
+	fileService := FileIDService{}
+	return fileService.CreateDownloadSession(ctx, c)
+}
+
+// Restore 將文件還原為歷史版本
+func (service *FileVersionService) Restore(ctx context.Context, c *gin.Context) serializer.Response {
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	file, version, res := service.findVersion(c, fs.User.ID)
+	if res.Code != 0 {
+		return res
+	}
+
+	if err := fs.RestoreVersion(ctx, file, version); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// Delete 刪除歷史版本
+func (service *FileVersionService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
+	// 建立文件系統
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	_, version, res := service.findVersion(c, fs.User.ID)
+	if res.Code != 0 {
+		return res
+	}
+
+	if err := fs.DeleteVersions(ctx, []model.FileVersion{*version}); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
@@ -35,9 +35,20 @@ type Service struct {
 
 // ArchiveService 分享歸檔下載服務
 type ArchiveService struct {
-	Path  string   `json:"path" binding:"required,max=65535"`
-	Items []string `json:"items"`
-	Dirs  []string `json:"dirs"`
+	Path   string   `json:"path" binding:"required,max=65535"`
+	Items  []string `json:"items"`
+	Dirs   []string `json:"dirs"`
+	Format string   `json:"format" binding:"omitempty,eq=zip|eq=tar|eq=tar.gz|eq=tar.bz2|eq=tar.xz"`
+	Stream bool     `json:"stream"`
+	Store  bool     `json:"store"`
+}
+
+// ArchiveEntryService 瀏覽分享的壓縮文件服務，
+// path 為目錄分享下壓縮文件的完整路徑，name 為要下載的條目路徑
+type ArchiveEntryService struct {
+	Path     string `form:"path" binding:"max=65535"`
+	Name     string `form:"name" binding:"max=65535"`
+	Encoding string `form:"encoding" binding:"omitempty,eq=auto|eq=utf-8|eq=gb18030|eq=big5|eq=shift_jis|eq=euc-kr|eq=cp437"`
 }
 
 // ShareListService 列出分享
@@ -362,10 +373,41 @@ func (service *ArchiveService) Archive(c *gin.Context) serializer.Response {
 	tempUser.Group.OptionsSerialized.ArchiveDownload = true
 	c.Set("user", tempUser)
 
-	subService := explorer.ItemIDService{
-		Dirs:  service.Dirs,
-		Items: service.Items,
+	subService := explorer.ItemArchiveService{
+		ItemIDService: explorer.ItemIDService{
+			Dirs:  service.Dirs,
+			Items: service.Items,
+		},
+		Format: service.Format,
+		Stream: service.Stream,
+		Store:  service.Store,
 	}
 
 	return subService.Archive(ctx, c)
 }
+
+// List 列出分享的壓縮文件的目錄結構
+func (service *ArchiveEntryService) List(ctx context.Context, c *gin.Context) serializer.Response {
+	subService := explorer.ArchiveEntryService{Encoding: service.Encoding}
+	return subService.List(service.context(ctx, c), c)
+}
+
+// Download 下載分享的壓縮文件中的單個文件
+func (service *ArchiveEntryService) Download(ctx context.Context, c *gin.Context) serializer.Response {
+	subService := explorer.ArchiveEntryService{Name: service.Name, Encoding: service.Encoding}
+	return subService.Download(service.context(ctx, c), c)
+}
+
+// context 建立用於調下層service的上下文
+func (service *ArchiveEntryService) context(ctx context.Context, c *gin.Context) context.Context {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	if share.IsDir {
+		ctx = context.WithValue(ctx, fsctx.FolderModelCtx, share.Source())
+		ctx = context.WithValue(ctx, fsctx.PathCtx, service.Path)
+	} else {
+		ctx = context.WithValue(ctx, fsctx.FileModelCtx, share.Source())
+	}
+	return ctx
+}